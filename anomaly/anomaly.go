@@ -0,0 +1,323 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// DefaultAssetType is the key of thresholds used for asset types without their own
+const DefaultAssetType = "*"
+
+// minReturns is the number of trailing returns needed to estimate volatility
+const minReturns = 20
+
+// Thresholds configure when a quote is considered anomalous. They are stored in
+// the library's anomaly_thresholds table keyed by asset type.
+type Thresholds struct {
+	AssetType       string
+	MaxReturnZScore float64
+	MaxVolumeRatio  float64
+	LookbackDays    int
+}
+
+// DefaultThresholds are used if the library has no thresholds configured
+var DefaultThresholds = Thresholds{
+	AssetType:       DefaultAssetType,
+	MaxReturnZScore: 6.0,
+	MaxVolumeRatio:  10.0,
+	LookbackDays:    90,
+}
+
+// Point is a single historical quote used to evaluate new quotes
+type Point struct {
+	Date   time.Time
+	Close  float64
+	Volume float64
+	Split  float64
+}
+
+// Check returns the reasons quote is anomalous compared with history, which
+// must be sorted by date and contain only quotes before the quote's date
+func Check(quote *data.Eod, history []*Point, thresholds *Thresholds) []string {
+	reasons := make([]string, 0)
+
+	if quote.Close <= 0 || quote.Open < 0 || quote.High < 0 || quote.Low < 0 {
+		reasons = append(reasons, "non-positive price")
+	}
+
+	if len(history) == 0 || quote.Close <= 0 {
+		return reasons
+	}
+
+	prev := history[len(history)-1]
+
+	// return volatility
+	returns := make([]float64, 0, len(history))
+	for idx := 1; idx < len(history); idx++ {
+		if history[idx-1].Close > 0 {
+			returns = append(returns, splitFactor(history[idx].Split)*history[idx].Close/history[idx-1].Close-1)
+		}
+	}
+
+	if len(returns) >= minReturns && prev.Close > 0 {
+		mean, stdev := meanStdev(returns)
+		ret := splitFactor(quote.Split)*quote.Close/prev.Close - 1
+		if stdev > 0 {
+			zscore := (ret - mean) / stdev
+			if math.Abs(zscore) > thresholds.MaxReturnZScore {
+				reasons = append(reasons, fmt.Sprintf("return of %.2f%% is %.1f standard deviations from the mean", ret*100, zscore))
+			}
+		}
+	}
+
+	// volume spikes
+	volumes := make([]float64, 0, len(history))
+	for _, point := range history {
+		if point.Volume > 0 {
+			volumes = append(volumes, point.Volume)
+		}
+	}
+
+	if len(volumes) >= minReturns {
+		avgVolume, _ := meanStdev(volumes)
+		if avgVolume > 0 && quote.Volume > thresholds.MaxVolumeRatio*avgVolume {
+			reasons = append(reasons, fmt.Sprintf("volume is %.1f times the average", quote.Volume/avgVolume))
+		}
+	}
+
+	return reasons
+}
+
+// Screener checks incoming EOD quotes against each asset's trailing history
+type Screener struct {
+	library    *library.Library
+	thresholds map[string]*Thresholds
+	assetTypes map[string]string
+
+	mu      sync.Mutex
+	history map[string][]*Point
+}
+
+// NewScreener loads thresholds and asset types from the library
+func NewScreener(ctx context.Context, myLibrary *library.Library) (*Screener, error) {
+	screener := &Screener{
+		library:    myLibrary,
+		thresholds: make(map[string]*Thresholds),
+		assetTypes: make(map[string]string),
+		history:    make(map[string][]*Point),
+	}
+
+	thresholds, err := LoadThresholds(ctx, myLibrary)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, threshold := range thresholds {
+		screener.thresholds[threshold.AssetType] = threshold
+	}
+
+	if assetTable := viper.GetString("default.asset_table"); assetTable != "" {
+		rows, err := myLibrary.Pool.Query(ctx, fmt.Sprintf(`SELECT composite_figi, asset_type::text FROM %s`, pgx.Identifier{assetTable}.Sanitize()))
+		if err != nil {
+			return nil, err
+		}
+
+		var compositeFigi, assetType string
+		if _, err := pgx.ForEachRow(rows, []any{&compositeFigi, &assetType}, func() error {
+			screener.assetTypes[compositeFigi] = assetType
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	} else {
+		log.Warn().Msg("default.asset_table not set; anomaly screening uses default thresholds for all assets")
+	}
+
+	return screener, nil
+}
+
+// Screen returns the reasons obs should be quarantined. Only EOD quotes are screened.
+func (screener *Screener) Screen(ctx context.Context, subscription *library.Subscription, obs *data.Observation) []string {
+	quote := obs.EodQuote
+	if quote == nil {
+		return nil
+	}
+
+	thresholds := screener.thresholdsFor(quote.CompositeFigi)
+	tbl := subscription.DataTablesMap[data.EODKey]
+
+	screener.mu.Lock()
+	defer screener.mu.Unlock()
+
+	history, err := screener.assetHistory(ctx, tbl, quote.CompositeFigi, thresholds.LookbackDays)
+	if err != nil {
+		log.Warn().Err(err).Str("CompositeFigi", quote.CompositeFigi).Msg("could not load quote history; skipping anomaly screening")
+		return nil
+	}
+
+	// only compare against quotes before this one within the lookback window
+	start := quote.Date.AddDate(0, 0, -thresholds.LookbackDays)
+	lo := sort.Search(len(history), func(i int) bool { return !history[i].Date.Before(start) })
+	hi := sort.Search(len(history), func(i int) bool { return !history[i].Date.Before(truncateDay(quote.Date)) })
+
+	reasons := Check(quote, history[lo:hi], thresholds)
+	if len(reasons) == 0 {
+		screener.remember(tbl, quote)
+	}
+
+	return reasons
+}
+
+func (screener *Screener) thresholdsFor(compositeFigi string) *Thresholds {
+	if thresholds, ok := screener.thresholds[screener.assetTypes[compositeFigi]]; ok {
+		return thresholds
+	}
+
+	if thresholds, ok := screener.thresholds[DefaultAssetType]; ok {
+		return thresholds
+	}
+
+	return &DefaultThresholds
+}
+
+// assetHistory returns cached quotes for the asset, loading them on first use
+func (screener *Screener) assetHistory(ctx context.Context, tbl, compositeFigi string, lookbackDays int) ([]*Point, error) {
+	key := tbl + "/" + compositeFigi
+	if history, ok := screener.history[key]; ok {
+		return history, nil
+	}
+
+	// quotes may be re-imported for several weeks so load extra history
+	since := time.Now().AddDate(0, 0, -lookbackDays-45)
+	rows, err := screener.library.Pool.Query(ctx, fmt.Sprintf(`SELECT event_date::timestamp, close::float8,
+volume::float8, split_factor::float8 FROM %s WHERE composite_figi = $1 AND event_date >= $2 ORDER BY event_date`,
+		pgx.Identifier{tbl}.Sanitize()), compositeFigi, since)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]*Point, 0, lookbackDays)
+	point := Point{}
+	if _, err := pgx.ForEachRow(rows, []any{&point.Date, &point.Close, &point.Volume, &point.Split}, func() error {
+		saved := point
+		history = append(history, &saved)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	screener.history[key] = history
+	return history, nil
+}
+
+// remember adds an accepted quote to the cached history
+func (screener *Screener) remember(tbl string, quote *data.Eod) {
+	key := tbl + "/" + quote.CompositeFigi
+	history := screener.history[key]
+
+	point := &Point{
+		Date:   truncateDay(quote.Date),
+		Close:  quote.Close,
+		Volume: quote.Volume,
+		Split:  quote.Split,
+	}
+
+	idx := sort.Search(len(history), func(i int) bool { return !history[i].Date.Before(point.Date) })
+	if idx < len(history) && history[idx].Date.Equal(point.Date) {
+		history[idx] = point
+		return
+	}
+
+	history = append(history, nil)
+	copy(history[idx+1:], history[idx:])
+	history[idx] = point
+	screener.history[key] = history
+}
+
+// LoadThresholds returns the thresholds configured in the library
+func LoadThresholds(ctx context.Context, myLibrary *library.Library) ([]*Thresholds, error) {
+	rows, err := myLibrary.Pool.Query(ctx, `SELECT asset_type, max_return_zscore::float8, max_volume_ratio::float8,
+lookback_days FROM anomaly_thresholds ORDER BY asset_type`)
+	if err != nil {
+		return nil, err
+	}
+
+	thresholds := make([]*Thresholds, 0)
+	threshold := Thresholds{}
+	_, err = pgx.ForEachRow(rows, []any{&threshold.AssetType, &threshold.MaxReturnZScore, &threshold.MaxVolumeRatio, &threshold.LookbackDays}, func() error {
+		saved := threshold
+		thresholds = append(thresholds, &saved)
+		return nil
+	})
+
+	return thresholds, err
+}
+
+// SaveThresholds stores thresholds for an asset type in the library
+func SaveThresholds(ctx context.Context, myLibrary *library.Library, thresholds *Thresholds) error {
+	_, err := myLibrary.Pool.Exec(ctx, `INSERT INTO anomaly_thresholds ("asset_type", "max_return_zscore",
+"max_volume_ratio", "lookback_days") VALUES ($1, $2, $3, $4) ON CONFLICT ON CONSTRAINT anomaly_thresholds_pkey
+DO UPDATE SET max_return_zscore = EXCLUDED.max_return_zscore, max_volume_ratio = EXCLUDED.max_volume_ratio,
+lookback_days = EXCLUDED.lookback_days`, thresholds.AssetType, thresholds.MaxReturnZScore, thresholds.MaxVolumeRatio,
+		thresholds.LookbackDays)
+	return err
+}
+
+func meanStdev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+
+	sum := 0.0
+	for _, val := range values {
+		sum += val
+	}
+	mean := sum / float64(len(values))
+
+	variance := 0.0
+	for _, val := range values {
+		variance += (val - mean) * (val - mean)
+	}
+
+	if len(values) > 1 {
+		variance /= float64(len(values) - 1)
+	}
+
+	return mean, math.Sqrt(variance)
+}
+
+// splitFactor treats a missing split factor as no split
+func splitFactor(split float64) float64 {
+	if split == 0 {
+		return 1
+	}
+	return split
+}
+
+func truncateDay(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+}
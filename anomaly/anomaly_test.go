@@ -0,0 +1,78 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package anomaly_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/penny-vault/pvdata/anomaly"
+	"github.com/penny-vault/pvdata/data"
+)
+
+var _ = Describe("Check", func() {
+	var (
+		history    []*anomaly.Point
+		thresholds anomaly.Thresholds
+	)
+
+	BeforeEach(func() {
+		thresholds = anomaly.DefaultThresholds
+
+		// alternate +1% and -1% days with steady volume
+		history = make([]*anomaly.Point, 0, 60)
+		price := 100.0
+		start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		for idx := 0; idx < 60; idx++ {
+			if idx%2 == 0 {
+				price *= 1.01
+			} else {
+				price *= 0.99
+			}
+			history = append(history, &anomaly.Point{Date: start.AddDate(0, 0, idx), Close: price, Volume: 1e6, Split: 1})
+		}
+	})
+
+	It("accepts an ordinary quote", func() {
+		last := history[len(history)-1].Close
+		quote := &data.Eod{Open: last, High: last * 1.01, Low: last * 0.99, Close: last * 1.01, Volume: 1.2e6, Split: 1}
+		Expect(anomaly.Check(quote, history, &thresholds)).To(BeEmpty())
+	})
+
+	It("flags non-positive prices", func() {
+		quote := &data.Eod{Close: 0, Split: 1}
+		Expect(anomaly.Check(quote, history, &thresholds)).To(ConsistOf("non-positive price"))
+	})
+
+	It("flags returns outside trailing volatility", func() {
+		last := history[len(history)-1].Close
+		quote := &data.Eod{Open: last, High: last * 2, Low: last, Close: last * 1.5, Volume: 1e6, Split: 1}
+		Expect(anomaly.Check(quote, history, &thresholds)).To(HaveLen(1))
+	})
+
+	It("does not flag a split as a large return", func() {
+		last := history[len(history)-1].Close
+		quote := &data.Eod{Open: last / 2, High: last / 2, Low: last / 2, Close: last / 2, Volume: 1e6, Split: 2}
+		Expect(anomaly.Check(quote, history, &thresholds)).To(BeEmpty())
+	})
+
+	It("flags volume spikes", func() {
+		last := history[len(history)-1].Close
+		quote := &data.Eod{Open: last, High: last, Low: last, Close: last, Volume: 5e7, Split: 1}
+		Expect(anomaly.Check(quote, history, &thresholds)).To(HaveLen(1))
+	})
+})
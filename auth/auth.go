@@ -0,0 +1,166 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package auth authenticates clients of the pvdata API server modes. Clients
+// present a static API token, a TLS client certificate, or an OIDC bearer
+// token; each identifies a Principal whose scopes decide what it may do.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrNoCredentials      = errors.New("request has no credentials")
+	ErrInvalidCredentials = errors.New("credentials are not valid")
+	ErrForbidden          = errors.New("credentials do not grant the required scope")
+	ErrUnknownScope       = errors.New("unknown scope")
+)
+
+// Scope is a permission granted to a principal
+type Scope string
+
+const (
+	// ScopeRead allows querying data in the library
+	ScopeRead Scope = "read"
+
+	// ScopeAdmin allows changing the library, e.g. subscriptions and runs;
+	// admin implies read
+	ScopeAdmin Scope = "admin"
+)
+
+// ParseScopes converts scope names, e.g. from the config file, into scopes
+func ParseScopes(names []string) ([]Scope, error) {
+	scopes := make([]Scope, 0, len(names))
+	for _, name := range names {
+		scope := Scope(strings.ToLower(strings.TrimSpace(name)))
+		switch scope {
+		case ScopeRead, ScopeAdmin:
+			scopes = append(scopes, scope)
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnknownScope, name)
+		}
+	}
+
+	return scopes, nil
+}
+
+// Principal is an authenticated client
+type Principal struct {
+	// Name identifies the client in logs, e.g. the token name or certificate subject
+	Name string
+
+	// Method is the name of the authenticator that identified the client
+	Method string
+
+	Scopes []Scope
+}
+
+// Allows returns true if the principal was granted scope
+func (principal *Principal) Allows(scope Scope) bool {
+	if principal == nil {
+		return false
+	}
+
+	return slices.Contains(principal.Scopes, scope) ||
+		(scope == ScopeRead && slices.Contains(principal.Scopes, ScopeAdmin))
+}
+
+// Authenticator identifies the client that sent a request. ErrNoCredentials is
+// returned if the request does not carry the kind of credentials the
+// authenticator checks so that the next authenticator may be tried.
+type Authenticator interface {
+	Name() string
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext returns the principal stored in ctx by Middleware
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
+
+// Authenticate tries each authenticator in order and returns the first
+// principal found. Several authenticators may accept the same kind of
+// credentials, e.g. bearer tokens, so a rejection only fails the request if no
+// other authenticator accepts it. ErrNoCredentials is returned if no
+// authenticator found credentials in the request.
+func Authenticate(r *http.Request, authenticators []Authenticator) (*Principal, error) {
+	rejected := ErrNoCredentials
+	for _, authenticator := range authenticators {
+		principal, err := authenticator.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+
+		if err != nil {
+			if errors.Is(rejected, ErrNoCredentials) {
+				rejected = fmt.Errorf("%s: %w", authenticator.Name(), err)
+			}
+			continue
+		}
+
+		principal.Method = authenticator.Name()
+		return principal, nil
+	}
+
+	return nil, rejected
+}
+
+// Middleware rejects requests that are not authenticated by one of
+// authenticators or whose principal was not granted scope. The principal is
+// stored in the request context of handlers that are called.
+func Middleware(authenticators []Authenticator, scope Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, err := Authenticate(r, authenticators)
+			if err != nil {
+				log.Warn().Err(err).Str("RemoteAddr", r.RemoteAddr).Str("Path", r.URL.Path).Msg("rejected unauthenticated request")
+				w.Header().Set("WWW-Authenticate", `Bearer realm="pvdata"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			if !principal.Allows(scope) {
+				log.Warn().Err(ErrForbidden).Str("Principal", principal.Name).Str("Scope", string(scope)).
+					Str("Path", r.URL.Path).Msg("rejected request")
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+		})
+	}
+}
+
+// bearerToken returns the token in the request's Authorization header
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
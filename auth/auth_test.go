@@ -0,0 +1,91 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package auth_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/penny-vault/pvdata/auth"
+)
+
+var _ = Describe("Middleware", func() {
+	var authenticators []auth.Authenticator
+
+	BeforeEach(func() {
+		adminHash := sha256.Sum256([]byte("admin-secret"))
+		tokens, err := auth.NewStaticTokens([]auth.TokenSpec{
+			{Name: "reader", Token: "read-secret", Scopes: []string{"read"}},
+			{Name: "admin", SHA256: hex.EncodeToString(adminHash[:]), Scopes: []string{"admin"}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		authenticators = []auth.Authenticator{tokens}
+	})
+
+	serve := func(scope auth.Scope, token string) (int, string) {
+		var name string
+		handler := auth.Middleware(authenticators, scope)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+				name = principal.Name
+			}
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/assets", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code, name
+	}
+
+	It("rejects requests without a token", func() {
+		code, _ := serve(auth.ScopeRead, "")
+		Expect(code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("rejects unknown tokens", func() {
+		code, _ := serve(auth.ScopeRead, "not-a-token")
+		Expect(code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("rejects tokens without the required scope", func() {
+		code, _ := serve(auth.ScopeAdmin, "read-secret")
+		Expect(code).To(Equal(http.StatusForbidden))
+	})
+
+	It("lets admin tokens read", func() {
+		code, name := serve(auth.ScopeRead, "admin-secret")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(name).To(Equal("admin"))
+	})
+})
+
+var _ = Describe("NewStaticTokens", func() {
+	It("rejects unknown scopes", func() {
+		_, err := auth.NewStaticTokens([]auth.TokenSpec{{Name: "ci", Token: "secret", Scopes: []string{"write"}}})
+		Expect(err).To(MatchError(auth.ErrUnknownScope))
+	})
+
+	It("rejects tokens without scopes", func() {
+		_, err := auth.NewStaticTokens([]auth.TokenSpec{{Name: "ci", Token: "secret"}})
+		Expect(err).To(MatchError(auth.ErrInvalidToken))
+	})
+})
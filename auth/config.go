@@ -0,0 +1,67 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package auth
+
+import (
+	"github.com/spf13/viper"
+)
+
+// FromConfig creates the authenticators configured in the auth section of the
+// config file. Authenticators are tried in the order: client certificates,
+// static tokens, OIDC.
+func FromConfig() ([]Authenticator, error) {
+	authenticators := make([]Authenticator, 0, 3)
+
+	var clients []ClientSpec
+	if err := viper.UnmarshalKey("auth.mtls.clients", &clients); err != nil {
+		return nil, err
+	}
+
+	if len(clients) > 0 {
+		authenticator, err := NewClientCertificates(clients)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, authenticator)
+	}
+
+	var tokens []TokenSpec
+	if err := viper.UnmarshalKey("auth.tokens", &tokens); err != nil {
+		return nil, err
+	}
+
+	if len(tokens) > 0 {
+		authenticator, err := NewStaticTokens(tokens)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, authenticator)
+	}
+
+	if viper.GetString("auth.oidc.issuer") != "" {
+		var config OIDCConfig
+		if err := viper.UnmarshalKey("auth.oidc", &config); err != nil {
+			return nil, err
+		}
+
+		authenticator, err := NewOIDC(config)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, authenticator)
+	}
+
+	return authenticators, nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+var (
+	ErrInvalidClientCA   = errors.New("client CA file does not contain any certificates")
+	ErrInvalidClientSpec = errors.New("client certificate must have a subject and scopes")
+)
+
+// ClientSpec grants scopes to the client certificates with a matching subject
+// in the config file. The subject is compared with the certificate's common
+// name and its DNS, email, and URI subject alternative names.
+type ClientSpec struct {
+	Subject string   `mapstructure:"subject"`
+	Scopes  []string `mapstructure:"scopes"`
+}
+
+// ClientCertificates authenticates requests by the TLS client certificate
+// they were sent with. The certificate chain is verified by the server's TLS
+// config, see ServerTLSConfig; requests without a verified certificate have no
+// credentials.
+type ClientCertificates struct {
+	subjects map[string][]Scope
+}
+
+// NewClientCertificates validates specs and returns an authenticator for them
+func NewClientCertificates(specs []ClientSpec) (*ClientCertificates, error) {
+	authenticator := &ClientCertificates{
+		subjects: make(map[string][]Scope, len(specs)),
+	}
+
+	for _, spec := range specs {
+		if spec.Subject == "" || len(spec.Scopes) == 0 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidClientSpec, spec.Subject)
+		}
+
+		scopes, err := ParseScopes(spec.Scopes)
+		if err != nil {
+			return nil, fmt.Errorf("client %q: %w", spec.Subject, err)
+		}
+
+		authenticator.subjects[spec.Subject] = scopes
+	}
+
+	return authenticator, nil
+}
+
+func (authenticator *ClientCertificates) Name() string {
+	return "mtls"
+}
+
+// Authenticate looks up the subject of the verified client certificate
+func (authenticator *ClientCertificates) Authenticate(r *http.Request) (*Principal, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	cert := r.TLS.VerifiedChains[0][0]
+	for _, subject := range certificateSubjects(cert) {
+		if scopes, ok := authenticator.subjects[subject]; ok {
+			return &Principal{
+				Name:   subject,
+				Scopes: scopes,
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: certificate %q is not a known client", ErrInvalidCredentials, cert.Subject.CommonName)
+}
+
+// certificateSubjects lists the names a certificate was issued to
+func certificateSubjects(cert *x509.Certificate) []string {
+	subjects := make([]string, 0, 1+len(cert.DNSNames)+len(cert.EmailAddresses)+len(cert.URIs))
+	if cert.Subject.CommonName != "" {
+		subjects = append(subjects, cert.Subject.CommonName)
+	}
+
+	subjects = append(subjects, cert.DNSNames...)
+	subjects = append(subjects, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		subjects = append(subjects, uri.String())
+	}
+
+	return subjects
+}
+
+// ServerTLSConfig returns the TLS config of a server that verifies client
+// certificates against the CAs in caFile. Clients without a certificate may
+// still connect so that they can authenticate with a token.
+func ServerTLSConfig(caFile string) (*tls.Config, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidClientCA, caFile)
+	}
+
+	return &tls.Config{
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  pool,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
@@ -0,0 +1,251 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	// oidcKeyRefresh is the minimum time between fetches of the issuer's
+	// signing keys; keys are re-fetched when a token is signed by an unknown key
+	oidcKeyRefresh = time.Minute
+
+	// oidcLeeway is the clock skew tolerated when validating token times
+	oidcLeeway = 30 * time.Second
+)
+
+var (
+	ErrInvalidOIDCConfig = errors.New("oidc requires an issuer and an audience")
+	ErrUnknownSigningKey = errors.New("token is not signed by a key of the issuer")
+)
+
+// oidcAlgorithms are the signature algorithms accepted in bearer tokens;
+// symmetric algorithms and "none" are never accepted
+var oidcAlgorithms = []string{
+	string(jose.RS256), string(jose.RS384), string(jose.RS512),
+	string(jose.PS256), string(jose.PS384), string(jose.PS512),
+	string(jose.ES256), string(jose.ES384), string(jose.ES512),
+	string(jose.EdDSA),
+}
+
+// OIDCConfig configures validation of bearer tokens issued by an OpenID
+// Connect provider
+type OIDCConfig struct {
+	// Issuer is the issuer URL; its discovery document lists the signing keys
+	Issuer string `mapstructure:"issuer"`
+
+	// Audience must be in the token's aud claim
+	Audience string `mapstructure:"audience"`
+
+	// ReadScope and AdminScope are the scopes in the token's scope or scp
+	// claim that grant read and admin access; defaults to pvdata:read and
+	// pvdata:admin
+	ReadScope  string `mapstructure:"read_scope"`
+	AdminScope string `mapstructure:"admin_scope"`
+}
+
+// OIDC authenticates requests with a bearer token signed by an OpenID Connect
+// provider
+type OIDC struct {
+	config OIDCConfig
+	client *resty.Client
+
+	mu      sync.Mutex
+	keys    *jose.JSONWebKeySet
+	fetched time.Time
+}
+
+// oidcClaims are the claims read from bearer tokens besides the registered claims
+type oidcClaims struct {
+	Scope json.RawMessage `json:"scope"`
+	Scp   json.RawMessage `json:"scp"`
+}
+
+// NewOIDC validates config and returns an authenticator for it. Signing keys
+// are fetched when the first token is validated.
+func NewOIDC(config OIDCConfig) (*OIDC, error) {
+	if config.Issuer == "" || config.Audience == "" {
+		return nil, ErrInvalidOIDCConfig
+	}
+
+	if config.ReadScope == "" {
+		config.ReadScope = "pvdata:read"
+	}
+
+	if config.AdminScope == "" {
+		config.AdminScope = "pvdata:admin"
+	}
+
+	return &OIDC{
+		config: config,
+		client: resty.New().SetTimeout(10 * time.Second),
+	}, nil
+}
+
+func (authenticator *OIDC) Name() string {
+	return "oidc"
+}
+
+// Authenticate validates the signature, issuer, audience, and lifetime of the
+// bearer token and maps its scopes to pvdata scopes
+func (authenticator *OIDC) Authenticate(r *http.Request) (*Principal, error) {
+	bearer, ok := bearerToken(r)
+	if !ok || strings.Count(bearer, ".") != 2 {
+		return nil, ErrNoCredentials
+	}
+
+	token, err := jwt.ParseSigned(bearer)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+
+	if len(token.Headers) != 1 || !slices.Contains(oidcAlgorithms, token.Headers[0].Algorithm) {
+		return nil, fmt.Errorf("%w: unsupported signature algorithm", ErrInvalidCredentials)
+	}
+
+	key, err := authenticator.signingKey(r.Context(), token.Headers[0].KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var registered jwt.Claims
+	var claims oidcClaims
+	if err := token.Claims(key, &registered, &claims); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+
+	if err := registered.ValidateWithLeeway(jwt.Expected{
+		Issuer:   authenticator.config.Issuer,
+		Audience: jwt.Audience{authenticator.config.Audience},
+		Time:     time.Now(),
+	}, oidcLeeway); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+
+	principal := &Principal{
+		Name:   registered.Subject,
+		Scopes: make([]Scope, 0, 2),
+	}
+
+	granted := append(claimScopes(claims.Scope), claimScopes(claims.Scp)...)
+	if slices.Contains(granted, authenticator.config.ReadScope) {
+		principal.Scopes = append(principal.Scopes, ScopeRead)
+	}
+
+	if slices.Contains(granted, authenticator.config.AdminScope) {
+		principal.Scopes = append(principal.Scopes, ScopeAdmin)
+	}
+
+	return principal, nil
+}
+
+// claimScopes reads a scope claim, which is either a space separated string
+// or a list of strings
+func claimScopes(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var scopes []string
+	if err := json.Unmarshal(raw, &scopes); err == nil {
+		return scopes
+	}
+
+	var scope string
+	if err := json.Unmarshal(raw, &scope); err == nil {
+		return strings.Fields(scope)
+	}
+
+	return nil
+}
+
+// signingKey returns the issuer's public key with the given id. The key set is
+// fetched on first use and again when a token names an unknown key, but no
+// more often than oidcKeyRefresh.
+func (authenticator *OIDC) signingKey(ctx context.Context, kid string) (*jose.JSONWebKey, error) {
+	authenticator.mu.Lock()
+	defer authenticator.mu.Unlock()
+
+	if authenticator.keys != nil {
+		if keys := authenticator.keys.Key(kid); len(keys) > 0 {
+			return &keys[0], nil
+		}
+
+		if time.Since(authenticator.fetched) < oidcKeyRefresh {
+			return nil, ErrUnknownSigningKey
+		}
+	}
+
+	keys, err := authenticator.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	authenticator.keys = keys
+	authenticator.fetched = time.Now()
+
+	if keys := keys.Key(kid); len(keys) > 0 {
+		return &keys[0], nil
+	}
+
+	return nil, ErrUnknownSigningKey
+}
+
+// fetchKeys downloads the issuer's key set listed in its discovery document
+func (authenticator *OIDC) fetchKeys(ctx context.Context) (*jose.JSONWebKeySet, error) {
+	discovery := struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}{}
+
+	resp, err := authenticator.client.R().SetContext(ctx).SetResult(&discovery).
+		Get(strings.TrimSuffix(authenticator.config.Issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() >= 300 {
+		return nil, fmt.Errorf("oidc discovery returned %s", resp.Status())
+	}
+
+	if discovery.Issuer != authenticator.config.Issuer || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: discovery document of %s does not match", ErrInvalidOIDCConfig, authenticator.config.Issuer)
+	}
+
+	keys := &jose.JSONWebKeySet{}
+	resp, err = authenticator.client.R().SetContext(ctx).SetResult(keys).Get(discovery.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() >= 300 {
+		return nil, fmt.Errorf("oidc key set returned %s", resp.Status())
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrInvalidToken = errors.New("api token must have a name, a token or sha256 hash, and scopes")
+)
+
+// TokenSpec is an API token in the config file. The token may be given in
+// plain text or as the hex encoded sha256 hash of the token so that the
+// config file does not contain the secret.
+type TokenSpec struct {
+	Name   string   `mapstructure:"name"`
+	Token  string   `mapstructure:"token"`
+	SHA256 string   `mapstructure:"sha256"`
+	Scopes []string `mapstructure:"scopes"`
+}
+
+type staticToken struct {
+	hash   [sha256.Size]byte
+	name   string
+	scopes []Scope
+}
+
+// StaticTokens authenticates requests that carry one of a fixed set of API
+// tokens as a bearer token
+type StaticTokens struct {
+	tokens []*staticToken
+}
+
+// NewStaticTokens validates specs and returns an authenticator for them
+func NewStaticTokens(specs []TokenSpec) (*StaticTokens, error) {
+	authenticator := &StaticTokens{
+		tokens: make([]*staticToken, 0, len(specs)),
+	}
+
+	for _, spec := range specs {
+		if spec.Name == "" || (spec.Token == "" && spec.SHA256 == "") || len(spec.Scopes) == 0 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidToken, spec.Name)
+		}
+
+		scopes, err := ParseScopes(spec.Scopes)
+		if err != nil {
+			return nil, fmt.Errorf("token %q: %w", spec.Name, err)
+		}
+
+		token := &staticToken{
+			name:   spec.Name,
+			scopes: scopes,
+		}
+
+		if spec.Token != "" {
+			token.hash = sha256.Sum256([]byte(spec.Token))
+		} else {
+			hash, err := hex.DecodeString(strings.TrimSpace(spec.SHA256))
+			if err != nil || len(hash) != sha256.Size {
+				return nil, fmt.Errorf("%w: %q has a malformed sha256 hash", ErrInvalidToken, spec.Name)
+			}
+			copy(token.hash[:], hash)
+		}
+
+		authenticator.tokens = append(authenticator.tokens, token)
+	}
+
+	return authenticator, nil
+}
+
+func (authenticator *StaticTokens) Name() string {
+	return "token"
+}
+
+// Authenticate compares the hash of the bearer token with every configured
+// token in constant time
+func (authenticator *StaticTokens) Authenticate(r *http.Request) (*Principal, error) {
+	bearer, ok := bearerToken(r)
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	hash := sha256.Sum256([]byte(bearer))
+
+	var match *staticToken
+	for _, token := range authenticator.tokens {
+		if subtle.ConstantTimeCompare(hash[:], token.hash[:]) == 1 {
+			match = token
+		}
+	}
+
+	if match == nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &Principal{
+		Name:   match.name,
+		Scopes: match.scopes,
+	}, nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/penny-vault/pvdata/data"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var (
+	ErrUnknownFormat    = errors.New("unknown serialization format; expected json or protobuf-struct")
+	ErrUnknownTransport = errors.New("unknown message bus; expected nats or kafka")
+)
+
+const (
+	FormatJSON = "json"
+
+	// FormatStruct encodes observations as a google.protobuf.Struct; there is
+	// no message schema per data type
+	FormatStruct = "protobuf-struct"
+)
+
+// Publisher sends observations to a message bus for downstream real-time consumers
+type Publisher interface {
+	Publish(ctx context.Context, obs *data.Observation) error
+	Close() error
+}
+
+// Config describes the message bus observations are published to
+type Config struct {
+	// Transport is either nats or kafka
+	Transport string
+
+	// URL is the NATS server url or a comma separated list of Kafka brokers
+	URL string
+
+	// Topic is the Kafka topic or the NATS subject prefix; NATS messages are
+	// published to <Topic>.<data type>
+	Topic string
+
+	// Format is the serialization used for messages: json (default) or protobuf-struct
+	Format string
+}
+
+// New creates a publisher for the configured message bus
+func New(config Config) (Publisher, error) {
+	if config.Format == "" {
+		config.Format = FormatJSON
+	}
+
+	if config.Format != FormatJSON && config.Format != FormatStruct {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, config.Format)
+	}
+
+	if config.Topic == "" {
+		config.Topic = "pvdata"
+	}
+
+	switch strings.ToLower(config.Transport) {
+	case "nats":
+		return newNATSPublisher(config)
+	case "kafka":
+		return newKafkaPublisher(config), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTransport, config.Transport)
+	}
+}
+
+// Encode serializes obs in the requested format. protobuf-struct messages are a
+// google.protobuf.Struct with the same fields as the JSON encoding.
+func Encode(obs *data.Observation, format string) ([]byte, error) {
+	buf, err := json.Marshal(obs)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatJSON, "":
+		return buf, nil
+	case FormatStruct:
+		fields := make(map[string]any)
+		if err := json.Unmarshal(buf, &fields); err != nil {
+			return nil, err
+		}
+
+		msg, err := structpb.NewStruct(fields)
+		if err != nil {
+			return nil, err
+		}
+
+		return proto.Marshal(msg)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+}
+
+func contentType(format string) string {
+	if format == FormatStruct {
+		return "application/protobuf; proto=google.protobuf.Struct"
+	}
+	return "application/json"
+}
@@ -0,0 +1,70 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package bus
+
+import (
+	"context"
+	"strings"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/segmentio/kafka-go"
+)
+
+type kafkaPublisher struct {
+	writer *kafka.Writer
+	format string
+}
+
+func newKafkaPublisher(config Config) *kafkaPublisher {
+	return &kafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(strings.Split(config.URL, ",")...),
+			Topic:                  config.Topic,
+			Balancer:               &kafka.Hash{},
+			AllowAutoTopicCreation: true,
+
+			// each write waits until every in-sync replica has the message so an
+			// observation is only acknowledged once it was delivered; a batch of
+			// one is sent immediately instead of waiting for more messages
+			RequiredAcks: kafka.RequireAll,
+			BatchSize:    1,
+		},
+		format: config.Format,
+	}
+}
+
+// Publish writes obs to the topic keyed by data type so that observations of
+// the same type are delivered in order. It returns once the brokers have
+// acknowledged the message.
+func (publisher *kafkaPublisher) Publish(ctx context.Context, obs *data.Observation) error {
+	buf, err := Encode(obs, publisher.format)
+	if err != nil {
+		return err
+	}
+
+	return publisher.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(obs.DataType()),
+		Value: buf,
+		Headers: []kafka.Header{
+			{Key: "Content-Type", Value: []byte(contentType(publisher.format))},
+			{Key: "Subscription-Id", Value: []byte(obs.SubscriptionID.String())},
+		},
+	})
+}
+
+// Close flushes pending messages and closes the writer
+func (publisher *kafkaPublisher) Close() error {
+	return publisher.writer.Close()
+}
@@ -0,0 +1,62 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package bus
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+	"github.com/penny-vault/pvdata/data"
+)
+
+type natsPublisher struct {
+	conn   *nats.Conn
+	prefix string
+	format string
+}
+
+func newNATSPublisher(config Config) (*natsPublisher, error) {
+	conn, err := nats.Connect(config.URL, nats.Name("pvdata"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &natsPublisher{
+		conn:   conn,
+		prefix: config.Topic,
+		format: config.Format,
+	}, nil
+}
+
+// Publish sends obs to the subject <prefix>.<data type>
+func (publisher *natsPublisher) Publish(ctx context.Context, obs *data.Observation) error {
+	buf, err := Encode(obs, publisher.format)
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(publisher.prefix + "." + obs.DataType())
+	msg.Data = buf
+	msg.Header.Set("Content-Type", contentType(publisher.format))
+	msg.Header.Set("Subscription-Id", obs.SubscriptionID.String())
+
+	return publisher.conn.PublishMsg(msg)
+}
+
+// Close flushes buffered messages and closes the connection
+func (publisher *natsPublisher) Close() error {
+	defer publisher.conn.Close()
+	return publisher.conn.Flush()
+}
@@ -0,0 +1,149 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/penny-vault/pvdata/db"
+	"github.com/penny-vault/pvdata/hook"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/penny-vault/pvdata/provider"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// applyCmd represents the apply command
+var applyCmd = &cobra.Command{
+	Use:   "apply <config file>",
+	Short: "Reconcile the library with a declarative config file",
+	Long: `Apply reads a TOML or YAML config file declaring the library, its database,
+subscriptions, schedules, and sinks and reconciles the library to match:
+declared subscriptions that do not exist are created and existing subscriptions
+are updated. With --prune active subscriptions that are not declared are
+deactivated. Settings in the file (e.g. db.url, sinks, bus) are merged into the
+configuration, so the same file can be passed to other commands with --config.
+
+Subscriptions are matched by provider, dataset, and name.
+
+Also see: subscribe, bootstrap`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		viper.SetConfigFile(args[0])
+		if err := viper.MergeInConfig(); err != nil {
+			log.Fatal().Err(err).Str("ConfigFile", args[0]).Msg("could not read config file")
+		}
+
+		var specs []*library.SubscriptionSpec
+		if err := viper.UnmarshalKey("subscriptions", &specs); err != nil {
+			log.Fatal().Err(err).Msg("could not parse subscriptions")
+		}
+
+		// fill in the data types of each subscription from its provider
+		for _, spec := range specs {
+			dataProvider, ok := provider.Map[spec.Provider]
+			if !ok {
+				log.Fatal().Str("Provider", spec.Provider).Str("Subscription", spec.Name).Msg("unknown provider")
+			}
+
+			dataset, ok := dataProvider.Datasets()[spec.Dataset]
+			if !ok {
+				log.Fatal().Str("Provider", spec.Provider).Str("Dataset", spec.Dataset).Msg("unknown dataset")
+			}
+
+			for _, dataType := range dataset.DataTypes {
+				spec.DataTypes = append(spec.DataTypes, dataType.Name)
+			}
+
+			spec.Config = canonicalConfig(dataProvider, spec.Config)
+			if err := dataProvider.ConfigSchema().Validate(spec.Config); err != nil {
+				log.Fatal().Err(err).Str("Subscription", spec.Name).Msg("invalid provider configuration")
+			}
+
+			if _, err := hook.Parse(strings.Join(spec.Hooks, ",")); err != nil {
+				log.Fatal().Err(err).Str("Subscription", spec.Name).Msg("invalid hooks")
+			}
+		}
+
+		dryRun := viper.GetBool("apply.dry_run")
+		dbURL := viper.GetString("db.url")
+
+		if !dryRun {
+			if err := db.Migrate(strings.Replace(dbURL, "postgres://", "pgx5://", 1)); err != nil {
+				log.Fatal().Err(err).Msg("could not migrate library schema")
+			}
+		}
+
+		myLibrary := &library.Library{
+			DBUrl: dbURL,
+			Name:  viper.GetString("library.name"),
+			Owner: viper.GetString("library.owner"),
+		}
+
+		if err := myLibrary.Connect(ctx); err != nil {
+			log.Fatal().Err(err).Msg("could not connect to library")
+		}
+		defer myLibrary.Close()
+
+		if myLibrary.Name != "" && !dryRun {
+			if err := myLibrary.ApplyMetadata(ctx); err != nil {
+				log.Fatal().Err(err).Msg("could not save library name and owner")
+			}
+		}
+
+		changes, err := myLibrary.Apply(ctx, specs, viper.GetBool("apply.prune"), dryRun)
+		for _, change := range changes {
+			fmt.Printf("%-10s %s  %-30s %s\n", change.Action, change.Subscription.ID, change.Subscription.Name, strings.Join(change.Fields, ","))
+		}
+
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not apply subscriptions")
+		}
+	},
+}
+
+// canonicalConfig restores the case of config keys the provider knows about;
+// viper lower cases all keys when it reads a config file
+func canonicalConfig(dataProvider provider.Provider, config map[string]string) map[string]string {
+	canonical := make(map[string]string, len(config))
+	for key, val := range config {
+		if field, ok := dataProvider.ConfigSchema().Field(key); ok {
+			key = field.Name
+		}
+
+		canonical[key] = val
+	}
+
+	return canonical
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+
+	applyCmd.Flags().Bool("prune", false, "deactivate subscriptions that are not declared in the config file")
+	if err := viper.BindPFlag("apply.prune", applyCmd.Flags().Lookup("prune")); err != nil {
+		log.Panic().Err(err).Msg("could not bind prune")
+	}
+
+	applyCmd.Flags().Bool("dry-run", false, "print the changes without saving them")
+	if err := viper.BindPFlag("apply.dry_run", applyCmd.Flags().Lookup("dry-run")); err != nil {
+		log.Panic().Err(err).Msg("could not bind dry-run")
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/penny-vault/pvdata/db"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// backupCmd represents the backup command
+var backupCmd = &cobra.Command{
+	Use:   "backup <directory>",
+	Short: "Export every table in the library to a directory",
+	Long: `backup writes each table in the library to <directory> as a gzipped file in
+PostgreSQL's COPY text format along with manifest.json, which records the
+schema version of the library and its subscriptions. The snapshot can be
+loaded into another database with restore.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		myLibrary, err := library.NewFromDB(ctx, viper.GetString("db.url"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not connect to library")
+		}
+
+		manifest, err := myLibrary.Snapshot(ctx, args[0])
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not snapshot library")
+		}
+
+		fmt.Printf("exported %d tables and %d subscriptions to %s\n", len(manifest.Tables), len(manifest.Subscriptions), args[0])
+	},
+}
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore <directory>",
+	Short: "Load a library exported with backup",
+	Long: `restore migrates the database configured by db.url and loads the snapshot in
+<directory> into it. The database must not have any subscriptions.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		dbURL := viper.GetString("db.url")
+
+		if err := db.Migrate(strings.Replace(dbURL, "postgres://", "pgx5://", 1)); err != nil {
+			log.Fatal().Err(err).Msg("could not migrate database")
+		}
+
+		myLibrary := &library.Library{DBUrl: dbURL}
+		if err := myLibrary.Connect(ctx); err != nil {
+			log.Fatal().Err(err).Msg("could not connect to database")
+		}
+		defer myLibrary.Close()
+
+		manifest, err := myLibrary.Restore(ctx, args[0])
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not restore library")
+		}
+
+		fmt.Printf("restored library %s with %d tables and %d subscriptions\n", manifest.Library, len(manifest.Tables), len(manifest.Subscriptions))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+}
@@ -0,0 +1,73 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/penny-vault/pvdata/provider"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// bootstrapCmd represents the bootstrap command
+var bootstrapCmd = &cobra.Command{
+	Use:   "bootstrap <data provider> <api key>",
+	Short: "Create the recommended subscriptions for a provider",
+	Long: `Bootstrap creates the default set of subscriptions for a data provider
+with recommended import schedules, e.g. assets daily, EOD quotes nightly, and
+fundamentals weekly. Datasets that are already subscribed to are skipped.
+
+Also see: subscribe, subscriptions`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		if _, ok := provider.Map[args[0]]; !ok {
+			log.Fatal().Str("Provider", args[0]).Msg("unknown provider")
+		}
+
+		myLibrary, err := library.NewFromDB(ctx, viper.GetString("db.url"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not connect to library")
+		}
+
+		subscriptions, err := myLibrary.Bootstrap(ctx, args[0], args[1])
+		if err != nil {
+			log.Fatal().Err(err).Str("Provider", args[0]).Msg("could not bootstrap subscriptions")
+		}
+
+		for _, subscription := range subscriptions {
+			fmt.Printf("%s  %-30s %s\n", subscription.ID, subscription.Name, subscription.Schedule)
+		}
+
+		fmt.Printf("created %d subscriptions\n", len(subscriptions))
+		if viper.GetString("default.asset_table") == "" {
+			for _, subscription := range subscriptions {
+				if table, ok := subscription.DataTablesMap[data.AssetKey]; ok {
+					fmt.Printf("set default.asset_table = '%s' in your configuration to use these assets by default\n", table)
+				}
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(bootstrapCmd)
+}
@@ -0,0 +1,107 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffDataTypes   []string
+	diffTolerance   float64
+	diffStart       string
+	diffEnd         string
+	diffMaxExamples int
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff <a> <b>",
+	Short: "Compare the data stored in two libraries or snapshots",
+	Long: `diff compares two sources data type by data type and prints a JSON report of
+rows missing from either source, rows whose values differ by more than the
+tolerance, and assets that are only present in one source. Each source is
+either a directory written by backup or a database URL of a library.
+
+Rows are matched on the primary key of their data type. Numbers are equal if
+their relative difference is at most --tolerance. diff exits with status 1 if
+any differences are found, which makes it suitable for validating a migration
+to a new provider.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		opts := library.DiffOptions{
+			DataTypes:   diffDataTypes,
+			Tolerance:   diffTolerance,
+			MaxExamples: diffMaxExamples,
+		}
+
+		var err error
+		if diffStart != "" {
+			if opts.Start, err = time.Parse(time.DateOnly, diffStart); err != nil {
+				log.Fatal().Err(err).Msg("could not parse start date")
+			}
+		}
+
+		if diffEnd != "" {
+			if opts.End, err = time.Parse(time.DateOnly, diffEnd); err != nil {
+				log.Fatal().Err(err).Msg("could not parse end date")
+			}
+		}
+
+		a, err := library.OpenDiffSource(ctx, args[0])
+		if err != nil {
+			log.Fatal().Err(err).Str("Source", args[0]).Msg("could not open source")
+		}
+
+		b, err := library.OpenDiffSource(ctx, args[1])
+		if err != nil {
+			log.Fatal().Err(err).Str("Source", args[1]).Msg("could not open source")
+		}
+
+		report, err := library.Diff(ctx, a, b, opts)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not compare sources")
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatal().Err(err).Msg("could not write report")
+		}
+
+		if report.HasDifferences() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().StringSliceVar(&diffDataTypes, "data-type", nil, "data types to compare (default all)")
+	diffCmd.Flags().Float64Var(&diffTolerance, "tolerance", 1e-6, "largest relative difference between numbers that are considered equal")
+	diffCmd.Flags().StringVar(&diffStart, "start", "", "only compare rows on or after this date (YYYY-MM-DD)")
+	diffCmd.Flags().StringVar(&diffEnd, "end", "", "only compare rows on or before this date (YYYY-MM-DD)")
+	diffCmd.Flags().IntVar(&diffMaxExamples, "examples", 20, "number of differing rows to list for each data type")
+}
@@ -0,0 +1,99 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	figiRefreshMaxAge int
+	figiRefreshTable  string
+	figiRefreshLimit  int
+	figiRefreshDryRun bool
+)
+
+// figiCmd represents the figi command
+var figiCmd = &cobra.Command{
+	Use:   "figi",
+	Short: "Manage the FIGI mappings of assets",
+}
+
+// figiRefreshCmd represents the figi refresh command
+var figiRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Re-resolve FIGIs of assets with stale or ambiguous mappings",
+	Long: `refresh looks up the composite FIGI of active assets on OpenFIGI again if their
+mapping was last checked more than --max-age days ago or if their ticker matched
+more than one composite FIGI. Assets are looked up in batches of 100 (10 without
+an OpenFIGI API key) within OpenFIGI's rate limit. Progress is saved after each
+batch; an interrupted refresh continues with the assets it had not checked.
+
+When a ticker maps to a different composite FIGI the existing asset is
+deactivated and a copy with the new FIGI is added. Changes are recorded in the
+asset_events table.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(log.Logger.WithContext(context.Background()), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		myLibrary, err := library.NewFromDB(ctx, viper.GetString("db.url"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not connect to library")
+		}
+
+		report, err := myLibrary.RefreshFigis(ctx, library.FigiRefreshOptions{
+			AssetTable: figiRefreshTable,
+			MaxAge:     time.Duration(figiRefreshMaxAge) * 24 * time.Hour,
+			Limit:      figiRefreshLimit,
+			DryRun:     figiRefreshDryRun,
+		})
+		if err != nil && (report == nil || !errors.Is(err, context.Canceled)) {
+			log.Fatal().Err(err).Msg("could not refresh figi mappings")
+		}
+
+		for _, change := range report.Changes {
+			fmt.Printf("%-10s %-6s %-26s %-12s -> %s\n", change.Ticker, change.PrimaryExchange, change.EventType, change.OldValue, change.NewValue)
+		}
+
+		fmt.Printf("checked %d: %d unchanged, %d changed, %d ambiguous, %d not found, %d failed\n", report.Checked,
+			report.Unchanged, len(report.Changes), report.Ambiguous, report.NotFound, report.Failed)
+
+		if err != nil {
+			log.Warn().Msg("refresh interrupted; run it again to continue")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(figiCmd)
+	figiCmd.AddCommand(figiRefreshCmd)
+
+	figiRefreshCmd.Flags().IntVar(&figiRefreshMaxAge, "max-age", 30, "re-resolve mappings last checked more than this many days ago")
+	figiRefreshCmd.Flags().StringVar(&figiRefreshTable, "table", "", "asset table to refresh (default: default.asset_table)")
+	figiRefreshCmd.Flags().IntVar(&figiRefreshLimit, "limit", 0, "maximum number of assets to check (0 checks all)")
+	figiRefreshCmd.Flags().BoolVar(&figiRefreshDryRun, "dry-run", false, "report mapping changes without applying them")
+}
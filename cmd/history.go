@@ -0,0 +1,73 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history <composite figi>",
+	Short: "Show the names and recorded changes of an asset",
+	Long: `history prints the current and previous names of an asset followed by the
+changes recorded in the asset event log, e.g. renames, new CIKs or websites, and
+FIGI changes found by figi refresh.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		myLibrary, err := library.NewFromDB(ctx, viper.GetString("db.url"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not connect to library")
+		}
+
+		history, err := myLibrary.AssetHistory(ctx, args[0])
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not load asset history")
+		}
+
+		fmt.Println("Names:")
+		for _, name := range history.Names {
+			validFrom := "-"
+			if !name.ValidFrom.IsZero() {
+				validFrom = name.ValidFrom.Format("2006-01-02")
+			}
+
+			validTo := "current"
+			if !name.ValidTo.IsZero() {
+				validTo = name.ValidTo.Format("2006-01-02")
+			}
+
+			fmt.Printf("  %-10s %-10s %s\n", validFrom, validTo, name.Name)
+		}
+
+		fmt.Println("Events:")
+		for _, event := range history.Events {
+			fmt.Printf("  %s %-6s %-24s %q -> %q\n", event.EventTime.Format("2006-01-02"), event.Ticker,
+				event.EventType, event.OldValue, event.NewValue)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+}
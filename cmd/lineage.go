@@ -0,0 +1,83 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// lineageCmd represents the lineage command
+var lineageCmd = &cobra.Command{
+	Use:   "lineage <composite figi> <date> <data type>",
+	Short: "Trace a stored value to the run that wrote it",
+	Long: `Every row saved by pvdata records the subscription, provider, and run that wrote
+it along with the time it was fetched. lineage lists that information for the
+rows stored for an asset on the given date (YYYY-MM-DD).`,
+	Args: cobra.ExactArgs(3),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		date, err := time.Parse(time.DateOnly, args[1])
+		if err != nil {
+			log.Fatal().Err(err).Str("Date", args[1]).Msg("could not parse date")
+		}
+
+		myLibrary, err := library.NewFromDB(ctx, viper.GetString("db.url"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not connect to library")
+		}
+
+		records, err := myLibrary.Lineage(ctx, args[0], date, args[2])
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not trace lineage")
+		}
+
+		if len(records) == 0 {
+			fmt.Println("no rows found")
+			return
+		}
+
+		for _, record := range records {
+			fmt.Printf("%s (%s)\n", record.SubscriptionName, record.SubscriptionID)
+			fmt.Printf("    Provider:   %s / %s\n", record.Provider, record.Dataset)
+			fmt.Printf("    Table:      %s\n", record.Table)
+
+			if record.FetchedAt.IsZero() {
+				fmt.Println("    Run:        unknown; row was saved before lineage was recorded")
+				continue
+			}
+
+			fmt.Printf("    Fetched At: %s\n", record.FetchedAt.Local().Format(time.DateTime))
+			if record.RunID == uuid.Nil {
+				fmt.Println("    Run:        not recorded")
+				continue
+			}
+
+			fmt.Printf("    Run:        %s started %s (%s)\n", record.RunID, record.RunStartTime.Format(time.DateTime), record.RunStatus)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lineageCmd)
+}
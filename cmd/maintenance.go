@@ -0,0 +1,75 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var maintenanceDeadRatio float64
+
+// maintenanceCmd represents the maintenance command
+var maintenanceCmd = &cobra.Command{
+	Use:   "maintenance",
+	Short: "Report table bloat and missing indexes",
+	Long: `maintenance reports tables with many dead rows and tables that may be missing an index.
+To vacuum bloated tables on a schedule subscribe to the maintenance provider's Database
+Maintenance dataset.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		myLibrary, err := library.NewFromDB(ctx, viper.GetString("db.url"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not connect to library")
+		}
+
+		bloat, err := myLibrary.Bloat(ctx, maintenanceDeadRatio)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not measure table bloat")
+		}
+
+		fmt.Println("Bloated tables:")
+		for _, table := range bloat {
+			fmt.Printf("  %-60s %10d dead rows (%.0f%%)  last vacuum %s\n", table.Table, table.DeadRows, table.DeadRatio*100,
+				table.LastVacuum.Format("2006-01-02"))
+		}
+
+		candidates, err := myLibrary.IndexCandidates(ctx)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not check for missing indexes")
+		}
+
+		fmt.Println("Index candidates:")
+		for _, candidate := range candidates {
+			fmt.Printf("  %-60s %s\n", candidate.Table, candidate.Reason)
+			if candidate.Statement != "" {
+				fmt.Printf("    %s\n", candidate.Statement)
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(maintenanceCmd)
+
+	maintenanceCmd.Flags().Float64Var(&maintenanceDeadRatio, "dead-ratio", 0.2, "report tables where at least this fraction of rows are dead")
+}
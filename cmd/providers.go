@@ -47,6 +47,12 @@ var providersCmd = &cobra.Command{
 				for _, dataset := range provider.Datasets() {
 					start, end := dataset.DateRange()
 					builder.WriteString(fmt.Sprintf("- %s (%s to %s): %s\n", dataset.Name, start.Format("2006-01-02"), end.Format("2006-01-02"), dataset.Description))
+					builder.WriteString(describeCapabilities(dataset.Capabilities))
+				}
+
+				if schema := provider.ConfigSchema(); len(schema) > 0 {
+					builder.WriteString("\n## Configuration\n")
+					builder.WriteString(describeConfig(schema))
 				}
 			}
 		} else {
@@ -66,6 +72,68 @@ var providersCmd = &cobra.Command{
 	},
 }
 
+// describeCapabilities lists the capabilities of a dataset as a nested markdown list
+func describeCapabilities(capabilities provider.Capabilities) string {
+	builder := strings.Builder{}
+
+	if len(capabilities.AssetTypes) > 0 {
+		assetTypes := make([]string, len(capabilities.AssetTypes))
+		for idx, assetType := range capabilities.AssetTypes {
+			assetTypes[idx] = string(assetType)
+		}
+		builder.WriteString(fmt.Sprintf("  - Asset types: %s\n", strings.Join(assetTypes, ", ")))
+	}
+
+	if len(capabilities.Geographies) > 0 {
+		builder.WriteString(fmt.Sprintf("  - Geographies: %s\n", strings.Join(capabilities.Geographies, ", ")))
+	}
+
+	if capabilities.Granularity != "" {
+		builder.WriteString(fmt.Sprintf("  - Granularity: %s\n", capabilities.Granularity))
+	}
+
+	if capabilities.Backfill {
+		builder.WriteString("  - Backfill: full history\n")
+	} else {
+		builder.WriteString("  - Backfill: recent observations only\n")
+	}
+
+	switch {
+	case capabilities.Cost.PerAsset > 0 && capabilities.Cost.PerRun > 0:
+		builder.WriteString(fmt.Sprintf("  - Cost: %d requests plus %d per asset\n", capabilities.Cost.PerRun, capabilities.Cost.PerAsset))
+	case capabilities.Cost.PerAsset > 0:
+		builder.WriteString(fmt.Sprintf("  - Cost: %d requests per asset\n", capabilities.Cost.PerAsset))
+	case capabilities.Cost.PerRun > 0:
+		builder.WriteString(fmt.Sprintf("  - Cost: %d requests\n", capabilities.Cost.PerRun))
+	}
+
+	return builder.String()
+}
+
+// describeConfig lists the configuration fields of a provider as a markdown list
+func describeConfig(schema provider.ConfigSchema) string {
+	builder := strings.Builder{}
+
+	for _, field := range schema {
+		attributes := []string{string(field.Type)}
+		if field.Required {
+			attributes = append(attributes, "required")
+		}
+
+		if field.Default != "" {
+			attributes = append(attributes, fmt.Sprintf("default %s", field.Default))
+		}
+
+		if field.Secret {
+			attributes = append(attributes, "secret")
+		}
+
+		builder.WriteString(fmt.Sprintf("- `%s` (%s): %s\n", field.Name, strings.Join(attributes, ", "), field.Prompt))
+	}
+
+	return builder.String()
+}
+
 func init() {
 	rootCmd.AddCommand(providersCmd)
 
@@ -0,0 +1,109 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var pruneDryRun bool
+
+// pruneCmd represents the prune command
+var pruneCmd = &cobra.Command{
+	Use:   "prune [subscription-id...]",
+	Short: "Delete observations and archives older than their retention period",
+	Long: `prune deletes rows older than each subscription's retention period. The period is set
+with the retention config value of the subscription, e.g. 30d, 12w, 6m, or 2y, and may be
+overridden for a single data type with retention.<data type>. Subscriptions without a
+retention period keep their observations forever.
+
+Recorded provider responses in snapshot.dir older than retention.snapshots are removed as
+well. Run prune on a schedule, e.g. from cron, to keep the library from growing without
+bound. If no subscription IDs are provided every subscription is pruned.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+		now := time.Now()
+
+		myLibrary, err := library.NewFromDB(ctx, viper.GetString("db.url"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not connect to library")
+		}
+
+		var subscriptions []*library.Subscription
+		if len(args) == 0 {
+			subscriptions, err = myLibrary.Subscriptions(ctx)
+			if err != nil {
+				log.Fatal().Err(err).Msg("could not list subscriptions")
+			}
+		}
+
+		for _, subscriptionID := range args {
+			subscription, err := myLibrary.SubscriptionFromID(ctx, subscriptionID)
+			if err != nil {
+				log.Fatal().Err(err).Str("SubscriptionID", subscriptionID).Msg("could not load subscription")
+			}
+			subscriptions = append(subscriptions, subscription)
+		}
+
+		verb := "deleted"
+		if pruneDryRun {
+			verb = "would delete"
+		}
+
+		for _, subscription := range subscriptions {
+			results, err := subscription.Prune(ctx, now, pruneDryRun)
+			if err != nil {
+				log.Error().Err(err).Str("SubscriptionID", subscription.ID.String()).Msg("could not prune subscription")
+			}
+
+			for _, result := range results {
+				fmt.Printf("%s: %s %d rows from %s before %s\n", result.SubscriptionName, verb, result.NumRows,
+					result.Table, result.Cutoff.Format("2006-01-02"))
+			}
+		}
+
+		if dir := viper.GetString("snapshot.dir"); dir != "" {
+			retention, err := library.ParseRetention(viper.GetString("retention.snapshots"))
+			if err != nil {
+				log.Fatal().Err(err).Msg("invalid snapshot retention")
+			}
+
+			if !retention.IsZero() {
+				cutoff := retention.Cutoff(now)
+				numFiles, err := library.PruneFiles(dir, cutoff, pruneDryRun)
+				if err != nil {
+					log.Error().Err(err).Str("Dir", dir).Msg("could not prune snapshots")
+				}
+
+				fmt.Printf("snapshots: %s %d files from %s before %s\n", verb, numFiles, strings.TrimSuffix(dir, "/"),
+					cutoff.Format("2006-01-02"))
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "report what would be deleted without deleting it")
+}
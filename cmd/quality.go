@@ -0,0 +1,86 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	qualityFilter  library.QualityFilter
+	qualityRefresh bool
+)
+
+// qualityCmd represents the quality command
+var qualityCmd = &cobra.Command{
+	Use:   "quality [composite figi...]",
+	Short: "Show the data-quality scorecard of assets",
+	Long: `quality prints the data-quality scorecard of the assets in default.eod_table,
+worst coverage first. Each asset is scored over a trailing window of sessions on
+the share of sessions with a quote, the number of gaps, provider corrections,
+quarantined quotes, and the number of sessions since the last quote.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		myLibrary, err := library.NewFromDB(ctx, viper.GetString("db.url"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not connect to library")
+		}
+
+		if qualityFilter.EODTable == "" {
+			qualityFilter.EODTable = viper.GetString("default.eod_table")
+		}
+
+		if qualityRefresh {
+			if _, err := myLibrary.RefreshQuality(ctx, qualityFilter.EODTable); err != nil {
+				log.Fatal().Err(err).Msg("could not refresh data quality scorecard")
+			}
+		}
+
+		qualityFilter.CompositeFigis = args
+		report, err := myLibrary.QualityReport(ctx, qualityFilter)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not load data quality scorecard")
+		}
+
+		fmt.Printf("%-10s %-12s %8s %5s %11s %9s %10s %5s\n", "TICKER", "FIGI", "COVERAGE", "GAPS", "CORRECTIONS",
+			"ANOMALIES", "LAST QUOTE", "STALE")
+		for _, asset := range report {
+			lastQuote := "-"
+			if !asset.LastQuote.IsZero() {
+				lastQuote = asset.LastQuote.Format("2006-01-02")
+			}
+
+			fmt.Printf("%-10s %-12s %7.1f%% %5d %11d %9d %10s %5d\n", asset.Ticker, asset.CompositeFigi,
+				asset.Coverage*100, asset.Gaps, asset.Corrections, asset.Anomalies, lastQuote, asset.StaleSessions)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(qualityCmd)
+
+	qualityCmd.Flags().StringVar(&qualityFilter.EODTable, "table", "", "EOD table to report on (default: default.eod_table)")
+	qualityCmd.Flags().Float64Var(&qualityFilter.MinCoverage, "min-coverage", 0, "only print assets with at least this fraction of sessions quoted")
+	qualityCmd.Flags().IntVar(&qualityFilter.MaxGaps, "max-gaps", 0, "only print assets with at most this many gaps (0 is unlimited)")
+	qualityCmd.Flags().IntVar(&qualityFilter.MaxStaleSessions, "max-stale", 0, "only print assets quoted within this many sessions (0 is unlimited)")
+	qualityCmd.Flags().BoolVar(&qualityRefresh, "refresh", false, "recompute the scorecard before printing it")
+}
@@ -0,0 +1,123 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/penny-vault/pvdata/anomaly"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// quarantineCmd represents the quarantine command
+var quarantineCmd = &cobra.Command{
+	Use:   "quarantine",
+	Short: "List observations held for review",
+	Long: `Incoming quotes that fail anomaly screening (non-positive prices, returns far outside
+the asset's trailing volatility, or volume spikes) are quarantined instead of saved. The
+quarantine sub-command lists them; use accept or reject to review each observation.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		myLibrary, err := library.NewFromDB(ctx, viper.GetString("db.url"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not load library info")
+		}
+
+		quarantined, err := myLibrary.QuarantinedObservations(ctx)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not list quarantined observations")
+		}
+
+		for _, obs := range quarantined {
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\n", obs.ID, obs.DataType, obs.Ticker, obs.EventDate.Format("2006-01-02"), strings.Join(obs.Reasons, "; "))
+		}
+	},
+}
+
+var quarantineThresholds anomaly.Thresholds
+
+// quarantineThresholdsCmd sets the anomaly thresholds for an asset type
+var quarantineThresholdsCmd = &cobra.Command{
+	Use:   "thresholds [asset-type]",
+	Short: "View or set anomaly screening thresholds",
+	Long: `With no arguments print the configured thresholds. With an asset type (e.g. CS, ETF, or *
+for the default) set its thresholds.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		myLibrary, err := library.NewFromDB(ctx, viper.GetString("db.url"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not load library info")
+		}
+
+		if len(args) == 1 {
+			quarantineThresholds.AssetType = args[0]
+			if err := anomaly.SaveThresholds(ctx, myLibrary, &quarantineThresholds); err != nil {
+				log.Fatal().Err(err).Msg("could not save thresholds")
+			}
+		}
+
+		thresholds, err := anomaly.LoadThresholds(ctx, myLibrary)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not load thresholds")
+		}
+
+		for _, threshold := range thresholds {
+			fmt.Printf("%s\tz-score: %.1f\tvolume ratio: %.1f\tlookback: %d days\n", threshold.AssetType,
+				threshold.MaxReturnZScore, threshold.MaxVolumeRatio, threshold.LookbackDays)
+		}
+	},
+}
+
+func quarantineReviewCmd(use, short string, action func(*library.Library, context.Context, string) error) *cobra.Command {
+	return &cobra.Command{
+		Use:   use + " <quarantine-id...>",
+		Short: short,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+
+			myLibrary, err := library.NewFromDB(ctx, viper.GetString("db.url"))
+			if err != nil {
+				log.Fatal().Err(err).Msg("could not load library info")
+			}
+
+			for _, id := range args {
+				if err := action(myLibrary, ctx, id); err != nil {
+					log.Error().Err(err).Str("QuarantineID", id).Msgf("could not %s observation", use)
+				}
+			}
+		},
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(quarantineCmd)
+
+	quarantineCmd.AddCommand(quarantineReviewCmd("accept", "Save quarantined observations to the library", (*library.Library).AcceptQuarantined))
+	quarantineCmd.AddCommand(quarantineReviewCmd("reject", "Discard quarantined observations", (*library.Library).RejectQuarantined))
+
+	quarantineCmd.AddCommand(quarantineThresholdsCmd)
+	quarantineThresholdsCmd.Flags().Float64Var(&quarantineThresholds.MaxReturnZScore, "zscore", anomaly.DefaultThresholds.MaxReturnZScore, "maximum absolute z-score of a daily return")
+	quarantineThresholdsCmd.Flags().Float64Var(&quarantineThresholds.MaxVolumeRatio, "volume-ratio", anomaly.DefaultThresholds.MaxVolumeRatio, "maximum ratio of volume to trailing average volume")
+	quarantineThresholdsCmd.Flags().IntVar(&quarantineThresholds.LookbackDays, "lookback", anomaly.DefaultThresholds.LookbackDays, "number of days of history used to compute volatility and average volume")
+}
@@ -0,0 +1,133 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	quotesTable   string
+	quotesStart   string
+	quotesEnd     string
+	quotesUSD     bool
+	quotesFXTable string
+)
+
+// quotesCmd represents the quotes command
+var quotesCmd = &cobra.Command{
+	Use:   "quotes <composite figi>",
+	Short: "Export the EOD quotes of an asset as CSV",
+	Long: `quotes writes the EOD quotes of an asset to stdout as CSV. Prices are in the
+currency the asset trades in unless --usd is set, which converts them to US
+dollars with the rates in the fx table. Quotes on dates without an exchange
+rate are written unconverted with their original currency.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		query := &data.EodQuery{
+			CompositeFigi:     args[0],
+			Start:             time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC),
+			End:               time.Now(),
+			NormalizeCurrency: quotesUSD,
+			FXTable:           quotesFXTable,
+		}
+
+		var err error
+		if quotesStart != "" {
+			if query.Start, err = time.Parse(time.DateOnly, quotesStart); err != nil {
+				log.Fatal().Err(err).Msg("could not parse start date")
+			}
+		}
+
+		if quotesEnd != "" {
+			if query.End, err = time.Parse(time.DateOnly, quotesEnd); err != nil {
+				log.Fatal().Err(err).Msg("could not parse end date")
+			}
+		}
+
+		if quotesTable == "" {
+			quotesTable = viper.GetString("default.eod_table")
+		}
+
+		myLibrary, err := library.NewFromDB(ctx, viper.GetString("db.url"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not connect to library")
+		}
+
+		conn, err := myLibrary.Pool.Acquire(ctx)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not acquire database connection")
+		}
+		defer conn.Release()
+
+		quotes, err := data.EodQuotes(ctx, conn, quotesTable, query)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not load quotes")
+		}
+
+		writer := csv.NewWriter(os.Stdout)
+		if err := writer.Write([]string{"date", "ticker", "composite_figi", "open", "high", "low", "close",
+			"volume", "dividend", "split_factor", "currency"}); err != nil {
+			log.Fatal().Err(err).Msg("could not write quotes")
+		}
+
+		for _, quote := range quotes {
+			record := []string{
+				quote.Date.Format(time.DateOnly),
+				quote.Ticker,
+				quote.CompositeFigi,
+				strconv.FormatFloat(quote.Open, 'f', -1, 64),
+				strconv.FormatFloat(quote.High, 'f', -1, 64),
+				strconv.FormatFloat(quote.Low, 'f', -1, 64),
+				strconv.FormatFloat(quote.Close, 'f', -1, 64),
+				strconv.FormatFloat(quote.Volume, 'f', -1, 64),
+				strconv.FormatFloat(quote.Dividend, 'f', -1, 64),
+				strconv.FormatFloat(quote.Split, 'f', -1, 64),
+				quote.Currency,
+			}
+
+			if err := writer.Write(record); err != nil {
+				log.Fatal().Err(err).Msg("could not write quotes")
+			}
+		}
+
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			log.Fatal().Err(err).Msg("could not write quotes")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(quotesCmd)
+
+	quotesCmd.Flags().StringVar(&quotesTable, "table", "", "EOD table to read (default: default.eod_table)")
+	quotesCmd.Flags().StringVar(&quotesStart, "start", "", "only export quotes on or after this date (YYYY-MM-DD)")
+	quotesCmd.Flags().StringVar(&quotesEnd, "end", "", "only export quotes on or before this date (YYYY-MM-DD)")
+	quotesCmd.Flags().BoolVar(&quotesUSD, "usd", false, "convert prices to US dollars")
+	quotesCmd.Flags().StringVar(&quotesFXTable, "fx-table", "", "table holding fx rates (default: default.fx_table)")
+}
@@ -0,0 +1,124 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/penny-vault/pvdata/notify"
+	"github.com/penny-vault/pvdata/report"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	reportFormat string
+	reportSince  time.Duration
+	reportSend   bool
+)
+
+// reportCmd represents the report command
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Generate a summary report of the library",
+	Long: `The report sub-command summarizes new listings and delistings, assets missing the most
+recent quote, and the biggest movers. Use --send to deliver the report through the configured
+notifiers instead of printing it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		myLibrary, err := library.NewFromDB(ctx, viper.GetString("db.url"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not load library info")
+		}
+
+		subscriptions, err := myLibrary.Subscriptions(ctx)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not load subscriptions")
+		}
+
+		myReport, err := generateReport(ctx, myLibrary, &report.Options{
+			Since: time.Now().Add(-reportSince),
+		}, subscriptions)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not generate report")
+		}
+
+		if reportSend {
+			sendReport(ctx, myReport, reportFormat)
+			return
+		}
+
+		out, err := myReport.Render(reportFormat)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not render report")
+		}
+
+		fmt.Println(out)
+	},
+}
+
+// generateReport fills in the tables used by the report and generates it
+func generateReport(ctx context.Context, myLibrary *library.Library, opts *report.Options, subscriptions []*library.Subscription) (*report.Report, error) {
+	conn, err := myLibrary.Pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	opts.AssetTable = viper.GetString("default.asset_table")
+	opts.EODTable = viper.GetString("default.eod_table")
+	if opts.EODTable == "" {
+		for _, subscription := range subscriptions {
+			if tbl, ok := subscription.DataTablesMap[data.EODKey]; ok {
+				opts.EODTable = tbl
+				break
+			}
+		}
+	}
+
+	if opts.Quarantined == nil {
+		opts.Quarantined, err = myLibrary.NumQuarantined(ctx, opts.Since)
+		if err != nil {
+			log.Warn().Err(err).Msg("could not count quarantined observations")
+		}
+	}
+
+	return report.Generate(ctx, conn, opts)
+}
+
+// sendReport delivers the report through the configured notifiers
+func sendReport(ctx context.Context, myReport *report.Report, format string) {
+	msg, err := myReport.Message(format)
+	if err != nil {
+		log.Error().Err(err).Msg("could not render report")
+		return
+	}
+
+	notify.Send(ctx, notify.FromConfig(), msg)
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+
+	reportCmd.Flags().StringVarP(&reportFormat, "format", "f", report.FormatMarkdown, "report format: markdown, html, or json")
+	reportCmd.Flags().DurationVar(&reportSince, "since", 24*time.Hour, "report listings and delistings within this period")
+	reportCmd.Flags().BoolVar(&reportSend, "send", false, "send the report through the configured notifiers")
+}
@@ -5,13 +5,24 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/penny-vault/pvdata/anomaly"
+	"github.com/penny-vault/pvdata/bus"
 	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/db"
 	"github.com/penny-vault/pvdata/library"
+	"github.com/penny-vault/pvdata/notify"
+	"github.com/penny-vault/pvdata/orchestrator"
 	"github.com/penny-vault/pvdata/provider"
+	"github.com/penny-vault/pvdata/report"
+	"github.com/penny-vault/pvdata/sink"
 	"github.com/rs/zerolog/log"
+	"github.com/spf13/cast"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -23,10 +34,21 @@ var runCmd = &cobra.Command{
 	Long: `The run sub-command executes subscriptions and saves the data they generate. If no
 arguments are provided then run will execute as a daemon and execute each subscription at the
 scheduled times. If subscription IDs are provided then each subscription will execute
-sequentially (ignoring any set schedule).`,
+immediately (ignoring any set schedule). Subscriptions that depend on data produced by
+other requested subscriptions run after them and are skipped if a prerequisite fails.
+
+By default subscriptions execute sequentially. Use --parallel to run independent
+subscriptions concurrently; --max-http, --provider-concurrency, and --max-db-conns bound
+the resources they share. Subscriptions of a provider that use the same API key
+share its rate limit.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := context.Background()
 
+		// bring the library schema up-to-date
+		if err := db.Migrate(strings.Replace(viper.GetString("db.url"), "postgres://", "pgx5://", 1)); err != nil {
+			log.Fatal().Err(err).Msg("could not migrate library schema")
+		}
+
 		// load the library
 		myLibrary, err := library.NewFromDB(ctx, viper.GetString("db.url"))
 		if err != nil {
@@ -40,49 +62,90 @@ sequentially (ignoring any set schedule).`,
 			os.Exit(0)
 		}
 
-		outChan := make(chan *data.Observation, 1000)
-		exitChan := make(chan data.RunSummary, 5)
+		// buffer observations so slow database writes do not stall providers
+		queue := library.NewObservationQueue(viper.GetInt("queue.size"), viper.GetString("queue.spill_dir"))
+		outChan := queue.In()
+
+		// record observations in the journal before they are queued for the database
+		if journalDir := viper.GetString("journal.dir"); journalDir != "" {
+			journal, err := library.OpenJournal(journalDir)
+			if err != nil {
+				log.Fatal().Err(err).Str("JournalDir", journalDir).Msg("could not open journal")
+			}
+
+			defer func() {
+				if err := journal.Close(); err != nil {
+					log.Error().Err(err).Msg("could not close journal")
+				}
+			}()
+
+			myLibrary.Journal = journal
+			journalChan := make(chan *data.Observation)
+			go journal.Record(journalChan, outChan)
+			outChan = journalChan
+		}
+
+		// write observations to each subscription's sinks
+		router := sink.NewRouter(myLibrary, newSinks(myLibrary)...)
+		defer router.Close()
+
+		// hold suspicious quotes for review
+		if viper.GetBool("anomaly.enabled") {
+			screener, err := anomaly.NewScreener(ctx, myLibrary)
+			if err != nil {
+				log.Error().Err(err).Msg("could not create anomaly screener; observations will not be screened")
+			} else {
+				router.Screener = screener
+			}
+		}
 
 		var wg sync.WaitGroup
 		wg.Add(1)
-		go myLibrary.SaveObservations(outChan, &wg)
+		go router.Run(queue.Out(), &wg)
 
-		// not daemon mode, execute each subscription individually
+		// not daemon mode, load each requested subscription
+		subscriptions := make([]*library.Subscription, 0, len(args))
 		for _, subscriptionID := range args {
 			subscription, err := myLibrary.SubscriptionFromID(ctx, subscriptionID)
 			if err != nil {
 				log.Fatal().Err(err).Str("SubscriptionID", subscriptionID).Msg("could not load subscription")
 			}
+			subscriptions = append(subscriptions, subscription)
+		}
 
-			var (
-				subProvider provider.Provider
-				subDataset  provider.Dataset
-				ok          bool
-			)
+		if viper.GetBool("run.plan") {
+			close(outChan)
+			wg.Wait()
+			printPlan(ctx, myLibrary, subscriptions)
+			return
+		}
 
-			// create any needed partitions
-			err = subscription.ManagePartitions(ctx)
+		// record provider HTTP traffic or serve it from a previous recording
+		if dir := viper.GetString("snapshot.dir"); dir != "" {
+			snapshot, err := provider.NewSnapshot(dir, provider.SnapshotMode(viper.GetString("snapshot.mode")))
 			if err != nil {
-				log.Error().Err(err).Msg("ManagePartitions returned an error")
-			}
-
-			if subProvider, ok = provider.Map[subscription.Provider]; !ok {
-				log.Fatal().Str("ProviderKey", subscription.Provider).Msg("subscription is mis-configured, provider not found")
+				log.Fatal().Err(err).Str("Dir", dir).Msg("could not open snapshot")
 			}
+			ctx = provider.WithSnapshot(ctx, snapshot)
+		}
 
-			if subDataset, ok = subProvider.Datasets()[subscription.Dataset]; !ok {
-				log.Fatal().Str("ProviderKey", subscription.Provider).Str("DatasetKey", subscription.Dataset).
-					Msg("subscription is mis-configured, dataset not found")
-			}
-
-			fetchLogger := log.With().Str("SubscriptionID", subscriptionID).Logger()
-			ctx = fetchLogger.WithContext(ctx)
-
-			subDataset.Fetch(ctx, subscription, outChan, exitChan)
+		// execute subscriptions in dependency order
+		runner := orchestrator.New(myLibrary)
+		runner.Limits = orchestrator.Limits{
+			MaxConcurrent:       viper.GetInt("run.parallel"),
+			MaxHTTPConcurrency:  viper.GetInt("run.max_http"),
+			ProviderConcurrency: cast.ToStringMapInt(viper.Get("run.provider_concurrency")),
+		}
+		runner.Notifiers = notify.FromConfig()
+		runner.EnforceQuota = viper.GetBool("run.enforce_quota")
+		runner.EnforceRefresh = viper.GetBool("run.enforce_refresh")
+		runner.WaitForLock = viper.GetBool("run.wait_for_lock")
+		runner.Barrier = router
 
-			// read the exit message from exitChan
-			summaryMsg := <-exitChan
-			fetchLogger.Info().Time("StartTime", summaryMsg.StartTime).Time("EndTime", summaryMsg.EndTime).Str("RunTime", summaryMsg.EndTime.Sub(summaryMsg.StartTime).String()).Msg("finished running subscription")
+		cycleStart := time.Now()
+		summaries, err := runner.Run(ctx, subscriptions, outChan)
+		if err != nil {
+			log.Error().Err(err).Msg("could not run subscriptions")
 		}
 
 		// close the output channel
@@ -90,9 +153,252 @@ sequentially (ignoring any set schedule).`,
 
 		// wait for library SaveObservations to finish
 		wg.Wait()
+
+		stats := queue.Stats()
+		log.Info().Int64("HighWater", stats.HighWater).Msg("observation queue drained")
+
+		for name, count := range router.Failures() {
+			log.Warn().Str("Sink", name).Int("NumFailed", count).Msg("sink failed to write observations")
+		}
+
+		// refresh planner statistics of tables that received many new rows
+		analyzeLargeIngests(ctx, subscriptions, summaries)
+
+		// score the assets of EOD tables that received new quotes
+		if viper.GetBool("quality.enabled") {
+			refreshQuality(ctx, myLibrary, subscriptions, summaries)
+		}
+
+		// summarize the cycle
+		if viper.GetBool("report.enabled") {
+			myReport, err := generateReport(ctx, myLibrary, &report.Options{
+				Since:        cycleStart.AddDate(0, 0, -1),
+				Runs:         summaries,
+				Observations: router.Counts(),
+				Quarantined:  router.Quarantined(),
+			}, subscriptions)
+			if err != nil {
+				log.Error().Err(err).Msg("could not generate report")
+			} else {
+				sendReport(ctx, myReport, viper.GetString("report.format"))
+			}
+		}
 	},
 }
 
+// analyzeLargeIngests runs ANALYZE on the tables of subscriptions whose run
+// produced at least maintenance.analyze_threshold observations
+func analyzeLargeIngests(ctx context.Context, subscriptions []*library.Subscription, summaries []data.RunSummary) {
+	threshold := viper.GetInt("maintenance.analyze_threshold")
+	if threshold <= 0 {
+		return
+	}
+
+	numObs := make(map[string]int, len(summaries))
+	for _, summary := range summaries {
+		numObs[summary.SubscriptionID.String()] += summary.NumObservations
+	}
+
+	for _, subscription := range subscriptions {
+		if numObs[subscription.ID.String()] < threshold {
+			continue
+		}
+
+		log.Info().Str("SubscriptionID", subscription.ID.String()).Int("NumObservations", numObs[subscription.ID.String()]).
+			Msg("analyzing tables after large ingest")
+		if err := subscription.Analyze(ctx); err != nil {
+			log.Error().Err(err).Str("SubscriptionID", subscription.ID.String()).Msg("could not analyze tables")
+		}
+	}
+}
+
+// refreshQuality recomputes the data-quality scorecard of each EOD table that
+// a subscription saved observations to during the run
+func refreshQuality(ctx context.Context, myLibrary *library.Library, subscriptions []*library.Subscription, summaries []data.RunSummary) {
+	if viper.GetString("default.asset_table") == "" {
+		return
+	}
+
+	numObs := make(map[string]int, len(summaries))
+	for _, summary := range summaries {
+		numObs[summary.SubscriptionID.String()] += summary.NumObservations
+	}
+
+	refreshed := make(map[string]bool)
+	for _, subscription := range subscriptions {
+		eodTable, ok := subscription.DataTablesMap[data.EODKey]
+		if !ok || refreshed[eodTable] || numObs[subscription.ID.String()] == 0 {
+			continue
+		}
+
+		refreshed[eodTable] = true
+		numAssets, err := myLibrary.RefreshQuality(ctx, eodTable)
+		if err != nil {
+			log.Error().Err(err).Str("Table", eodTable).Msg("could not refresh data quality scorecard")
+			continue
+		}
+
+		log.Info().Str("Table", eodTable).Int("NumAssets", numAssets).Msg("refreshed data quality scorecard")
+	}
+}
+
+// printPlan prints the estimated cost of running subscriptions
+func printPlan(ctx context.Context, myLibrary *library.Library, subscriptions []*library.Subscription) {
+	assets := []*data.Asset{}
+	if viper.GetString("default.asset_table") != "" {
+		conn, err := myLibrary.Pool.Acquire(ctx)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not acquire database connection")
+		}
+
+		assets = data.ActiveAssets(ctx, conn)
+		conn.Release()
+	}
+
+	plan, err := orchestrator.Plan(subscriptions, assets)
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not plan subscriptions")
+	}
+
+	for _, planned := range plan {
+		quota := "no daily quota"
+		if planned.DailyQuota > 0 {
+			quota = fmt.Sprintf("daily quota %d", planned.DailyQuota)
+		}
+
+		fmt.Printf("%-36s  %-30s  %6d assets  %8d requests  %-18s  %s\n", planned.Subscription.ID, planned.Subscription.Name,
+			planned.NumAssets, planned.Requests, quota, planned.Duration.Round(time.Second))
+		for _, warning := range planned.Warnings {
+			fmt.Printf("    warning: %s\n", warning)
+		}
+	}
+}
+
+// newSinks creates the sinks that are configured; postgres and stdout are always available
+func newSinks(myLibrary *library.Library) []sink.Sink {
+	sinks := []sink.Sink{
+		sink.NewPostgres(myLibrary),
+		sink.NewStdout(),
+	}
+
+	if dir := viper.GetString("sinks.parquet_dir"); dir != "" {
+		parquetSink, err := sink.NewParquet(dir)
+		if err != nil {
+			log.Fatal().Err(err).Str("Dir", dir).Msg("could not create parquet sink")
+		}
+		sinks = append(sinks, parquetSink)
+	}
+
+	if dsn := viper.GetString("clickhouse.dsn"); dsn != "" {
+		ttl := time.Duration(viper.GetInt("clickhouse.ttl_days")) * 24 * time.Hour
+		clickHouseSink, err := sink.NewClickHouse(dsn, ttl)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not connect to clickhouse")
+		}
+		sinks = append(sinks, clickHouseSink)
+	}
+
+	if transport := viper.GetString("bus.transport"); transport != "" {
+		busSink, err := sink.NewBus(bus.Config{
+			Transport: transport,
+			URL:       viper.GetString("bus.url"),
+			Topic:     viper.GetString("bus.topic"),
+			Format:    viper.GetString("bus.format"),
+		})
+		if err != nil {
+			log.Fatal().Err(err).Str("Transport", transport).Msg("could not connect to message bus")
+		}
+		sinks = append(sinks, busSink)
+	}
+
+	return sinks
+}
+
 func init() {
 	rootCmd.AddCommand(runCmd)
+
+	runCmd.Flags().Int("parallel", 1, "maximum number of subscriptions to run concurrently")
+	if err := viper.BindPFlag("run.parallel", runCmd.Flags().Lookup("parallel")); err != nil {
+		log.Panic().Err(err).Msg("could not bind parallel")
+	}
+
+	runCmd.Flags().Bool("plan", false, "print the estimated number of requests and run time of each subscription without running them")
+	if err := viper.BindPFlag("run.plan", runCmd.Flags().Lookup("plan")); err != nil {
+		log.Panic().Err(err).Msg("could not bind plan")
+	}
+
+	runCmd.Flags().Bool("enforce-quota", false, "refuse to run subscriptions that are estimated to exceed the provider's remaining daily quota")
+	if err := viper.BindPFlag("run.enforce_quota", runCmd.Flags().Lookup("enforce-quota")); err != nil {
+		log.Panic().Err(err).Msg("could not bind enforce-quota")
+	}
+
+	runCmd.Flags().Bool("enforce-refresh", false, "skip subscriptions that succeeded more recently than their dataset changes")
+	if err := viper.BindPFlag("run.enforce_refresh", runCmd.Flags().Lookup("enforce-refresh")); err != nil {
+		log.Panic().Err(err).Msg("could not bind enforce-refresh")
+	}
+
+	runCmd.Flags().Bool("wait-for-lock", false, "wait for subscriptions that another process is running instead of skipping them")
+	if err := viper.BindPFlag("run.wait_for_lock", runCmd.Flags().Lookup("wait-for-lock")); err != nil {
+		log.Panic().Err(err).Msg("could not bind wait-for-lock")
+	}
+
+	runCmd.Flags().Int("max-http", 0, "maximum number of concurrent HTTP requests across all providers (0 is unlimited)")
+	if err := viper.BindPFlag("run.max_http", runCmd.Flags().Lookup("max-http")); err != nil {
+		log.Panic().Err(err).Msg("could not bind max-http")
+	}
+
+	runCmd.Flags().StringToInt("provider-concurrency", map[string]int{}, "maximum number of concurrent subscriptions per provider, e.g. tiingo=2 (default 1)")
+	if err := viper.BindPFlag("run.provider_concurrency", runCmd.Flags().Lookup("provider-concurrency")); err != nil {
+		log.Panic().Err(err).Msg("could not bind provider-concurrency")
+	}
+
+	runCmd.Flags().Int("queue-size", 1000, "number of observations buffered in memory between providers and the database")
+	if err := viper.BindPFlag("queue.size", runCmd.Flags().Lookup("queue-size")); err != nil {
+		log.Panic().Err(err).Msg("could not bind queue-size")
+	}
+
+	runCmd.Flags().String("spill-dir", "", "directory observations are spilled to when the in-memory queue is full (default: block providers)")
+	if err := viper.BindPFlag("queue.spill_dir", runCmd.Flags().Lookup("spill-dir")); err != nil {
+		log.Panic().Err(err).Msg("could not bind spill-dir")
+	}
+
+	runCmd.Flags().String("journal-dir", "", "directory of the write-ahead journal; unsaved observations from a previous run are replayed")
+	if err := viper.BindPFlag("journal.dir", runCmd.Flags().Lookup("journal-dir")); err != nil {
+		log.Panic().Err(err).Msg("could not bind journal-dir")
+	}
+
+	runCmd.Flags().String("snapshot-dir", "", "directory provider HTTP responses are recorded to or replayed from")
+	if err := viper.BindPFlag("snapshot.dir", runCmd.Flags().Lookup("snapshot-dir")); err != nil {
+		log.Panic().Err(err).Msg("could not bind snapshot-dir")
+	}
+
+	runCmd.Flags().String("snapshot-mode", string(provider.SnapshotRecord), "record responses from providers or replay recorded responses: record or replay")
+	if err := viper.BindPFlag("snapshot.mode", runCmd.Flags().Lookup("snapshot-mode")); err != nil {
+		log.Panic().Err(err).Msg("could not bind snapshot-mode")
+	}
+
+	runCmd.Flags().Int("analyze-threshold", 10000, "analyze a subscription's tables after a run saves at least this many observations (0 disables)")
+	if err := viper.BindPFlag("maintenance.analyze_threshold", runCmd.Flags().Lookup("analyze-threshold")); err != nil {
+		log.Panic().Err(err).Msg("could not bind analyze-threshold")
+	}
+
+	runCmd.Flags().Bool("quality", true, "refresh the data-quality scorecard of EOD tables that received new quotes")
+	if err := viper.BindPFlag("quality.enabled", runCmd.Flags().Lookup("quality")); err != nil {
+		log.Panic().Err(err).Msg("could not bind quality")
+	}
+
+	runCmd.Flags().Bool("report", false, "send a summary report through the configured notifiers when the run finishes")
+	if err := viper.BindPFlag("report.enabled", runCmd.Flags().Lookup("report")); err != nil {
+		log.Panic().Err(err).Msg("could not bind report")
+	}
+
+	runCmd.Flags().Bool("screen", false, "quarantine anomalous quotes for review instead of saving them")
+	if err := viper.BindPFlag("anomaly.enabled", runCmd.Flags().Lookup("screen")); err != nil {
+		log.Panic().Err(err).Msg("could not bind screen")
+	}
+
+	runCmd.Flags().Int32("max-db-conns", 0, "maximum number of database connections (0 uses the driver default)")
+	if err := viper.BindPFlag("db.max_conns", runCmd.Flags().Lookup("max-db-conns")); err != nil {
+		log.Panic().Err(err).Msg("could not bind max-db-conns")
+	}
 }
@@ -0,0 +1,80 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// runsCmd represents the runs command
+var runsCmd = &cobra.Command{
+	Use:   "runs",
+	Short: "List in-flight subscription runs",
+	Long: `The runs sub-command lists subscription runs that are currently running or paused. Use
+the cancel, pause, and resume sub-commands to control them.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		myLibrary, err := library.NewFromDB(ctx, viper.GetString("db.url"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not load library info")
+		}
+
+		runs, err := myLibrary.Runs(ctx)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not list runs")
+		}
+
+		for _, run := range runs {
+			fmt.Printf("%s\t%s\t%s\t%s\t%d requests\n", run.ID, run.SubscriptionID, run.State, run.StartTime.Format("2006-01-02 15:04:05"), run.EstimatedRequests)
+		}
+	},
+}
+
+func runControlCmd(use, short string, action func(*library.Library, context.Context, string) error) *cobra.Command {
+	return &cobra.Command{
+		Use:   use + " <run-id...>",
+		Short: short,
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+
+			myLibrary, err := library.NewFromDB(ctx, viper.GetString("db.url"))
+			if err != nil {
+				log.Fatal().Err(err).Msg("could not load library info")
+			}
+
+			for _, runID := range args {
+				if err := action(myLibrary, ctx, runID); err != nil {
+					log.Error().Err(err).Str("RunID", runID).Msgf("could not %s run", use)
+				}
+			}
+		},
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(runsCmd)
+
+	runsCmd.AddCommand(runControlCmd("cancel", "Cancel a run; providers stop at their next checkpoint", (*library.Library).CancelRun))
+	runsCmd.AddCommand(runControlCmd("pause", "Pause a run at its next checkpoint", (*library.Library).PauseRun))
+	runsCmd.AddCommand(runControlCmd("resume", "Resume a paused run", (*library.Library).ResumeRun))
+}
@@ -0,0 +1,74 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	searchLimit      int
+	searchActiveOnly bool
+)
+
+// searchCmd represents the search command
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Find assets by ticker or company name",
+	Long: `search looks up assets in default.asset_table by ticker or company name and prints
+the best matches first. Prefixes and misspellings are matched, e.g. "appl" finds
+Apple Inc.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		myLibrary, err := library.NewFromDB(ctx, viper.GetString("db.url"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not connect to library")
+		}
+
+		matches, err := myLibrary.SearchAssetsWithOptions(ctx, strings.Join(args, " "), library.AssetSearchOptions{
+			Limit:      searchLimit,
+			ActiveOnly: searchActiveOnly,
+		})
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not search assets")
+		}
+
+		for _, match := range matches {
+			status := "active"
+			if !match.Active {
+				status = "inactive"
+			}
+
+			fmt.Printf("%-10s %-12s %-6s %-4s %-8s %.2f  %s\n", match.Ticker, match.CompositeFigi, match.PrimaryExchange,
+				match.AssetType, status, match.Score, match.Name)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+
+	searchCmd.Flags().IntVar(&searchLimit, "limit", 20, "maximum number of matches to print")
+	searchCmd.Flags().BoolVar(&searchActiveOnly, "active", false, "only search active assets")
+}
@@ -91,12 +91,19 @@ Also see: subscriptions, unsubscribe`,
 		}
 
 		// create a new field group for configuring the provider
-		configFields := make([]huh.Field, 0, len(dataProvider.ConfigDescription()))
-		config := make(map[string]*string, len(dataProvider.ConfigDescription()))
-		for k, v := range dataProvider.ConfigDescription() {
-			val := ""
-			config[k] = &val
-			configFields = append(configFields, huh.NewInput().Title(v).Value(config[k]))
+		schema := dataProvider.ConfigSchema()
+		configFields := make([]huh.Field, 0, len(schema))
+		config := make(map[string]*string, len(schema))
+		for _, field := range schema {
+			val := field.Default
+			config[field.Name] = &val
+
+			input := huh.NewInput().Title(field.Prompt).Value(config[field.Name]).Validate(field.Validate)
+			if field.Secret {
+				input = input.EchoMode(huh.EchoModePassword)
+			}
+
+			configFields = append(configFields, input)
 		}
 
 		// walk user through settings required for subscription
@@ -186,7 +193,7 @@ Also see: subscriptions, unsubscribe`,
 			)
 
 			fmt.Fprintln(&sb, lipgloss.NewStyle().Bold(true).Render("Provider Configuration"))
-			for k, v := range subscription.Config {
+			for k, v := range schema.Mask(subscription.Config) {
 				fmt.Fprintf(&sb, "\n%s: %s", k, keyword(v))
 			}
 
@@ -216,7 +223,7 @@ Also see: subscriptions, unsubscribe`,
 		if confirmed {
 			if monitored {
 				checkSlug := slug.Make(fmt.Sprintf("%s %s %s %s", subscription.Name, subscription.Provider, subscription.Dataset, subscription.ID.String()[:5]))
-				checkID, err := healthcheck.Create(
+				checkID, err := healthcheck.Create(ctx,
 					fmt.Sprintf("%s %s (%s)", subscription.Name, subscription.Dataset, subscription.ID.String()[:5]),
 					checkSlug,
 					subscription.DataTypes,
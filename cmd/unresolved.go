@@ -0,0 +1,72 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var unresolvedMinAttempts int
+
+// unresolvedCmd represents the unresolved command
+var unresolvedCmd = &cobra.Command{
+	Use:   "unresolved",
+	Short: "List assets whose composite FIGI could not be found",
+	Long: `Assets whose composite FIGI cannot be found on OpenFIGI are handled according to
+figi.unresolved_policy:
+
+    drop       discard the asset
+    pending    discard the asset and record it for review (default)
+    synthetic  record the asset and save it with a synthetic FIGI
+
+Recorded assets are looked up again each time a provider lists them. unresolved
+lists assets that have failed to resolve at least --min-attempts times.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := context.Background()
+
+		myLibrary, err := library.NewFromDB(ctx, viper.GetString("db.url"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not connect to library")
+		}
+
+		unresolved, err := myLibrary.UnresolvedAssets(ctx, unresolvedMinAttempts)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not list unresolved assets")
+		}
+
+		for _, asset := range unresolved {
+			synthetic := ""
+			if asset.SyntheticFigi != nil {
+				synthetic = *asset.SyntheticFigi
+			}
+
+			fmt.Printf("%-10s %-6s %-8s %4d attempts  first seen %s  %s  %s\n", asset.Ticker, asset.PrimaryExchange,
+				asset.AssetType, asset.Attempts, asset.FirstSeen.Format("2006-01-02"), synthetic, asset.Name)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(unresolvedCmd)
+
+	unresolvedCmd.Flags().IntVar(&unresolvedMinAttempts, "min-attempts", 1, "only list assets that failed to resolve at least this many times")
+}
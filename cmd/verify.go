@@ -0,0 +1,113 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/penny-vault/pvdata/hook"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/penny-vault/pvdata/provider"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	verifySamples     int
+	verifyTolerance   float64
+	verifyMaxExamples int
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify <subscription-id>",
+	Short: "Compare a sample of stored rows with the provider",
+	Long: `verify samples random rows stored by a subscription, fetches the subscription's
+dataset from its provider again, and prints a JSON report of sampled rows that
+differ from what the provider returns now or that the provider no longer
+returns. It catches parser regressions and silent restatements by providers.
+
+Only rows within the date range the dataset fetches are sampled. Refetched data
+is compared in temporary tables and does not change the library, but verify
+costs as many provider requests as a regular run. verify exits with status 1 if
+any mismatches are found.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := log.Logger.WithContext(context.Background())
+
+		myLibrary, err := library.NewFromDB(ctx, viper.GetString("db.url"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not connect to library")
+		}
+
+		subscription, err := myLibrary.SubscriptionFromID(ctx, args[0])
+		if err != nil {
+			log.Fatal().Err(err).Str("ID", args[0]).Msg("could not get subscription for ID")
+		}
+
+		subProvider, ok := provider.Map[subscription.Provider]
+		if !ok {
+			log.Fatal().Err(provider.ErrProviderNotFound).Str("Provider", subscription.Provider).Msg("could not verify subscription")
+		}
+
+		dataset, ok := subProvider.Datasets()[subscription.Dataset]
+		if !ok {
+			log.Fatal().Err(provider.ErrDatasetNotFound).Str("Dataset", subscription.Dataset).Msg("could not verify subscription")
+		}
+
+		opts := library.VerifyOptions{
+			Samples:     verifySamples,
+			Tolerance:   verifyTolerance,
+			MaxExamples: verifyMaxExamples,
+		}
+
+		if dataset.DateRange != nil {
+			opts.Start, opts.End = dataset.DateRange()
+		}
+
+		// stored observations were transformed by the subscription's hooks so
+		// the refetched ones are as well
+		hooks, err := hook.ForSubscription(subscription)
+		if err != nil {
+			log.Fatal().Err(err).Msg("invalid hooks")
+		}
+
+		report, err := subscription.Verify(ctx, hook.WrapFetch(hooks, dataset.Fetch), opts)
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not verify subscription")
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatal().Err(err).Msg("could not write report")
+		}
+
+		if report.HasMismatches() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	verifyCmd.Flags().IntVar(&verifySamples, "samples", 20, "number of rows to sample from each data type")
+	verifyCmd.Flags().Float64Var(&verifyTolerance, "tolerance", 1e-6, "largest relative difference between numbers that are considered equal")
+	verifyCmd.Flags().IntVar(&verifyMaxExamples, "examples", 20, "number of mismatched rows to list for each data type")
+}
@@ -18,9 +18,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"time"
 
 	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -29,6 +32,14 @@ import (
 
 type AssetType string
 
+// Kinds of asset changes counted in the run summary of asset syncs
+const (
+	AssetsCreated   = "created"
+	AssetsChanged   = "changed"
+	AssetsDelisted  = "delisted"
+	AssetsUnchanged = "unchanged"
+)
+
 const (
 	CommonStock  AssetType = "CS"
 	ETF          AssetType = "ETF"
@@ -53,7 +64,22 @@ const (
 	ARCAExchange    Exchange = "ARCX"
 	IndexExchange   Exchange = "INDEX"
 	OTCExchange     Exchange = "OTC"
+	OTCQXExchange   Exchange = "OTCQ"
+	OTCQBExchange   Exchange = "OTCB"
+	PinkExchange    Exchange = "PINX"
 	UnknownExchange Exchange = "UNK"
+
+	TSXExchange      Exchange = "XTSE"
+	TSXVExchange     Exchange = "XTSX"
+	LSEExchange      Exchange = "XLON"
+	XetraExchange    Exchange = "XETR"
+	EuronextExchange Exchange = "XPAR"
+	SIXExchange      Exchange = "XSWX"
+	TokyoExchange    Exchange = "XTKS"
+	HongKongExchange Exchange = "XHKG"
+	ShanghaiExchange Exchange = "XSHG"
+	ShenzhenExchange Exchange = "XSHE"
+	ASXExchange      Exchange = "XASX"
 )
 
 type Asset struct {
@@ -82,21 +108,58 @@ type Asset struct {
 	OtherIdentifiers     map[string]string
 	Tags                 []string
 	SimilarTickers       []string  `json:"similar_tickers" toml:"similar_tickers" parquet:"name=similar_tickers, type=MAP, convertedtype=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	PriceCurrency        string    `json:"price_currency" toml:"price_currency" parquet:"name=price_currency, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY" db:"price_currency"`
 	LastUpdated          time.Time `json:"last_updated" parquet:"name=last_updated, type=INT64"`
 }
 
 func ActiveAssets(ctx context.Context, dbConn *pgxpool.Conn, tables ...string) []*Asset {
-	var assetTable string
+	assetTable := activeAssetTable(tables)
+	if assetTable == "" {
+		log.Panic().Msg("default.asset_table not set list of active assets is not possible")
+		return nil
+	}
+
+	return activeAssets(ctx, dbConn, assetTable)
+}
+
+// ActiveAssetsAsOf returns the assets that were active in the library at asOf.
+// The asset table must be system-versioned, see AsOf.
+func ActiveAssetsAsOf(ctx context.Context, dbConn *pgxpool.Conn, asOf time.Time, tables ...string) ([]*Asset, error) {
+	assetTable := activeAssetTable(tables)
+	if assetTable == "" {
+		return nil, ErrAssetTableNotSet
+	}
+
+	source, err := AsOf(ctx, dbConn, assetTable, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	return activeAssets(ctx, dbConn, source), nil
+}
+
+// activeAssetTable returns the first of tables or the default asset table if
+// tables is empty
+func activeAssetTable(tables []string) string {
 	if len(tables) == 0 {
-		assetTable = viper.GetString("default.asset_table")
-		if assetTable == "" {
-			log.Panic().Msg("default.asset_table not set list of active assets is not possible")
-			return nil
-		}
-	} else {
-		assetTable = tables[0]
+		return viper.GetString("default.asset_table")
+	}
+	return tables[0]
+}
+
+// activeAssets selects the active assets from source, which is either a table
+// name or a subquery
+func activeAssets(ctx context.Context, dbConn *pgxpool.Conn, source string) []*Asset {
+	dbActiveAssets, err := selectAssets(ctx, dbConn, source, "active=true")
+	if err != nil {
+		log.Error().Err(err).Msg("error when scanning values into dbActiveAssets")
 	}
 
+	return dbActiveAssets
+}
+
+// selectAssets returns the assets in source that match the where clause
+func selectAssets(ctx context.Context, dbConn *pgxpool.Conn, source string, where string, args ...any) ([]*Asset, error) {
 	sql := fmt.Sprintf(`SELECT
 		ticker,
 		composite_figi,
@@ -116,31 +179,171 @@ func ActiveAssets(ctx context.Context, dbConn *pgxpool.Conn, tables ...string) [
 		other_identifiers,
 		similar_tickers,
 		tags,
+		coalesce(price_currency, 'USD') as price_currency,
 		coalesce(to_char(listed, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"'), '') as listed,
 		coalesce(to_char(delisted, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"'), '') as delisted,
 		last_updated
 	FROM %s
-	WHERE active=true`, assetTable)
+	WHERE %s`, source, where)
 
-	rows, err := dbConn.Query(ctx, sql)
+	rows, err := dbConn.Query(ctx, sql, args...)
 	if err != nil {
 		log.Error().Err(err).Str("SQL", sql).Msg("save asset to DB failed")
-		return nil
+		return nil, err
+	}
+
+	var assets []*Asset
+	err = pgxscan.ScanAll(&assets, rows)
+	return assets, err
+}
+
+// DelistedAsset is a delisted asset whose stored quotes end before its
+// delisting date
+type DelistedAsset struct {
+	Asset    *Asset
+	Delisted MarketDate
+
+	// LastQuote is the date of the asset's most recent stored quote; it is
+	// zero if no quotes are stored
+	LastQuote MarketDate
+}
+
+// QuoteStart returns the first date to fetch so that the asset's quotes reach
+// its delisting date: the day after the last stored quote, but no earlier than
+// maxDays before delisting, or lookbackDays before delisting if no quotes are
+// stored
+func (asset *DelistedAsset) QuoteStart(lookbackDays, maxDays int) MarketDate {
+	if asset.LastQuote.IsZero() {
+		return asset.Delisted.AddDays(-lookbackDays)
+	}
+
+	start := asset.LastQuote.AddDays(1)
+	if earliest := asset.Delisted.AddDays(-maxDays); start.Before(earliest) {
+		return earliest
+	}
+
+	return start
+}
+
+// DelistedAssets returns the assets delisted on or after since whose quotes in
+// eodTable do not reach their delisting date, e.g. an asset delisted mid-week
+// whose final sessions were never fetched because it was no longer active.
+// Assets are read from the first of tables or the default asset table.
+func DelistedAssets(ctx context.Context, dbConn *pgxpool.Conn, since MarketDate, eodTable string, tables ...string) ([]*DelistedAsset, error) {
+	assetTable := activeAssetTable(tables)
+	if assetTable == "" {
+		return nil, ErrAssetTableNotSet
 	}
 
-	var dbActiveAssets []*Asset
-	err = pgxscan.ScanAll(&dbActiveAssets, rows)
+	assets, err := selectAssets(ctx, dbConn, assetTable, "active=false AND delisted >= $1", since.Time())
 	if err != nil {
-		log.Error().Err(err).Msg("error when scanning values into dbActiveAssets")
+		return nil, err
 	}
 
-	return dbActiveAssets
+	figis := make([]string, 0, len(assets))
+	for _, asset := range assets {
+		figis = append(figis, asset.CompositeFigi)
+	}
+
+	lastQuotes := make(map[string]MarketDate, len(assets))
+	rows, err := dbConn.Query(ctx, fmt.Sprintf(`SELECT composite_figi::text, max(event_date) FROM %s
+WHERE composite_figi = ANY($1) GROUP BY composite_figi`, pgx.Identifier{eodTable}.Sanitize()), figis)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var (
+			compositeFigi string
+			lastQuote     MarketDate
+		)
+
+		if err := rows.Scan(&compositeFigi, &lastQuote); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		lastQuotes[compositeFigi] = lastQuote
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	delisted := make([]*DelistedAsset, 0, len(assets))
+	for _, asset := range assets {
+		delistedOn, err := ParseMarketDate(asset.DelistingDate)
+		if err != nil {
+			continue
+		}
+
+		lastQuote := lastQuotes[asset.CompositeFigi]
+		if !lastQuote.IsZero() && !lastQuote.Before(delistedOn) {
+			continue
+		}
+
+		delisted = append(delisted, &DelistedAsset{
+			Asset:     asset,
+			Delisted:  delistedOn,
+			LastQuote: lastQuote,
+		})
+	}
+
+	return delisted, nil
 }
 
 func (asset *Asset) ID() string {
 	return fmt.Sprintf("%s:%s", asset.Ticker, asset.CompositeFigi)
 }
 
+// ChangedFrom returns true if saving asset would change the stored asset.
+// Providers only fill in some fields so fields that are empty in asset are
+// not compared. Dates are compared by day since the database adds a time.
+func (asset *Asset) ChangedFrom(stored *Asset) bool {
+	changedString := func(incoming, current string) bool {
+		return incoming != "" && incoming != current
+	}
+
+	changedDate := func(incoming, current string) bool {
+		return incoming != "" && !sameDay(incoming, current)
+	}
+
+	changedSlice := func(incoming, current []string) bool {
+		return len(incoming) != 0 && !slices.Equal(incoming, current)
+	}
+
+	return asset.Active != stored.Active ||
+		changedString(asset.Name, stored.Name) ||
+		changedString(asset.Description, stored.Description) ||
+		changedString(string(asset.PrimaryExchange), string(stored.PrimaryExchange)) ||
+		changedString(string(asset.AssetType), string(stored.AssetType)) ||
+		changedString(asset.ShareClassFigi, stored.ShareClassFigi) ||
+		changedString(asset.CIK, stored.CIK) ||
+		changedString(asset.Industry, stored.Industry) ||
+		changedString(asset.Sector, stored.Sector) ||
+		changedString(asset.CorporateUrl, stored.CorporateUrl) ||
+		changedString(asset.PriceCurrency, stored.PriceCurrency) ||
+		changedDate(asset.ListingDate, stored.ListingDate) ||
+		changedDate(asset.DelistingDate, stored.DelistingDate) ||
+		changedSlice(asset.CUSIP, stored.CUSIP) ||
+		changedSlice(asset.ISIN, stored.ISIN) ||
+		changedSlice(asset.Tags, stored.Tags) ||
+		changedSlice(asset.SimilarTickers, stored.SimilarTickers) ||
+		(asset.SIC != 0 && asset.SIC != stored.SIC) ||
+		(len(asset.OtherIdentifiers) != 0 && !maps.Equal(asset.OtherIdentifiers, stored.OtherIdentifiers))
+}
+
+// sameDay returns true if both dates, which may include a time, are on the
+// same day
+func sameDay(a, b string) bool {
+	dateA, errA := ParseMarketDate(a)
+	dateB, errB := ParseMarketDate(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return dateA == dateB
+}
+
 func (asset *Asset) SaveFiles(ctx context.Context, filer Filer) error {
 	type File struct {
 		Name     string
@@ -190,7 +393,7 @@ func (asset *Asset) SaveDB(ctx context.Context, tbl string, dbConn *pgxpool.Conn
 		return nil
 	}
 
-	tx, err := dbConn.Begin(ctx)
+	tx, err := beginSave(ctx, dbConn)
 	if err != nil {
 		return err
 	}
@@ -235,20 +438,21 @@ func (asset *Asset) SaveDB(ctx context.Context, tbl string, dbConn *pgxpool.Conn
 		"tags",
 		"listed",
 		"delisted",
-		"last_updated"
+		"last_updated",
+		"price_currency"
 	) VALUES (
 		$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12,
-		$13, $14, $15, $16, $17, $18, $19, $20, $21
+		$13, $14, $15, $16, $17, $18, $19, $20, $21, $22
 	) ON CONFLICT ON CONSTRAINT %[1]s_pkey DO UPDATE SET
 		primary_exchange = EXCLUDED.primary_exchange,
 		active = EXCLUDED.active,
-		name = EXCLUDED.name,
+		name = coalesce(nullif(EXCLUDED.name, ''), %[1]s.name),
 		description = EXCLUDED.description,
-		corporate_url = EXCLUDED.corporate_url,
+		corporate_url = coalesce(nullif(EXCLUDED.corporate_url, ''), %[1]s.corporate_url),
 		sector = EXCLUDED.sector,
 		industry = EXCLUDED.industry,
 		sic_code = EXCLUDED.sic_code,
-		cik = EXCLUDED.cik,
+		cik = coalesce(nullif(EXCLUDED.cik, ''), %[1]s.cik),
 		cusips = EXCLUDED.cusips,
 		isins = EXCLUDED.isins,
 		other_identifiers = EXCLUDED.other_identifiers,
@@ -256,13 +460,19 @@ func (asset *Asset) SaveDB(ctx context.Context, tbl string, dbConn *pgxpool.Conn
 		tags = EXCLUDED.tags,
 		listed = EXCLUDED.listed,
 		delisted = EXCLUDED.delisted,
-		last_updated = EXCLUDED.last_updated`, tbl)
+		last_updated = EXCLUDED.last_updated,
+		price_currency = EXCLUDED.price_currency`, tbl)
+
+	priceCurrency := asset.PriceCurrency
+	if priceCurrency == "" {
+		priceCurrency = USD
+	}
 
 	_, err = tx.Exec(ctx, sql, asset.Ticker, asset.CompositeFigi, asset.ShareClassFigi,
 		asset.PrimaryExchange, asset.AssetType, asset.Active, asset.Name, asset.Description,
 		asset.CorporateUrl, asset.Sector, asset.Industry, asset.SIC, asset.CIK,
 		asset.CUSIP, asset.ISIN, asset.OtherIdentifiers, asset.SimilarTickers, asset.Tags,
-		listingDate, delistingDate, asset.LastUpdated)
+		listingDate, delistingDate, asset.LastUpdated, priceCurrency)
 
 	if err != nil {
 		log.Error().Err(err).Str("SQL", sql).Msg("save asset to DB failed")
@@ -298,5 +508,6 @@ func (asset *Asset) MarshalZerologObject(e *zerolog.Event) {
 
 	e.Strs("Tags", asset.Tags)
 	e.Strs("SimilarTickers", asset.SimilarTickers)
+	e.Str("PriceCurrency", asset.PriceCurrency)
 	e.Time("LastUpdated", asset.LastUpdated)
 }
@@ -0,0 +1,110 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Types of events recorded in the asset_events table
+const (
+	AssetEventCompositeFigi  = "composite-figi-changed"
+	AssetEventShareClassFigi = "share-class-figi-changed"
+	AssetEventName           = "name-changed"
+	AssetEventCIK            = "cik-changed"
+	AssetEventWebsite        = "website-changed"
+)
+
+// assetEventsTrigger records changes to an asset's name, CIK, and website in
+// the asset_events table
+const assetEventsTrigger = `DROP TRIGGER IF EXISTS %[1]s_asset_events ON %[1]s;
+CREATE TRIGGER %[1]s_asset_events
+AFTER UPDATE ON %[1]s
+FOR EACH ROW
+EXECUTE PROCEDURE pvdata_asset_events();`
+
+// AssetEvent records a change to an asset, e.g. a rename or a ticker that now
+// maps to a different composite FIGI
+type AssetEvent struct {
+	EventTime       time.Time `db:"event_time" json:"event_time"`
+	AssetTable      string    `db:"asset_table" json:"asset_table"`
+	Ticker          string    `db:"ticker" json:"ticker"`
+	CompositeFigi   string    `db:"composite_figi" json:"composite_figi"`
+	PrimaryExchange string    `db:"primary_exchange" json:"primary_exchange"`
+	EventType       string    `db:"event_type" json:"event_type"`
+	OldValue        string    `db:"old_value" json:"old_value"`
+	NewValue        string    `db:"new_value" json:"new_value"`
+}
+
+// AssetName is a name an asset was known by. ValidFrom is zero if the name was
+// in use before changes were tracked and ValidTo is zero for the current name.
+type AssetName struct {
+	Name      string    `json:"name"`
+	ValidFrom time.Time `json:"valid_from"`
+	ValidTo   time.Time `json:"valid_to"`
+}
+
+// AssetEvents returns the events recorded for the asset, oldest first
+func AssetEvents(ctx context.Context, dbConn *pgxpool.Conn, compositeFigi string) ([]*AssetEvent, error) {
+	events := make([]*AssetEvent, 0)
+	err := pgxscan.Select(ctx, dbConn, &events, `SELECT event_time, asset_table, ticker, composite_figi,
+primary_exchange, event_type, old_value, new_value FROM asset_events WHERE composite_figi = $1 ORDER BY event_time, id`,
+		compositeFigi)
+	return events, err
+}
+
+// AssetNames returns the current and previous names of the asset, current name
+// first. The current name is read from the first of tables or the default
+// asset table.
+func AssetNames(ctx context.Context, dbConn *pgxpool.Conn, compositeFigi string, tables ...string) ([]*AssetName, error) {
+	assetTable := activeAssetTable(tables)
+	if assetTable == "" {
+		return nil, ErrAssetTableNotSet
+	}
+
+	var current string
+	sql := fmt.Sprintf(`SELECT coalesce(max(name) FILTER (WHERE active), max(name), '')
+	FROM %s WHERE composite_figi = $1`, assetTable)
+	if err := dbConn.QueryRow(ctx, sql, compositeFigi).Scan(&current); err != nil {
+		return nil, err
+	}
+
+	events, err := AssetEvents(ctx, dbConn, compositeFigi)
+	if err != nil {
+		return nil, err
+	}
+
+	// walk the renames backwards from the current name
+	names := []*AssetName{{Name: current}}
+	for idx := len(events) - 1; idx >= 0; idx-- {
+		event := events[idx]
+		if event.EventType != AssetEventName {
+			continue
+		}
+
+		names[len(names)-1].ValidFrom = event.EventTime
+		names = append(names, &AssetName{
+			Name:    event.OldValue,
+			ValidTo: event.EventTime,
+		})
+	}
+
+	return names, nil
+}
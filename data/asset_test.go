@@ -0,0 +1,66 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/penny-vault/pvdata/data"
+)
+
+var _ = Describe("Asset", func() {
+	var stored *data.Asset
+
+	BeforeEach(func() {
+		stored = &data.Asset{
+			Ticker:          "VTI",
+			CompositeFigi:   "BBG000BDTBL9",
+			Name:            "Vanguard Total Stock Market ETF",
+			PrimaryExchange: data.ARCAExchange,
+			AssetType:       data.ETF,
+			Active:          true,
+			ListingDate:     "2001-05-31T00:00:00.000000Z",
+			PriceCurrency:   "USD",
+		}
+	})
+
+	It("ignores fields the provider did not set", func() {
+		incoming := &data.Asset{
+			Ticker:          "VTI",
+			CompositeFigi:   "BBG000BDTBL9",
+			PrimaryExchange: data.ARCAExchange,
+			AssetType:       data.ETF,
+			Active:          true,
+			ListingDate:     "2001-05-31",
+			PriceCurrency:   "USD",
+		}
+		Expect(incoming.ChangedFrom(stored)).To(BeFalse())
+	})
+
+	It("detects changed fields", func() {
+		incoming := *stored
+		incoming.PrimaryExchange = data.NYSEExchange
+		Expect(incoming.ChangedFrom(stored)).To(BeTrue())
+
+		incoming = *stored
+		incoming.ListingDate = "2001-06-01"
+		Expect(incoming.ChangedFrom(stored)).To(BeTrue())
+
+		incoming = *stored
+		incoming.Active = false
+		Expect(incoming.ChangedFrom(stored)).To(BeTrue())
+	})
+})
@@ -0,0 +1,292 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrUnknownBarPeriod = errors.New("unknown bar period")
+)
+
+// BarPeriod is the length of time covered by a resampled bar
+type BarPeriod string
+
+const (
+	// BarWeekly bars cover the sessions of a calendar week, Monday to Sunday
+	BarWeekly BarPeriod = "week"
+
+	// BarMonthly bars cover the sessions of a calendar month
+	BarMonthly BarPeriod = "month"
+)
+
+// ParseBarPeriod converts the name of a bar period, e.g. from a subscription
+// config, into a BarPeriod
+func ParseBarPeriod(name string) (BarPeriod, error) {
+	switch period := BarPeriod(strings.ToLower(strings.TrimSpace(name))); period {
+	case BarWeekly, BarMonthly:
+		return period, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownBarPeriod, name)
+	}
+}
+
+// Start returns the first calendar day of the period that contains date.
+// Weeks start on Monday so a week that spans new year is a single bar.
+func (period BarPeriod) Start(date MarketDate) MarketDate {
+	switch period {
+	case BarWeekly:
+		return date.AddDays(-((int(date.Weekday()) + 6) % 7))
+	case BarMonthly:
+		return NewMarketDate(date.Year, date.Month, 1)
+	default:
+		return date
+	}
+}
+
+// End returns the last calendar day of the period that contains date
+func (period BarPeriod) End(date MarketDate) MarketDate {
+	switch period {
+	case BarWeekly:
+		return period.Start(date).AddDays(6)
+	case BarMonthly:
+		return NewMarketDate(date.Year, date.Month+1, 0)
+	default:
+		return date
+	}
+}
+
+// Bar is an OHLCV bar resampled from the daily quotes of an asset. Prices are
+// on the share basis of the last session in the bar: sessions before a split
+// within the period are adjusted by the split so that the high and low are
+// comparable.
+type Bar struct {
+	Ticker        string    `json:"ticker"`
+	CompositeFigi string    `json:"compositeFigi"`
+	Period        BarPeriod `json:"period"`
+
+	// PeriodStart is the first calendar day of the period and identifies the
+	// bar; FirstSession and LastSession are the first and last days with
+	// quotes, which differ from the calendar around weekends and holidays
+	PeriodStart  time.Time `json:"periodStart"`
+	FirstSession time.Time `json:"firstSession"`
+	LastSession  time.Time `json:"lastSession"`
+
+	Open     float64 `json:"open"`
+	High     float64 `json:"high"`
+	Low      float64 `json:"low"`
+	Close    float64 `json:"close"`
+	Volume   float64 `json:"volume"`
+	Dividend float64 `json:"divCash"`
+	Split    float64 `json:"splitFactor"`
+	Currency string  `json:"currency"`
+
+	// NumSessions is the number of daily quotes in the bar
+	NumSessions int `json:"numSessions"`
+
+	// Complete is false while the period has not ended; the bar is updated as
+	// quotes for the rest of the period arrive
+	Complete bool `json:"complete"`
+}
+
+// BarQuery selects the bars returned by Bars
+type BarQuery struct {
+	CompositeFigi string
+	Period        BarPeriod
+	Start         time.Time
+	End           time.Time
+}
+
+// ResampleBars combines daily quotes into bars of the given period. quotes must
+// be sorted by date and belong to a single asset. Bars whose period ends on or
+// after asOf are incomplete.
+func ResampleBars(quotes []*Eod, period BarPeriod, asOf MarketDate) []*Bar {
+	bars := make([]*Bar, 0)
+
+	for start := 0; start < len(quotes); {
+		periodStart := period.Start(dateOf(quotes[start].Date))
+
+		end := start + 1
+		for end < len(quotes) && period.Start(dateOf(quotes[end].Date)) == periodStart {
+			end++
+		}
+
+		bar := resampleBar(quotes[start:end], period)
+		bar.PeriodStart = periodStart.Time()
+		bar.Complete = period.End(periodStart).Before(asOf)
+		bars = append(bars, bar)
+
+		start = end
+	}
+
+	return bars
+}
+
+// resampleBar combines the quotes of a single period
+func resampleBar(quotes []*Eod, period BarPeriod) *Bar {
+	last := quotes[len(quotes)-1]
+	bar := &Bar{
+		Ticker:        last.Ticker,
+		CompositeFigi: last.CompositeFigi,
+		Period:        period,
+		FirstSession:  quotes[0].Date,
+		LastSession:   last.Date,
+		Close:         last.Close,
+		Split:         1.0,
+		Currency:      last.Currency,
+		NumSessions:   len(quotes),
+	}
+
+	// walk backwards so that each quote is adjusted by the splits after it
+	adjustment := 1.0
+	for idx := len(quotes) - 1; idx >= 0; idx-- {
+		quote := quotes[idx]
+
+		if idx == len(quotes)-1 || quote.High/adjustment > bar.High {
+			bar.High = quote.High / adjustment
+		}
+
+		if idx == len(quotes)-1 || quote.Low/adjustment < bar.Low {
+			bar.Low = quote.Low / adjustment
+		}
+
+		bar.Open = quote.Open / adjustment
+		bar.Volume += quote.Volume * adjustment
+		bar.Dividend += quote.Dividend / adjustment
+
+		if quote.Split != 0 && quote.Split != 1 {
+			bar.Split *= quote.Split
+			adjustment *= quote.Split
+		}
+	}
+
+	bar.Volume = math.Round(bar.Volume)
+
+	return bar
+}
+
+func (bar *Bar) SaveDB(ctx context.Context, tbl string, dbConn *pgxpool.Conn) error {
+	tx, err := beginSave(ctx, dbConn)
+	if err != nil {
+		return err
+	}
+
+	sql := fmt.Sprintf(`INSERT INTO %[1]s (
+		"ticker",
+		"composite_figi",
+		"period",
+		"period_start",
+		"first_session",
+		"last_session",
+		"open",
+		"high",
+		"low",
+		"close",
+		"volume",
+		"dividend",
+		"split_factor",
+		"currency",
+		"num_sessions",
+		"complete"
+	) VALUES (
+		$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16
+	) ON CONFLICT ON CONSTRAINT %[1]s_pkey
+	DO UPDATE SET
+		ticker = EXCLUDED.ticker,
+		first_session = EXCLUDED.first_session,
+		last_session = EXCLUDED.last_session,
+		open = EXCLUDED.open,
+		high = EXCLUDED.high,
+		low = EXCLUDED.low,
+		close = EXCLUDED.close,
+		volume = EXCLUDED.volume,
+		dividend = EXCLUDED.dividend,
+		split_factor = EXCLUDED.split_factor,
+		currency = EXCLUDED.currency,
+		num_sessions = EXCLUDED.num_sessions,
+		complete = EXCLUDED.complete;`, tbl)
+
+	currency := bar.Currency
+	if currency == "" {
+		currency = USD
+	}
+
+	_, err = tx.Exec(ctx, sql, bar.Ticker, bar.CompositeFigi, bar.Period, bar.PeriodStart, bar.FirstSession,
+		bar.LastSession, bar.Open, bar.High, bar.Low, bar.Close, bar.Volume, bar.Dividend, bar.Split,
+		currency, bar.NumSessions, bar.Complete)
+	if err != nil {
+		log.Error().Err(err).Str("SQL", sql).Msg("error saving bar to database")
+		if err2 := tx.Rollback(ctx); err2 != nil {
+			log.Error().Err(err2).Msg("error rolling back bar transaction")
+		}
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("error committing bar transaction to database")
+		return err
+	}
+
+	return nil
+}
+
+// Bars returns the bars stored in tbl for the requested asset, period, and
+// range of period start dates
+func Bars(ctx context.Context, dbConn *pgxpool.Conn, tbl string, query *BarQuery) ([]*Bar, error) {
+	sql := fmt.Sprintf(`SELECT
+		ticker,
+		composite_figi,
+		period,
+		period_start,
+		first_session,
+		last_session,
+		open,
+		high,
+		low,
+		close,
+		volume,
+		dividend,
+		split_factor AS split,
+		currency,
+		num_sessions,
+		complete
+	FROM %s
+	WHERE composite_figi=$1 AND period=$2 AND period_start BETWEEN $3 AND $4
+	ORDER BY period_start`, tbl)
+
+	rows, err := dbConn.Query(ctx, sql, query.CompositeFigi, query.Period, query.Start, query.End)
+	if err != nil {
+		log.Error().Err(err).Str("SQL", sql).Msg("querying bars failed")
+		return nil, err
+	}
+
+	bars := make([]*Bar, 0)
+	if err := pgxscan.ScanAll(&bars, rows); err != nil {
+		log.Error().Err(err).Msg("error when scanning values into bars")
+		return nil, err
+	}
+
+	return bars, nil
+}
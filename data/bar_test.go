@@ -0,0 +1,98 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/penny-vault/pvdata/data"
+)
+
+var _ = Describe("ResampleBars", func() {
+	quote := func(date string, open, high, low, close, volume, split float64) *data.Eod {
+		day, err := data.ParseMarketDate(date)
+		Expect(err).To(BeNil())
+		return &data.Eod{
+			Date:          day.Time(),
+			Ticker:        "VTI",
+			CompositeFigi: "BBG000BDTBL9",
+			Open:          open,
+			High:          high,
+			Low:           low,
+			Close:         close,
+			Volume:        volume,
+			Split:         split,
+			Currency:      "USD",
+		}
+	}
+
+	var quotes []*data.Eod
+
+	BeforeEach(func() {
+		quotes = []*data.Eod{
+			quote("2024-12-30", 10, 11, 9, 10.5, 100, 1),
+			quote("2024-12-31", 10.5, 12, 10, 11, 100, 1),
+			// 2025-01-01 is a holiday
+			quote("2025-01-02", 11, 11.5, 10.5, 11.2, 100, 1),
+			quote("2025-01-03", 11.2, 11.4, 11, 11.3, 100, 1),
+			quote("2025-01-06", 100, 110, 95, 105, 1000, 1),
+			quote("2025-01-07", 105, 108, 100, 106, 1000, 1),
+			quote("2025-01-08", 53, 55, 52, 54, 2000, 2),
+		}
+	})
+
+	It("keeps a week that spans new year in a single bar", func() {
+		bars := data.ResampleBars(quotes, data.BarWeekly, data.NewMarketDate(2025, time.January, 8))
+		Expect(bars).To(HaveLen(2))
+
+		Expect(bars[0].PeriodStart).To(Equal(data.NewMarketDate(2024, time.December, 30).Time()))
+		Expect(bars[0].LastSession).To(Equal(data.NewMarketDate(2025, time.January, 3).Time()))
+		Expect(bars[0].NumSessions).To(Equal(4))
+		Expect(bars[0].Open).To(Equal(10.0))
+		Expect(bars[0].High).To(Equal(12.0))
+		Expect(bars[0].Low).To(Equal(9.0))
+		Expect(bars[0].Close).To(Equal(11.3))
+		Expect(bars[0].Volume).To(Equal(400.0))
+		Expect(bars[0].Complete).To(BeTrue())
+	})
+
+	It("adjusts sessions before a split within the period", func() {
+		bars := data.ResampleBars(quotes, data.BarWeekly, data.NewMarketDate(2025, time.January, 8))
+		bar := bars[1]
+
+		Expect(bar.Open).To(Equal(50.0))
+		Expect(bar.High).To(Equal(55.0))
+		Expect(bar.Low).To(Equal(47.5))
+		Expect(bar.Close).To(Equal(54.0))
+		Expect(bar.Volume).To(Equal(6000.0))
+		Expect(bar.Split).To(Equal(2.0))
+		Expect(bar.Complete).To(BeFalse())
+	})
+
+	It("splits months at the calendar boundary", func() {
+		bars := data.ResampleBars(quotes, data.BarMonthly, data.NewMarketDate(2025, time.February, 1))
+		Expect(bars).To(HaveLen(2))
+
+		Expect(bars[0].PeriodStart).To(Equal(data.NewMarketDate(2024, time.December, 1).Time()))
+		Expect(bars[0].NumSessions).To(Equal(2))
+		Expect(bars[0].Close).To(Equal(11.0))
+		Expect(bars[1].PeriodStart).To(Equal(data.NewMarketDate(2025, time.January, 1).Time()))
+		Expect(bars[1].FirstSession).To(Equal(data.NewMarketDate(2025, time.January, 2).Time()))
+		Expect(bars[1].Complete).To(BeTrue())
+	})
+})
@@ -0,0 +1,87 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// CloseDiscrepancy flags a stored close price that differs from the official
+// close, i.e. the closing auction print, of the asset's primary exchange
+type CloseDiscrepancy struct {
+	Ticker        string    `json:"ticker"`
+	CompositeFigi string    `json:"compositeFigi"`
+	EventDate     time.Time `json:"eventDate"`
+	Exchange      Exchange  `json:"exchange"`
+
+	StoredClose   float64 `json:"storedClose"`
+	OfficialClose float64 `json:"officialClose"`
+
+	// Difference is the relative difference of the stored close from the
+	// official close, e.g. 0.001 if the stored close is 0.1% higher
+	Difference float64 `json:"difference"`
+
+	// Source is the provider of the official close
+	Source string `json:"source"`
+}
+
+func (discrepancy *CloseDiscrepancy) SaveDB(ctx context.Context, tbl string, dbConn *pgxpool.Conn) error {
+	tx, err := beginSave(ctx, dbConn)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := tx.Commit(ctx); err != nil {
+			log.Error().Err(err).Msg("error committing close discrepancy transaction to database")
+		}
+	}()
+
+	sql := fmt.Sprintf(`INSERT INTO %[1]s (
+		"ticker",
+		"composite_figi",
+		"event_date",
+		"exchange",
+		"stored_close",
+		"official_close",
+		"difference",
+		"source"
+	) VALUES (
+		$1, $2, $3, $4, $5, $6, $7, $8
+	) ON CONFLICT ON CONSTRAINT %[1]s_pkey DO UPDATE SET
+		ticker = EXCLUDED.ticker,
+		exchange = EXCLUDED.exchange,
+		stored_close = EXCLUDED.stored_close,
+		official_close = EXCLUDED.official_close,
+		difference = EXCLUDED.difference`, tbl)
+
+	_, err = tx.Exec(ctx, sql, discrepancy.Ticker, discrepancy.CompositeFigi, discrepancy.EventDate,
+		string(discrepancy.Exchange), discrepancy.StoredClose, discrepancy.OfficialClose, discrepancy.Difference,
+		discrepancy.Source)
+
+	if err != nil {
+		log.Error().Err(err).Str("SQL", sql).Msg("save close discrepancy to DB failed")
+		if err2 := tx.Rollback(ctx); err2 != nil {
+			log.Error().Err(err).Msg("error rollingback tx")
+		}
+	}
+
+	return err
+}
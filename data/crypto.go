@@ -0,0 +1,89 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// CryptoQuote is an OHLCV candle for a cryptocurrency market on a single exchange
+type CryptoQuote struct {
+	// Market is the base and quote currency, e.g. BTC-USD
+	Market   string `json:"market"`
+	Exchange string `json:"exchange"`
+
+	// Interval is the candle duration, e.g. 1h or 1d
+	Interval  string    `json:"interval"`
+	EventTime time.Time `json:"eventTime"`
+
+	Open   float64 `json:"open"`
+	High   float64 `json:"high"`
+	Low    float64 `json:"low"`
+	Close  float64 `json:"close"`
+	Volume float64 `json:"volume"`
+}
+
+func (quote *CryptoQuote) SaveDB(ctx context.Context, tbl string, dbConn *pgxpool.Conn) error {
+	if quote.Market == "" {
+		return nil
+	}
+
+	tx, err := beginSave(ctx, dbConn)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := tx.Commit(ctx); err != nil {
+			log.Error().Err(err).Msg("error committing crypto quote transaction to database")
+		}
+	}()
+
+	sql := fmt.Sprintf(`INSERT INTO %[1]s (
+		"market",
+		"exchange",
+		"interval",
+		"event_time",
+		"open",
+		"high",
+		"low",
+		"close",
+		"volume"
+	) VALUES (
+		$1, $2, $3, $4, $5, $6, $7, $8, $9
+	) ON CONFLICT ON CONSTRAINT %[1]s_pkey DO UPDATE SET
+		open = EXCLUDED.open,
+		high = EXCLUDED.high,
+		low = EXCLUDED.low,
+		close = EXCLUDED.close,
+		volume = EXCLUDED.volume`, tbl)
+
+	_, err = tx.Exec(ctx, sql, quote.Market, quote.Exchange, quote.Interval, quote.EventTime, quote.Open,
+		quote.High, quote.Low, quote.Close, quote.Volume)
+
+	if err != nil {
+		log.Error().Err(err).Str("SQL", sql).Msg("save crypto quote to DB failed")
+		if err2 := tx.Rollback(ctx); err2 != nil {
+			log.Error().Err(err).Msg("error rollingback tx")
+		}
+	}
+
+	return err
+}
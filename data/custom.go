@@ -36,7 +36,7 @@ func (custom *Custom) SaveDB(ctx context.Context, tbl string, dbConn *pgxpool.Co
 		return nil
 	}
 
-	tx, err := dbConn.Begin(ctx)
+	tx, err := beginSave(ctx, dbConn)
 	if err != nil {
 		return err
 	}
@@ -16,17 +16,52 @@ package data
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+var primaryKeyRegexp = regexp.MustCompile(`PRIMARY KEY \(([^)]+)\)`)
+
 type StatusType int
 
 const (
 	StatusUnknown StatusType = iota
 	RunFailed
 	RunSuccess
+	RunSkipped
+	RunCanceled
+)
+
+func (status StatusType) String() string {
+	switch status {
+	case RunFailed:
+		return "failed"
+	case RunSuccess:
+		return "success"
+	case RunSkipped:
+		return "skipped"
+	case RunCanceled:
+		return "canceled"
+	default:
+		return "unknown"
+	}
+}
+
+// NoDataType classifies runs that finished without producing observations
+type NoDataType int
+
+const (
+	// NoDataNotApplicable is used for runs that produced observations or failed
+	NoDataNotApplicable NoDataType = iota
+
+	// NoDataExpected runs covered a day the market was closed
+	NoDataExpected
+
+	// NoDataUnexpected runs covered a trading day and may indicate a broken provider
+	NoDataUnexpected
 )
 
 type RunSummary struct {
@@ -34,44 +69,191 @@ type RunSummary struct {
 	EndTime          time.Time
 	NumObservations  int
 	Status           StatusType
+	NoData           NoDataType
 	SubscriptionID   uuid.UUID
 	SubscriptionName string
+
+	// Counts breaks the run's observations down by kind, e.g. the number of
+	// created, changed, and delisted assets of an asset sync
+	Counts map[string]int
+
+	// SchemaDrift lists provider responses whose format did not match the
+	// format the provider expects
+	SchemaDrift []*SchemaDrift
 }
 
 type Observation struct {
 	AssetObject       *Asset
+	Bar               *Bar
+	CloseDiscrepancy  *CloseDiscrepancy
+	CryptoQuote       *CryptoQuote
 	CustomObject      *Custom
+	DividendEvent     *DividendEvent
+	Earnings          *Earnings
 	EconomicIndicator *EconomicIndicator
 	EodQuote          *Eod
+	ETFHolding        *ETFHolding
 	Fundamental       *Fundamental
+	FXRate            *FXRate
+	ListingEvent      *ListingEvent
 	MarketHoliday     *MarketHoliday
 	Metric            *Metric
+	News              *News
+	Peers             *Peers
 	Rating            *AnalystRating
 
 	ObservationDate  time.Time
 	SubscriptionID   uuid.UUID
 	SubscriptionName string
+
+	// RunID, Provider, and FetchedAt record where the observation came from and
+	// are persisted with each row
+	RunID     uuid.UUID
+	Provider  string
+	FetchedAt time.Time
+
+	// JournalSeq is the observation's position in the write-ahead journal; 0 if not journaled
+	JournalSeq uint64 `json:"-"`
 }
 
+// DataType returns the key of the data type carried by the observation
+func (obs *Observation) DataType() string {
+	switch {
+	case obs.AssetObject != nil:
+		return AssetKey
+	case obs.Bar != nil:
+		return BarKey
+	case obs.CloseDiscrepancy != nil:
+		return CloseDiscrepancyKey
+	case obs.CryptoQuote != nil:
+		return CryptoQuoteKey
+	case obs.CustomObject != nil:
+		return CustomKey
+	case obs.DividendEvent != nil:
+		return DividendEventKey
+	case obs.Earnings != nil:
+		return EarningsKey
+	case obs.EconomicIndicator != nil:
+		return EconomicIndicatorKey
+	case obs.EodQuote != nil:
+		return EODKey
+	case obs.ETFHolding != nil:
+		return ETFHoldingKey
+	case obs.Fundamental != nil:
+		return FundamentalsKey
+	case obs.FXRate != nil:
+		return FXRateKey
+	case obs.ListingEvent != nil:
+		return ListingEventKey
+	case obs.MarketHoliday != nil:
+		return MarketHolidaysKey
+	case obs.Metric != nil:
+		return MetricKey
+	case obs.News != nil:
+		return NewsKey
+	case obs.Peers != nil:
+		return PeersKey
+	case obs.Rating != nil:
+		return RatingKey
+	default:
+		return ""
+	}
+}
+
+// PartitionInterval is the range of dates covered by each partition of a
+// partitioned table
+type PartitionInterval string
+
+const (
+	PartitionYearly  PartitionInterval = "year"
+	PartitionMonthly PartitionInterval = "month"
+)
+
 type DataType struct {
 	Name          string
 	Schema        string
 	Migrations    []string
 	Version       int
 	IsPartitioned bool
+
+	// PartitionInterval sets the size of the partitions of partitioned tables
+	PartitionInterval PartitionInterval
+
+	// DateColumn is the column that dates each row; rows older than a
+	// subscription's retention period are pruned by it. Data types without a
+	// date column are never pruned.
+	DateColumn string
+
+	// Versioned data types keep the previous versions of changed rows in a
+	// history table when `library.versioning` is enabled so that the table
+	// can be read as of a past time
+	Versioned bool
 }
 
 const (
 	AssetKey             = "asset-description"
+	BarKey               = "bar"
+	CloseDiscrepancyKey  = "close-discrepancy"
+	CryptoQuoteKey       = "crypto-quote"
 	CustomKey            = "custom"
+	DividendEventKey     = "dividend-event"
+	EarningsKey          = "earnings"
 	EconomicIndicatorKey = "economic-indicator"
 	EODKey               = "eod"
+	ETFHoldingKey        = "etf-holding"
 	FundamentalsKey      = "fundamental"
+	FXRateKey            = "fx-rate"
+	ListingEventKey      = "listing-event"
 	MarketHolidaysKey    = "market-holidays"
 	MetricKey            = "metric"
+	NewsKey              = "news"
+	PeersKey             = "peers"
 	RatingKey            = "rating"
 )
 
+// lineageMigration adds the columns that trace each row to the subscription, provider, and run
+// that wrote it. The columns are filled in by the pvdata_lineage trigger.
+const lineageMigration = `ALTER TABLE %[1]s
+ADD COLUMN IF NOT EXISTS subscription_id UUID,
+ADD COLUMN IF NOT EXISTS provider        TEXT,
+ADD COLUMN IF NOT EXISTS run_id          UUID,
+ADD COLUMN IF NOT EXISTS fetched_at      TIMESTAMPTZ;
+
+DROP TRIGGER IF EXISTS %[1]s_lineage ON %[1]s;
+CREATE TRIGGER %[1]s_lineage
+BEFORE INSERT OR UPDATE ON %[1]s
+FOR EACH ROW
+EXECUTE PROCEDURE pvdata_lineage();`
+
+// versioningMigration adds the valid_from column and the history table of
+// system-versioned data types. The history table is created even if versioning
+// is disabled so that it can be turned on at any time.
+const versioningMigration = `ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS valid_from TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS %[1]s_history (LIKE %[1]s);
+ALTER TABLE %[1]s_history ADD COLUMN IF NOT EXISTS valid_to TIMESTAMPTZ NOT NULL;
+CREATE INDEX IF NOT EXISTS %[1]s_history_valid_idx ON %[1]s_history(valid_from, valid_to);`
+
+// eodCorrectionsMigration counts revisions of saved quotes for the data-quality
+// scorecard. The column is maintained by the pvdata_eod_corrections trigger.
+const eodCorrectionsMigration = `ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS num_corrections INTEGER NOT NULL DEFAULT 0;
+
+DROP TRIGGER IF EXISTS %[1]s_corrections ON %[1]s;
+CREATE TRIGGER %[1]s_corrections
+BEFORE UPDATE ON %[1]s
+FOR EACH ROW
+EXECUTE PROCEDURE pvdata_eod_corrections();`
+
+const (
+	versioningEnable = `DROP TRIGGER IF EXISTS %[1]s_versioning ON %[1]s;
+CREATE TRIGGER %[1]s_versioning
+BEFORE INSERT OR UPDATE OR DELETE ON %[1]s
+FOR EACH ROW
+EXECUTE PROCEDURE pvdata_versioning();`
+
+	versioningDisable = `DROP TRIGGER IF EXISTS %[1]s_versioning ON %[1]s;`
+)
+
 var DataTypes = map[string]*DataType{
 	AssetKey: {
 		Name: AssetKey,
@@ -94,6 +276,7 @@ isins text[],
 other_identifiers JSONB,
 similar_tickers TEXT[],
 tags TEXT[],
+price_currency CHARACTER(3) DEFAULT 'USD',
 listed timestamp,
 delisted timestamp,
 last_updated timestamp,
@@ -111,10 +294,88 @@ ADD COLUMN search tsvector
 ) STORED;
 
 CREATE INDEX %[1]s_search_idx ON %[1]s USING GIN (search);`,
-		Migrations:    []string{},
-		Version:       0,
+		Migrations: []string{
+			`ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS price_currency CHARACTER(3) DEFAULT 'USD';`,
+			lineageMigration,
+			versioningMigration,
+			`CREATE INDEX IF NOT EXISTS %[1]s_ticker_trgm_idx ON %[1]s USING GIN (ticker gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS %[1]s_name_trgm_idx ON %[1]s USING GIN (name gin_trgm_ops);`,
+			assetEventsTrigger,
+		},
+		Version:       5,
+		IsPartitioned: false,
+		Versioned:     true,
+	},
+	BarKey: {
+		Name: BarKey,
+		Schema: `CREATE TABLE %[1]s (
+ticker         CHARACTER VARYING(10) NOT NULL,
+composite_figi CHARACTER(12)         NOT NULL,
+period         TEXT                  NOT NULL,
+period_start   DATE                  NOT NULL,
+first_session  DATE                  NOT NULL,
+last_session   DATE                  NOT NULL,
+open           NUMERIC(12, 4)        NOT NULL DEFAULT 0.0,
+high           NUMERIC(12, 4)        NOT NULL DEFAULT 0.0,
+low            NUMERIC(12, 4)        NOT NULL DEFAULT 0.0,
+close          NUMERIC(12, 4)        NOT NULL DEFAULT 0.0,
+volume         BIGINT                NOT NULL DEFAULT 0,
+dividend       NUMERIC(12, 4)        NOT NULL DEFAULT 0.0,
+split_factor   NUMERIC(12, 6)        NOT NULL DEFAULT 1.0,
+currency       CHARACTER(3)          NOT NULL DEFAULT 'USD',
+num_sessions   INTEGER               NOT NULL DEFAULT 0,
+complete       BOOLEAN               NOT NULL DEFAULT false,
+PRIMARY KEY (composite_figi, period, period_start)
+);
+
+CREATE INDEX %[1]s_period_start_idx ON %[1]s(period, period_start);`,
+		Migrations:    []string{lineageMigration},
+		Version:       1,
+		DateColumn:    "period_start",
 		IsPartitioned: false,
 	},
+	CloseDiscrepancyKey: {
+		Name: CloseDiscrepancyKey,
+		Schema: `CREATE TABLE %[1]s (
+ticker         CHARACTER VARYING(10) NOT NULL,
+composite_figi CHARACTER(12)         NOT NULL,
+event_date     DATE                  NOT NULL,
+exchange       TEXT                  NOT NULL DEFAULT '',
+stored_close   DOUBLE PRECISION      NOT NULL,
+official_close DOUBLE PRECISION      NOT NULL,
+difference     DOUBLE PRECISION      NOT NULL,
+source         TEXT                  NOT NULL,
+PRIMARY KEY (composite_figi, event_date, source)
+);
+
+CREATE INDEX %[1]s_event_date_idx ON %[1]s(event_date, exchange);`,
+		Migrations:    []string{lineageMigration},
+		Version:       1,
+		DateColumn:    "event_date",
+		IsPartitioned: false,
+	},
+	CryptoQuoteKey: {
+		Name: CryptoQuoteKey,
+		Schema: `CREATE TABLE %[1]s (
+market     TEXT             NOT NULL,
+exchange   TEXT             NOT NULL,
+interval   TEXT             NOT NULL,
+event_time TIMESTAMPTZ      NOT NULL,
+open       DOUBLE PRECISION NOT NULL,
+high       DOUBLE PRECISION NOT NULL,
+low        DOUBLE PRECISION NOT NULL,
+close      DOUBLE PRECISION NOT NULL,
+volume     DOUBLE PRECISION NOT NULL,
+PRIMARY KEY (market, exchange, interval, event_time)
+) PARTITION BY RANGE (event_time);
+
+CREATE INDEX %[1]s_event_time_idx ON %[1]s(event_time);`,
+		Migrations:        []string{lineageMigration},
+		Version:           1,
+		DateColumn:        "event_time",
+		IsPartitioned:     true,
+		PartitionInterval: PartitionMonthly,
+	},
 	CustomKey: {
 		Name: CustomKey,
 		Schema: `CREATE TABLE %[1]s (
@@ -127,8 +388,54 @@ CREATE INDEX %[1]s_search_idx ON %[1]s USING GIN (search);`,
 );
 
 CREATE INDEX %[1]s_key_ticker_event_date_idx ON %[1]s(key, ticker, event_date DESC)`,
-		Migrations:    []string{},
-		Version:       0,
+		Migrations:    []string{lineageMigration},
+		Version:       1,
+		DateColumn:    "event_date",
+		IsPartitioned: false,
+	},
+	DividendEventKey: {
+		Name: DividendEventKey,
+		Schema: `CREATE TABLE %[1]s (
+ticker           CHARACTER VARYING(10) NOT NULL,
+composite_figi   CHARACTER(12)         NOT NULL,
+ex_date          DATE                  NOT NULL,
+declaration_date DATE,
+record_date      DATE,
+pay_date         DATE,
+cash_amount      NUMERIC(18, 8)        NOT NULL,
+currency         CHARACTER(3)          NOT NULL DEFAULT 'USD',
+frequency        INT                   NOT NULL DEFAULT 0,
+dividend_type    TEXT                  NOT NULL DEFAULT 'CD',
+PRIMARY KEY (composite_figi, ex_date, dividend_type)
+);
+
+CREATE INDEX %[1]s_ex_date_idx ON %[1]s(ex_date);
+CREATE INDEX %[1]s_ticker_idx ON %[1]s(ticker, ex_date DESC);`,
+		Migrations:    []string{lineageMigration},
+		Version:       1,
+		DateColumn:    "ex_date",
+		IsPartitioned: false,
+	},
+	EarningsKey: {
+		Name: EarningsKey,
+		Schema: `CREATE TABLE %[1]s (
+ticker           CHARACTER VARYING(10) NOT NULL,
+composite_figi   CHARACTER(12)         NOT NULL,
+event_date       DATE                  NOT NULL,
+fiscal_year      INT                   NOT NULL DEFAULT 0,
+fiscal_quarter   INT                   NOT NULL DEFAULT 0,
+hour             TEXT                  NOT NULL DEFAULT '',
+eps_estimate     REAL,
+eps_actual       REAL,
+revenue_estimate DOUBLE PRECISION,
+revenue_actual   DOUBLE PRECISION,
+PRIMARY KEY (composite_figi, event_date)
+);
+
+CREATE INDEX %[1]s_event_date_idx ON %[1]s(event_date);`,
+		Migrations:    []string{lineageMigration},
+		Version:       1,
+		DateColumn:    "event_date",
 		IsPartitioned: false,
 	},
 	EconomicIndicatorKey: {
@@ -139,8 +446,9 @@ CREATE INDEX %[1]s_key_ticker_event_date_idx ON %[1]s(key, ticker, event_date DE
 			value      REAL NOT NULL,
 			PRIMARY KEY (series, event_date)
 		);`,
-		Migrations:    []string{},
-		Version:       0,
+		Migrations:    []string{lineageMigration},
+		Version:       1,
+		DateColumn:    "event_date",
 		IsPartitioned: false,
 	},
 	EODKey: {
@@ -157,6 +465,9 @@ adj_close      NUMERIC(12, 4)        NOT NULL DEFAULT 0.0,
 volume         BIGINT                NOT NULL DEFAULT 0.0,
 dividend       NUMERIC(12, 4)        NOT NULL DEFAULT 0.0,
 split_factor   NUMERIC(9, 6)         NOT NULL DEFAULT 1.0,
+currency       CHARACTER(3)          NOT NULL DEFAULT 'USD',
+pre_market_open   NUMERIC(12, 4),
+after_hours_close NUMERIC(12, 4),
 PRIMARY KEY (composite_figi, event_date)
 ) PARTITION BY RANGE (event_date);
 
@@ -168,9 +479,35 @@ BEFORE INSERT ON %[1]s
 FOR EACH ROW
 WHEN (NEW.adj_close IS NULL AND NEW.close IS NOT NULL)
 EXECUTE PROCEDURE adj_close_default();`,
-		Migrations:    []string{},
-		Version:       0,
-		IsPartitioned: true,
+		Migrations: []string{
+			`ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS currency CHARACTER(3) NOT NULL DEFAULT 'USD';`,
+			`ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS pre_market_open NUMERIC(12, 4), ADD COLUMN IF NOT EXISTS after_hours_close NUMERIC(12, 4);`,
+			lineageMigration,
+			eodCorrectionsMigration,
+		},
+		Version:           4,
+		DateColumn:        "event_date",
+		IsPartitioned:     true,
+		PartitionInterval: PartitionYearly,
+	},
+	ETFHoldingKey: {
+		Name: ETFHoldingKey,
+		Schema: `CREATE TABLE %[1]s (
+ticker             CHARACTER VARYING(10) NOT NULL,
+composite_figi     CHARACTER(12)         NOT NULL,
+event_date         DATE                  NOT NULL,
+constituent_ticker CHARACTER VARYING(10) NOT NULL DEFAULT '',
+constituent_figi   CHARACTER(12)         NOT NULL,
+weight             DOUBLE PRECISION      NOT NULL DEFAULT 0.0,
+shares             DOUBLE PRECISION      NOT NULL DEFAULT 0.0,
+PRIMARY KEY (composite_figi, constituent_figi, event_date)
+);
+
+CREATE INDEX %[1]s_constituent_figi_idx ON %[1]s(constituent_figi, event_date DESC);`,
+		Migrations:    []string{lineageMigration},
+		Version:       1,
+		DateColumn:    "event_date",
+		IsPartitioned: false,
 	},
 	FundamentalsKey: {
 		Name: FundamentalsKey,
@@ -286,8 +623,43 @@ EXECUTE PROCEDURE adj_close_default();`,
 
 CREATE INDEX %[1]s_ticker_idx ON %[1]s(ticker, dimension);
 CREATE INDEX %[1]s_event_date_idx ON %[1]s(event_date, dimension);`,
-		Migrations:    []string{},
-		Version:       0,
+		Migrations:    []string{lineageMigration, versioningMigration},
+		Version:       2,
+		DateColumn:    "event_date",
+		IsPartitioned: false,
+		Versioned:     true,
+	},
+	FXRateKey: {
+		Name: FXRateKey,
+		Schema: `CREATE TABLE %[1]s (
+currency   CHARACTER(3)   NOT NULL,
+event_date DATE           NOT NULL,
+usd_rate   NUMERIC(18, 8) NOT NULL,
+PRIMARY KEY (currency, event_date)
+);`,
+		Migrations:    []string{lineageMigration},
+		Version:       1,
+		DateColumn:    "event_date",
+		IsPartitioned: false,
+	},
+	ListingEventKey: {
+		Name: ListingEventKey,
+		Schema: `CREATE TABLE %[1]s (
+ticker     CHARACTER VARYING(10) NOT NULL,
+name       TEXT                  NOT NULL DEFAULT '',
+event_date DATE                  NOT NULL,
+exchange   TEXT                  NOT NULL DEFAULT '',
+price_low  NUMERIC(18, 4),
+price_high NUMERIC(18, 4),
+num_shares BIGINT                NOT NULL DEFAULT 0,
+status     TEXT                  NOT NULL,
+PRIMARY KEY (ticker, event_date)
+);
+
+CREATE INDEX %[1]s_event_date_idx ON %[1]s(event_date);`,
+		Migrations:    []string{lineageMigration},
+		Version:       1,
+		DateColumn:    "event_date",
 		IsPartitioned: false,
 	},
 	MarketHolidaysKey: {
@@ -300,8 +672,9 @@ early_close BOOLEAN NOT NULL DEFAULT false,
 close_time TIME NOT NULL DEFAULT '16:00:00',
 PRIMARY KEY (event_date, market)
 );`,
-		Migrations:    []string{},
-		Version:       0,
+		Migrations:    []string{lineageMigration},
+		Version:       1,
+		DateColumn:    "event_date",
 		IsPartitioned: false,
 	},
 	MetricKey: {
@@ -324,9 +697,47 @@ PRIMARY KEY (composite_figi, event_date)
 
 CREATE INDEX %[1]s_event_date_idx ON %[1]s(event_date);
 CREATE INDEX %[1]s_ticker_idx ON %[1]s(ticker);`,
-		Migrations:    []string{},
-		Version:       0,
-		IsPartitioned: true,
+		Migrations:        []string{lineageMigration},
+		Version:           1,
+		DateColumn:        "event_date",
+		IsPartitioned:     true,
+		PartitionInterval: PartitionYearly,
+	},
+	NewsKey: {
+		Name: NewsKey,
+		Schema: `CREATE TABLE %[1]s (
+ticker         CHARACTER VARYING(10) NOT NULL,
+composite_figi CHARACTER(12)         NOT NULL,
+published_at   TIMESTAMPTZ           NOT NULL,
+news_id        TEXT                  NOT NULL,
+source         TEXT                  NOT NULL DEFAULT '',
+category       TEXT                  NOT NULL DEFAULT '',
+headline       TEXT                  NOT NULL DEFAULT '',
+summary        TEXT                  NOT NULL DEFAULT '',
+url            TEXT                  NOT NULL DEFAULT '',
+sentiment      REAL,
+PRIMARY KEY (composite_figi, news_id)
+);
+
+CREATE INDEX %[1]s_published_at_idx ON %[1]s(composite_figi, published_at DESC);`,
+		Migrations:    []string{lineageMigration},
+		Version:       1,
+		DateColumn:    "published_at",
+		IsPartitioned: false,
+	},
+	PeersKey: {
+		Name: PeersKey,
+		Schema: `CREATE TABLE %[1]s (
+ticker         CHARACTER VARYING(10) NOT NULL,
+composite_figi CHARACTER(12)         NOT NULL,
+event_date     DATE                  NOT NULL,
+peers          TEXT[]                NOT NULL,
+PRIMARY KEY (composite_figi, event_date)
+);`,
+		Migrations:    []string{lineageMigration},
+		Version:       1,
+		DateColumn:    "event_date",
+		IsPartitioned: false,
 	},
 	RatingKey: {
 		Name: RatingKey,
@@ -340,8 +751,9 @@ CREATE INDEX %[1]s_ticker_idx ON %[1]s(ticker);`,
 );
 
 CREATE INDEX %[1]s_ticker_event_date_idx ON %[1]s(ticker, event_date DESC)`,
-		Migrations:    []string{},
-		Version:       0,
+		Migrations:    []string{lineageMigration},
+		Version:       1,
+		DateColumn:    "event_date",
 		IsPartitioned: false,
 	},
 }
@@ -350,3 +762,40 @@ CREATE INDEX %[1]s_ticker_event_date_idx ON %[1]s(ticker, event_date DESC)`,
 func (dt *DataType) ExpandedSchema(tableName string) string {
 	return fmt.Sprintf(dt.Schema, tableName)
 }
+
+// ExpandedMigrations returns the migrations of the data type with the table name filled in. Migrations
+// must be idempotent as they are applied to tables created with both old and new versions of the schema
+func (dt *DataType) ExpandedMigrations(tableName string) []string {
+	migrations := make([]string, len(dt.Migrations))
+	for idx, migration := range dt.Migrations {
+		migrations[idx] = fmt.Sprintf(migration, tableName)
+	}
+	return migrations
+}
+
+// ExpandedVersioning returns the SQL that turns system-versioning of the table
+// on or off; it is empty for data types that are not versioned
+func (dt *DataType) ExpandedVersioning(tableName string, enabled bool) string {
+	switch {
+	case !dt.Versioned:
+		return ""
+	case enabled:
+		return fmt.Sprintf(versioningEnable, tableName)
+	default:
+		return fmt.Sprintf(versioningDisable, tableName)
+	}
+}
+
+// KeyColumns returns the columns of the data type's primary key
+func (dt *DataType) KeyColumns() []string {
+	match := primaryKeyRegexp.FindStringSubmatch(dt.Schema)
+	if match == nil {
+		return nil
+	}
+
+	columns := strings.Split(match[1], ",")
+	for idx, column := range columns {
+		columns[idx] = strings.TrimSpace(column)
+	}
+	return columns
+}
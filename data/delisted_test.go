@@ -0,0 +1,143 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/db/dbtest"
+)
+
+var _ = Describe("Delisted assets", func() {
+	delistedOn := data.NewMarketDate(2024, time.January, 10)
+
+	DescribeTable("start fetching quotes",
+		func(lastQuote, expected data.MarketDate) {
+			asset := &data.DelistedAsset{Delisted: delistedOn, LastQuote: lastQuote}
+			Expect(asset.QuoteStart(14, 30)).To(Equal(expected))
+		},
+		Entry("lookback days before delisting without stored quotes",
+			data.MarketDate{}, data.NewMarketDate(2023, time.December, 27)),
+		Entry("the day after the last stored quote",
+			data.NewMarketDate(2024, time.January, 5), data.NewMarketDate(2024, time.January, 6)),
+		Entry("no earlier than the maximum number of days before delisting",
+			data.NewMarketDate(2023, time.June, 30), data.NewMarketDate(2023, time.December, 11)),
+	)
+
+	Context("in the library", func() {
+		var (
+			ctx        context.Context
+			conn       *pgxpool.Conn
+			assetTable string
+			eodTable   string
+		)
+
+		exec := func(sql string, args ...any) {
+			_, err := conn.Exec(ctx, sql, args...)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		BeforeEach(func() {
+			ctx = context.Background()
+
+			myLibrary, err := dbtest.Library(ctx)
+			if errors.Is(err, dbtest.ErrNotConfigured) {
+				Skip("no test database configured")
+			}
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(myLibrary.Close)
+
+			conn, err = myLibrary.Pool.Acquire(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(conn.Release)
+
+			suffix := strings.ReplaceAll(uuid.NewString(), "-", "")[:8]
+			assetTable = "delisted_test_assets_" + suffix
+			eodTable = "delisted_test_eod_" + suffix
+
+			exec(data.DataTypes[data.AssetKey].ExpandedSchema(assetTable))
+			for _, sql := range data.DataTypes[data.AssetKey].ExpandedMigrations(assetTable) {
+				exec(sql)
+			}
+
+			exec(data.DataTypes[data.EODKey].ExpandedSchema(eodTable))
+			exec(fmt.Sprintf(`CREATE TABLE %s PARTITION OF %s DEFAULT`, pgx.Identifier{eodTable + "_default"}.Sanitize(),
+				pgx.Identifier{eodTable}.Sanitize()))
+
+			DeferCleanup(func() {
+				for _, tbl := range []string{assetTable, eodTable} {
+					_, err := conn.Exec(context.Background(), fmt.Sprintf(`DROP TABLE IF EXISTS %s CASCADE`, pgx.Identifier{tbl}.Sanitize()))
+					Expect(err).NotTo(HaveOccurred())
+				}
+			})
+
+			assets := []struct {
+				ticker   string
+				figi     string
+				active   bool
+				delisted any
+			}{
+				{"LIVE", "BBG000LIVE01", true, nil},
+				{"OLD", "BBG000OLD001", false, "2023-06-01"},
+				{"PART", "BBG000PART01", false, "2024-01-10"},
+				{"DONE", "BBG000DONE01", false, "2024-01-10"},
+				{"NONE", "BBG000NONE01", false, "2024-01-10"},
+			}
+
+			for _, asset := range assets {
+				exec(fmt.Sprintf(`INSERT INTO %s (ticker, composite_figi, active, delisted) VALUES ($1, $2, $3, $4::timestamp)`,
+					pgx.Identifier{assetTable}.Sanitize()), asset.ticker, asset.figi, asset.active, asset.delisted)
+			}
+
+			quotes := map[string]string{
+				"BBG000LIVE01": "2024-01-10",
+				"BBG000PART01": "2024-01-08",
+				"BBG000DONE01": "2024-01-10",
+			}
+
+			for figi, day := range quotes {
+				exec(fmt.Sprintf(`INSERT INTO %s (ticker, composite_figi, event_date, close) VALUES ('X', $1, $2, 10)`,
+					pgx.Identifier{eodTable}.Sanitize()), figi, day)
+			}
+		})
+
+		It("returns assets whose quotes end before their delisting date", func() {
+			delisted, err := data.DelistedAssets(ctx, conn, data.NewMarketDate(2024, time.January, 1), eodTable, assetTable)
+			Expect(err).NotTo(HaveOccurred())
+
+			lastQuotes := make(map[string]data.MarketDate, len(delisted))
+			for _, asset := range delisted {
+				Expect(asset.Delisted).To(Equal(delistedOn))
+				lastQuotes[asset.Asset.Ticker] = asset.LastQuote
+			}
+
+			Expect(lastQuotes).To(Equal(map[string]data.MarketDate{
+				"PART": data.NewMarketDate(2024, time.January, 8),
+				"NONE": {},
+			}))
+		})
+	})
+})
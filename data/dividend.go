@@ -0,0 +1,112 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// DividendEvent is a dividend declared by a company. Unlike the dividend
+// column of EOD quotes, which is recorded on the ex-date, dividend events are
+// available as soon as the dividend is announced.
+type DividendEvent struct {
+	Ticker        string `json:"ticker"`
+	CompositeFigi string `json:"compositeFigi"`
+
+	// ExDate is the first day the stock trades without the dividend
+	ExDate time.Time `json:"exDate"`
+
+	// DeclarationDate, RecordDate, and PayDate are nil if the provider does
+	// not report them
+	DeclarationDate *time.Time `json:"declarationDate"`
+	RecordDate      *time.Time `json:"recordDate"`
+	PayDate         *time.Time `json:"payDate"`
+
+	CashAmount float64 `json:"cashAmount"`
+	Currency   string  `json:"currency"`
+
+	// Frequency is the number of times per year the dividend is paid; 0 for
+	// one-time dividends
+	Frequency int `json:"frequency"`
+
+	// DividendType distinguishes regular (CD) from special (SC) dividends and
+	// long-term (LT) and short-term (ST) capital gain distributions
+	DividendType string `json:"dividendType"`
+}
+
+func (dividend *DividendEvent) SaveDB(ctx context.Context, tbl string, dbConn *pgxpool.Conn) error {
+	if dividend.CompositeFigi == "" {
+		return nil
+	}
+
+	currency := dividend.Currency
+	if currency == "" {
+		currency = USD
+	}
+
+	dividendType := dividend.DividendType
+	if dividendType == "" {
+		dividendType = "CD"
+	}
+
+	tx, err := beginSave(ctx, dbConn)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := tx.Commit(ctx); err != nil {
+			log.Error().Err(err).Msg("error committing dividend transaction to database")
+		}
+	}()
+
+	sql := fmt.Sprintf(`INSERT INTO %[1]s (
+		"ticker",
+		"composite_figi",
+		"ex_date",
+		"declaration_date",
+		"record_date",
+		"pay_date",
+		"cash_amount",
+		"currency",
+		"frequency",
+		"dividend_type"
+	) VALUES (
+		$1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+	) ON CONFLICT ON CONSTRAINT %[1]s_pkey DO UPDATE SET
+		declaration_date = coalesce(EXCLUDED.declaration_date, %[1]s.declaration_date),
+		record_date = coalesce(EXCLUDED.record_date, %[1]s.record_date),
+		pay_date = coalesce(EXCLUDED.pay_date, %[1]s.pay_date),
+		cash_amount = EXCLUDED.cash_amount,
+		currency = EXCLUDED.currency,
+		frequency = EXCLUDED.frequency`, tbl)
+
+	_, err = tx.Exec(ctx, sql, dividend.Ticker, dividend.CompositeFigi, dividend.ExDate, dividend.DeclarationDate,
+		dividend.RecordDate, dividend.PayDate, dividend.CashAmount, currency, dividend.Frequency, dividendType)
+
+	if err != nil {
+		log.Error().Err(err).Str("SQL", sql).Msg("save dividend to DB failed")
+		if err2 := tx.Rollback(ctx); err2 != nil {
+			log.Error().Err(err).Msg("error rollingback tx")
+		}
+	}
+
+	return err
+}
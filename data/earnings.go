@@ -0,0 +1,97 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// Earnings is a scheduled or reported quarterly earnings announcement
+type Earnings struct {
+	Ticker        string    `json:"ticker"`
+	CompositeFigi string    `json:"compositeFigi"`
+	EventDate     time.Time `json:"eventDate"`
+
+	FiscalYear    int `json:"fiscalYear"`
+	FiscalQuarter int `json:"fiscalQuarter"`
+
+	// Hour the announcement is made: bmo (before market open), amc (after
+	// market close), dmh (during market hours), or empty if unknown
+	Hour string `json:"hour"`
+
+	// Actual values are nil until the company reports
+	EpsEstimate     *float64 `json:"epsEstimate"`
+	EpsActual       *float64 `json:"epsActual"`
+	RevenueEstimate *float64 `json:"revenueEstimate"`
+	RevenueActual   *float64 `json:"revenueActual"`
+}
+
+func (earnings *Earnings) SaveDB(ctx context.Context, tbl string, dbConn *pgxpool.Conn) error {
+	if earnings.CompositeFigi == "" {
+		return nil
+	}
+
+	tx, err := beginSave(ctx, dbConn)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := tx.Commit(ctx); err != nil {
+			log.Error().Err(err).Msg("error committing earnings transaction to database")
+		}
+	}()
+
+	sql := fmt.Sprintf(`INSERT INTO %[1]s (
+		"ticker",
+		"composite_figi",
+		"event_date",
+		"fiscal_year",
+		"fiscal_quarter",
+		"hour",
+		"eps_estimate",
+		"eps_actual",
+		"revenue_estimate",
+		"revenue_actual"
+	) VALUES (
+		$1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+	) ON CONFLICT ON CONSTRAINT %[1]s_pkey DO UPDATE SET
+		ticker = EXCLUDED.ticker,
+		fiscal_year = EXCLUDED.fiscal_year,
+		fiscal_quarter = EXCLUDED.fiscal_quarter,
+		hour = EXCLUDED.hour,
+		eps_estimate = EXCLUDED.eps_estimate,
+		eps_actual = EXCLUDED.eps_actual,
+		revenue_estimate = EXCLUDED.revenue_estimate,
+		revenue_actual = EXCLUDED.revenue_actual`, tbl)
+
+	_, err = tx.Exec(ctx, sql, earnings.Ticker, earnings.CompositeFigi, earnings.EventDate, earnings.FiscalYear,
+		earnings.FiscalQuarter, earnings.Hour, earnings.EpsEstimate, earnings.EpsActual, earnings.RevenueEstimate,
+		earnings.RevenueActual)
+
+	if err != nil {
+		log.Error().Err(err).Str("SQL", sql).Msg("save earnings to DB failed")
+		if err2 := tx.Rollback(ctx); err2 != nil {
+			log.Error().Err(err).Msg("error rollingback tx")
+		}
+	}
+
+	return err
+}
@@ -34,7 +34,7 @@ func (ind *EconomicIndicator) SaveDB(ctx context.Context, tbl string, dbConn *pg
 		return nil
 	}
 
-	tx, err := dbConn.Begin(ctx)
+	tx, err := beginSave(ctx, dbConn)
 	if err != nil {
 		return err
 	}
@@ -19,8 +19,10 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/georgysavva/scany/v2/pgxscan"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
 )
 
 type Eod struct {
@@ -34,20 +36,40 @@ type Eod struct {
 	Volume        float64   `json:"volume"`
 	Dividend      float64   `json:"divCash"`
 	Split         float64   `json:"splitFactor"`
+	Currency      string    `json:"currency"`
+
+	// PreMarketOpen and AfterHoursClose are the first pre-market trade and
+	// last after-hours trade; zero when the provider does not supply them
+	PreMarketOpen   float64 `json:"preMarketOpen,omitempty"`
+	AfterHoursClose float64 `json:"afterHoursClose,omitempty"`
+
+	// OmitCorporateActions is set by providers that do not report dividends
+	// and splits; the dividend and split factor already stored for the quote
+	// are kept when it is saved
+	OmitCorporateActions bool `json:"omitCorporateActions,omitempty"`
+}
+
+// EodQuery selects the quotes returned by EodQuotes
+type EodQuery struct {
+	CompositeFigi string
+	Start         time.Time
+	End           time.Time
+
+	// NormalizeCurrency converts all prices to USD using the most recent
+	// rate in FXTable on or before each quote's date. Quotes for which no
+	// rate is available are returned unconverted in their own currency.
+	NormalizeCurrency bool
+
+	// FXTable is the table holding fx rates; if empty `default.fx_table` is used
+	FXTable string
 }
 
 func (eod *Eod) SaveDB(ctx context.Context, tbl string, dbConn *pgxpool.Conn) error {
-	tx, err := dbConn.Begin(ctx)
+	tx, err := beginSave(ctx, dbConn)
 	if err != nil {
 		return err
 	}
 
-	defer func() {
-		if err := tx.Commit(ctx); err != nil {
-			log.Error().Err(err).Msg("error committing eod transaction to database")
-		}
-	}()
-
 	sql := fmt.Sprintf(`INSERT INTO %[1]s (
 		"ticker",
 		"composite_figi",
@@ -58,7 +80,10 @@ func (eod *Eod) SaveDB(ctx context.Context, tbl string, dbConn *pgxpool.Conn) er
 		"close",
 		"volume",
 		"dividend",
-		"split_factor"
+		"split_factor",
+		"currency",
+		"pre_market_open",
+		"after_hours_close"
 	) VALUES (
 		$1,
 		$2,
@@ -68,8 +93,11 @@ func (eod *Eod) SaveDB(ctx context.Context, tbl string, dbConn *pgxpool.Conn) er
 		$6,
 		$7,
 		$8,
-		$9,
-		$10
+		coalesce($9, 0.0),
+		coalesce($10, 1.0),
+		$11,
+		$12,
+		$13
 	) ON CONFLICT ON CONSTRAINT %[1]s_pkey
 	DO UPDATE SET
 		open = EXCLUDED.open,
@@ -77,15 +105,116 @@ func (eod *Eod) SaveDB(ctx context.Context, tbl string, dbConn *pgxpool.Conn) er
 		low = EXCLUDED.low,
 		close = EXCLUDED.close,
 		volume = EXCLUDED.volume,
-		dividend = EXCLUDED.dividend,
-		split_factor = EXCLUDED.split_factor;`, tbl)
+		dividend = coalesce($9, %[1]s.dividend),
+		split_factor = coalesce($10, %[1]s.split_factor),
+		currency = EXCLUDED.currency,
+		pre_market_open = COALESCE(EXCLUDED.pre_market_open, %[1]s.pre_market_open),
+		after_hours_close = COALESCE(EXCLUDED.after_hours_close, %[1]s.after_hours_close);`, tbl)
+
+	currency := eod.Currency
+	if currency == "" {
+		currency = USD
+	}
+
+	var dividend, split *float64
+	if !eod.OmitCorporateActions {
+		dividend, split = &eod.Dividend, &eod.Split
+	}
 
 	_, err = tx.Exec(ctx, sql, eod.Ticker, eod.CompositeFigi, eod.Date,
-		eod.Open, eod.High, eod.Low, eod.Close, eod.Volume, eod.Dividend,
-		eod.Split)
+		eod.Open, eod.High, eod.Low, eod.Close, eod.Volume, dividend,
+		split, currency, nullIfZero(eod.PreMarketOpen), nullIfZero(eod.AfterHoursClose))
 	if err != nil {
 		log.Error().Err(err).Str("SQL", sql).Msg("error saving EOD quote to database")
+		if err2 := tx.Rollback(ctx); err2 != nil {
+			log.Error().Err(err2).Msg("error rolling back eod transaction")
+		}
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Error().Err(err).Msg("error committing eod transaction to database")
+		return err
 	}
 
 	return nil
 }
+
+// EodQuotes returns the quotes stored in tbl for the requested asset and date range
+func EodQuotes(ctx context.Context, dbConn *pgxpool.Conn, tbl string, query *EodQuery) ([]*Eod, error) {
+	sql := fmt.Sprintf(`SELECT
+		event_date AS date,
+		ticker,
+		composite_figi,
+		open,
+		high,
+		low,
+		close,
+		volume,
+		dividend,
+		split_factor AS split,
+		currency,
+		coalesce(pre_market_open, 0) AS pre_market_open,
+		coalesce(after_hours_close, 0) AS after_hours_close
+	FROM %s
+	WHERE composite_figi=$1 AND event_date BETWEEN $2 AND $3
+	ORDER BY event_date`, tbl)
+
+	if query.NormalizeCurrency {
+		fxTable := query.FXTable
+		if fxTable == "" {
+			fxTable = viper.GetString("default.fx_table")
+		}
+
+		if fxTable == "" {
+			return nil, ErrFXTableNotSet
+		}
+
+		sql = fmt.Sprintf(`SELECT
+			e.event_date AS date,
+			e.ticker,
+			e.composite_figi,
+			e.open * coalesce(fx.usd_rate, 1.0) AS open,
+			e.high * coalesce(fx.usd_rate, 1.0) AS high,
+			e.low * coalesce(fx.usd_rate, 1.0) AS low,
+			e.close * coalesce(fx.usd_rate, 1.0) AS close,
+			e.volume,
+			e.dividend * coalesce(fx.usd_rate, 1.0) AS dividend,
+			e.split_factor AS split,
+			CASE WHEN fx.usd_rate IS NULL THEN e.currency ELSE 'USD' END AS currency,
+			coalesce(e.pre_market_open * coalesce(fx.usd_rate, 1.0), 0) AS pre_market_open,
+			coalesce(e.after_hours_close * coalesce(fx.usd_rate, 1.0), 0) AS after_hours_close
+		FROM %[1]s e
+		CROSS JOIN LATERAL (
+			SELECT CASE WHEN e.currency = 'USD' THEN 1.0 ELSE (
+				SELECT usd_rate FROM %[2]s f
+				WHERE f.currency = e.currency AND f.event_date <= e.event_date
+				ORDER BY f.event_date DESC LIMIT 1
+			) END AS usd_rate
+		) fx
+		WHERE e.composite_figi=$1 AND e.event_date BETWEEN $2 AND $3
+		ORDER BY e.event_date`, tbl, fxTable)
+	}
+
+	rows, err := dbConn.Query(ctx, sql, query.CompositeFigi, query.Start, query.End)
+	if err != nil {
+		log.Error().Err(err).Str("SQL", sql).Msg("querying eod quotes failed")
+		return nil, err
+	}
+
+	quotes := make([]*Eod, 0, 252)
+	if err := pgxscan.ScanAll(&quotes, rows); err != nil {
+		log.Error().Err(err).Msg("error when scanning values into eod quotes")
+		return nil, err
+	}
+
+	return quotes, nil
+}
+
+// nullIfZero maps missing (zero) prices to NULL so they are not stored as real values
+func nullIfZero(val float64) *float64 {
+	if val == 0 {
+		return nil
+	}
+	return &val
+}
@@ -0,0 +1,85 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// ETFHolding is a position held by an ETF on the as-of date. Holdings are used
+// to look through an ETF to the securities it owns.
+type ETFHolding struct {
+	// Ticker and CompositeFigi identify the ETF
+	Ticker        string    `json:"ticker"`
+	CompositeFigi string    `json:"compositeFigi"`
+	EventDate     time.Time `json:"eventDate"`
+
+	ConstituentTicker string `json:"constituentTicker"`
+	ConstituentFigi   string `json:"constituentFigi"`
+
+	// Weight is the fraction of the ETF's net assets invested in the
+	// constituent, e.g. 0.07 for 7%
+	Weight float64 `json:"weight"`
+	Shares float64 `json:"shares"`
+}
+
+func (holding *ETFHolding) SaveDB(ctx context.Context, tbl string, dbConn *pgxpool.Conn) error {
+	if holding.CompositeFigi == "" || holding.ConstituentFigi == "" {
+		return nil
+	}
+
+	tx, err := beginSave(ctx, dbConn)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := tx.Commit(ctx); err != nil {
+			log.Error().Err(err).Msg("error committing etf holding transaction to database")
+		}
+	}()
+
+	sql := fmt.Sprintf(`INSERT INTO %[1]s (
+		"ticker",
+		"composite_figi",
+		"event_date",
+		"constituent_ticker",
+		"constituent_figi",
+		"weight",
+		"shares"
+	) VALUES (
+		$1, $2, $3, $4, $5, $6, $7
+	) ON CONFLICT ON CONSTRAINT %[1]s_pkey DO UPDATE SET
+		constituent_ticker = EXCLUDED.constituent_ticker,
+		weight = EXCLUDED.weight,
+		shares = EXCLUDED.shares`, tbl)
+
+	_, err = tx.Exec(ctx, sql, holding.Ticker, holding.CompositeFigi, holding.EventDate, holding.ConstituentTicker,
+		holding.ConstituentFigi, holding.Weight, holding.Shares)
+
+	if err != nil {
+		log.Error().Err(err).Str("SQL", sql).Msg("save etf holding to DB failed")
+		if err2 := tx.Rollback(ctx); err2 != nil {
+			log.Error().Err(err).Msg("error rollingback tx")
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,110 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ExchangeInfo describes the trading calendar of an exchange
+type ExchangeInfo struct {
+	Name     string
+	Timezone string
+
+	// regular session open and close in the exchange's local time
+	OpenHour    int
+	OpenMinute  int
+	CloseHour   int
+	CloseMinute int
+}
+
+// Exchanges maps each supported exchange to its calendar. Exchanges that are
+// missing from the map are assumed to follow the NYSE calendar.
+var Exchanges = map[Exchange]*ExchangeInfo{
+	NasdaqExchange:   {Name: "Nasdaq", Timezone: "America/New_York", OpenHour: 9, OpenMinute: 30, CloseHour: 16},
+	NYSEExchange:     {Name: "New York Stock Exchange", Timezone: "America/New_York", OpenHour: 9, OpenMinute: 30, CloseHour: 16},
+	BATSExchange:     {Name: "Cboe BZX", Timezone: "America/New_York", OpenHour: 9, OpenMinute: 30, CloseHour: 16},
+	NYSEMktExchange:  {Name: "NYSE American", Timezone: "America/New_York", OpenHour: 9, OpenMinute: 30, CloseHour: 16},
+	NMFQSExchange:    {Name: "Nasdaq Mutual Fund Quotation Service", Timezone: "America/New_York", OpenHour: 9, OpenMinute: 30, CloseHour: 16},
+	ARCAExchange:     {Name: "NYSE Arca", Timezone: "America/New_York", OpenHour: 9, OpenMinute: 30, CloseHour: 16},
+	OTCExchange:      {Name: "OTC Markets", Timezone: "America/New_York", OpenHour: 9, OpenMinute: 30, CloseHour: 16},
+	OTCQXExchange:    {Name: "OTCQX Best Market", Timezone: "America/New_York", OpenHour: 9, OpenMinute: 30, CloseHour: 16},
+	OTCQBExchange:    {Name: "OTCQB Venture Market", Timezone: "America/New_York", OpenHour: 9, OpenMinute: 30, CloseHour: 16},
+	PinkExchange:     {Name: "Pink Open Market", Timezone: "America/New_York", OpenHour: 9, OpenMinute: 30, CloseHour: 16},
+	TSXExchange:      {Name: "Toronto Stock Exchange", Timezone: "America/Toronto", OpenHour: 9, OpenMinute: 30, CloseHour: 16},
+	TSXVExchange:     {Name: "TSX Venture Exchange", Timezone: "America/Toronto", OpenHour: 9, OpenMinute: 30, CloseHour: 16},
+	LSEExchange:      {Name: "London Stock Exchange", Timezone: "Europe/London", OpenHour: 8, CloseHour: 16, CloseMinute: 30},
+	XetraExchange:    {Name: "Xetra", Timezone: "Europe/Berlin", OpenHour: 9, CloseHour: 17, CloseMinute: 30},
+	EuronextExchange: {Name: "Euronext Paris", Timezone: "Europe/Paris", OpenHour: 9, CloseHour: 17, CloseMinute: 30},
+	SIXExchange:      {Name: "SIX Swiss Exchange", Timezone: "Europe/Zurich", OpenHour: 9, CloseHour: 17, CloseMinute: 30},
+	TokyoExchange:    {Name: "Tokyo Stock Exchange", Timezone: "Asia/Tokyo", OpenHour: 9, CloseHour: 15},
+	HongKongExchange: {Name: "Hong Kong Stock Exchange", Timezone: "Asia/Hong_Kong", OpenHour: 9, OpenMinute: 30, CloseHour: 16},
+	ShanghaiExchange: {Name: "Shanghai Stock Exchange", Timezone: "Asia/Shanghai", OpenHour: 9, OpenMinute: 30, CloseHour: 15},
+	ShenzhenExchange: {Name: "Shenzhen Stock Exchange", Timezone: "Asia/Shanghai", OpenHour: 9, OpenMinute: 30, CloseHour: 15},
+	ASXExchange:      {Name: "Australian Securities Exchange", Timezone: "Australia/Sydney", OpenHour: 10, CloseHour: 16},
+}
+
+var locationCache sync.Map
+
+// Info returns the calendar for the exchange
+func (exchange Exchange) Info() *ExchangeInfo {
+	if info, ok := Exchanges[exchange]; ok {
+		return info
+	}
+	return Exchanges[NYSEExchange]
+}
+
+// IsOTC returns true if the exchange is an over-the-counter market
+func (exchange Exchange) IsOTC() bool {
+	switch exchange {
+	case OTCExchange, OTCQXExchange, OTCQBExchange, PinkExchange:
+		return true
+	default:
+		return false
+	}
+}
+
+// Location returns the time zone the exchange operates in
+func (exchange Exchange) Location() *time.Location {
+	tz := exchange.Info().Timezone
+	if loc, ok := locationCache.Load(tz); ok {
+		return loc.(*time.Location)
+	}
+
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		log.Panic().Err(err).Str("Timezone", tz).Msg("could not load timezone")
+	}
+
+	locationCache.Store(tz, loc)
+	return loc
+}
+
+// CloseTime returns the regular session close on the given calendar date in
+// the exchange's time zone. Only the year, month, and day of date are used.
+func (exchange Exchange) CloseTime(date time.Time) time.Time {
+	info := exchange.Info()
+	return time.Date(date.Year(), date.Month(), date.Day(), info.CloseHour, info.CloseMinute, 0, 0, exchange.Location())
+}
+
+// OpenTime returns the regular session open on the given calendar date in
+// the exchange's time zone. Only the year, month, and day of date are used.
+func (exchange Exchange) OpenTime(date time.Time) time.Time {
+	info := exchange.Info()
+	return time.Date(date.Year(), date.Month(), date.Day(), info.OpenHour, info.OpenMinute, 0, 0, exchange.Location())
+}
@@ -635,7 +635,7 @@ func (fundamental *Fundamental) SaveDB(ctx context.Context, tbl string, dbConn *
 		return nil
 	}
 
-	tx, err := dbConn.Begin(ctx)
+	tx, err := beginSave(ctx, dbConn)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,133 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const USD = "USD"
+
+var (
+	ErrAssetTableNotSet = errors.New("default.asset_table not set")
+	ErrFXTableNotSet    = errors.New("default.fx_table not set, currency normalization is not possible")
+)
+
+// FXRate stores the value of one unit of a foreign currency in US dollars
+type FXRate struct {
+	Currency  string    `db:"currency"`
+	EventDate time.Time `db:"event_date"`
+	Rate      float64   `db:"usd_rate"`
+}
+
+func (fx *FXRate) SaveDB(ctx context.Context, tbl string, dbConn *pgxpool.Conn) error {
+	if fx.Currency == "" {
+		return nil
+	}
+
+	tx, err := beginSave(ctx, dbConn)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := tx.Commit(ctx); err != nil {
+			log.Error().Err(err).Msg("error committing fx rate transaction to database")
+		}
+	}()
+
+	sql := fmt.Sprintf(`INSERT INTO %[1]s (
+		"currency",
+		"event_date",
+		"usd_rate"
+	) VALUES (
+		$1, $2, $3
+	) ON CONFLICT ON CONSTRAINT %[1]s_pkey DO UPDATE SET
+		usd_rate = EXCLUDED.usd_rate`, tbl)
+
+	_, err = tx.Exec(ctx, sql, fx.Currency, fx.EventDate, fx.Rate)
+
+	if err != nil {
+		log.Error().Err(err).Str("SQL", sql).Msg("save fx rate to DB failed")
+		if err2 := tx.Rollback(ctx); err2 != nil {
+			log.Error().Err(err).Msg("error rollingback tx")
+		}
+	}
+
+	return err
+}
+
+// ActiveCurrencies returns the distinct non-USD currencies that active assets are priced in
+func ActiveCurrencies(ctx context.Context, dbConn *pgxpool.Conn, tables ...string) ([]string, error) {
+	var assetTable string
+	if len(tables) == 0 {
+		assetTable = viper.GetString("default.asset_table")
+		if assetTable == "" {
+			return nil, ErrAssetTableNotSet
+		}
+	} else {
+		assetTable = tables[0]
+	}
+
+	sql := fmt.Sprintf("SELECT DISTINCT price_currency FROM %s WHERE active=true AND price_currency IS NOT NULL AND price_currency <> 'USD'", assetTable)
+	rows, err := dbConn.Query(ctx, sql)
+	if err != nil {
+		log.Error().Err(err).Str("SQL", sql).Msg("querying active currencies failed")
+		return nil, err
+	}
+	defer rows.Close()
+
+	currencies := make([]string, 0, 10)
+	for rows.Next() {
+		var currency string
+		if err := rows.Scan(&currency); err != nil {
+			return nil, err
+		}
+		currencies = append(currencies, currency)
+	}
+
+	return currencies, rows.Err()
+}
+
+// LatestFXRates returns the date of the most recent rate stored in tbl for each currency
+func LatestFXRates(ctx context.Context, dbConn *pgxpool.Conn, tbl string) (map[string]MarketDate, error) {
+	rows, err := dbConn.Query(ctx, fmt.Sprintf("SELECT currency, max(event_date) FROM %s GROUP BY currency", tbl))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	latest := make(map[string]MarketDate, 10)
+	for rows.Next() {
+		var (
+			currency  string
+			eventDate MarketDate
+		)
+
+		if err := rows.Scan(&currency, &eventDate); err != nil {
+			return nil, err
+		}
+		latest[currency] = eventDate
+	}
+
+	return latest, rows.Err()
+}
@@ -19,9 +19,11 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
 )
 
 type MarketHoliday struct {
@@ -41,7 +43,7 @@ func (holiday *MarketHoliday) MarshalZerologObject(e *zerolog.Event) {
 }
 
 func (holiday *MarketHoliday) SaveDB(ctx context.Context, tbl string, dbConn *pgxpool.Conn) error {
-	tx, err := dbConn.Begin(ctx)
+	tx, err := beginSave(ctx, dbConn)
 	if err != nil {
 		return err
 	}
@@ -76,3 +78,34 @@ func (holiday *MarketHoliday) SaveDB(ctx context.Context, tbl string, dbConn *pg
 
 	return nil
 }
+
+// IsTradingDay reports whether US equity markets were open on date. Weekends are
+// never trading days; holidays are read from the market holiday table, which
+// defaults to `default.holiday_table`. If no holiday table is configured only
+// weekends are considered closed. Early close days are trading days.
+func IsTradingDay(ctx context.Context, dbConn *pgxpool.Conn, date time.Time, tables ...string) (bool, error) {
+	if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+		return false, nil
+	}
+
+	var holidayTable string
+	if len(tables) == 0 {
+		holidayTable = viper.GetString("default.holiday_table")
+	} else {
+		holidayTable = tables[0]
+	}
+
+	if holidayTable == "" {
+		return true, nil
+	}
+
+	closed := false
+	err := dbConn.QueryRow(ctx, fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE event_date = $1 AND
+market IN ('NYSE', 'NASDAQ') AND NOT early_close)`, pgx.Identifier{holidayTable}.Sanitize()),
+		time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)).Scan(&closed)
+	if err != nil {
+		return false, err
+	}
+
+	return !closed, nil
+}
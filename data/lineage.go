@@ -0,0 +1,83 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Lineage identifies the subscription, provider, and run that produced the
+// rows being saved. The pvdata_lineage trigger stamps it on each row.
+type Lineage struct {
+	SubscriptionID uuid.UUID
+	Provider       string
+	RunID          uuid.UUID
+	FetchedAt      time.Time
+}
+
+type lineageKey struct{}
+
+// WithLineage returns a context that causes SaveDB to stamp rows with lineage
+func WithLineage(ctx context.Context, lineage *Lineage) context.Context {
+	return context.WithValue(ctx, lineageKey{}, lineage)
+}
+
+// beginSave starts the transaction rows are saved in. The lineage set on ctx
+// is configured for the transaction only, in the same round trip as BEGIN, so
+// it never applies to later writes on the connection.
+func beginSave(ctx context.Context, dbConn *pgxpool.Conn) (pgx.Tx, error) {
+	lineage, ok := ctx.Value(lineageKey{}).(*Lineage)
+	if !ok || lineage == nil {
+		return dbConn.Begin(ctx)
+	}
+
+	subscriptionID := ""
+	if lineage.SubscriptionID != uuid.Nil {
+		subscriptionID = lineage.SubscriptionID.String()
+	}
+
+	runID := ""
+	if lineage.RunID != uuid.Nil {
+		runID = lineage.RunID.String()
+	}
+
+	fetchedAt := ""
+	if !lineage.FetchedAt.IsZero() {
+		fetchedAt = lineage.FetchedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	return dbConn.BeginTx(ctx, pgx.TxOptions{
+		BeginQuery: fmt.Sprintf(`BEGIN; SELECT set_config('pvdata.subscription_id', %s, true),
+set_config('pvdata.provider', %s, true),
+set_config('pvdata.run_id', %s, true),
+set_config('pvdata.fetched_at', %s, true)`, quoteLiteral(subscriptionID), quoteLiteral(lineage.Provider),
+			quoteLiteral(runID), quoteLiteral(fetchedAt)),
+	})
+}
+
+// quoteLiteral quotes val as an escaped SQL string literal
+func quoteLiteral(val string) string {
+	val = strings.ReplaceAll(val, "\x00", "")
+	val = strings.ReplaceAll(val, `\`, `\\`)
+	val = strings.ReplaceAll(val, `'`, `''`)
+	return `E'` + val + `'`
+}
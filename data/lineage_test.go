@@ -0,0 +1,95 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/db/dbtest"
+)
+
+var _ = Describe("Lineage", func() {
+	var (
+		ctx      context.Context
+		conn     *pgxpool.Conn
+		eodTable string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		myLibrary, err := dbtest.Library(ctx)
+		if errors.Is(err, dbtest.ErrNotConfigured) {
+			Skip("no test database configured")
+		}
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(myLibrary.Close)
+
+		conn, err = myLibrary.Pool.Acquire(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(conn.Release)
+
+		eodTable = "lineage_test_eod_" + strings.ReplaceAll(uuid.NewString(), "-", "")[:8]
+
+		statements := []string{
+			data.DataTypes[data.EODKey].ExpandedSchema(eodTable),
+			fmt.Sprintf(`CREATE TABLE %s PARTITION OF %s DEFAULT`, pgx.Identifier{eodTable + "_default"}.Sanitize(),
+				pgx.Identifier{eodTable}.Sanitize()),
+		}
+		statements = append(statements, data.DataTypes[data.EODKey].ExpandedMigrations(eodTable)...)
+
+		for _, sql := range statements {
+			_, err := conn.Exec(ctx, sql)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		DeferCleanup(func() {
+			_, err := conn.Exec(context.Background(), fmt.Sprintf(`DROP TABLE IF EXISTS %s CASCADE`, pgx.Identifier{eodTable}.Sanitize()))
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	provider := func(figi string) *string {
+		var val *string
+		Expect(conn.QueryRow(ctx, fmt.Sprintf(`SELECT provider FROM %s WHERE composite_figi = $1`,
+			pgx.Identifier{eodTable}.Sanitize()), figi).Scan(&val)).To(Succeed())
+		return val
+	}
+
+	It("stamps rows saved with a lineage and not later rows saved on the same connection", func() {
+		date := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+
+		lineage := &data.Lineage{SubscriptionID: uuid.New(), Provider: "o'brien", RunID: uuid.New(), FetchedAt: date}
+		stamped := &data.Eod{Date: date, Ticker: "LIN", CompositeFigi: "BBG000LINE01", Close: 10}
+		Expect(stamped.SaveDB(data.WithLineage(ctx, lineage), eodTable, conn)).To(Succeed())
+
+		unstamped := &data.Eod{Date: date, Ticker: "OTHER", CompositeFigi: "BBG000LINE02", Close: 10}
+		Expect(unstamped.SaveDB(ctx, eodTable, conn)).To(Succeed())
+
+		Expect(provider("BBG000LINE01")).To(HaveValue(Equal("o'brien")))
+		Expect(provider("BBG000LINE02")).To(BeNil())
+	})
+})
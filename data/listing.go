@@ -0,0 +1,99 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	ListingExpected  = "expected"
+	ListingFiled     = "filed"
+	ListingPriced    = "priced"
+	ListingWithdrawn = "withdrawn"
+)
+
+// ListingEvent is an upcoming or recent IPO or direct listing
+type ListingEvent struct {
+	Ticker string `json:"ticker"`
+	Name   string `json:"name"`
+
+	// EventDate is the expected (or actual, once priced) first day of trading
+	EventDate time.Time `json:"eventDate"`
+	Exchange  string    `json:"exchange"`
+
+	// PriceLow and PriceHigh are the range the shares are offered at; both are
+	// the offer price once the listing is priced and nil if not yet announced
+	PriceLow  *float64 `json:"priceLow"`
+	PriceHigh *float64 `json:"priceHigh"`
+
+	NumShares int64 `json:"numShares"`
+
+	// Status is one of ListingExpected, ListingFiled, ListingPriced, or ListingWithdrawn
+	Status string `json:"status"`
+}
+
+func (listing *ListingEvent) SaveDB(ctx context.Context, tbl string, dbConn *pgxpool.Conn) error {
+	if listing.Ticker == "" {
+		return nil
+	}
+
+	tx, err := beginSave(ctx, dbConn)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := tx.Commit(ctx); err != nil {
+			log.Error().Err(err).Msg("error committing listing transaction to database")
+		}
+	}()
+
+	sql := fmt.Sprintf(`INSERT INTO %[1]s (
+		"ticker",
+		"name",
+		"event_date",
+		"exchange",
+		"price_low",
+		"price_high",
+		"num_shares",
+		"status"
+	) VALUES (
+		$1, $2, $3, $4, $5, $6, $7, $8
+	) ON CONFLICT ON CONSTRAINT %[1]s_pkey DO UPDATE SET
+		name = EXCLUDED.name,
+		exchange = EXCLUDED.exchange,
+		price_low = coalesce(EXCLUDED.price_low, %[1]s.price_low),
+		price_high = coalesce(EXCLUDED.price_high, %[1]s.price_high),
+		num_shares = EXCLUDED.num_shares,
+		status = EXCLUDED.status`, tbl)
+
+	_, err = tx.Exec(ctx, sql, listing.Ticker, listing.Name, listing.EventDate, listing.Exchange, listing.PriceLow,
+		listing.PriceHigh, listing.NumShares, listing.Status)
+
+	if err != nil {
+		log.Error().Err(err).Str("SQL", sql).Msg("save listing to DB failed")
+		if err2 := tx.Rollback(ctx); err2 != nil {
+			log.Error().Err(err).Msg("error rollingback tx")
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,203 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Date handling policy
+//
+// Every date stored by pv-data is a market date: the calendar day of a trading
+// session on the exchange the security is listed on. Market dates have no time
+// of day and no time zone, which keeps them from shifting when a timestamp is
+// converted to the time zone of the host pvdata runs on.
+//
+//   - "today" is always the exchange's current day, see Today. The host's local
+//     date is never used because it is off by one for users outside the US for
+//     part of every day.
+//   - dates provider APIs return as strings are taken as written, see
+//     ParseMarketDate. Providers report the session date even when they format
+//     it as midnight UTC.
+//   - instants (e.g. news publication times) are converted to the exchange's
+//     time zone before the date is taken, see MarketDateOf.
+//   - DATE columns are written and read as midnight UTC, see MarketDate.Time.
+
+var (
+	ErrInvalidMarketDate = errors.New("invalid market date")
+)
+
+// marketDateLayouts are the formats accepted by ParseMarketDate
+var marketDateLayouts = []string{
+	time.DateOnly,
+	"20060102",
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	time.DateTime,
+}
+
+// MarketDate is a calendar day on an exchange's trading calendar
+type MarketDate struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// NewMarketDate returns the market date for the given year, month, and day.
+// Out of range values are normalized the same way time.Date normalizes them.
+func NewMarketDate(year int, month time.Month, day int) MarketDate {
+	return dateOf(time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+}
+
+// MarketDateOf returns the market date of the instant t on exchange
+func MarketDateOf(t time.Time, exchange Exchange) MarketDate {
+	return dateOf(t.In(exchange.Location()))
+}
+
+// Today returns the current market date on exchange
+func Today(exchange Exchange) MarketDate {
+	return MarketDateOf(time.Now(), exchange)
+}
+
+// ParseMarketDate parses a date returned by a provider. Dates may be formatted
+// as YYYY-MM-DD, YYYYMMDD, or as a timestamp; the date of a timestamp is used as
+// written without converting it to another time zone.
+func ParseMarketDate(value string) (MarketDate, error) {
+	for _, layout := range marketDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return dateOf(t), nil
+		}
+	}
+
+	return MarketDate{}, fmt.Errorf("%w: %q", ErrInvalidMarketDate, value)
+}
+
+func dateOf(t time.Time) MarketDate {
+	year, month, day := t.Date()
+	return MarketDate{Year: year, Month: month, Day: day}
+}
+
+// IsZero returns true if the date has not been set
+func (d MarketDate) IsZero() bool {
+	return d.Year == 0 && d.Month == 0 && d.Day == 0
+}
+
+// Time returns midnight UTC on the date. This is the representation used for
+// DATE columns and by providers that format dates as midnight UTC.
+func (d MarketDate) Time() time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, time.UTC)
+}
+
+// Open returns the regular session open on the date in the exchange's time zone
+func (d MarketDate) Open(exchange Exchange) time.Time {
+	return exchange.OpenTime(d.Time())
+}
+
+// Close returns the regular session close on the date in the exchange's time zone
+func (d MarketDate) Close(exchange Exchange) time.Time {
+	return exchange.CloseTime(d.Time())
+}
+
+// AddDays returns the date n days after d; n may be negative
+func (d MarketDate) AddDays(n int) MarketDate {
+	return NewMarketDate(d.Year, d.Month, d.Day+n)
+}
+
+// AddMonths returns the date n months after d; n may be negative
+func (d MarketDate) AddMonths(n int) MarketDate {
+	return NewMarketDate(d.Year, d.Month+time.Month(n), d.Day)
+}
+
+// Weekday returns the day of the week of the date
+func (d MarketDate) Weekday() time.Weekday {
+	return d.Time().Weekday()
+}
+
+// IsWeekend returns true if the date falls on a Saturday or Sunday
+func (d MarketDate) IsWeekend() bool {
+	weekday := d.Weekday()
+	return weekday == time.Saturday || weekday == time.Sunday
+}
+
+// Before returns true if d is before other
+func (d MarketDate) Before(other MarketDate) bool {
+	return d.Time().Before(other.Time())
+}
+
+// After returns true if d is after other
+func (d MarketDate) After(other MarketDate) bool {
+	return d.Time().After(other.Time())
+}
+
+// Format returns the date formatted with a time.Time layout
+func (d MarketDate) Format(layout string) string {
+	return d.Time().Format(layout)
+}
+
+// String returns the date formatted as YYYY-MM-DD
+func (d MarketDate) String() string {
+	return d.Format(time.DateOnly)
+}
+
+// MarshalJSON encodes the date as a YYYY-MM-DD string
+func (d MarketDate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON decodes any of the formats accepted by ParseMarketDate
+func (d *MarketDate) UnmarshalJSON(buf []byte) error {
+	var value string
+	if err := json.Unmarshal(buf, &value); err != nil {
+		return err
+	}
+
+	parsed, err := ParseMarketDate(value)
+	if err != nil {
+		return err
+	}
+
+	*d = parsed
+	return nil
+}
+
+// Value implements driver.Valuer so dates can be used as query arguments
+func (d MarketDate) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// Scan implements sql.Scanner. DATE columns are returned as midnight UTC so
+// the date is read without converting it to the local time zone.
+func (d *MarketDate) Scan(src any) error {
+	switch value := src.(type) {
+	case time.Time:
+		*d = dateOf(value)
+		return nil
+	case string:
+		parsed, err := ParseMarketDate(value)
+		if err != nil {
+			return err
+		}
+		*d = parsed
+		return nil
+	case []byte:
+		return d.Scan(string(value))
+	default:
+		return fmt.Errorf("%w: cannot scan %T", ErrInvalidMarketDate, src)
+	}
+}
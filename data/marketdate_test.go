@@ -0,0 +1,85 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data_test
+
+import (
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/penny-vault/pvdata/data"
+)
+
+var _ = Describe("MarketDate", func() {
+	DescribeTable("parses provider dates as written",
+		func(value string, expected data.MarketDate) {
+			date, err := data.ParseMarketDate(value)
+			Expect(err).To(BeNil())
+			Expect(date).To(Equal(expected))
+		},
+		Entry("iso dates", "2024-03-08", data.NewMarketDate(2024, time.March, 8)),
+		Entry("compact dates", "20240308", data.NewMarketDate(2024, time.March, 8)),
+		Entry("midnight utc", "2024-03-08T00:00:00.000Z", data.NewMarketDate(2024, time.March, 8)),
+		Entry("timestamps with an offset", "2024-03-08T23:30:00+09:00", data.NewMarketDate(2024, time.March, 8)),
+	)
+
+	It("rejects invalid dates", func() {
+		_, err := data.ParseMarketDate("03/08/2024")
+		Expect(err).To(MatchError(data.ErrInvalidMarketDate))
+	})
+
+	It("uses the exchange's date regardless of the host time zone", func() {
+		tokyo, err := time.LoadLocation("Asia/Tokyo")
+		Expect(err).To(BeNil())
+
+		// 9am Saturday in Tokyo is still Friday evening in New York
+		instant := time.Date(2024, time.March, 9, 9, 0, 0, 0, tokyo)
+		Expect(data.MarketDateOf(instant, data.NYSEExchange)).To(Equal(data.NewMarketDate(2024, time.March, 8)))
+		Expect(data.MarketDateOf(instant, data.TokyoExchange)).To(Equal(data.NewMarketDate(2024, time.March, 9)))
+	})
+
+	It("does date arithmetic across month boundaries", func() {
+		date := data.NewMarketDate(2024, time.February, 28)
+		Expect(date.AddDays(2).String()).To(Equal("2024-03-01"))
+		Expect(date.AddDays(-28).String()).To(Equal("2024-01-31"))
+		Expect(date.AddDays(2).After(date)).To(BeTrue())
+		Expect(data.NewMarketDate(2024, time.March, 9).IsWeekend()).To(BeTrue())
+	})
+
+	It("converts to the exchange's session close", func() {
+		date := data.NewMarketDate(2024, time.March, 8)
+		closeTime := date.Close(data.LSEExchange)
+		Expect(closeTime.UTC()).To(Equal(time.Date(2024, time.March, 8, 16, 30, 0, 0, time.UTC)))
+		Expect(date.Time()).To(Equal(time.Date(2024, time.March, 8, 0, 0, 0, 0, time.UTC)))
+	})
+
+	It("round trips through json and sql", func() {
+		date := data.NewMarketDate(2024, time.March, 8)
+
+		buf, err := json.Marshal(date)
+		Expect(err).To(BeNil())
+		Expect(string(buf)).To(Equal(`"2024-03-08"`))
+
+		var decoded data.MarketDate
+		Expect(json.Unmarshal(buf, &decoded)).To(Succeed())
+		Expect(decoded).To(Equal(date))
+
+		var scanned data.MarketDate
+		Expect(scanned.Scan(date.Time())).To(Succeed())
+		Expect(scanned).To(Equal(date))
+	})
+})
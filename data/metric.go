@@ -43,7 +43,7 @@ func (metric *Metric) SaveDB(ctx context.Context, tbl string, dbConn *pgxpool.Co
 		return nil
 	}
 
-	tx, err := dbConn.Begin(ctx)
+	tx, err := beginSave(ctx, dbConn)
 	if err != nil {
 		return err
 	}
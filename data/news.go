@@ -0,0 +1,90 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// News is an article about a company
+type News struct {
+	Ticker        string    `json:"ticker"`
+	CompositeFigi string    `json:"compositeFigi"`
+	PublishedAt   time.Time `json:"publishedAt"`
+
+	// NewsID identifies the article at its source
+	NewsID   string `json:"newsId"`
+	Source   string `json:"source"`
+	Category string `json:"category"`
+	Headline string `json:"headline"`
+	Summary  string `json:"summary"`
+	URL      string `json:"url"`
+
+	// Sentiment ranges from -1 (bearish) to 1 (bullish); nil if the provider
+	// does not score news
+	Sentiment *float64 `json:"sentiment"`
+}
+
+func (news *News) SaveDB(ctx context.Context, tbl string, dbConn *pgxpool.Conn) error {
+	if news.CompositeFigi == "" {
+		return nil
+	}
+
+	tx, err := beginSave(ctx, dbConn)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := tx.Commit(ctx); err != nil {
+			log.Error().Err(err).Msg("error committing news transaction to database")
+		}
+	}()
+
+	sql := fmt.Sprintf(`INSERT INTO %[1]s (
+		"ticker",
+		"composite_figi",
+		"published_at",
+		"news_id",
+		"source",
+		"category",
+		"headline",
+		"summary",
+		"url",
+		"sentiment"
+	) VALUES (
+		$1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+	) ON CONFLICT ON CONSTRAINT %[1]s_pkey DO UPDATE SET
+		headline = EXCLUDED.headline,
+		summary = EXCLUDED.summary,
+		sentiment = coalesce(EXCLUDED.sentiment, %[1]s.sentiment)`, tbl)
+
+	_, err = tx.Exec(ctx, sql, news.Ticker, news.CompositeFigi, news.PublishedAt, news.NewsID, news.Source,
+		news.Category, news.Headline, news.Summary, news.URL, news.Sentiment)
+
+	if err != nil {
+		log.Error().Err(err).Str("SQL", sql).Msg("save news to DB failed")
+		if err2 := tx.Rollback(ctx); err2 != nil {
+			log.Error().Err(err).Msg("error rollingback tx")
+		}
+	}
+
+	return err
+}
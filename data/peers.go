@@ -0,0 +1,71 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// Peers lists the companies a provider considers comparable to an asset, e.g.
+// companies in the same industry
+type Peers struct {
+	Ticker        string    `json:"ticker"`
+	CompositeFigi string    `json:"compositeFigi"`
+	EventDate     time.Time `json:"eventDate"`
+	Peers         []string  `json:"peers"`
+}
+
+func (peers *Peers) SaveDB(ctx context.Context, tbl string, dbConn *pgxpool.Conn) error {
+	if peers.CompositeFigi == "" {
+		return nil
+	}
+
+	tx, err := beginSave(ctx, dbConn)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := tx.Commit(ctx); err != nil {
+			log.Error().Err(err).Msg("error committing peers transaction to database")
+		}
+	}()
+
+	sql := fmt.Sprintf(`INSERT INTO %[1]s (
+		"ticker",
+		"composite_figi",
+		"event_date",
+		"peers"
+	) VALUES (
+		$1, $2, $3, $4
+	) ON CONFLICT ON CONSTRAINT %[1]s_pkey DO UPDATE SET
+		peers = EXCLUDED.peers`, tbl)
+
+	_, err = tx.Exec(ctx, sql, peers.Ticker, peers.CompositeFigi, peers.EventDate, peers.Peers)
+
+	if err != nil {
+		log.Error().Err(err).Str("SQL", sql).Msg("save peers to DB failed")
+		if err2 := tx.Rollback(ctx); err2 != nil {
+			log.Error().Err(err).Msg("error rollingback tx")
+		}
+	}
+
+	return err
+}
@@ -40,7 +40,7 @@ func (rating *AnalystRating) SaveDB(ctx context.Context, tbl string, dbConn *pgx
 		return nil
 	}
 
-	tx, err := dbConn.Begin(ctx)
+	tx, err := beginSave(ctx, dbConn)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,36 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data
+
+// SchemaDrift is a change in the format of a provider response, e.g. a renamed
+// CSV column. Fields that are missing or have changed type would otherwise be
+// read as zero values.
+type SchemaDrift struct {
+	// Source names the provider response, e.g. "tiingo supported tickers"
+	Source string `json:"source"`
+
+	// UnknownFields are in the response but not in the expected schema
+	UnknownFields []string `json:"unknown_fields,omitempty"`
+
+	// MissingFields are required by the expected schema but not in the response
+	MissingFields []string `json:"missing_fields,omitempty"`
+
+	// TypeChanges describe fields whose values are not of the expected type,
+	// e.g. "close: expected number, got string"
+	TypeChanges []string `json:"type_changes,omitempty"`
+
+	// Sample is the start of the response that drifted
+	Sample string `json:"sample"`
+}
@@ -0,0 +1,99 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data
+
+import (
+	"strings"
+	"sync"
+)
+
+// ShareClassSeparator separates the root symbol from the share class in
+// pv-data tickers, e.g. BRK/A
+const ShareClassSeparator = "/"
+
+// TickerRule describes how a provider formats tickers that have a share class
+type TickerRule struct {
+	// Separator the provider places between the root symbol and share class,
+	// e.g. "." for BRK.A or "-" for BRK-A
+	Separator string
+
+	// Lowercase is true if the provider expects lower case tickers
+	Lowercase bool
+}
+
+var (
+	tickerRulesMu sync.RWMutex
+	tickerRules   = map[string]TickerRule{
+		"finnhub":  {Separator: "."},
+		"iex":      {Separator: "."},
+		"polygon":  {Separator: "."},
+		"sharadar": {Separator: "."},
+		"stooq":    {Separator: "-", Lowercase: true},
+		"tiingo":   {Separator: "-"},
+		"zacks":    {Separator: "."},
+	}
+)
+
+// RegisterTickerRule sets the ticker format of a provider, replacing any
+// existing rule
+func RegisterTickerRule(provider string, rule TickerRule) {
+	tickerRulesMu.Lock()
+	defer tickerRulesMu.Unlock()
+
+	tickerRules[provider] = rule
+}
+
+// tickerRule returns the rule of the provider; providers without a rule are
+// assumed to use pv-data's format
+func tickerRule(provider string) TickerRule {
+	tickerRulesMu.RLock()
+	defer tickerRulesMu.RUnlock()
+
+	if rule, ok := tickerRules[provider]; ok {
+		return rule
+	}
+
+	return TickerRule{Separator: ShareClassSeparator}
+}
+
+// NormalizeTicker converts a ticker from the provider's format to pv-data's,
+// e.g. NormalizeTicker("tiingo", "brk-a") returns BRK/A
+func NormalizeTicker(provider, ticker string) string {
+	rule := tickerRule(provider)
+
+	ticker = strings.ToUpper(strings.TrimSpace(ticker))
+	if rule.Separator != "" && rule.Separator != ShareClassSeparator {
+		ticker = strings.ReplaceAll(ticker, strings.ToUpper(rule.Separator), ShareClassSeparator)
+	}
+
+	return ticker
+}
+
+// DenormalizeTicker converts a pv-data ticker to the provider's format; it is
+// the inverse of NormalizeTicker, e.g. DenormalizeTicker("polygon", "BRK/A")
+// returns BRK.A
+func DenormalizeTicker(provider, ticker string) string {
+	rule := tickerRule(provider)
+
+	if rule.Separator != "" && rule.Separator != ShareClassSeparator {
+		ticker = strings.ReplaceAll(ticker, ShareClassSeparator, rule.Separator)
+	}
+
+	if rule.Lowercase {
+		ticker = strings.ToLower(ticker)
+	}
+
+	return ticker
+}
@@ -0,0 +1,62 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/penny-vault/pvdata/data"
+)
+
+var _ = Describe("Ticker normalization", func() {
+	DescribeTable("converts provider tickers to pv-data tickers",
+		func(provider, ticker, expected string) {
+			Expect(data.NormalizeTicker(provider, ticker)).To(Equal(expected))
+		},
+		Entry("tiingo", "tiingo", "BRK-A", "BRK/A"),
+		Entry("tiingo lower case", "tiingo", "brk-a", "BRK/A"),
+		Entry("polygon", "polygon", "BRK.A", "BRK/A"),
+		Entry("sharadar", "sharadar", "BF.B", "BF/B"),
+		Entry("stooq", "stooq", "brk-b", "BRK/B"),
+		Entry("tickers without a share class", "polygon", "AAPL", "AAPL"),
+		Entry("unknown providers", "unknown", " brk/a ", "BRK/A"),
+	)
+
+	DescribeTable("converts pv-data tickers to provider tickers",
+		func(provider, ticker, expected string) {
+			Expect(data.DenormalizeTicker(provider, ticker)).To(Equal(expected))
+		},
+		Entry("tiingo", "tiingo", "BRK/A", "BRK-A"),
+		Entry("finnhub", "finnhub", "BRK/B", "BRK.B"),
+		Entry("iex", "iex", "BF/B", "BF.B"),
+		Entry("stooq", "stooq", "BRK/B", "brk-b"),
+		Entry("unknown providers", "unknown", "BRK/A", "BRK/A"),
+	)
+
+	It("round trips tickers through every provider", func() {
+		for _, provider := range []string{"finnhub", "iex", "polygon", "sharadar", "stooq", "tiingo", "zacks", "unknown"} {
+			for _, ticker := range []string{"AAPL", "BRK/A", "BF/B"} {
+				Expect(data.NormalizeTicker(provider, data.DenormalizeTicker(provider, ticker))).To(Equal(ticker), provider)
+			}
+		}
+	})
+
+	It("uses registered rules", func() {
+		data.RegisterTickerRule("example", data.TickerRule{Separator: "_"})
+		Expect(data.NormalizeTicker("example", "BRK_A")).To(Equal("BRK/A"))
+		Expect(data.DenormalizeTicker("example", "BRK/A")).To(Equal("BRK_A"))
+	})
+})
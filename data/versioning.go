@@ -0,0 +1,77 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrNotVersioned = errors.New("table is not system-versioned")
+)
+
+// AsOf returns a subquery that selects the rows of the versioned table tbl as
+// they were stored in the library at asOf. It is used in place of the table
+// name in a query, e.g.
+//
+//	source, err := data.AsOf(ctx, conn, tbl, asOf)
+//	rows, err := conn.Query(ctx, "SELECT * FROM "+source+" WHERE composite_figi = $1", figi)
+//
+// Rows saved before versioning was enabled have no valid_from and are treated
+// as if they had always been present.
+func AsOf(ctx context.Context, dbConn *pgxpool.Conn, tbl string, asOf time.Time) (string, error) {
+	history := tbl + "_history"
+
+	var versioned bool
+	if err := dbConn.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, pgx.Identifier{history}.Sanitize()).Scan(&versioned); err != nil {
+		return "", err
+	}
+
+	if !versioned {
+		return "", fmt.Errorf("%w: %s", ErrNotVersioned, tbl)
+	}
+
+	rows, err := dbConn.Query(ctx, `SELECT attname FROM pg_attribute
+WHERE attrelid = to_regclass($1) AND attnum > 0 AND NOT attisdropped
+ORDER BY attnum`, pgx.Identifier{tbl}.Sanitize())
+	if err != nil {
+		return "", err
+	}
+
+	columns, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return "", err
+	}
+
+	quoted := make([]string, len(columns))
+	for idx, column := range columns {
+		quoted[idx] = pgx.Identifier{column}.Sanitize()
+	}
+
+	selectList := strings.Join(quoted, ", ")
+	timestamp := fmt.Sprintf("'%s'::timestamptz", asOf.UTC().Format(time.RFC3339Nano))
+
+	return fmt.Sprintf(`(SELECT %[1]s FROM %[2]s WHERE coalesce(valid_from, '-infinity') <= %[4]s
+UNION ALL
+SELECT %[1]s FROM %[3]s WHERE coalesce(valid_from, '-infinity') <= %[4]s AND valid_to > %[4]s) AS as_of`,
+		selectList, pgx.Identifier{tbl}.Sanitize(), pgx.Identifier{history}.Sanitize(), timestamp), nil
+}
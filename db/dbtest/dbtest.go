@@ -0,0 +1,82 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package dbtest connects integration tests to a scratch PostgreSQL database.
+// Tests that need a database are skipped unless PVDATA_TEST_DB_URL is set, e.g.
+//
+//	PVDATA_TEST_DB_URL=postgres://pvdata@localhost:5432/pvdata_test go test ./...
+//
+// The database is migrated to the latest schema before it is used. Tests
+// should remove whatever they create.
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/penny-vault/pvdata/db"
+	"github.com/penny-vault/pvdata/library"
+)
+
+// URLEnv is the environment variable naming the test database
+const URLEnv = "PVDATA_TEST_DB_URL"
+
+var (
+	ErrNotConfigured = errors.New(URLEnv + " is not set")
+)
+
+// Library migrates the test database and returns a library connected to it.
+// ErrNotConfigured is returned if no test database is configured.
+func Library(ctx context.Context) (*library.Library, error) {
+	dbURL := os.Getenv(URLEnv)
+	if dbURL == "" {
+		return nil, ErrNotConfigured
+	}
+
+	if err := db.Migrate(strings.Replace(dbURL, "postgres://", "pgx5://", 1)); err != nil {
+		return nil, err
+	}
+
+	myLibrary := &library.Library{
+		DBUrl: dbURL,
+	}
+
+	if err := myLibrary.Connect(ctx); err != nil {
+		return nil, err
+	}
+
+	err := myLibrary.Pool.QueryRow(ctx, "SELECT name, owner FROM library LIMIT 1").Scan(&myLibrary.Name, &myLibrary.Owner)
+	if err == nil {
+		return myLibrary, nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		myLibrary.Close()
+		return nil, err
+	}
+
+	// a freshly migrated database does not have a library yet
+	myLibrary.Name = "pvdata test"
+	myLibrary.Owner = "pvdata"
+
+	if err := myLibrary.SaveDB(ctx); err != nil {
+		myLibrary.Close()
+		return nil, err
+	}
+
+	return myLibrary, nil
+}
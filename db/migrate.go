@@ -16,6 +16,7 @@ package db
 
 import (
 	"embed"
+	"errors"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/pgx/v5"
@@ -25,7 +26,8 @@ import (
 //go:embed migrations/*
 var migrationFS embed.FS
 
-// Migrate runs database migrations for a data library
+// Migrate runs database migrations for a data library. It is safe to call on
+// a library that is already up-to-date.
 func Migrate(databaseURL string) error {
 	migrationDir, err := iofs.New(migrationFS, "migrations")
 	if err != nil {
@@ -38,5 +40,9 @@ func Migrate(databaseURL string) error {
 	}
 
 	err = migration.Up()
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+
 	return err
 }
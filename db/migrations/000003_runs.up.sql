@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS runs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+
+    state TEXT NOT NULL DEFAULT 'running', -- running, paused, canceled, finished
+    status TEXT,                           -- final status of the run once finished
+    num_observations INTEGER DEFAULT 0,
+
+    start_time TIMESTAMP DEFAULT now(),
+    end_time TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS runs_state_idx ON runs(state);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS quarantine;
+DROP TABLE IF EXISTS anomaly_thresholds;
@@ -0,0 +1,27 @@
+-- Thresholds used when screening incoming quotes. The row with asset_type '*'
+-- applies to asset types without their own row.
+CREATE TABLE IF NOT EXISTS anomaly_thresholds (
+    asset_type TEXT PRIMARY KEY,
+    max_return_zscore REAL NOT NULL DEFAULT 6.0,
+    max_volume_ratio REAL NOT NULL DEFAULT 10.0,
+    lookback_days INTEGER NOT NULL DEFAULT 90
+);
+
+INSERT INTO anomaly_thresholds (asset_type) VALUES ('*') ON CONFLICT DO NOTHING;
+
+-- Observations that failed screening and are waiting for review
+CREATE TABLE IF NOT EXISTS quarantine (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    data_type TEXT NOT NULL,
+    ticker TEXT,
+    composite_figi TEXT,
+    event_date DATE,
+    reasons TEXT[] NOT NULL,
+    observation JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending', -- pending, accepted, rejected
+    created_on TIMESTAMP DEFAULT now(),
+    reviewed_on TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS quarantine_status_idx ON quarantine(status);
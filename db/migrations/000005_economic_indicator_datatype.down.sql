@@ -0,0 +1,3 @@
+-- PostgreSQL does not support removing values from an enum type; 'custom' and
+-- 'economic-indicator' are left in place
+SELECT 1;
@@ -0,0 +1,4 @@
+-- the baseline enum was missing a comma and created 'customeconomic-indicator'
+-- in place of these two values
+ALTER TYPE datatype ADD VALUE IF NOT EXISTS 'custom';
+ALTER TYPE datatype ADD VALUE IF NOT EXISTS 'economic-indicator';
@@ -0,0 +1,3 @@
+-- PostgreSQL does not support removing values from an enum type; 'earnings',
+-- 'news', and 'peers' are left in place
+SELECT 1;
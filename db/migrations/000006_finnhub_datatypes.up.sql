@@ -0,0 +1,3 @@
+ALTER TYPE datatype ADD VALUE IF NOT EXISTS 'earnings';
+ALTER TYPE datatype ADD VALUE IF NOT EXISTS 'news';
+ALTER TYPE datatype ADD VALUE IF NOT EXISTS 'peers';
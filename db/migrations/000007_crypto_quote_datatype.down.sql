@@ -0,0 +1,3 @@
+-- PostgreSQL does not support removing values from an enum type; 'crypto-quote'
+-- is left in place
+SELECT 1;
@@ -0,0 +1,15 @@
+-- Assets whose composite FIGI could not be found. Enrichment is retried each
+-- time a provider lists the asset; resolved_figi is set once it succeeds.
+CREATE TABLE IF NOT EXISTS unresolved_assets (
+    ticker TEXT NOT NULL,
+    primary_exchange TEXT NOT NULL,
+    asset_type TEXT NOT NULL DEFAULT '',
+    name TEXT NOT NULL DEFAULT '',
+    synthetic_figi CHARACTER(12),
+    resolved_figi CHARACTER(12),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    first_seen TIMESTAMP NOT NULL DEFAULT now(),
+    last_attempt TIMESTAMP NOT NULL DEFAULT now(),
+    resolved_on TIMESTAMP,
+    PRIMARY KEY (ticker, primary_exchange)
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS runs_start_time_idx;
+
+ALTER TABLE runs DROP COLUMN IF EXISTS estimated_requests;
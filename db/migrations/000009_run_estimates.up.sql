@@ -0,0 +1,3 @@
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS estimated_requests INTEGER DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS runs_start_time_idx ON runs(start_time);
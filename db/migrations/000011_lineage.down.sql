@@ -0,0 +1 @@
+DROP FUNCTION IF EXISTS pvdata_lineage() CASCADE;
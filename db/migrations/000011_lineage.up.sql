@@ -0,0 +1,16 @@
+-- pvdata_lineage stamps each row written to a subscription table with the
+-- subscription, provider, and run that wrote it. The values are read from
+-- settings local to the transaction the rows are saved in; rows written
+-- without them keep the values they were given.
+CREATE OR REPLACE FUNCTION pvdata_lineage()
+  RETURNS trigger
+  LANGUAGE plpgsql AS
+$func$
+BEGIN
+   NEW.subscription_id := coalesce(nullif(current_setting('pvdata.subscription_id', true), '')::uuid, NEW.subscription_id);
+   NEW.provider := coalesce(nullif(current_setting('pvdata.provider', true), ''), NEW.provider);
+   NEW.run_id := coalesce(nullif(current_setting('pvdata.run_id', true), '')::uuid, NEW.run_id);
+   NEW.fetched_at := coalesce(nullif(current_setting('pvdata.fetched_at', true), '')::timestamptz, NEW.fetched_at, now());
+   RETURN NEW;
+END
+$func$;
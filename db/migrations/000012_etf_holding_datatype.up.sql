@@ -0,0 +1 @@
+ALTER TYPE datatype ADD VALUE IF NOT EXISTS 'etf-holding';
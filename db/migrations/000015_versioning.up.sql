@@ -0,0 +1,34 @@
+-- pvdata_versioning keeps the history of rows in system-versioned tables. When
+-- a row changes or is deleted the old version is copied to <table>_history
+-- with valid_to set to the time of the change; valid_from of the current row
+-- records when it was last changed. Updates that only touch lineage or
+-- bookkeeping columns do not create a new version.
+CREATE OR REPLACE FUNCTION pvdata_versioning()
+  RETURNS trigger
+  LANGUAGE plpgsql AS
+$func$
+DECLARE
+   unversioned TEXT[] := ARRAY['subscription_id', 'provider', 'run_id', 'fetched_at', 'valid_from', 'last_updated', 'search'];
+   history     TEXT   := TG_TABLE_NAME || '_history';
+BEGIN
+   IF TG_OP = 'INSERT' THEN
+      NEW.valid_from := coalesce(NEW.valid_from, now());
+      RETURN NEW;
+   END IF;
+
+   IF TG_OP = 'UPDATE' AND (to_jsonb(OLD) - unversioned) = (to_jsonb(NEW) - unversioned) THEN
+      NEW.valid_from := OLD.valid_from;
+      RETURN NEW;
+   END IF;
+
+   EXECUTE format('INSERT INTO %1$I.%2$I SELECT * FROM jsonb_populate_record(NULL::%1$I.%2$I, $1)', TG_TABLE_SCHEMA, history)
+   USING to_jsonb(OLD) || jsonb_build_object('valid_to', now());
+
+   IF TG_OP = 'DELETE' THEN
+      RETURN OLD;
+   END IF;
+
+   NEW.valid_from := now();
+   RETURN NEW;
+END
+$func$;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS asset_events;
+DROP TABLE IF EXISTS figi_mappings;
@@ -0,0 +1,28 @@
+-- When the composite FIGI of each listed ticker was last confirmed with
+-- OpenFIGI. `pvdata figi refresh` re-resolves mappings that are older than a
+-- cutoff or that matched more than one composite FIGI.
+CREATE TABLE IF NOT EXISTS figi_mappings (
+    ticker TEXT NOT NULL,
+    primary_exchange TEXT NOT NULL,
+    composite_figi TEXT NOT NULL DEFAULT '',
+    share_class_figi TEXT NOT NULL DEFAULT '',
+    candidates INTEGER NOT NULL DEFAULT 0,
+    ambiguous BOOLEAN NOT NULL DEFAULT false,
+    mapped_on TIMESTAMP NOT NULL DEFAULT now(),
+    PRIMARY KEY (ticker, primary_exchange)
+);
+
+-- Changes made to assets outside of provider runs, e.g. a ticker that now
+-- maps to a different composite FIGI
+CREATE TABLE IF NOT EXISTS asset_events (
+    id BIGSERIAL PRIMARY KEY,
+    event_time TIMESTAMPTZ NOT NULL DEFAULT now(),
+    asset_table TEXT NOT NULL,
+    ticker TEXT NOT NULL,
+    primary_exchange TEXT NOT NULL DEFAULT '',
+    event_type TEXT NOT NULL,
+    old_value TEXT NOT NULL DEFAULT '',
+    new_value TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS asset_events_ticker_idx ON asset_events(ticker, event_time DESC);
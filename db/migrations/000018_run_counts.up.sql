@@ -0,0 +1,3 @@
+-- Breakdown of a run's observations by kind, e.g. the number of created,
+-- changed, and delisted assets of an asset sync
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS counts JSONB;
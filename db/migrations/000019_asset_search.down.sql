@@ -0,0 +1 @@
+DROP EXTENSION IF EXISTS pg_trgm CASCADE;
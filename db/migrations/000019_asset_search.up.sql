@@ -0,0 +1,2 @@
+-- Trigram matching is used to find assets by approximate ticker or name
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
@@ -0,0 +1,3 @@
+DROP FUNCTION IF EXISTS pvdata_asset_events() CASCADE;
+DROP INDEX IF EXISTS asset_events_composite_figi_idx;
+ALTER TABLE asset_events DROP COLUMN IF EXISTS composite_figi;
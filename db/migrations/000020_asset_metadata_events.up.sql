@@ -0,0 +1,29 @@
+ALTER TABLE asset_events ADD COLUMN IF NOT EXISTS composite_figi TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS asset_events_composite_figi_idx ON asset_events(composite_figi, event_time DESC);
+
+-- pvdata_asset_events records changes to the name, CIK, and website of
+-- assets in the asset_events table, e.g. a company renamed after a merger.
+-- Values that were not known before are not recorded as changes.
+CREATE OR REPLACE FUNCTION pvdata_asset_events()
+  RETURNS trigger
+  LANGUAGE plpgsql AS
+$func$
+BEGIN
+   IF coalesce(OLD.name, '') <> '' AND coalesce(NEW.name, '') <> '' AND OLD.name <> NEW.name THEN
+      INSERT INTO asset_events (asset_table, ticker, composite_figi, primary_exchange, event_type, old_value, new_value)
+      VALUES (TG_TABLE_NAME, NEW.ticker, NEW.composite_figi, coalesce(NEW.primary_exchange, ''), 'name-changed', OLD.name, NEW.name);
+   END IF;
+
+   IF coalesce(OLD.cik, '') <> '' AND coalesce(NEW.cik, '') <> '' AND OLD.cik <> NEW.cik THEN
+      INSERT INTO asset_events (asset_table, ticker, composite_figi, primary_exchange, event_type, old_value, new_value)
+      VALUES (TG_TABLE_NAME, NEW.ticker, NEW.composite_figi, coalesce(NEW.primary_exchange, ''), 'cik-changed', OLD.cik, NEW.cik);
+   END IF;
+
+   IF coalesce(OLD.corporate_url, '') <> '' AND coalesce(NEW.corporate_url, '') <> '' AND OLD.corporate_url <> NEW.corporate_url THEN
+      INSERT INTO asset_events (asset_table, ticker, composite_figi, primary_exchange, event_type, old_value, new_value)
+      VALUES (TG_TABLE_NAME, NEW.ticker, NEW.composite_figi, coalesce(NEW.primary_exchange, ''), 'website-changed', OLD.corporate_url, NEW.corporate_url);
+   END IF;
+
+   RETURN NULL;
+END
+$func$;
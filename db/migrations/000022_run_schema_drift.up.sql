@@ -0,0 +1,3 @@
+-- Provider responses whose format did not match the format the provider
+-- expects, e.g. a renamed CSV column
+ALTER TABLE runs ADD COLUMN IF NOT EXISTS schema_drift JSONB;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS quarantine_composite_figi_idx;
+DROP TABLE IF EXISTS data_quality;
+DROP FUNCTION IF EXISTS pvdata_eod_corrections() CASCADE;
@@ -0,0 +1,58 @@
+-- pvdata_eod_corrections counts how often a provider revised a quote after it
+-- was first saved. Changes to adj_close are not counted because it changes
+-- with every dividend and split.
+CREATE OR REPLACE FUNCTION pvdata_eod_corrections()
+  RETURNS trigger
+  LANGUAGE plpgsql AS
+$func$
+BEGIN
+   NEW.num_corrections := OLD.num_corrections;
+   IF (OLD.open, OLD.high, OLD.low, OLD.close, OLD.volume, OLD.dividend, OLD.split_factor) IS DISTINCT FROM
+      (NEW.open, NEW.high, NEW.low, NEW.close, NEW.volume, NEW.dividend, NEW.split_factor) THEN
+      NEW.num_corrections := OLD.num_corrections + 1;
+   END IF;
+
+   RETURN NEW;
+END
+$func$;
+
+-- Data-quality scorecard of each asset in an EOD table, refreshed after runs
+-- that save quotes to the table
+CREATE TABLE IF NOT EXISTS data_quality (
+    eod_table TEXT NOT NULL,
+    composite_figi TEXT NOT NULL,
+    ticker TEXT NOT NULL,
+    window_start DATE NOT NULL,
+    window_end DATE NOT NULL,
+    expected_sessions INTEGER NOT NULL DEFAULT 0,
+    observed_sessions INTEGER NOT NULL DEFAULT 0,
+    coverage REAL NOT NULL DEFAULT 0,
+    gaps INTEGER NOT NULL DEFAULT 0,
+    corrections INTEGER NOT NULL DEFAULT 0,
+    anomalies INTEGER NOT NULL DEFAULT 0,
+    last_quote DATE,
+    stale_sessions INTEGER NOT NULL DEFAULT 0,
+    refreshed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (eod_table, composite_figi)
+);
+
+CREATE INDEX IF NOT EXISTS quarantine_composite_figi_idx ON quarantine(composite_figi, event_date);
+
+-- count corrections in the EOD tables of existing subscriptions; tables created
+-- later get the column and trigger from the eod data type's migrations
+DO $do$
+DECLARE
+   tbl TEXT;
+BEGIN
+   FOR tbl IN
+      SELECT DISTINCT s.data_tables[i]
+      FROM subscriptions s, generate_subscripts(s.data_types, 1) i
+      WHERE s.data_types[i] = 'eod' AND to_regclass(quote_ident(s.data_tables[i])) IS NOT NULL
+   LOOP
+      EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS num_corrections INTEGER NOT NULL DEFAULT 0', tbl);
+      EXECUTE format('DROP TRIGGER IF EXISTS %I ON %I', tbl || '_corrections', tbl);
+      EXECUTE format('CREATE TRIGGER %I BEFORE UPDATE ON %I FOR EACH ROW EXECUTE PROCEDURE pvdata_eod_corrections()',
+         tbl || '_corrections', tbl);
+   END LOOP;
+END
+$do$;
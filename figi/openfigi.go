@@ -16,10 +16,11 @@ package figi
 
 import (
 	"context"
+	"errors"
 	"time"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/httpclient"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 	"golang.org/x/time/rate"
@@ -29,8 +30,14 @@ const (
 	OPENFIGI_MAPPING_URL string = "https://api.openfigi.com/v3/mapping"
 )
 
+var (
+	ErrMappingFailed = errors.New("openfigi mapping request failed")
+)
+
 type MappingResponse struct {
-	Data []*OpenFigiAsset `json:"data"`
+	Data    []*OpenFigiAsset `json:"data"`
+	Warning string           `json:"warning"`
+	Error   string           `json:"error"`
 }
 
 type OpenFigiAsset struct {
@@ -53,21 +60,26 @@ type OpenFigiQuery struct {
 	MarketSectorDescription string `json:"marketSecDes"`
 }
 
-func rateLimit() *rate.Limiter {
-	dur := (time.Second * 6) / 25
-	openFigiRate := rate.Every(dur)
-	return rate.NewLimiter(openFigiRate, 10)
+// rateLimiter is shared by every caller so that concurrent subscriptions stay
+// within OpenFIGI's rate limit together
+var rateLimiter = rate.NewLimiter(rate.Every((time.Second*6)/25), 10)
+
+// RateLimiter returns the limiter that keeps mapping requests within OpenFIGI's
+// rate limit
+func RateLimiter() *rate.Limiter {
+	return rateLimiter
 }
 
-func mapFigis(query []*OpenFigiQuery) ([]*MappingResponse, error) {
+func mapFigis(ctx context.Context, query []*OpenFigiQuery) ([]*MappingResponse, error) {
 	if len(query) > 100 {
 		log.Error().Msg("programming error - too many assets in request")
 	}
 
 	apiKey := viper.GetString("openfigi.apikey")
 	mappingResponse := make([]*MappingResponse, 0)
-	client := resty.New()
+	client := httpclient.New(ctx)
 	resp, err := client.R().
+		SetContext(ctx).
 		SetHeader("X-OPENFIGI-APIKEY", apiKey).
 		SetBody(query).
 		SetResult(&mappingResponse).
@@ -88,9 +100,7 @@ func mapFigis(query []*OpenFigiQuery) ([]*MappingResponse, error) {
 	return mappingResponse, nil
 }
 
-func Enrich(assets ...*data.Asset) {
-	rateLimiter := rateLimit()
-
+func Enrich(ctx context.Context, assets ...*data.Asset) {
 	emptyFigis := make([]*data.Asset, 0, 100)
 	for _, asset := range assets {
 		if (asset.CompositeFigi == "" || asset.AssetType == data.UnknownAsset) && asset.DelistingDate == "" {
@@ -98,7 +108,7 @@ func Enrich(assets ...*data.Asset) {
 		}
 	}
 
-	figiMap := LookupFigi(emptyFigis, rateLimiter)
+	figiMap := LookupFigi(ctx, emptyFigis, rateLimiter)
 	for _, asset := range emptyFigis {
 		if assetFigi, ok := figiMap[asset.Ticker]; ok {
 			asset.CompositeFigi = assetFigi.CompositeFIGI
@@ -143,7 +153,7 @@ func Enrich(assets ...*data.Asset) {
 	}
 }
 
-func LookupFigi(assets []*data.Asset, rateLimiter *rate.Limiter) map[string]*OpenFigiAsset {
+func LookupFigi(ctx context.Context, assets []*data.Asset, rateLimiter *rate.Limiter) map[string]*OpenFigiAsset {
 	query := make([]*OpenFigiQuery, 0, 100)
 	result := make(map[string]*OpenFigiAsset)
 
@@ -156,11 +166,12 @@ func LookupFigi(assets []*data.Asset, rateLimiter *rate.Limiter) map[string]*Ope
 		})
 
 		if len(query) == 100 {
-			if err := rateLimiter.Wait(context.Background()); err != nil {
-				log.Panic().Err(err).Msg("rate limiter failed")
+			if err := rateLimiter.Wait(ctx); err != nil {
+				log.Warn().Err(err).Msg("stopping figi lookup")
+				return result
 			}
 
-			mappingResponse, _ := mapFigis(query)
+			mappingResponse, _ := mapFigis(ctx, query)
 			for _, resp := range mappingResponse {
 				for _, figiAsset := range resp.Data {
 					result[figiAsset.Ticker] = figiAsset
@@ -171,11 +182,12 @@ func LookupFigi(assets []*data.Asset, rateLimiter *rate.Limiter) map[string]*Ope
 	}
 
 	if len(query) > 0 {
-		if err := rateLimiter.Wait(context.Background()); err != nil {
-			log.Panic().Err(err).Msg("rate limiter failed")
+		if err := rateLimiter.Wait(ctx); err != nil {
+			log.Warn().Err(err).Msg("stopping figi lookup")
+			return result
 		}
 
-		mappingResponse, _ := mapFigis(query)
+		mappingResponse, _ := mapFigis(ctx, query)
 		for _, resp := range mappingResponse {
 			for _, figiAsset := range resp.Data {
 				result[figiAsset.Ticker] = figiAsset
@@ -185,3 +197,57 @@ func LookupFigi(assets []*data.Asset, rateLimiter *rate.Limiter) map[string]*Ope
 
 	return result
 }
+
+// BatchSize returns the number of tickers OpenFIGI accepts in one mapping
+// request, which is smaller for requests without an API key
+func BatchSize() int {
+	if viper.GetString("openfigi.apikey") == "" {
+		return 10
+	}
+	return 100
+}
+
+// Candidates returns every OpenFIGI match for the ticker of each asset in the
+// same order as assets. Unlike LookupFigi, which keeps a single match per
+// ticker, the caller can tell when a ticker maps to more than one security. A
+// nil entry means OpenFIGI reported an error for that asset. At most
+// BatchSize() assets may be passed per call.
+func Candidates(ctx context.Context, assets []*data.Asset, rateLimiter *rate.Limiter) ([][]*OpenFigiAsset, error) {
+	query := make([]*OpenFigiQuery, 0, len(assets))
+	for _, asset := range assets {
+		query = append(query, &OpenFigiQuery{
+			IdType:                  "TICKER",
+			IdValue:                 asset.Ticker,
+			ExchangeCode:            "US",
+			MarketSectorDescription: "Equity",
+		})
+	}
+
+	if err := rateLimiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	mappingResponse, err := mapFigis(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	// each query receives exactly one response; anything else is an error
+	// response for the request as a whole
+	if len(mappingResponse) != len(query) {
+		return nil, ErrMappingFailed
+	}
+
+	candidates := make([][]*OpenFigiAsset, len(assets))
+	for idx, resp := range mappingResponse {
+		if resp.Error != "" {
+			log.Warn().Str("Ticker", assets[idx].Ticker).Str("Error", resp.Error).Msg("openfigi could not map ticker")
+			continue
+		}
+
+		candidates[idx] = make([]*OpenFigiAsset, 0, len(resp.Data))
+		candidates[idx] = append(candidates[idx], resp.Data...)
+	}
+
+	return candidates, nil
+}
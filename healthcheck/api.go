@@ -15,11 +15,12 @@
 package healthcheck
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
 
-	"github.com/go-resty/resty/v2"
+	"github.com/penny-vault/pvdata/httpclient"
 	"github.com/spf13/viper"
 )
 
@@ -43,7 +44,7 @@ type createResp struct {
 }
 
 // Create a new healthchecks.io check and return the id
-func Create(name string, slug string, tags []string, schedule string) (string, error) {
+func Create(ctx context.Context, name string, slug string, tags []string, schedule string) (string, error) {
 	command := createReq{
 		APIKey:   viper.GetString("healthchecks.apikey"),
 		Name:     name,
@@ -56,8 +57,9 @@ func Create(name string, slug string, tags []string, schedule string) (string, e
 
 	result := createResp{}
 
-	client := resty.New()
+	client := httpclient.New(ctx)
 	resp, err := client.R().
+		SetContext(ctx).
 		SetHeader("Content-Type", "application/json").
 		SetBody(command).
 		SetResult(&result).
@@ -78,11 +80,12 @@ func Create(name string, slug string, tags []string, schedule string) (string, e
 }
 
 // Pause monitoring of a health check
-func Delete(id string) error {
+func Delete(ctx context.Context, id string) error {
 	result := createResp{}
 
-	client := resty.New()
+	client := httpclient.New(ctx)
 	resp, err := client.R().
+		SetContext(ctx).
 		SetHeader("Content-Type", "application/json").
 		SetHeader("X-Api-Key", viper.GetString("healthchecks.apikey")).
 		SetResult(&result).
@@ -100,11 +103,12 @@ func Delete(id string) error {
 }
 
 // Pause monitoring of a health check
-func Pause(id string) error {
+func Pause(ctx context.Context, id string) error {
 	result := createResp{}
 
-	client := resty.New()
+	client := httpclient.New(ctx)
 	resp, err := client.R().
+		SetContext(ctx).
 		SetHeader("Content-Type", "application/json").
 		SetHeader("X-Api-Key", viper.GetString("healthchecks.apikey")).
 		SetResult(&result).
@@ -122,11 +126,12 @@ func Pause(id string) error {
 }
 
 // Resume monitoring of a health check
-func Resume(id string) error {
+func Resume(ctx context.Context, id string) error {
 	result := createResp{}
 
-	client := resty.New()
+	client := httpclient.New(ctx)
 	resp, err := client.R().
+		SetContext(ctx).
 		SetHeader("Content-Type", "application/json").
 		SetHeader("X-Api-Key", viper.GetString("healthchecks.apikey")).
 		SetResult(&result).
@@ -142,3 +147,25 @@ func Resume(id string) error {
 
 	return nil
 }
+
+// Ping signals that the job monitored by the health check finished. If failed is
+// true the check is immediately marked as down.
+func Ping(ctx context.Context, id string, failed bool) error {
+	url := fmt.Sprintf("https://hc-ping.com/%s", id)
+	if failed {
+		url += "/fail"
+	}
+
+	client := httpclient.New(ctx)
+	resp, err := client.R().SetContext(ctx).Get(url)
+
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode() != 200 {
+		return fmt.Errorf("%w: %d", ErrStatus, resp.StatusCode())
+	}
+
+	return nil
+}
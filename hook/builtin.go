@@ -0,0 +1,76 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hook
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+)
+
+// newDropZeroVolume drops EOD quotes without any volume, which providers
+// report for days an asset did not trade
+func newDropZeroVolume(arg string) (Hook, error) {
+	if arg != "" {
+		return nil, fmt.Errorf("%w: drop-zero-volume does not take an argument", ErrInvalidHook)
+	}
+
+	return HookFunc(func(ctx context.Context, subscription *library.Subscription, obs *data.Observation) (*data.Observation, error) {
+		if obs.EodQuote != nil && obs.EodQuote.Volume == 0 {
+			return nil, nil
+		}
+		return obs, nil
+	}), nil
+}
+
+// newScalePrices multiplies the prices and dividends of EOD quotes by a
+// factor, e.g. 0.01 to convert prices quoted in cents to dollars
+func newScalePrices(arg string) (Hook, error) {
+	factor, err := strconv.ParseFloat(arg, 64)
+	if err != nil || factor <= 0 {
+		return nil, fmt.Errorf("%w: scale-prices requires a positive factor", ErrInvalidHook)
+	}
+
+	return HookFunc(func(ctx context.Context, subscription *library.Subscription, obs *data.Observation) (*data.Observation, error) {
+		if quote := obs.EodQuote; quote != nil {
+			quote.Open *= factor
+			quote.High *= factor
+			quote.Low *= factor
+			quote.Close *= factor
+			quote.Dividend *= factor
+			quote.PreMarketOpen *= factor
+			quote.AfterHoursClose *= factor
+		}
+		return obs, nil
+	}), nil
+}
+
+// newTag adds a tag to assets
+func newTag(arg string) (Hook, error) {
+	if arg == "" {
+		return nil, fmt.Errorf("%w: tag requires the tag to add", ErrInvalidHook)
+	}
+
+	return HookFunc(func(ctx context.Context, subscription *library.Subscription, obs *data.Observation) (*data.Observation, error) {
+		if asset := obs.AssetObject; asset != nil && !slices.Contains(asset.Tags, arg) {
+			asset.Tags = append(asset.Tags, arg)
+		}
+		return obs, nil
+	}), nil
+}
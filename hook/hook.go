@@ -0,0 +1,187 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package hook transforms and filters observations before they are written to
+// sinks. Subscriptions list the hooks they use in their `hooks` config value,
+// a comma separated list of hook names each optionally followed by a colon and
+// an argument, e.g. `drop-zero-volume,scale-prices:0.01`.
+package hook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"plugin"
+	"strings"
+	"sync"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog"
+)
+
+var (
+	ErrUnknownHook   = errors.New("unknown hook")
+	ErrInvalidHook   = errors.New("invalid hook argument")
+	ErrInvalidPlugin = errors.New("plugin does not export a Hook")
+)
+
+// Hook transforms or filters the observations of a subscription
+type Hook interface {
+	// Apply returns the observation to write, which may be modified in place,
+	// or nil to drop it
+	Apply(ctx context.Context, subscription *library.Subscription, obs *data.Observation) (*data.Observation, error)
+}
+
+// HookFunc adapts a function to the Hook interface
+type HookFunc func(ctx context.Context, subscription *library.Subscription, obs *data.Observation) (*data.Observation, error)
+
+func (fn HookFunc) Apply(ctx context.Context, subscription *library.Subscription, obs *data.Observation) (*data.Observation, error) {
+	return fn(ctx, subscription, obs)
+}
+
+// Factory creates a hook from the argument given in the subscription's config;
+// arg is empty if the hook is listed without one
+type Factory func(arg string) (Hook, error)
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{
+		"drop-zero-volume": newDropZeroVolume,
+		"plugin":           newPlugin,
+		"scale-prices":     newScalePrices,
+		"tag":              newTag,
+	}
+)
+
+// Register makes a hook available to subscriptions under name, replacing any
+// hook already registered with that name
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	factories[strings.ToLower(name)] = factory
+}
+
+// Parse creates the hooks listed in a subscription's `hooks` config value
+func Parse(config string) ([]Hook, error) {
+	hooks := make([]Hook, 0)
+
+	for _, item := range strings.Split(config, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, arg, _ := strings.Cut(item, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		mu.RLock()
+		factory, ok := factories[name]
+		mu.RUnlock()
+
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownHook, name)
+		}
+
+		hook, err := factory(strings.TrimSpace(arg))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		hooks = append(hooks, hook)
+	}
+
+	return hooks, nil
+}
+
+// ForSubscription creates the hooks configured for subscription
+func ForSubscription(subscription *library.Subscription) ([]Hook, error) {
+	return Parse(subscription.Config["hooks"])
+}
+
+// Apply runs obs through each hook in order. It returns nil if a hook dropped
+// the observation.
+func Apply(ctx context.Context, hooks []Hook, subscription *library.Subscription, obs *data.Observation) (*data.Observation, error) {
+	var err error
+	for _, hook := range hooks {
+		if obs, err = hook.Apply(ctx, subscription, obs); err != nil || obs == nil {
+			return nil, err
+		}
+	}
+	return obs, nil
+}
+
+// WrapFetch returns a fetch function that runs the observations of fetch
+// through hooks. Observations that fail a hook are dropped.
+func WrapFetch(hooks []Hook, fetch func(context.Context, *library.Subscription, chan<- *data.Observation, chan<- data.RunSummary)) func(context.Context, *library.Subscription, chan<- *data.Observation, chan<- data.RunSummary) {
+	if len(hooks) == 0 {
+		return fetch
+	}
+
+	return func(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
+		in := make(chan *data.Observation, cap(out))
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+			for obs := range in {
+				transformed, err := Apply(ctx, hooks, subscription, obs)
+				if err != nil {
+					zerolog.Ctx(ctx).Warn().Err(err).Str("DataType", obs.DataType()).Msg("hook failed; dropping observation")
+					continue
+				}
+
+				if transformed != nil {
+					out <- transformed
+				}
+			}
+		}()
+
+		// the run summary is sent after every observation has been passed on
+		summary := make(chan data.RunSummary, 1)
+		fetch(ctx, subscription, in, summary)
+		close(in)
+		<-done
+
+		exitNotification <- <-summary
+	}
+}
+
+// newPlugin loads a hook from a Go plugin built with `go build -buildmode=plugin`.
+// The plugin must export a variable named Hook that implements the Hook interface.
+func newPlugin(path string) (Hook, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%w: plugin requires the path of a shared object", ErrInvalidHook)
+	}
+
+	lib, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	symbol, err := lib.Lookup("Hook")
+	if err != nil {
+		return nil, err
+	}
+
+	switch hook := symbol.(type) {
+	case *Hook:
+		return *hook, nil
+	case Hook:
+		return hook, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPlugin, path)
+	}
+}
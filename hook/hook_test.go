@@ -0,0 +1,128 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package hook_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/hook"
+	"github.com/penny-vault/pvdata/library"
+)
+
+var _ = Describe("Hooks", func() {
+	var (
+		ctx          context.Context
+		subscription *library.Subscription
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		subscription = &library.Subscription{Config: map[string]string{}}
+	})
+
+	quote := func(volume float64) *data.Observation {
+		return &data.Observation{EodQuote: &data.Eod{Open: 100, High: 110, Low: 90, Close: 105, Volume: volume}}
+	}
+
+	It("parses an empty config", func() {
+		hooks, err := hook.Parse("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hooks).To(BeEmpty())
+	})
+
+	It("rejects unknown hooks", func() {
+		_, err := hook.Parse("drop-zero-volume, does-not-exist")
+		Expect(err).To(MatchError(hook.ErrUnknownHook))
+	})
+
+	It("rejects invalid arguments", func() {
+		_, err := hook.Parse("scale-prices:abc")
+		Expect(err).To(MatchError(hook.ErrInvalidHook))
+	})
+
+	It("drops quotes without volume", func() {
+		hooks, err := hook.Parse("drop-zero-volume")
+		Expect(err).NotTo(HaveOccurred())
+
+		obs, err := hook.Apply(ctx, hooks, subscription, quote(0))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(obs).To(BeNil())
+
+		obs, err = hook.Apply(ctx, hooks, subscription, quote(1000))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(obs).NotTo(BeNil())
+	})
+
+	It("applies hooks in order", func() {
+		hooks, err := hook.ForSubscription(&library.Subscription{Config: map[string]string{"hooks": "scale-prices:0.01, drop-zero-volume"}})
+		Expect(err).NotTo(HaveOccurred())
+
+		obs, err := hook.Apply(ctx, hooks, subscription, quote(1000))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(obs.EodQuote.Close).To(BeNumerically("~", 1.05))
+		Expect(obs.EodQuote.Volume).To(Equal(1000.0))
+	})
+
+	It("tags assets once", func() {
+		hooks, err := hook.Parse("tag:vendor,tag:vendor")
+		Expect(err).NotTo(HaveOccurred())
+
+		obs, err := hook.Apply(ctx, hooks, subscription, &data.Observation{AssetObject: &data.Asset{Ticker: "SPY"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(obs.AssetObject.Tags).To(Equal([]string{"vendor"}))
+	})
+
+	It("uses registered hooks", func() {
+		hook.Register("close-only", func(arg string) (hook.Hook, error) {
+			return hook.HookFunc(func(ctx context.Context, subscription *library.Subscription, obs *data.Observation) (*data.Observation, error) {
+				if obs.EodQuote != nil {
+					obs.EodQuote.Open, obs.EodQuote.High, obs.EodQuote.Low = 0, 0, 0
+				}
+				return obs, nil
+			}), nil
+		})
+
+		hooks, err := hook.Parse("close-only")
+		Expect(err).NotTo(HaveOccurred())
+
+		obs, err := hook.Apply(ctx, hooks, subscription, quote(1000))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(obs.EodQuote.Open).To(Equal(0.0))
+		Expect(obs.EodQuote.Close).To(Equal(105.0))
+	})
+
+	It("transforms the observations of a fetch", func() {
+		hooks, err := hook.Parse("drop-zero-volume")
+		Expect(err).NotTo(HaveOccurred())
+
+		fetch := hook.WrapFetch(hooks, func(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
+			out <- quote(0)
+			out <- quote(1000)
+			exitNotification <- data.RunSummary{Status: data.RunSuccess, EndTime: time.Now()}
+		})
+
+		out := make(chan *data.Observation, 10)
+		exitNotification := make(chan data.RunSummary, 1)
+		fetch(ctx, subscription, out, exitNotification)
+
+		Expect(<-exitNotification).To(HaveField("Status", data.RunSuccess))
+		Expect(out).To(HaveLen(1))
+	})
+})
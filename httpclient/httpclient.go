@@ -0,0 +1,108 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package httpclient creates the HTTP clients pvdata uses to call external
+// services. Clients created from a context that carries a Limiter share its
+// cap on the number of requests in flight.
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/go-resty/resty/v2"
+)
+
+type limiterKey struct{}
+
+// Limiter caps the number of HTTP requests in-flight across all clients that
+// share it
+type Limiter struct {
+	slots chan struct{}
+}
+
+// NewLimiter creates a limiter that allows at most n concurrent requests
+func NewLimiter(n int) *Limiter {
+	return &Limiter{
+		slots: make(chan struct{}, n),
+	}
+}
+
+// WithLimiter returns a context that causes clients created from it to share limiter
+func WithLimiter(ctx context.Context, limiter *Limiter) context.Context {
+	return context.WithValue(ctx, limiterKey{}, limiter)
+}
+
+// New returns a resty client limited by the limiter set on ctx, if any
+func New(ctx context.Context) *resty.Client {
+	client := resty.New()
+
+	if transport := Transport(ctx, http.DefaultTransport); transport != http.DefaultTransport {
+		client.SetTransport(transport)
+	}
+
+	return client
+}
+
+// Transport wraps next so that its requests hold a slot of the limiter set on
+// ctx; next is returned unchanged if ctx has no limiter
+func Transport(ctx context.Context, next http.RoundTripper) http.RoundTripper {
+	limiter, ok := ctx.Value(limiterKey{}).(*Limiter)
+	if !ok || limiter == nil {
+		return next
+	}
+
+	return &limitedTransport{
+		limiter: limiter,
+		next:    next,
+	}
+}
+
+// limitedTransport holds a limiter slot from the time a request is sent until
+// its response body is closed
+type limitedTransport struct {
+	limiter *Limiter
+	next    http.RoundTripper
+}
+
+func (transport *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	select {
+	case transport.limiter.slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	release := sync.OnceFunc(func() { <-transport.limiter.slots })
+
+	resp, err := transport.next.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (body *releasingBody) Close() error {
+	defer body.release()
+	return body.ReadCloser.Close()
+}
@@ -0,0 +1,280 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrInvalidSpec   = errors.New("subscription spec must have a name, provider, and dataset")
+	ErrDuplicateSpec = errors.New("subscription is declared more than once")
+)
+
+const defaultSchedule = "0 0 * * 1-5"
+
+// SubscriptionSpec declares a subscription the library should have. Specs are
+// matched to existing subscriptions by provider, dataset, and name.
+type SubscriptionSpec struct {
+	Name     string            `mapstructure:"name"`
+	Provider string            `mapstructure:"provider"`
+	Dataset  string            `mapstructure:"dataset"`
+	Schedule string            `mapstructure:"schedule"`
+	Active   *bool             `mapstructure:"active"`
+	Sinks    []string          `mapstructure:"sinks"`
+	Hooks    []string          `mapstructure:"hooks"`
+	Config   map[string]string `mapstructure:"config"`
+
+	// DataTypes produced by the dataset; filled in from the provider
+	DataTypes []string `mapstructure:"-"`
+}
+
+type ApplyAction string
+
+const (
+	ApplyCreate     ApplyAction = "create"
+	ApplyUpdate     ApplyAction = "update"
+	ApplyDeactivate ApplyAction = "deactivate"
+	ApplyUnchanged  ApplyAction = "unchanged"
+)
+
+// Change describes how Apply reconciled a single subscription
+type Change struct {
+	Action       ApplyAction
+	Subscription *Subscription
+
+	// Fields that were updated
+	Fields []string
+}
+
+func (spec *SubscriptionSpec) key() string {
+	return fmt.Sprintf("%s/%s/%s", spec.Provider, spec.Dataset, spec.Name)
+}
+
+func (subscription *Subscription) key() string {
+	return fmt.Sprintf("%s/%s/%s", subscription.Provider, subscription.Dataset, subscription.Name)
+}
+
+// config returns the subscription config described by spec
+func (spec *SubscriptionSpec) config() map[string]string {
+	config := make(map[string]string, len(spec.Config)+2)
+	maps.Copy(config, spec.Config)
+	if len(spec.Sinks) > 0 {
+		config["sinks"] = strings.Join(spec.Sinks, ",")
+	}
+
+	if len(spec.Hooks) > 0 {
+		config["hooks"] = strings.Join(spec.Hooks, ",")
+	}
+
+	return config
+}
+
+// schedule returns the spec's schedule; if it is not set the schedule recommended
+// by the provider's subscription template is used
+func (spec *SubscriptionSpec) schedule() string {
+	if spec.Schedule != "" {
+		return spec.Schedule
+	}
+
+	for _, template := range Templates(spec.Provider) {
+		if template.Dataset == spec.Dataset {
+			return template.Schedule
+		}
+	}
+
+	return defaultSchedule
+}
+
+// Apply reconciles the library's subscriptions with specs. Declared subscriptions
+// that do not exist are created and existing ones are updated to match. When
+// prune is set active subscriptions that are not declared are deactivated; their
+// data is kept. If dryRun is set the changes are computed but not saved.
+func (myLibrary *Library) Apply(ctx context.Context, specs []*SubscriptionSpec, prune, dryRun bool) ([]*Change, error) {
+	declared := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		if spec.Name == "" || spec.Provider == "" || spec.Dataset == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSpec, spec.key())
+		}
+
+		if declared[spec.key()] {
+			return nil, fmt.Errorf("%w: %q", ErrDuplicateSpec, spec.key())
+		}
+		declared[spec.key()] = true
+	}
+
+	existing, err := myLibrary.Subscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	subscriptions := make(map[string]*Subscription, len(existing))
+	for _, subscription := range existing {
+		if other, ok := subscriptions[subscription.key()]; !ok || (!other.Active && subscription.Active) {
+			subscriptions[subscription.key()] = subscription
+		}
+	}
+
+	changes := make([]*Change, 0, len(specs))
+	for _, spec := range specs {
+		var change *Change
+		if subscription, ok := subscriptions[spec.key()]; ok {
+			change, err = myLibrary.applyUpdate(ctx, subscription, spec, dryRun)
+		} else {
+			change, err = myLibrary.applyCreate(ctx, spec, dryRun)
+		}
+
+		if err != nil {
+			return changes, err
+		}
+
+		changes = append(changes, change)
+	}
+
+	if !prune {
+		return changes, nil
+	}
+
+	for _, subscription := range existing {
+		if declared[subscription.key()] || !subscription.Active {
+			continue
+		}
+
+		if !dryRun {
+			if err := subscription.Deactivate(ctx); err != nil {
+				return changes, err
+			}
+		}
+
+		subscription.Active = false
+		changes = append(changes, &Change{Action: ApplyDeactivate, Subscription: subscription})
+	}
+
+	return changes, nil
+}
+
+func (myLibrary *Library) applyCreate(ctx context.Context, spec *SubscriptionSpec, dryRun bool) (*Change, error) {
+	subscription := &Subscription{
+		ID:        uuid.New(),
+		Name:      spec.Name,
+		Provider:  spec.Provider,
+		Dataset:   spec.Dataset,
+		Config:    spec.config(),
+		DataTypes: spec.DataTypes,
+		Schedule:  spec.schedule(),
+		Active:    spec.Active == nil || *spec.Active,
+		Library:   myLibrary,
+	}
+
+	subscription.ComputeTableNames()
+
+	if dryRun {
+		return &Change{Action: ApplyCreate, Subscription: subscription}, nil
+	}
+
+	if err := subscription.Save(ctx); err != nil {
+		return nil, err
+	}
+
+	// subscriptions are saved as active
+	if !subscription.Active {
+		if err := subscription.Deactivate(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Info().Str("Subscription", subscription.key()).Str("ID", subscription.ID.String()).Msg("created subscription")
+
+	return &Change{Action: ApplyCreate, Subscription: subscription}, nil
+}
+
+func (myLibrary *Library) applyUpdate(ctx context.Context, subscription *Subscription, spec *SubscriptionSpec, dryRun bool) (*Change, error) {
+	change := &Change{Action: ApplyUnchanged, Subscription: subscription}
+
+	if config := spec.config(); !maps.Equal(subscription.Config, config) {
+		subscription.Config = config
+		change.Fields = append(change.Fields, "config")
+	}
+
+	if schedule := spec.schedule(); subscription.Schedule != schedule {
+		subscription.Schedule = schedule
+		change.Fields = append(change.Fields, "schedule")
+	}
+
+	if len(change.Fields) > 0 && !dryRun {
+		if err := subscription.Update(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if active := spec.Active == nil || *spec.Active; subscription.Active != active {
+		if !dryRun {
+			var err error
+			if active {
+				err = subscription.Activate(ctx)
+			} else {
+				err = subscription.Deactivate(ctx)
+			}
+
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		subscription.Active = active
+		change.Fields = append(change.Fields, "active")
+	}
+
+	if len(change.Fields) > 0 {
+		change.Action = ApplyUpdate
+		if !dryRun {
+			log.Info().Str("Subscription", subscription.key()).Strs("Fields", change.Fields).Msg("updated subscription")
+		}
+	}
+
+	return change, nil
+}
+
+// ApplyMetadata sets the library name and owner, creating the library record
+// if the database does not have one yet
+func (myLibrary *Library) ApplyMetadata(ctx context.Context) error {
+	conn, err := myLibrary.Pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	var name, owner string
+	err = conn.QueryRow(ctx, "SELECT name, owner FROM library").Scan(&name, &owner)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return myLibrary.SaveDB(ctx)
+	case err != nil:
+		return err
+	case name == myLibrary.Name && owner == myLibrary.Owner:
+		return nil
+	}
+
+	_, err = conn.Exec(ctx, `UPDATE library SET name=$1, owner=$2`, myLibrary.Name, myLibrary.Owner)
+	return err
+}
@@ -0,0 +1,418 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	manifestFileName = "manifest.json"
+
+	// snapshotFormatVersion is incremented when the layout of a snapshot changes
+	snapshotFormatVersion = 1
+)
+
+var (
+	ErrUnsupportedSnapshot = errors.New("snapshot was written in an unsupported format")
+	ErrSnapshotTooNew      = errors.New("snapshot was taken from a library with a newer schema; run migrations first")
+	ErrLibraryNotEmpty     = errors.New("library already has subscriptions; restore requires an empty library")
+)
+
+// Manifest describes the contents of a library snapshot
+type Manifest struct {
+	FormatVersion    int                     `json:"formatVersion"`
+	Library          string                  `json:"library"`
+	Owner            string                  `json:"owner"`
+	CreatedAt        time.Time               `json:"createdAt"`
+	MigrationVersion uint                    `json:"migrationVersion"`
+	Subscriptions    []*ManifestSubscription `json:"subscriptions"`
+	Tables           []*ManifestTable        `json:"tables"`
+}
+
+// ManifestSubscription records a subscription and the schema version of its tables
+type ManifestSubscription struct {
+	ID            uuid.UUID         `json:"id"`
+	Name          string            `json:"name"`
+	Provider      string            `json:"provider"`
+	Dataset       string            `json:"dataset"`
+	Config        map[string]string `json:"config"`
+	DataTypes     []string          `json:"dataTypes"`
+	DataTables    []string          `json:"dataTables"`
+	SchemaVersion int               `json:"schemaVersion"`
+}
+
+// ManifestTable records a table exported in PostgreSQL's COPY text format
+type ManifestTable struct {
+	Name    string   `json:"name"`
+	File    string   `json:"file"`
+	Columns []string `json:"columns"`
+	Rows    int64    `json:"rows"`
+}
+
+// Snapshot exports every table in the library to dest along with a manifest
+// of schema versions and subscriptions. Tables are read in a single
+// transaction so the snapshot is consistent. Each table is stored as a gzipped
+// file in the COPY text format used by pg_dump. Partitions that were moved to
+// the archive schema are not included.
+func (myLibrary *Library) Snapshot(ctx context.Context, dest string) (*Manifest, error) {
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return nil, err
+	}
+
+	conn, err := myLibrary.Pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	tx, err := conn.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Error().Err(err).Msg("error rollingback tx")
+		}
+	}()
+
+	manifest := &Manifest{
+		FormatVersion: snapshotFormatVersion,
+		CreatedAt:     time.Now().UTC(),
+	}
+
+	if err := tx.QueryRow(ctx, "SELECT name, owner FROM library").Scan(&manifest.Library, &manifest.Owner); err != nil {
+		return nil, err
+	}
+
+	if err := tx.QueryRow(ctx, "SELECT version FROM schema_migrations").Scan(&manifest.MigrationVersion); err != nil {
+		return nil, err
+	}
+
+	subscriptions, err := myLibrary.Subscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, subscription := range subscriptions {
+		manifest.Subscriptions = append(manifest.Subscriptions, &ManifestSubscription{
+			ID:            subscription.ID,
+			Name:          subscription.Name,
+			Provider:      subscription.Provider,
+			Dataset:       subscription.Dataset,
+			Config:        subscription.Config,
+			DataTypes:     subscription.DataTypes,
+			DataTables:    subscription.DataTables,
+			SchemaVersion: subscription.SchemaVersion,
+		})
+	}
+
+	tables, err := libraryTables(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, table := range tables {
+		columns, err := tableColumns(ctx, tx, table)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := &ManifestTable{
+			Name:    table,
+			File:    table + ".copy.gz",
+			Columns: columns,
+		}
+
+		rows, err := exportTable(ctx, tx, entry, filepath.Join(dest, entry.File))
+		if err != nil {
+			return nil, fmt.Errorf("exporting %s: %w", table, err)
+		}
+
+		entry.Rows = rows
+		manifest.Tables = append(manifest.Tables, entry)
+
+		log.Info().Str("Table", table).Int64("Rows", rows).Msg("exported table")
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	contents, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(filepath.Join(dest, manifestFileName), contents, 0o644); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// Restore loads a snapshot written by Snapshot into the library. The library
+// must already be migrated to at least the snapshot's schema version and must
+// not have any subscriptions. Subscription tables are created with the current
+// schema so snapshots of older libraries are brought up-to-date the next time
+// the subscription runs.
+func (myLibrary *Library) Restore(ctx context.Context, src string) (*Manifest, error) {
+	contents, err := os.ReadFile(filepath.Join(src, manifestFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+	if err := json.Unmarshal(contents, manifest); err != nil {
+		return nil, err
+	}
+
+	if manifest.FormatVersion != snapshotFormatVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedSnapshot, manifest.FormatVersion)
+	}
+
+	conn, err := myLibrary.Pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Error().Err(err).Msg("error rollingback tx")
+		}
+	}()
+
+	var migrationVersion uint
+	if err := tx.QueryRow(ctx, "SELECT version FROM schema_migrations").Scan(&migrationVersion); err != nil {
+		return nil, err
+	}
+
+	if migrationVersion < manifest.MigrationVersion {
+		return nil, ErrSnapshotTooNew
+	}
+
+	var numSubscriptions int
+	if err := tx.QueryRow(ctx, "SELECT count(*) FROM subscriptions").Scan(&numSubscriptions); err != nil {
+		return nil, err
+	}
+
+	if numSubscriptions > 0 {
+		return nil, ErrLibraryNotEmpty
+	}
+
+	// tables owned by subscriptions, including the history tables of versioned
+	// data types, are created from their data type's schema; the remaining
+	// tables are created by migrations
+	subscriptionTables := make(map[string]bool)
+	for _, entry := range manifest.Subscriptions {
+		for _, table := range entry.DataTables {
+			subscriptionTables[table] = true
+			subscriptionTables[table+"_history"] = true
+		}
+	}
+
+	libraryTables := make([]string, 0, len(manifest.Tables))
+	for _, table := range manifest.Tables {
+		if !subscriptionTables[table.Name] {
+			libraryTables = append(libraryTables, pgx.Identifier{table.Name}.Sanitize())
+		}
+	}
+
+	if len(libraryTables) > 0 {
+		if _, err := tx.Exec(ctx, fmt.Sprintf("TRUNCATE %s CASCADE", strings.Join(libraryTables, ", "))); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, entry := range manifest.Subscriptions {
+		subscription := &Subscription{
+			ID:            entry.ID,
+			Name:          entry.Name,
+			Provider:      entry.Provider,
+			Dataset:       entry.Dataset,
+			Config:        entry.Config,
+			DataTypes:     entry.DataTypes,
+			DataTables:    entry.DataTables,
+			DataTablesMap: make(map[string]string, len(entry.DataTypes)),
+			Library:       myLibrary,
+		}
+
+		for idx, dataType := range entry.DataTypes {
+			subscription.DataTablesMap[dataType] = entry.DataTables[idx]
+		}
+
+		if err := subscription.createTables(ctx, tx); err != nil {
+			return nil, err
+		}
+
+		if err := subscription.managePartitionsWithTransaction(ctx, tx); err != nil {
+			return nil, err
+		}
+	}
+
+	// tables are listed in the manifest so that referenced tables are loaded first
+	for _, table := range manifest.Tables {
+		if err := importTable(ctx, tx, table, filepath.Join(src, table.File)); err != nil {
+			return nil, fmt.Errorf("importing %s: %w", table.Name, err)
+		}
+
+		log.Info().Str("Table", table.Name).Int64("Rows", table.Rows).Msg("imported table")
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// libraryTables lists the tables of the library ordered so that tables
+// referenced by a foreign key come before the tables that reference them.
+// Partitions are exported through their parent table.
+func libraryTables(ctx context.Context, tx pgx.Tx) ([]string, error) {
+	rows, err := tx.Query(ctx, `SELECT c.relname,
+coalesce(array_agg(ref.relname) FILTER (WHERE ref.relname IS NOT NULL AND ref.relname <> c.relname), '{}')
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+LEFT JOIN pg_constraint con ON con.conrelid = c.oid AND con.contype = 'f'
+LEFT JOIN pg_class ref ON ref.oid = con.confrelid
+WHERE n.nspname = 'public' AND c.relkind IN ('r', 'p') AND NOT c.relispartition AND c.relname <> 'schema_migrations'
+GROUP BY c.relname
+ORDER BY c.relname`)
+	if err != nil {
+		return nil, err
+	}
+
+	references := make(map[string][]string)
+	names := make([]string, 0)
+	for rows.Next() {
+		var name string
+		var refs []string
+		if err := rows.Scan(&name, &refs); err != nil {
+			return nil, err
+		}
+
+		names = append(names, name)
+		references[name] = refs
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ordered := make([]string, 0, len(names))
+	visited := make(map[string]bool, len(names))
+
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+
+		visited[name] = true
+		for _, ref := range references[name] {
+			visit(ref)
+		}
+
+		ordered = append(ordered, name)
+	}
+
+	for _, name := range names {
+		visit(name)
+	}
+
+	return ordered, nil
+}
+
+// tableColumns returns the columns of table that are not generated
+func tableColumns(ctx context.Context, tx pgx.Tx, table string) ([]string, error) {
+	rows, err := tx.Query(ctx, `SELECT attname FROM pg_attribute
+WHERE attrelid = to_regclass($1) AND attnum > 0 AND NOT attisdropped AND attgenerated = ''
+ORDER BY attnum`, pgx.Identifier{table}.Sanitize())
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// exportTable writes the rows of table to a gzipped file in COPY text format
+func exportTable(ctx context.Context, tx pgx.Tx, table *ManifestTable, fn string) (int64, error) {
+	fh, err := os.Create(fn)
+	if err != nil {
+		return 0, err
+	}
+	defer fh.Close()
+
+	writer := gzip.NewWriter(fh)
+
+	sql := fmt.Sprintf("COPY (SELECT %s FROM %s) TO STDOUT", quoteColumns(table.Columns), pgx.Identifier{table.Name}.Sanitize())
+	tag, err := tx.Conn().PgConn().CopyTo(ctx, writer, sql)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), fh.Close()
+}
+
+// importTable loads a file written by exportTable into table
+func importTable(ctx context.Context, tx pgx.Tx, table *ManifestTable, fn string) error {
+	fh, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	reader, err := gzip.NewReader(fh)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	sql := fmt.Sprintf("COPY %s (%s) FROM STDIN", pgx.Identifier{table.Name}.Sanitize(), quoteColumns(table.Columns))
+	_, err = tx.Conn().PgConn().CopyFrom(ctx, reader, sql)
+	return err
+}
+
+func quoteColumns(columns []string) string {
+	quoted := make([]string, len(columns))
+	for idx, column := range columns {
+		quoted[idx] = pgx.Identifier{column}.Sanitize()
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,117 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrNoTemplates = errors.New("provider does not have any subscription templates")
+)
+
+// SubscriptionTemplate describes a subscription with recommended settings that
+// Bootstrap creates for a provider
+type SubscriptionTemplate struct {
+	Name      string
+	Dataset   string
+	DataTypes []string
+	Schedule  string
+
+	// Config holds default configuration values; the api key is added by Bootstrap
+	Config map[string]string
+}
+
+var (
+	templatesMu sync.RWMutex
+	templates   = make(map[string][]*SubscriptionTemplate)
+)
+
+// RegisterTemplates sets the subscriptions Bootstrap creates for a provider
+func RegisterTemplates(providerName string, providerTemplates ...*SubscriptionTemplate) {
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	templates[providerName] = providerTemplates
+}
+
+// Templates returns the subscription templates registered for a provider
+func Templates(providerName string) []*SubscriptionTemplate {
+	templatesMu.RLock()
+	defer templatesMu.RUnlock()
+	return templates[providerName]
+}
+
+// Bootstrap creates the default set of subscriptions for a provider, e.g. assets
+// daily, EOD quotes nightly, and fundamentals weekly. Datasets the library is
+// already subscribed to for the provider are skipped so Bootstrap may be run
+// more than once. The newly created subscriptions are returned.
+func (myLibrary *Library) Bootstrap(ctx context.Context, providerName, apiKey string) ([]*Subscription, error) {
+	providerTemplates := Templates(providerName)
+	if len(providerTemplates) == 0 {
+		return nil, ErrNoTemplates
+	}
+
+	existing, err := myLibrary.Subscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	subscribed := make(map[string]bool, len(existing))
+	for _, subscription := range existing {
+		if subscription.Provider == providerName {
+			subscribed[subscription.Dataset] = true
+		}
+	}
+
+	created := make([]*Subscription, 0, len(providerTemplates))
+	for _, template := range providerTemplates {
+		if subscribed[template.Dataset] {
+			log.Info().Str("Provider", providerName).Str("Dataset", template.Dataset).Msg("already subscribed to dataset; skipping")
+			continue
+		}
+
+		config := make(map[string]string, len(template.Config)+1)
+		maps.Copy(config, template.Config)
+		config["apiKey"] = apiKey
+
+		subscription := &Subscription{
+			ID:        uuid.New(),
+			Name:      template.Name,
+			Provider:  providerName,
+			Dataset:   template.Dataset,
+			Config:    config,
+			DataTypes: template.DataTypes,
+			Schedule:  template.Schedule,
+			Active:    true,
+			Library:   myLibrary,
+		}
+
+		subscription.ComputeTableNames()
+
+		if err := subscription.Save(ctx); err != nil {
+			return created, err
+		}
+
+		created = append(created, subscription)
+	}
+
+	return created, nil
+}
@@ -16,6 +16,7 @@ package library
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -25,6 +26,7 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/penny-vault/pvdata/data"
 	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
 )
 
 type Library struct {
@@ -33,6 +35,31 @@ type Library struct {
 	Owner string
 
 	Pool *pgxpool.Pool
+
+	// PoolConfig tunes Pool; it is read from the `db` section of the
+	// configuration if it is not set before the library connects
+	PoolConfig PoolConfig
+
+	// Journal, if set, is acknowledged as observations are saved
+	Journal *Journal
+
+	// lockPool holds the sessions of run locks; it is created by the first lock
+	lockMu   sync.Mutex
+	lockPool *pgxpool.Pool
+}
+
+// newPool creates a connection pool for dbURL tuned with poolConfig
+func newPool(dbURL string, poolConfig PoolConfig) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(dbURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := poolConfig.apply(config); err != nil {
+		return nil, err
+	}
+
+	return pgxpool.NewWithConfig(context.Background(), config)
 }
 
 // Connect to the database configured for the library
@@ -41,7 +68,11 @@ func (myLibrary *Library) Connect(ctx context.Context) error {
 		return nil
 	}
 
-	pool, err := pgxpool.New(context.Background(), myLibrary.DBUrl)
+	if myLibrary.PoolConfig == (PoolConfig{}) {
+		myLibrary.PoolConfig = PoolConfigFromViper()
+	}
+
+	pool, err := newPool(myLibrary.DBUrl, myLibrary.PoolConfig)
 	if err != nil {
 		return err
 	}
@@ -53,11 +84,19 @@ func (myLibrary *Library) Connect(ctx context.Context) error {
 // Close the database pool
 func (myLibrary *Library) Close() {
 	myLibrary.Pool.Close()
+
+	myLibrary.lockMu.Lock()
+	defer myLibrary.lockMu.Unlock()
+
+	if myLibrary.lockPool != nil {
+		myLibrary.lockPool.Close()
+	}
 }
 
 // NewFromDB creates a new library object with values from the database
 func NewFromDB(ctx context.Context, dbURL string) (*Library, error) {
-	pool, err := pgxpool.New(context.Background(), dbURL)
+	poolConfig := PoolConfigFromViper()
+	pool, err := newPool(dbURL, poolConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -69,8 +108,9 @@ func NewFromDB(ctx context.Context, dbURL string) (*Library, error) {
 	defer conn.Release()
 
 	myLibrary := Library{
-		DBUrl: dbURL,
-		Pool:  pool,
+		DBUrl:      dbURL,
+		Pool:       pool,
+		PoolConfig: poolConfig,
 	}
 
 	if err := conn.QueryRow(ctx, "SELECT name, owner FROM library").Scan(&myLibrary.Name, &myLibrary.Owner); err != nil {
@@ -174,70 +214,187 @@ func (myLibrary *Library) SaveObservations(queue <-chan *data.Observation, wg *s
 		subscription, ok := subscriptions[elem.SubscriptionID]
 		if !ok {
 			log.Error().Str("SubscriptionID", elem.SubscriptionID.String()).Str("SubscriptionName", elem.SubscriptionName).Msg("subscription not found")
+			if myLibrary.Journal != nil && elem.JournalSeq != 0 {
+				myLibrary.Journal.Nack(elem.JournalSeq)
+			}
 			continue
 		}
 
-		var filer data.Filer
-		if filerPath, ok := subscription.Config["filer"]; ok {
-			filer = data.NewFilerFromString(filerPath)
-		}
+		err := myLibrary.SaveObservation(ctx, conn, subscription, elem)
 
-		if elem.AssetObject != nil {
-			if filer != nil {
-				err := elem.AssetObject.SaveFiles(ctx, filer)
-				if err != nil {
-					log.Error().Err(err).Msg("cannot save asset files")
-					continue
-				}
+		if myLibrary.Journal != nil && elem.JournalSeq != 0 {
+			if err != nil {
+				myLibrary.Journal.Nack(elem.JournalSeq)
+			} else if err := myLibrary.Journal.Ack(elem.JournalSeq); err != nil {
+				log.Error().Err(err).Msg("could not acknowledge journal entry")
 			}
+		}
+	}
+}
+
+// SaveObservation writes each data object in the observation to its subscription's table
+func (myLibrary *Library) SaveObservation(ctx context.Context, conn *pgxpool.Conn, subscription *Subscription, elem *data.Observation) error {
+	var saveErr error
+
+	var filer data.Filer
+	if filerPath, ok := subscription.Config["filer"]; ok {
+		filer = data.NewFilerFromString(filerPath)
+	}
 
-			if err := elem.AssetObject.SaveDB(ctx, subscription.DataTablesMap[data.AssetKey], conn); err != nil {
-				log.Error().Err(err).Msg("cannot save asset to database")
+	provider := elem.Provider
+	if provider == "" {
+		provider = subscription.Provider
+	}
+
+	ctx = data.WithLineage(ctx, &data.Lineage{
+		SubscriptionID: subscription.ID,
+		Provider:       provider,
+		RunID:          elem.RunID,
+		FetchedAt:      elem.FetchedAt,
+	})
+
+	if elem.AssetObject != nil {
+		if filer != nil {
+			err := elem.AssetObject.SaveFiles(ctx, filer)
+			if err != nil {
+				log.Error().Err(err).Msg("cannot save asset files")
+				return err
 			}
 		}
 
-		if elem.CustomObject != nil {
-			if err := elem.CustomObject.SaveDB(ctx, subscription.DataTablesMap[data.CustomKey], conn); err != nil {
-				log.Error().Err(err).Msg("cannot save custom data to database")
-			}
+		// datasets that discover new assets, such as listing calendars, do not
+		// have an asset table of their own and add them to the default table
+		assetTable, ok := subscription.DataTablesMap[data.AssetKey]
+		if !ok {
+			assetTable = viper.GetString("default.asset_table")
 		}
 
-		if elem.EconomicIndicator != nil {
-			if err := elem.EconomicIndicator.SaveDB(ctx, subscription.DataTablesMap[data.EconomicIndicatorKey], conn); err != nil {
-				log.Error().Err(err).Msg("cannot save economic indicator to database")
-			}
+		if err := elem.AssetObject.SaveDB(ctx, assetTable, conn); err != nil {
+			log.Error().Err(err).Msg("cannot save asset to database")
+			saveErr = errors.Join(saveErr, err)
 		}
+	}
 
-		if elem.EodQuote != nil {
-			if err := elem.EodQuote.SaveDB(ctx, subscription.DataTablesMap[data.EODKey], conn); err != nil {
-				log.Error().Err(err).Msg("cannot save eod quote to database")
-			}
+	if elem.Bar != nil {
+		if err := elem.Bar.SaveDB(ctx, subscription.DataTablesMap[data.BarKey], conn); err != nil {
+			log.Error().Err(err).Msg("cannot save bar to database")
+			saveErr = errors.Join(saveErr, err)
 		}
+	}
 
-		if elem.Fundamental != nil {
-			if err := elem.Fundamental.SaveDB(ctx, subscription.DataTablesMap[data.FundamentalsKey], conn); err != nil {
-				log.Error().Err(err).Msg("cannot save fundamental to database")
-			}
+	if elem.CloseDiscrepancy != nil {
+		if err := elem.CloseDiscrepancy.SaveDB(ctx, subscription.DataTablesMap[data.CloseDiscrepancyKey], conn); err != nil {
+			log.Error().Err(err).Msg("cannot save close discrepancy to database")
+			saveErr = errors.Join(saveErr, err)
 		}
+	}
 
-		if elem.MarketHoliday != nil {
-			if err := elem.MarketHoliday.SaveDB(ctx, subscription.DataTablesMap[data.MarketHolidaysKey], conn); err != nil {
-				log.Error().Err(err).Msg("cannot save market holiday to database")
-			}
+	if elem.CryptoQuote != nil {
+		if err := elem.CryptoQuote.SaveDB(ctx, subscription.DataTablesMap[data.CryptoQuoteKey], conn); err != nil {
+			log.Error().Err(err).Msg("cannot save crypto quote to database")
+			saveErr = errors.Join(saveErr, err)
 		}
+	}
 
-		if elem.Metric != nil {
-			if err := elem.Metric.SaveDB(ctx, subscription.DataTablesMap[data.MetricKey], conn); err != nil {
-				log.Error().Err(err).Msg("cannot save metric to database")
-			}
+	if elem.CustomObject != nil {
+		if err := elem.CustomObject.SaveDB(ctx, subscription.DataTablesMap[data.CustomKey], conn); err != nil {
+			log.Error().Err(err).Msg("cannot save custom data to database")
+			saveErr = errors.Join(saveErr, err)
 		}
+	}
 
-		if elem.Rating != nil {
-			if err := elem.Rating.SaveDB(ctx, subscription.DataTablesMap[data.RatingKey], conn); err != nil {
-				log.Error().Err(err).Msg("cannot save rating to database")
-			}
+	if elem.DividendEvent != nil {
+		if err := elem.DividendEvent.SaveDB(ctx, subscription.DataTablesMap[data.DividendEventKey], conn); err != nil {
+			log.Error().Err(err).Msg("cannot save dividend to database")
+			saveErr = errors.Join(saveErr, err)
+		}
+	}
+
+	if elem.Earnings != nil {
+		if err := elem.Earnings.SaveDB(ctx, subscription.DataTablesMap[data.EarningsKey], conn); err != nil {
+			log.Error().Err(err).Msg("cannot save earnings to database")
+			saveErr = errors.Join(saveErr, err)
+		}
+	}
+
+	if elem.EconomicIndicator != nil {
+		if err := elem.EconomicIndicator.SaveDB(ctx, subscription.DataTablesMap[data.EconomicIndicatorKey], conn); err != nil {
+			log.Error().Err(err).Msg("cannot save economic indicator to database")
+			saveErr = errors.Join(saveErr, err)
+		}
+	}
+
+	if elem.EodQuote != nil {
+		if err := elem.EodQuote.SaveDB(ctx, subscription.DataTablesMap[data.EODKey], conn); err != nil {
+			log.Error().Err(err).Msg("cannot save eod quote to database")
+			saveErr = errors.Join(saveErr, err)
+		}
+	}
+
+	if elem.ETFHolding != nil {
+		if err := elem.ETFHolding.SaveDB(ctx, subscription.DataTablesMap[data.ETFHoldingKey], conn); err != nil {
+			log.Error().Err(err).Msg("cannot save etf holding to database")
+			saveErr = errors.Join(saveErr, err)
+		}
+	}
+
+	if elem.Fundamental != nil {
+		if err := elem.Fundamental.SaveDB(ctx, subscription.DataTablesMap[data.FundamentalsKey], conn); err != nil {
+			log.Error().Err(err).Msg("cannot save fundamental to database")
+			saveErr = errors.Join(saveErr, err)
+		}
+	}
+
+	if elem.FXRate != nil {
+		if err := elem.FXRate.SaveDB(ctx, subscription.DataTablesMap[data.FXRateKey], conn); err != nil {
+			log.Error().Err(err).Msg("cannot save fx rate to database")
+			saveErr = errors.Join(saveErr, err)
 		}
 	}
+
+	if elem.ListingEvent != nil {
+		if err := elem.ListingEvent.SaveDB(ctx, subscription.DataTablesMap[data.ListingEventKey], conn); err != nil {
+			log.Error().Err(err).Msg("cannot save listing to database")
+			saveErr = errors.Join(saveErr, err)
+		}
+	}
+
+	if elem.MarketHoliday != nil {
+		if err := elem.MarketHoliday.SaveDB(ctx, subscription.DataTablesMap[data.MarketHolidaysKey], conn); err != nil {
+			log.Error().Err(err).Msg("cannot save market holiday to database")
+			saveErr = errors.Join(saveErr, err)
+		}
+	}
+
+	if elem.Metric != nil {
+		if err := elem.Metric.SaveDB(ctx, subscription.DataTablesMap[data.MetricKey], conn); err != nil {
+			log.Error().Err(err).Msg("cannot save metric to database")
+			saveErr = errors.Join(saveErr, err)
+		}
+	}
+
+	if elem.News != nil {
+		if err := elem.News.SaveDB(ctx, subscription.DataTablesMap[data.NewsKey], conn); err != nil {
+			log.Error().Err(err).Msg("cannot save news to database")
+			saveErr = errors.Join(saveErr, err)
+		}
+	}
+
+	if elem.Peers != nil {
+		if err := elem.Peers.SaveDB(ctx, subscription.DataTablesMap[data.PeersKey], conn); err != nil {
+			log.Error().Err(err).Msg("cannot save peers to database")
+			saveErr = errors.Join(saveErr, err)
+		}
+	}
+
+	if elem.Rating != nil {
+		if err := elem.Rating.SaveDB(ctx, subscription.DataTablesMap[data.RatingKey], conn); err != nil {
+			log.Error().Err(err).Msg("cannot save rating to database")
+			saveErr = errors.Join(saveErr, err)
+		}
+	}
+
+	return saveErr
 }
 
 // Subscriptions returns an array of subscription objects
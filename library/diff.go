@@ -0,0 +1,641 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/penny-vault/pvdata/data"
+)
+
+const (
+	defaultDiffTolerance   = 1e-6
+	defaultDiffMaxExamples = 20
+
+	// copyNull is how COPY text format represents NULL
+	copyNull = `\N`
+)
+
+var (
+	ErrMalformedCopyRow = errors.New("row does not match the table's columns")
+)
+
+// ignoredDiffColumns differ between libraries by design and are not compared
+var ignoredDiffColumns = map[string]bool{
+	"subscription_id": true,
+	"provider":        true,
+	"run_id":          true,
+	"fetched_at":      true,
+}
+
+// RowDiffKind describes how a row differs between two sources
+type RowDiffKind string
+
+const (
+	RowMissingFromA RowDiffKind = "missing-from-a"
+	RowMissingFromB RowDiffKind = "missing-from-b"
+	RowValueDiffers RowDiffKind = "value-differs"
+)
+
+// DiffOptions controls which rows are compared and how values are matched
+type DiffOptions struct {
+	// DataTypes to compare; all data types stored in either source if empty
+	DataTypes []string
+
+	// Start and End limit the comparison to rows with a date in [Start, End];
+	// zero values are unbounded. Data types without a date column are always
+	// compared in full.
+	Start time.Time
+	End   time.Time
+
+	// Tolerance is the largest relative difference between two numbers that
+	// are considered equal; numbers smaller than 1 are compared absolutely
+	Tolerance float64
+
+	// MaxExamples is the number of differing rows reported for each data type
+	MaxExamples int
+}
+
+// DiffReport is the machine-readable result of comparing two sources
+type DiffReport struct {
+	A         string          `json:"a"`
+	B         string          `json:"b"`
+	Tolerance float64         `json:"tolerance"`
+	DataTypes []*DataTypeDiff `json:"dataTypes"`
+	Universe  *UniverseDiff   `json:"universe,omitempty"`
+}
+
+// DataTypeDiff summarizes the differences in one data type
+type DataTypeDiff struct {
+	DataType     string     `json:"dataType"`
+	RowsA        int        `json:"rowsA"`
+	RowsB        int        `json:"rowsB"`
+	MissingFromA int        `json:"missingFromA"`
+	MissingFromB int        `json:"missingFromB"`
+	Differing    int        `json:"differing"`
+	Examples     []*RowDiff `json:"examples"`
+}
+
+// RowDiff describes a single row that is missing or has a differing value
+type RowDiff struct {
+	Kind   RowDiffKind       `json:"kind"`
+	Key    map[string]string `json:"key"`
+	Column string            `json:"column,omitempty"`
+	A      *string           `json:"a,omitempty"`
+	B      *string           `json:"b,omitempty"`
+}
+
+// UniverseDiff lists assets that are only present in one of the sources
+type UniverseDiff struct {
+	OnlyInA []string `json:"onlyInA"`
+	OnlyInB []string `json:"onlyInB"`
+}
+
+// HasDifferences reports if any data type or asset differs
+func (report *DiffReport) HasDifferences() bool {
+	for _, dataType := range report.DataTypes {
+		if dataType.MissingFromA > 0 || dataType.MissingFromB > 0 || dataType.Differing > 0 {
+			return true
+		}
+	}
+
+	return report.Universe != nil && (len(report.Universe.OnlyInA) > 0 || len(report.Universe.OnlyInB) > 0)
+}
+
+// DiffSource is a library or a snapshot written by Snapshot
+type DiffSource struct {
+	library  *Library
+	dir      string
+	manifest *Manifest
+}
+
+// NewLibraryDiffSource compares the tables of myLibrary
+func NewLibraryDiffSource(myLibrary *Library) *DiffSource {
+	return &DiffSource{library: myLibrary}
+}
+
+// NewSnapshotDiffSource compares the tables of the snapshot stored in dir
+func NewSnapshotDiffSource(dir string) (*DiffSource, error) {
+	contents, err := os.ReadFile(filepath.Join(dir, manifestFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{}
+	if err := json.Unmarshal(contents, manifest); err != nil {
+		return nil, err
+	}
+
+	if manifest.FormatVersion != snapshotFormatVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedSnapshot, manifest.FormatVersion)
+	}
+
+	return &DiffSource{dir: dir, manifest: manifest}, nil
+}
+
+// OpenDiffSource opens location as a snapshot if it is a directory and as a
+// library database URL otherwise
+func OpenDiffSource(ctx context.Context, location string) (*DiffSource, error) {
+	if info, err := os.Stat(location); err == nil && info.IsDir() {
+		return NewSnapshotDiffSource(location)
+	}
+
+	myLibrary, err := NewFromDB(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLibraryDiffSource(myLibrary), nil
+}
+
+// Name describes the source in reports
+func (source *DiffSource) Name() string {
+	if source.manifest != nil {
+		return fmt.Sprintf("snapshot %s", source.dir)
+	}
+	return fmt.Sprintf("library %s", source.library.Name)
+}
+
+// tables returns the tables that hold each data type
+func (source *DiffSource) tables(ctx context.Context) (map[string][]string, error) {
+	tables := make(map[string][]string)
+
+	if source.manifest != nil {
+		for _, subscription := range source.manifest.Subscriptions {
+			for idx, dataType := range subscription.DataTypes {
+				tables[dataType] = append(tables[dataType], subscription.DataTables[idx])
+			}
+		}
+		return tables, nil
+	}
+
+	subscriptions, err := source.library.Subscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, subscription := range subscriptions {
+		for idx, dataType := range subscription.DataTypes {
+			tables[dataType] = append(tables[dataType], subscription.DataTables[idx])
+		}
+	}
+
+	return tables, nil
+}
+
+// open returns the columns of table and a reader of its rows in COPY text format
+func (source *DiffSource) open(ctx context.Context, table string) ([]string, io.ReadCloser, error) {
+	if source.manifest != nil {
+		idx := slices.IndexFunc(source.manifest.Tables, func(entry *ManifestTable) bool { return entry.Name == table })
+		if idx == -1 {
+			return nil, nil, fmt.Errorf("table %s is not in snapshot %s", table, source.dir)
+		}
+
+		entry := source.manifest.Tables[idx]
+		fh, err := os.Open(filepath.Join(source.dir, entry.File))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		reader, err := gzip.NewReader(fh)
+		if err != nil {
+			fh.Close()
+			return nil, nil, err
+		}
+
+		return entry.Columns, &gzipFile{Reader: reader, file: fh}, nil
+	}
+
+	conn, err := source.library.Pool.Acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		conn.Release()
+		return nil, nil, err
+	}
+
+	columns, err := tableColumns(ctx, tx, table)
+	if err != nil {
+		_ = tx.Rollback(ctx)
+		conn.Release()
+		return nil, nil, err
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		defer conn.Release()
+		defer func() { _ = tx.Rollback(ctx) }()
+
+		sql := fmt.Sprintf("COPY (SELECT %s FROM %s) TO STDOUT", quoteColumns(columns), pgx.Identifier{table}.Sanitize())
+		_, err := conn.Conn().PgConn().CopyTo(ctx, writer, sql)
+		writer.CloseWithError(err)
+	}()
+
+	return columns, reader, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (gz *gzipFile) Close() error {
+	return errors.Join(gz.Reader.Close(), gz.file.Close())
+}
+
+// diffRow is a row keyed by column name; nil values are NULL
+type diffRow map[string]*string
+
+// Diff compares the data stored in sources a and b. Rows are matched on the
+// primary key of their data type; rows from several subscriptions of the same
+// data type are merged. Lineage columns are not compared.
+func Diff(ctx context.Context, a, b *DiffSource, opts DiffOptions) (*DiffReport, error) {
+	if opts.Tolerance == 0 {
+		opts.Tolerance = defaultDiffTolerance
+	}
+
+	if opts.MaxExamples == 0 {
+		opts.MaxExamples = defaultDiffMaxExamples
+	}
+
+	tablesA, err := a.tables(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tablesB, err := b.tables(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dataTypes := opts.DataTypes
+	if len(dataTypes) == 0 {
+		for dataType := range tablesA {
+			dataTypes = append(dataTypes, dataType)
+		}
+
+		for dataType := range tablesB {
+			if _, ok := tablesA[dataType]; !ok {
+				dataTypes = append(dataTypes, dataType)
+			}
+		}
+
+		slices.Sort(dataTypes)
+	}
+
+	report := &DiffReport{
+		A:         a.Name(),
+		B:         b.Name(),
+		Tolerance: opts.Tolerance,
+	}
+
+	for _, dataTypeName := range dataTypes {
+		dataType, ok := data.DataTypes[dataTypeName]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownDataType, dataTypeName)
+		}
+
+		rowsA, err := readDiffRows(ctx, a, tablesA[dataTypeName], dataType, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		rowsB, err := readDiffRows(ctx, b, tablesB[dataTypeName], dataType, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		report.DataTypes = append(report.DataTypes, diffRows(dataType, rowsA, rowsB, opts))
+
+		if dataTypeName == data.AssetKey {
+			report.Universe = diffUniverse(rowsA, rowsB)
+		}
+	}
+
+	return report, nil
+}
+
+// readDiffRows reads the rows of all tables into a map keyed by primary key
+func readDiffRows(ctx context.Context, source *DiffSource, tables []string, dataType *data.DataType, opts DiffOptions) (map[string]diffRow, error) {
+	keyColumns := dataType.KeyColumns()
+	rows := make(map[string]diffRow)
+
+	for _, table := range tables {
+		columns, reader, err := source.open(ctx, table)
+		if err != nil {
+			return nil, err
+		}
+
+		err = readCopyText(reader, columns, func(row diffRow) {
+			if !inDiffRange(row, dataType.DateColumn, opts) {
+				return
+			}
+			rows[diffKey(row, keyColumns)] = row
+		})
+
+		if closeErr := reader.Close(); err == nil {
+			err = closeErr
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("reading %s from %s: %w", table, source.Name(), err)
+		}
+	}
+
+	return rows, nil
+}
+
+// diffRows compares rows of a single data type
+func diffRows(dataType *data.DataType, rowsA, rowsB map[string]diffRow, opts DiffOptions) *DataTypeDiff {
+	result := &DataTypeDiff{
+		DataType: dataType.Name,
+		RowsA:    len(rowsA),
+		RowsB:    len(rowsB),
+		Examples: make([]*RowDiff, 0),
+	}
+
+	keyColumns := dataType.KeyColumns()
+	isKey := make(map[string]bool, len(keyColumns))
+	for _, column := range keyColumns {
+		isKey[column] = true
+	}
+
+	example := func(kind RowDiffKind, row diffRow, column string, valueA, valueB *string) {
+		if len(result.Examples) >= opts.MaxExamples {
+			return
+		}
+
+		key := make(map[string]string, len(keyColumns))
+		for _, column := range keyColumns {
+			if value := row[column]; value != nil {
+				key[column] = *value
+			}
+		}
+
+		result.Examples = append(result.Examples, &RowDiff{Kind: kind, Key: key, Column: column, A: valueA, B: valueB})
+	}
+
+	keys := make([]string, 0, len(rowsA))
+	for key := range rowsA {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		rowA := rowsA[key]
+		rowB, ok := rowsB[key]
+		if !ok {
+			result.MissingFromB++
+			example(RowMissingFromB, rowA, "", nil, nil)
+			continue
+		}
+
+		columns := make([]string, 0, len(rowA))
+		for column := range rowA {
+			if _, ok := rowB[column]; ok && !isKey[column] && !ignoredDiffColumns[column] {
+				columns = append(columns, column)
+			}
+		}
+		slices.Sort(columns)
+
+		for _, column := range columns {
+			if !equalDiffValues(rowA[column], rowB[column], opts.Tolerance) {
+				result.Differing++
+				example(RowValueDiffers, rowA, column, rowA[column], rowB[column])
+				break
+			}
+		}
+	}
+
+	keys = keys[:0]
+	for key := range rowsB {
+		if _, ok := rowsA[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		result.MissingFromA++
+		example(RowMissingFromA, rowsB[key], "", nil, nil)
+	}
+
+	return result
+}
+
+// diffUniverse compares the composite FIGIs of the asset rows in each source
+func diffUniverse(rowsA, rowsB map[string]diffRow) *UniverseDiff {
+	figis := func(rows map[string]diffRow) map[string]bool {
+		set := make(map[string]bool, len(rows))
+		for _, row := range rows {
+			if figi := row["composite_figi"]; figi != nil && *figi != "" {
+				set[*figi] = true
+			}
+		}
+		return set
+	}
+
+	figisA := figis(rowsA)
+	figisB := figis(rowsB)
+
+	universe := &UniverseDiff{
+		OnlyInA: make([]string, 0),
+		OnlyInB: make([]string, 0),
+	}
+
+	for figi := range figisA {
+		if !figisB[figi] {
+			universe.OnlyInA = append(universe.OnlyInA, figi)
+		}
+	}
+
+	for figi := range figisB {
+		if !figisA[figi] {
+			universe.OnlyInB = append(universe.OnlyInB, figi)
+		}
+	}
+
+	slices.Sort(universe.OnlyInA)
+	slices.Sort(universe.OnlyInB)
+
+	return universe
+}
+
+// equalDiffValues compares two values as numbers if both parse as numbers and
+// as text otherwise
+func equalDiffValues(a, b *string, tolerance float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	if *a == *b {
+		return true
+	}
+
+	numA, errA := strconv.ParseFloat(*a, 64)
+	numB, errB := strconv.ParseFloat(*b, 64)
+	if errA != nil || errB != nil {
+		return false
+	}
+
+	scale := math.Max(1, math.Max(math.Abs(numA), math.Abs(numB)))
+	return math.Abs(numA-numB) <= tolerance*scale
+}
+
+// inDiffRange reports if the row's date is within the range of the options
+func inDiffRange(row diffRow, dateColumn string, opts DiffOptions) bool {
+	if dateColumn == "" || (opts.Start.IsZero() && opts.End.IsZero()) {
+		return true
+	}
+
+	value := row[dateColumn]
+	if value == nil || len(*value) < len(time.DateOnly) {
+		return false
+	}
+
+	date := (*value)[:len(time.DateOnly)]
+	if !opts.Start.IsZero() && date < opts.Start.Format(time.DateOnly) {
+		return false
+	}
+
+	if !opts.End.IsZero() && date > opts.End.Format(time.DateOnly) {
+		return false
+	}
+
+	return true
+}
+
+func diffKey(row diffRow, keyColumns []string) string {
+	parts := make([]string, len(keyColumns))
+	for idx, column := range keyColumns {
+		if value := row[column]; value != nil {
+			parts[idx] = *value
+		}
+	}
+	return strings.Join(parts, "\x1f")
+}
+
+// readCopyText parses rows written in PostgreSQL's COPY text format
+func readCopyText(reader io.Reader, columns []string, fn func(diffRow)) error {
+	buffered := bufio.NewReader(reader)
+
+	for {
+		line, err := buffered.ReadString('\n')
+		if len(line) > 0 {
+			fields := strings.Split(strings.TrimSuffix(line, "\n"), "\t")
+			if len(fields) != len(columns) {
+				return ErrMalformedCopyRow
+			}
+
+			row := make(diffRow, len(columns))
+			for idx, field := range fields {
+				if field == copyNull {
+					row[columns[idx]] = nil
+					continue
+				}
+
+				value := unescapeCopyText(field)
+				row[columns[idx]] = &value
+			}
+
+			fn(row)
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// unescapeCopyText reverses the backslash escapes of the COPY text format
+func unescapeCopyText(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+
+	var builder strings.Builder
+	for idx := 0; idx < len(field); idx++ {
+		if field[idx] != '\\' || idx+1 == len(field) {
+			builder.WriteByte(field[idx])
+			continue
+		}
+
+		idx++
+		switch field[idx] {
+		case 'b':
+			builder.WriteByte('\b')
+		case 'f':
+			builder.WriteByte('\f')
+		case 'n':
+			builder.WriteByte('\n')
+		case 'r':
+			builder.WriteByte('\r')
+		case 't':
+			builder.WriteByte('\t')
+		case 'v':
+			builder.WriteByte('\v')
+		case 'x':
+			end := idx + 1
+			for end < len(field) && end < idx+3 && isHexDigit(field[end]) {
+				end++
+			}
+
+			if value, err := strconv.ParseUint(field[idx+1:end], 16, 8); err == nil {
+				builder.WriteByte(byte(value))
+				idx = end - 1
+			} else {
+				builder.WriteByte('x')
+			}
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			end := idx
+			for end < len(field) && end < idx+3 && field[end] >= '0' && field[end] <= '7' {
+				end++
+			}
+
+			value, _ := strconv.ParseUint(field[idx:end], 8, 8)
+			builder.WriteByte(byte(value))
+			idx = end - 1
+		default:
+			builder.WriteByte(field[idx])
+		}
+	}
+
+	return builder.String()
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
@@ -0,0 +1,163 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library_test
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+)
+
+var (
+	eodColumns   = []string{"ticker", "composite_figi", "event_date", "close", "run_id"}
+	assetColumns = []string{"ticker", "composite_figi", "name"}
+)
+
+// writeSnapshot creates a snapshot in a temporary directory with one EOD and
+// one asset table; columns of each row are separated by |
+func writeSnapshot(eodRows, assetRows []string) string {
+	dir := GinkgoT().TempDir()
+
+	manifest := &library.Manifest{
+		FormatVersion: 1,
+		Library:       "test",
+		Subscriptions: []*library.ManifestSubscription{
+			{
+				ID:         uuid.New(),
+				DataTypes:  []string{data.EODKey, data.AssetKey},
+				DataTables: []string{"test_eod", "test_assets"},
+			},
+		},
+		Tables: []*library.ManifestTable{
+			{Name: "test_eod", File: "test_eod.copy.gz", Columns: eodColumns},
+			{Name: "test_assets", File: "test_assets.copy.gz", Columns: assetColumns},
+		},
+	}
+
+	for _, table := range []struct {
+		file string
+		rows []string
+	}{{"test_eod.copy.gz", eodRows}, {"test_assets.copy.gz", assetRows}} {
+		fh, err := os.Create(filepath.Join(dir, table.file))
+		Expect(err).NotTo(HaveOccurred())
+
+		writer := gzip.NewWriter(fh)
+		for _, row := range table.rows {
+			_, err := writer.Write([]byte(strings.ReplaceAll(row, "|", "\t") + "\n"))
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(writer.Close()).To(Succeed())
+		Expect(fh.Close()).To(Succeed())
+	}
+
+	contents, err := json.Marshal(manifest)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(os.WriteFile(filepath.Join(dir, "manifest.json"), contents, 0o644)).To(Succeed())
+
+	return dir
+}
+
+var _ = Describe("Diff", func() {
+	var (
+		report *library.DiffReport
+		eod    *library.DataTypeDiff
+	)
+
+	BeforeEach(func() {
+		dirA := writeSnapshot([]string{
+			"SPY|BBG000BDTBL9|2024-06-03|527.8000|11111111-1111-1111-1111-111111111111",
+			"SPY|BBG000BDTBL9|2024-06-04|528.3900|\\N",
+			"SPY|BBG000BDTBL9|2024-06-05|534.6700|\\N",
+			"QQQ|BBG000BSWKH7|2024-06-03|450.0000|\\N",
+		}, []string{
+			"SPY|BBG000BDTBL9|SPDR\\tS&P 500",
+			"QQQ|BBG000BSWKH7|Invesco QQQ",
+		})
+
+		dirB := writeSnapshot([]string{
+			"SPY|BBG000BDTBL9|2024-06-03|527.8000|22222222-2222-2222-2222-222222222222",
+			"SPY|BBG000BDTBL9|2024-06-04|528.3900001|\\N",
+			"SPY|BBG000BDTBL9|2024-06-05|535.0000|\\N",
+			"IWM|BBG000CGC1X8|2024-06-03|203.1000|\\N",
+		}, []string{
+			"SPY|BBG000BDTBL9|SPDR\\tS&P 500",
+			"IWM|BBG000CGC1X8|iShares Russell 2000",
+		})
+
+		a, err := library.NewSnapshotDiffSource(dirA)
+		Expect(err).NotTo(HaveOccurred())
+
+		b, err := library.NewSnapshotDiffSource(dirB)
+		Expect(err).NotTo(HaveOccurred())
+
+		report, err = library.Diff(context.Background(), a, b, library.DiffOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(report.DataTypes).To(HaveLen(2))
+		eod = report.DataTypes[1]
+		Expect(eod.DataType).To(Equal(data.EODKey))
+	})
+
+	It("counts rows missing from each side", func() {
+		Expect(eod.RowsA).To(Equal(4))
+		Expect(eod.RowsB).To(Equal(4))
+		Expect(eod.MissingFromA).To(Equal(1))
+		Expect(eod.MissingFromB).To(Equal(1))
+	})
+
+	It("ignores differences within tolerance and in lineage columns", func() {
+		Expect(eod.Differing).To(Equal(1))
+
+		differing := eod.Examples[0]
+		Expect(differing.Kind).To(Equal(library.RowValueDiffers))
+		Expect(differing.Column).To(Equal("close"))
+		Expect(differing.Key).To(Equal(map[string]string{"composite_figi": "BBG000BDTBL9", "event_date": "2024-06-05"}))
+		Expect(*differing.A).To(Equal("534.6700"))
+		Expect(*differing.B).To(Equal("535.0000"))
+	})
+
+	It("reports differences in the asset universe", func() {
+		Expect(report.DataTypes[0].Differing).To(Equal(0))
+		Expect(report.Universe.OnlyInA).To(Equal([]string{"BBG000BSWKH7"}))
+		Expect(report.Universe.OnlyInB).To(Equal([]string{"BBG000CGC1X8"}))
+		Expect(report.HasDifferences()).To(BeTrue())
+	})
+
+	It("limits the comparison to a date range", func() {
+		a, err := library.NewSnapshotDiffSource(writeSnapshot([]string{"SPY|BBG000BDTBL9|2024-06-05|534.6700|\\N"}, nil))
+		Expect(err).NotTo(HaveOccurred())
+
+		b, err := library.NewSnapshotDiffSource(writeSnapshot([]string{"SPY|BBG000BDTBL9|2024-06-05|535.0000|\\N"}, nil))
+		Expect(err).NotTo(HaveOccurred())
+
+		report, err := library.Diff(context.Background(), a, b, library.DiffOptions{
+			DataTypes: []string{data.EODKey},
+			End:       time.Date(2024, 6, 4, 0, 0, 0, 0, time.UTC),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.HasDifferences()).To(BeFalse())
+	})
+})
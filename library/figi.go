@@ -0,0 +1,296 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/figi"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+// FigiRefreshOptions selects the assets re-resolved by RefreshFigis
+type FigiRefreshOptions struct {
+	// AssetTable is the table to refresh; default.asset_table is used if empty
+	AssetTable string
+
+	// MaxAge is the age after which a mapping is re-resolved. Mappings that
+	// matched more than one composite FIGI are always re-resolved.
+	MaxAge time.Duration
+
+	// Limit is the maximum number of assets to check; 0 checks all of them
+	Limit int
+
+	// DryRun reports mapping changes without applying or recording them
+	DryRun bool
+}
+
+// FigiRefreshReport summarizes a FIGI refresh
+type FigiRefreshReport struct {
+	Checked   int                `json:"checked"`
+	Unchanged int                `json:"unchanged"`
+	Ambiguous int                `json:"ambiguous"`
+	NotFound  int                `json:"not_found"`
+	Failed    int                `json:"failed"`
+	Changes   []*data.AssetEvent `json:"changes"`
+}
+
+// figiMapping is the most recent OpenFIGI result for a ticker
+type figiMapping struct {
+	Ticker          string    `db:"ticker"`
+	PrimaryExchange string    `db:"primary_exchange"`
+	CompositeFigi   string    `db:"composite_figi"`
+	ShareClassFigi  string    `db:"share_class_figi"`
+	Candidates      int       `db:"candidates"`
+	Ambiguous       bool      `db:"ambiguous"`
+	MappedOn        time.Time `db:"mapped_on"`
+}
+
+// RefreshFigis re-resolves the composite FIGI of active assets whose mapping is
+// older than opts.MaxAge or ambiguous. Assets are looked up in OpenFIGI-sized
+// batches and each batch's mappings are saved before the next batch starts, so
+// an interrupted refresh picks up where it stopped. When a ticker maps to a
+// different composite FIGI the existing asset is deactivated and a copy with
+// the new FIGI is saved; each change is recorded in the asset_events table.
+func (myLibrary *Library) RefreshFigis(ctx context.Context, opts FigiRefreshOptions) (*FigiRefreshReport, error) {
+	logger := zerolog.Ctx(ctx)
+
+	assetTable := opts.AssetTable
+	if assetTable == "" {
+		assetTable = viper.GetString("default.asset_table")
+	}
+
+	if assetTable == "" {
+		return nil, data.ErrAssetTableNotSet
+	}
+
+	conn, err := myLibrary.Pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	stale, err := staleFigiMappings(ctx, conn, assetTable, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info().Int("NumAssets", len(stale)).Str("AssetTable", assetTable).Msg("refreshing figi mappings")
+
+	report := &FigiRefreshReport{
+		Changes: make([]*data.AssetEvent, 0),
+	}
+
+	rateLimiter := figi.RateLimiter()
+	batchSize := figi.BatchSize()
+	for start := 0; start < len(stale); start += batchSize {
+		if err := Checkpoint(ctx); err != nil {
+			return report, err
+		}
+
+		batch := stale[start:min(start+batchSize, len(stale))]
+		candidates, err := figi.Candidates(ctx, batch, rateLimiter)
+		if err != nil {
+			return report, err
+		}
+
+		for idx, asset := range batch {
+			if err := applyFigiMapping(ctx, conn, assetTable, asset, candidates[idx], opts.DryRun, report); err != nil {
+				return report, err
+			}
+		}
+
+		logger.Info().Int("Checked", report.Checked).Int("Remaining", len(stale)-start-len(batch)).Msg("saved figi mappings")
+	}
+
+	return report, nil
+}
+
+// staleFigiMappings returns the active assets in assetTable that need to be
+// re-resolved, oldest mapping first. Assets with a synthetic FIGI are skipped;
+// they are resolved by ResolveAssets the next time a provider lists them.
+func staleFigiMappings(ctx context.Context, conn *pgxpool.Conn, assetTable string, opts FigiRefreshOptions) ([]*data.Asset, error) {
+	mappings := make([]*figiMapping, 0)
+	if err := pgxscan.Select(ctx, conn, &mappings, `SELECT ticker, primary_exchange, composite_figi,
+share_class_figi, candidates, ambiguous, mapped_on FROM figi_mappings`); err != nil {
+		return nil, err
+	}
+
+	mappedOn := make(map[string]time.Time, len(mappings))
+	for _, mapping := range mappings {
+		// ambiguous mappings sort first with assets that were never mapped
+		if !mapping.Ambiguous {
+			mappedOn[mapping.Ticker+":"+mapping.PrimaryExchange] = mapping.MappedOn
+		}
+	}
+
+	cutoff := time.Now().Add(-opts.MaxAge)
+	stale := slices.DeleteFunc(data.ActiveAssets(ctx, conn, assetTable), func(asset *data.Asset) bool {
+		return IsSyntheticFigi(asset.CompositeFigi) || mappedOn[asset.Ticker+":"+string(asset.PrimaryExchange)].After(cutoff)
+	})
+
+	slices.SortStableFunc(stale, func(a, b *data.Asset) int {
+		return mappedOn[a.Ticker+":"+string(a.PrimaryExchange)].Compare(mappedOn[b.Ticker+":"+string(b.PrimaryExchange)])
+	})
+
+	if opts.Limit > 0 && len(stale) > opts.Limit {
+		stale = stale[:opts.Limit]
+	}
+
+	return stale, nil
+}
+
+// applyFigiMapping compares the OpenFIGI matches for asset with its current
+// FIGIs, applies any change, and saves the mapping. A nil candidates means the
+// lookup failed; the mapping is left as is so it is retried.
+func applyFigiMapping(ctx context.Context, conn *pgxpool.Conn, assetTable string, asset *data.Asset, candidates []*figi.OpenFigiAsset, dryRun bool, report *FigiRefreshReport) error {
+	logger := zerolog.Ctx(ctx).With().Str("Ticker", asset.Ticker).Str("CompositeFigi", asset.CompositeFigi).Logger()
+
+	if candidates == nil {
+		report.Failed++
+		return nil
+	}
+
+	report.Checked++
+
+	// a ticker may match several securities that share a composite FIGI
+	matches := make(map[string]*figi.OpenFigiAsset, len(candidates))
+	for _, candidate := range candidates {
+		if candidate.CompositeFIGI != "" {
+			if _, ok := matches[candidate.CompositeFIGI]; !ok {
+				matches[candidate.CompositeFIGI] = candidate
+			}
+		}
+	}
+
+	mapping := &figiMapping{
+		Ticker:          asset.Ticker,
+		PrimaryExchange: string(asset.PrimaryExchange),
+		CompositeFigi:   asset.CompositeFigi,
+		ShareClassFigi:  asset.ShareClassFigi,
+		Candidates:      len(matches),
+		Ambiguous:       len(matches) > 1,
+		MappedOn:        time.Now(),
+	}
+
+	events := make([]*data.AssetEvent, 0, 1)
+	newEvent := func(eventType, oldValue, newValue string) *data.AssetEvent {
+		return &data.AssetEvent{
+			EventTime:       mapping.MappedOn,
+			AssetTable:      assetTable,
+			Ticker:          asset.Ticker,
+			CompositeFigi:   asset.CompositeFigi,
+			PrimaryExchange: string(asset.PrimaryExchange),
+			EventType:       eventType,
+			OldValue:        oldValue,
+			NewValue:        newValue,
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		report.NotFound++
+		logger.Warn().Msg("openfigi no longer lists ticker")
+	case 1:
+		for _, match := range matches {
+			mapping.CompositeFigi = match.CompositeFIGI
+			mapping.ShareClassFigi = match.ShareClassFIGI
+		}
+
+		switch {
+		case mapping.CompositeFigi != asset.CompositeFigi:
+			events = append(events, newEvent(data.AssetEventCompositeFigi, asset.CompositeFigi, mapping.CompositeFigi))
+		case mapping.ShareClassFigi != "" && mapping.ShareClassFigi != asset.ShareClassFigi:
+			events = append(events, newEvent(data.AssetEventShareClassFigi, asset.ShareClassFigi, mapping.ShareClassFigi))
+		default:
+			report.Unchanged++
+		}
+	default:
+		report.Ambiguous++
+		logger.Warn().Int("NumCandidates", len(matches)).Msg("ticker maps to more than one composite figi")
+	}
+
+	report.Changes = append(report.Changes, events...)
+
+	if dryRun {
+		return nil
+	}
+
+	for _, event := range events {
+		logger.Info().Str("EventType", event.EventType).Str("OldValue", event.OldValue).Str("NewValue", event.NewValue).Msg("figi mapping changed")
+
+		if err := applyAssetEvent(ctx, conn, asset, mapping, event); err != nil {
+			return err
+		}
+	}
+
+	// the mapping is saved last so that assets are checked again if applying
+	// the change failed
+	_, err := conn.Exec(ctx, `INSERT INTO figi_mappings
+(ticker, primary_exchange, composite_figi, share_class_figi, candidates, ambiguous, mapped_on)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (ticker, primary_exchange) DO UPDATE SET
+	composite_figi = EXCLUDED.composite_figi,
+	share_class_figi = EXCLUDED.share_class_figi,
+	candidates = EXCLUDED.candidates,
+	ambiguous = EXCLUDED.ambiguous,
+	mapped_on = EXCLUDED.mapped_on`, mapping.Ticker, mapping.PrimaryExchange, mapping.CompositeFigi,
+		mapping.ShareClassFigi, mapping.Candidates, mapping.Ambiguous, mapping.MappedOn)
+
+	return err
+}
+
+// applyAssetEvent updates the asset table for a mapping change and records the
+// event
+func applyAssetEvent(ctx context.Context, conn *pgxpool.Conn, asset *data.Asset, mapping *figiMapping, event *data.AssetEvent) error {
+	switch event.EventType {
+	case data.AssetEventCompositeFigi:
+		// the composite FIGI is part of the primary key so the ticker now refers
+		// to a different security; retire the old one and list the new one
+		retired := *asset
+		retired.Active = false
+		retired.DelistingDate = data.Today(data.NYSEExchange).String()
+		if err := retired.SaveDB(ctx, event.AssetTable, conn); err != nil {
+			return err
+		}
+
+		replacement := *asset
+		replacement.CompositeFigi = event.NewValue
+		replacement.ShareClassFigi = mapping.ShareClassFigi
+		replacement.LastUpdated = event.EventTime
+		if err := replacement.SaveDB(ctx, event.AssetTable, conn); err != nil {
+			return err
+		}
+	case data.AssetEventShareClassFigi:
+		if _, err := conn.Exec(ctx, fmt.Sprintf(`UPDATE %s SET share_class_figi=$3 WHERE ticker=$1 AND composite_figi=$2`,
+			pgx.Identifier{event.AssetTable}.Sanitize()), asset.Ticker, asset.CompositeFigi, event.NewValue); err != nil {
+			return err
+		}
+	}
+
+	_, err := conn.Exec(ctx, `INSERT INTO asset_events
+(event_time, asset_table, ticker, composite_figi, primary_exchange, event_type, old_value, new_value)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`, event.EventTime, event.AssetTable, event.Ticker, event.CompositeFigi,
+		event.PrimaryExchange, event.EventType, event.OldValue, event.NewValue)
+	return err
+}
@@ -0,0 +1,271 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	journalFileName = "observations.journal"
+	ackFileName     = "observations.ack"
+
+	// ackPersistInterval is the number of acknowledgements between writes of
+	// the ack file; entries acknowledged but not persisted are replayed after a
+	// crash, which is safe because saving an observation is idempotent
+	ackPersistInterval = 1000
+)
+
+// Journal is an append-only log of observations stored on local disk. Every
+// observation is recorded before it is saved to the database and acknowledged
+// afterwards. Entries that were never acknowledged, e.g. because the process
+// crashed, are replayed the next time the journal is used, giving at-least-once
+// delivery without requesting the data from the provider again.
+type Journal struct {
+	dir string
+
+	mu      sync.Mutex
+	file    *os.File
+	writer  *bufio.Writer
+	nextSeq uint64
+	lastSeq uint64
+
+	acked     uint64
+	numUnsync int
+	stalled   bool
+}
+
+type journalEntry struct {
+	Seq         uint64            `json:"seq"`
+	Observation *data.Observation `json:"obs"`
+}
+
+// OpenJournal opens the journal stored in dir, creating it if necessary
+func OpenJournal(dir string) (*Journal, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	journal := &Journal{
+		dir: dir,
+	}
+
+	acked, err := journal.readAck()
+	if err != nil {
+		return nil, err
+	}
+	journal.acked = acked
+
+	file, err := os.OpenFile(filepath.Join(dir, journalFileName), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	journal.file = file
+	journal.writer = bufio.NewWriter(file)
+
+	// continue numbering after the last entry in the journal
+	journal.lastSeq = acked
+	if err := journal.scan(func(entry *journalEntry) {
+		journal.lastSeq = max(journal.lastSeq, entry.Seq)
+	}); err != nil {
+		file.Close()
+		return nil, err
+	}
+	journal.nextSeq = journal.lastSeq + 1
+
+	return journal, nil
+}
+
+// Pending returns the observations that were recorded but never acknowledged
+func (journal *Journal) Pending() ([]*data.Observation, error) {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	pending := make([]*data.Observation, 0)
+	err := journal.scan(func(entry *journalEntry) {
+		if entry.Seq > journal.acked && entry.Observation != nil {
+			entry.Observation.JournalSeq = entry.Seq
+			pending = append(pending, entry.Observation)
+		}
+	})
+
+	return pending, err
+}
+
+// Append records obs in the journal and assigns its JournalSeq
+func (journal *Journal) Append(obs *data.Observation) error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	entry := journalEntry{
+		Seq:         journal.nextSeq,
+		Observation: obs,
+	}
+
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if _, err := journal.writer.Write(append(buf, '\n')); err != nil {
+		return err
+	}
+
+	// flush so the entry survives the process exiting unexpectedly
+	if err := journal.writer.Flush(); err != nil {
+		return err
+	}
+
+	obs.JournalSeq = entry.Seq
+	journal.lastSeq = entry.Seq
+	journal.nextSeq++
+
+	return nil
+}
+
+// Ack marks every entry up to and including seq as saved
+func (journal *Journal) Ack(seq uint64) error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	if journal.stalled || seq <= journal.acked {
+		return nil
+	}
+
+	journal.acked = seq
+	journal.numUnsync++
+
+	if journal.numUnsync >= ackPersistInterval {
+		return journal.writeAck()
+	}
+
+	return nil
+}
+
+// Nack marks the entry with seq as not saved. Acknowledgements stop advancing
+// so the entry and every entry after it are replayed when the journal is next used.
+func (journal *Journal) Nack(seq uint64) {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	if !journal.stalled {
+		log.Warn().Uint64("Seq", seq).Msg("observation was not saved; it will be replayed from the journal")
+	}
+
+	journal.stalled = true
+}
+
+// Record appends each observation read from in to the journal and forwards it
+// to out. Unacknowledged observations from previous runs are forwarded first.
+// out is closed once in is closed.
+func (journal *Journal) Record(in <-chan *data.Observation, out chan<- *data.Observation) {
+	defer close(out)
+
+	pending, err := journal.Pending()
+	if err != nil {
+		log.Error().Err(err).Msg("could not read pending observations from journal")
+	}
+
+	if len(pending) > 0 {
+		log.Info().Int("NumObservations", len(pending)).Msg("replaying unacknowledged observations from journal")
+	}
+
+	for _, obs := range pending {
+		out <- obs
+	}
+
+	for obs := range in {
+		if err := journal.Append(obs); err != nil {
+			log.Error().Err(err).Msg("could not append observation to journal")
+		}
+		out <- obs
+	}
+}
+
+// Close persists the acknowledged position and truncates the journal if every
+// entry has been acknowledged
+func (journal *Journal) Close() error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	err := errors.Join(journal.writer.Flush(), journal.writeAck())
+
+	if journal.acked >= journal.lastSeq {
+		err = errors.Join(err, journal.file.Truncate(0))
+	}
+
+	return errors.Join(err, journal.file.Close())
+}
+
+func (journal *Journal) scan(fn func(*journalEntry)) error {
+	if err := journal.writer.Flush(); err != nil {
+		return err
+	}
+
+	file, err := os.Open(journal.file.Name())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		entry := &journalEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			// a partially written final entry is expected after a crash
+			log.Warn().Err(err).Str("Journal", journal.file.Name()).Msg("skipping unreadable journal entry")
+			continue
+		}
+		fn(entry)
+	}
+
+	return scanner.Err()
+}
+
+func (journal *Journal) readAck() (uint64, error) {
+	buf, err := os.ReadFile(filepath.Join(journal.dir, ackFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(buf)), 10, 64)
+}
+
+// writeAck atomically replaces the ack file
+func (journal *Journal) writeAck() error {
+	journal.numUnsync = 0
+
+	tmpName := filepath.Join(journal.dir, ackFileName+".tmp")
+	if err := os.WriteFile(tmpName, []byte(strconv.FormatUint(journal.acked, 10)), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpName, filepath.Join(journal.dir, ackFileName))
+}
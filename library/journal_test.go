@@ -0,0 +1,171 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+)
+
+var _ = Describe("Journal", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+	})
+
+	appendN := func(journal *library.Journal, n int) []*data.Observation {
+		observations := make([]*data.Observation, 0, n)
+		for idx := 0; idx < n; idx++ {
+			obs := &data.Observation{SubscriptionName: fmt.Sprintf("obs-%d", idx)}
+			Expect(journal.Append(obs)).To(Succeed())
+			observations = append(observations, obs)
+		}
+		return observations
+	}
+
+	reopen := func(journal *library.Journal) *library.Journal {
+		Expect(journal.Close()).To(Succeed())
+		journal, err := library.OpenJournal(dir)
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(journal.Close)
+		return journal
+	}
+
+	pendingNames := func(journal *library.Journal) []string {
+		pending, err := journal.Pending()
+		Expect(err).NotTo(HaveOccurred())
+
+		names := make([]string, 0, len(pending))
+		for _, obs := range pending {
+			names = append(names, obs.SubscriptionName)
+		}
+		return names
+	}
+
+	open := func() *library.Journal {
+		journal, err := library.OpenJournal(dir)
+		Expect(err).NotTo(HaveOccurred())
+		return journal
+	}
+
+	It("numbers observations as they are appended", func() {
+		journal := open()
+		observations := appendN(journal, 3)
+
+		Expect(observations[0].JournalSeq).To(Equal(uint64(1)))
+		Expect(observations[2].JournalSeq).To(Equal(uint64(3)))
+		Expect(journal.Close()).To(Succeed())
+	})
+
+	It("replays unacknowledged observations after a restart", func() {
+		journal := open()
+		appendN(journal, 3)
+		Expect(journal.Ack(1)).To(Succeed())
+
+		journal = reopen(journal)
+		Expect(pendingNames(journal)).To(Equal([]string{"obs-1", "obs-2"}))
+
+		// numbering continues after the replayed entries
+		obs := &data.Observation{SubscriptionName: "obs-3"}
+		Expect(journal.Append(obs)).To(Succeed())
+		Expect(obs.JournalSeq).To(Equal(uint64(4)))
+	})
+
+	It("forwards replayed observations before new ones", func() {
+		journal := open()
+		appendN(journal, 2)
+		journal = reopen(journal)
+
+		in := make(chan *data.Observation)
+		out := make(chan *data.Observation)
+		go journal.Record(in, out)
+
+		go func() {
+			in <- &data.Observation{SubscriptionName: "obs-2"}
+			close(in)
+		}()
+
+		received := make([]uint64, 0, 3)
+		for obs := range out {
+			received = append(received, obs.JournalSeq)
+		}
+
+		Expect(received).To(Equal([]uint64{1, 2, 3}))
+	})
+
+	It("acknowledges every entry up to the acknowledged sequence", func() {
+		journal := open()
+		appendN(journal, 3)
+		Expect(journal.Ack(3)).To(Succeed())
+
+		// acknowledgements never move backwards
+		Expect(journal.Ack(2)).To(Succeed())
+
+		journal = reopen(journal)
+		Expect(pendingNames(journal)).To(BeEmpty())
+
+		info, err := os.Stat(filepath.Join(dir, "observations.journal"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Size()).To(BeZero())
+	})
+
+	It("replays a rejected entry and every entry after it", func() {
+		journal := open()
+		appendN(journal, 4)
+		Expect(journal.Ack(1)).To(Succeed())
+		journal.Nack(2)
+		Expect(journal.Ack(4)).To(Succeed())
+
+		journal = reopen(journal)
+		Expect(pendingNames(journal)).To(Equal([]string{"obs-1", "obs-2", "obs-3"}))
+	})
+
+	It("replays a rejected entry that passed through the queue's spill file", func() {
+		journal := open()
+		queue := library.NewObservationQueue(1, GinkgoT().TempDir())
+
+		in := make(chan *data.Observation)
+		go journal.Record(in, queue.In())
+
+		for idx := 0; idx < 4; idx++ {
+			in <- &data.Observation{SubscriptionName: fmt.Sprintf("obs-%d", idx)}
+		}
+		close(in)
+		Eventually(func() int64 { return queue.Stats().Spilled }).Should(Equal(int64(3)))
+
+		// a sink fails to write obs-2
+		received := make([]uint64, 0, 4)
+		for obs := range queue.Out() {
+			received = append(received, obs.JournalSeq)
+			if obs.SubscriptionName == "obs-2" {
+				journal.Nack(obs.JournalSeq)
+				continue
+			}
+			Expect(journal.Ack(obs.JournalSeq)).To(Succeed())
+		}
+		Expect(received).To(Equal([]uint64{1, 2, 3, 4}))
+
+		journal = reopen(journal)
+		Expect(pendingNames(journal)).To(Equal([]string{"obs-2", "obs-3"}))
+	})
+})
@@ -0,0 +1,116 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/penny-vault/pvdata/data"
+)
+
+var (
+	ErrUnknownDataType = errors.New("unknown data type")
+)
+
+// LineageRecord describes where a stored row came from
+type LineageRecord struct {
+	SubscriptionID   uuid.UUID
+	SubscriptionName string
+	Provider         string
+	Dataset          string
+	Table            string
+
+	// RunID is uuid.Nil for rows saved before lineage was recorded or by a
+	// run that could not be recorded
+	RunID        uuid.UUID
+	RunStartTime time.Time
+	RunStatus    string
+	FetchedAt    time.Time
+}
+
+// Lineage traces the rows stored for the asset identified by figi on date
+// back to the subscriptions and runs that wrote them. The date is ignored for
+// data types without a date column, e.g. assets. Subscriptions whose tables
+// are not keyed by composite FIGI are skipped.
+func (myLibrary *Library) Lineage(ctx context.Context, figi string, date time.Time, dataType string) ([]*LineageRecord, error) {
+	dt, ok := data.DataTypes[dataType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDataType, dataType)
+	}
+
+	subscriptions, err := myLibrary.Subscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := myLibrary.Pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	records := make([]*LineageRecord, 0)
+	for _, subscription := range subscriptions {
+		tbl, ok := subscription.DataTablesMap[dataType]
+		if !ok {
+			continue
+		}
+
+		var hasFigi bool
+		if err := conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM pg_attribute
+WHERE attrelid = to_regclass($1) AND attname = 'composite_figi' AND NOT attisdropped)`, tbl).Scan(&hasFigi); err != nil {
+			return nil, err
+		}
+
+		if !hasFigi {
+			continue
+		}
+
+		args := pgx.NamedArgs{"figi": figi}
+		where := "t.composite_figi = @figi"
+		if dt.DateColumn != "" {
+			where += fmt.Sprintf(" AND t.%s::date = @date", dt.DateColumn)
+			args["date"] = date.Format(time.DateOnly)
+		}
+
+		var rows []*LineageRecord
+		if err := pgxscan.Select(ctx, conn, &rows, fmt.Sprintf(`SELECT
+coalesce(t.run_id, '00000000-0000-0000-0000-000000000000'::uuid) AS run_id,
+coalesce(r.start_time, '0001-01-01'::timestamp) AS run_start_time,
+coalesce(r.status, '') AS run_status,
+coalesce(t.fetched_at, '0001-01-01'::timestamptz) AS fetched_at
+FROM %s t LEFT JOIN runs r ON r.id = t.run_id
+WHERE %s`, tbl, where), args); err != nil {
+			return nil, err
+		}
+
+		for _, row := range rows {
+			row.SubscriptionID = subscription.ID
+			row.SubscriptionName = subscription.Name
+			row.Provider = subscription.Provider
+			row.Dataset = subscription.Dataset
+			row.Table = tbl
+			records = append(records, row)
+		}
+	}
+
+	return records, nil
+}
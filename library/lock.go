@@ -0,0 +1,159 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrRunInProgress = errors.New("subscription is already running")
+)
+
+// RunLock is a postgres advisory lock held while a subscription runs. Advisory
+// locks belong to a database session so the lock keeps its connection until
+// Unlock is called; the connections come from a pool of their own so held locks
+// cannot exhaust the library's pool. If the process dies the connection closes
+// and postgres releases the lock.
+type RunLock struct {
+	SubscriptionID uuid.UUID
+
+	conn *pgxpool.Conn
+	key  int64
+}
+
+// runLockKey maps a subscription to the 64-bit key of its advisory lock
+func runLockKey(subscriptionID uuid.UUID) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte("pvdata.run:"))
+	hash.Write(subscriptionID[:])
+	return int64(hash.Sum64())
+}
+
+// LockRun takes the run lock of the subscription so that only one process
+// fetches it at a time. If another process holds the lock and wait is false
+// ErrRunInProgress is returned; otherwise LockRun blocks until the lock is
+// released or ctx is done.
+func (myLibrary *Library) LockRun(ctx context.Context, subscriptionID uuid.UUID, wait bool) (*RunLock, error) {
+	lock := &RunLock{
+		SubscriptionID: subscriptionID,
+		key:            runLockKey(subscriptionID),
+	}
+
+	pool, err := myLibrary.locks()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if wait {
+		if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lock.key); err != nil {
+			// the lock may have been granted before the error; closing the
+			// session guarantees it is not left behind
+			conn.Hijack().Close(context.Background())
+			return nil, err
+		}
+
+		lock.conn = conn
+		return lock, nil
+	}
+
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, lock.key).Scan(&locked); err != nil {
+		conn.Release()
+		return nil, err
+	}
+
+	if !locked {
+		conn.Release()
+		return nil, myLibrary.runInProgress(ctx, subscriptionID)
+	}
+
+	lock.conn = conn
+	return lock, nil
+}
+
+// locks returns the pool run locks take their sessions from
+func (myLibrary *Library) locks() (*pgxpool.Pool, error) {
+	myLibrary.lockMu.Lock()
+	defer myLibrary.lockMu.Unlock()
+
+	if myLibrary.lockPool != nil {
+		return myLibrary.lockPool, nil
+	}
+
+	poolConfig := myLibrary.PoolConfig
+	poolConfig.MaxConns = poolConfig.MaxLockConns
+	poolConfig.MinConns = 0
+
+	pool, err := newPool(myLibrary.DBUrl, poolConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	myLibrary.lockPool = pool
+	return pool, nil
+}
+
+// runInProgress describes the run that holds the subscription's lock
+func (myLibrary *Library) runInProgress(ctx context.Context, subscriptionID uuid.UUID) error {
+	var runID uuid.UUID
+	var startTime time.Time
+	err := myLibrary.Pool.QueryRow(ctx, `SELECT id, start_time FROM runs
+WHERE subscription_id = $1 AND state IN ('running', 'paused') ORDER BY start_time DESC LIMIT 1`,
+		subscriptionID).Scan(&runID, &startTime)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			log.Warn().Err(err).Str("SubscriptionID", subscriptionID.String()).Msg("could not look up in-flight run")
+		}
+		return fmt.Errorf("%w: %s", ErrRunInProgress, subscriptionID)
+	}
+
+	return fmt.Errorf("%w: %s (run %s started %s)", ErrRunInProgress, subscriptionID, runID,
+		startTime.Format(time.RFC3339))
+}
+
+// Unlock releases the run lock. If the lock cannot be released cleanly its
+// session is closed, which also releases it.
+func (lock *RunLock) Unlock(ctx context.Context) {
+	if lock == nil || lock.conn == nil {
+		return
+	}
+
+	conn := lock.conn
+	lock.conn = nil
+
+	var unlocked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_advisory_unlock($1)`, lock.key).Scan(&unlocked); err != nil || !unlocked {
+		log.Warn().Err(err).Str("SubscriptionID", lock.SubscriptionID.String()).Msg("could not release run lock; closing its connection")
+		conn.Hijack().Close(context.Background())
+		return
+	}
+
+	conn.Release()
+}
@@ -0,0 +1,151 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/rs/zerolog/log"
+)
+
+// TableBloat describes the dead rows of a table that have not been vacuumed
+type TableBloat struct {
+	Table       string    `db:"table_name"`
+	LiveRows    int64     `db:"live_rows"`
+	DeadRows    int64     `db:"dead_rows"`
+	DeadRatio   float64   `db:"dead_ratio"`
+	LastVacuum  time.Time `db:"last_vacuum"`
+	LastAnalyze time.Time `db:"last_analyze"`
+}
+
+// IndexCandidate is a table that would benefit from an additional index
+type IndexCandidate struct {
+	Table  string
+	Reason string
+
+	// Statement creates the suggested index; it is empty if the index depends
+	// on queries pvdata does not know about
+	Statement string
+}
+
+// Analyze updates the planner statistics of the subscription's tables
+func (subscription *Subscription) Analyze(ctx context.Context) error {
+	for _, table := range subscription.DataTables {
+		log.Debug().Str("Table", table).Msg("analyzing table")
+		if _, err := subscription.Library.Pool.Exec(ctx, fmt.Sprintf("ANALYZE %s", pgx.Identifier{table}.Sanitize())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Vacuum reclaims dead rows in table and updates its planner statistics
+func (myLibrary *Library) Vacuum(ctx context.Context, table string) error {
+	_, err := myLibrary.Pool.Exec(ctx, fmt.Sprintf("VACUUM (ANALYZE) %s", pgx.Identifier{table}.Sanitize()))
+	return err
+}
+
+// Bloat returns tables where at least minDeadRatio of the rows are dead,
+// ordered by the number of dead rows
+func (myLibrary *Library) Bloat(ctx context.Context, minDeadRatio float64) ([]*TableBloat, error) {
+	bloat := []*TableBloat{}
+	err := pgxscan.Select(ctx, myLibrary.Pool, &bloat, `SELECT relname AS table_name, n_live_tup AS live_rows,
+n_dead_tup AS dead_rows, n_dead_tup::float8 / greatest(n_live_tup + n_dead_tup, 1) AS dead_ratio,
+coalesce(greatest(last_vacuum, last_autovacuum), '0001-01-01'::timestamptz) AS last_vacuum,
+coalesce(greatest(last_analyze, last_autoanalyze), '0001-01-01'::timestamptz) AS last_analyze
+FROM pg_stat_user_tables
+WHERE n_dead_tup > 0 AND n_dead_tup::float8 / greatest(n_live_tup + n_dead_tup, 1) >= $1
+ORDER BY n_dead_tup DESC`, minDeadRatio)
+	return bloat, err
+}
+
+// IndexCandidates returns tables of the library's subscriptions that are
+// missing an index for the queries pvdata and penny-vault make, i.e. range
+// scans by date, along with tables that are mostly read with sequential scans
+func (myLibrary *Library) IndexCandidates(ctx context.Context) ([]*IndexCandidate, error) {
+	subscriptions, err := myLibrary.Subscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []*IndexCandidate{}
+
+	for _, subscription := range subscriptions {
+		for idx, dataTypeName := range subscription.DataTypes {
+			dataType, ok := data.DataTypes[dataTypeName]
+			if !ok || dataType.DateColumn == "" || idx >= len(subscription.DataTables) {
+				continue
+			}
+
+			table := subscription.DataTables[idx]
+
+			var leading []string
+			if err := pgxscan.Select(ctx, myLibrary.Pool, &leading, `SELECT a.attname FROM pg_index i
+JOIN pg_class c ON c.oid = i.indrelid
+JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum = i.indkey[0]
+WHERE c.relname = $1 AND pg_table_is_visible(c.oid)`, table); err != nil {
+				return nil, err
+			}
+
+			found := false
+			for _, column := range leading {
+				if column == dataType.DateColumn {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				candidates = append(candidates, &IndexCandidate{
+					Table:  table,
+					Reason: fmt.Sprintf("queries by %s range scan the whole table", dataType.DateColumn),
+					Statement: fmt.Sprintf("CREATE INDEX %s ON %s(%s);", pgx.Identifier{table + "_" + dataType.DateColumn + "_idx"}.Sanitize(),
+						pgx.Identifier{table}.Sanitize(), pgx.Identifier{dataType.DateColumn}.Sanitize()),
+				})
+			}
+		}
+	}
+
+	var scanned []struct {
+		Table      string `db:"table_name"`
+		SeqScans   int64  `db:"seq_scan"`
+		IndexScans int64  `db:"idx_scan"`
+		LiveRows   int64  `db:"live_rows"`
+	}
+
+	// large tables read mostly by sequential scans are queried by columns
+	// without an index
+	if err := pgxscan.Select(ctx, myLibrary.Pool, &scanned, `SELECT relname AS table_name, seq_scan,
+coalesce(idx_scan, 0) AS idx_scan, n_live_tup AS live_rows FROM pg_stat_user_tables
+WHERE n_live_tup >= 100000 AND seq_scan > 10 * coalesce(idx_scan, 0)
+ORDER BY seq_scan DESC`); err != nil {
+		return nil, err
+	}
+
+	for _, table := range scanned {
+		candidates = append(candidates, &IndexCandidate{
+			Table:  table.Table,
+			Reason: fmt.Sprintf("%d sequential scans and %d index scans of %d rows", table.SeqScans, table.IndexScans, table.LiveRows),
+		})
+	}
+
+	return candidates, nil
+}
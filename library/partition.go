@@ -0,0 +1,284 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// partitionArchiveSchema holds partitions detached from their table; it is
+	// created by the library migrations
+	partitionArchiveSchema = "archive"
+
+	// yearlyPartitionsAhead and monthlyPartitionsAhead are the number of
+	// partitions created beyond the current one
+	yearlyPartitionsAhead  = 1
+	monthlyPartitionsAhead = 3
+
+	// monthlyPartitionsHistory is the number of months before the current one
+	// with their own partition; older observations share a single partition
+	monthlyPartitionsHistory = 12
+
+	// partitionBoundLayout formats partition bounds so they are the same for
+	// DATE and TIMESTAMPTZ columns regardless of the session time zone
+	partitionBoundLayout = "2006-01-02 15:04:05+00"
+)
+
+var partitionBoundRegex = regexp.MustCompile(`FROM \((?:'([^']+)'|MINVALUE)\) TO \((?:'([^']+)'|MAXVALUE)\)`)
+
+// partitionSuffixRegex matches the suffix partitionRanges appends to the name
+// of a yearly or monthly partition
+var partitionSuffixRegex = regexp.MustCompile(`^_(\d{4}_\d{4}|\d{6}_\d{6})$`)
+
+// partitionRange is the half-open range of dates [Start, End) stored in a partition
+type partitionRange struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+func (partition partitionRange) overlaps(other partitionRange) bool {
+	return partition.Start.Before(other.End) && other.Start.Before(partition.End)
+}
+
+// partitionRanges returns the partitions a table partitioned by interval
+// should have on now. Yearly tables keep the coarse ranges used before 2015;
+// monthly tables keep observations older than a year in a single partition.
+func partitionRanges(table string, interval data.PartitionInterval, now time.Time) []partitionRange {
+	date := func(year int, month time.Month) time.Time {
+		return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	ranges := []partitionRange{}
+
+	switch interval {
+	case data.PartitionMonthly:
+		first := date(now.Year(), now.Month()).AddDate(0, -monthlyPartitionsHistory, 0)
+		last := date(now.Year(), now.Month()).AddDate(0, monthlyPartitionsAhead+1, 0)
+
+		ranges = append(ranges, partitionRange{Start: date(1900, 1), End: first})
+		for start := first; start.Before(last); start = start.AddDate(0, 1, 0) {
+			ranges = append(ranges, partitionRange{Start: start, End: start.AddDate(0, 1, 0)})
+		}
+
+		for idx := range ranges {
+			ranges[idx].Name = fmt.Sprintf("%s_%s_%s", table, ranges[idx].Start.Format("200601"), ranges[idx].End.Format("200601"))
+		}
+	default:
+		for _, years := range [][2]int{{1900, 2000}, {2000, 2005}, {2005, 2010}, {2010, 2015}} {
+			ranges = append(ranges, partitionRange{Start: date(years[0], 1), End: date(years[1], 1)})
+		}
+
+		for year := 2015; year <= now.Year()+yearlyPartitionsAhead; year++ {
+			ranges = append(ranges, partitionRange{Start: date(year, 1), End: date(year+1, 1)})
+		}
+
+		for idx := range ranges {
+			ranges[idx].Name = fmt.Sprintf("%s_%d_%d", table, ranges[idx].Start.Year(), ranges[idx].End.Year())
+		}
+	}
+
+	return ranges
+}
+
+// parsePartitionBound parses a partition bound value; MINVALUE and MAXVALUE
+// are represented by empty strings
+func parsePartitionBound(bound string, unbounded time.Time) (time.Time, error) {
+	if bound == "" {
+		return unbounded, nil
+	}
+
+	for _, layout := range []string{"2006-01-02 15:04:05-07", "2006-01-02 15:04:05-07:00", "2006-01-02 15:04:05"} {
+		if ts, err := time.Parse(layout, bound); err == nil {
+			return ts.UTC(), nil
+		}
+	}
+
+	return time.Parse("2006-01-02", bound)
+}
+
+// partitionTables returns the qualified names of every partition of table: the
+// partitions attached to it, whatever their range, and those ManagePartitions
+// moved to the archive schema
+func partitionTables(ctx context.Context, tx pgx.Tx, table string) ([]string, error) {
+	rows, err := tx.Query(ctx, `SELECT n.nspname, c.relname FROM pg_inherits i
+JOIN pg_class c ON c.oid = i.inhrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+JOIN pg_class p ON p.oid = i.inhparent
+WHERE p.relname = $1 AND pg_table_is_visible(p.oid)
+UNION
+SELECT schemaname, tablename FROM pg_tables WHERE schemaname = $2 AND starts_with(tablename, $1 || '_')`,
+		table, partitionArchiveSchema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := make([]string, 0, 20)
+	for rows.Next() {
+		var schema, name string
+		if err := rows.Scan(&schema, &name); err != nil {
+			return nil, err
+		}
+
+		// archived tables are only known to be partitions of table by their name
+		if schema == partitionArchiveSchema && !partitionSuffixRegex.MatchString(strings.TrimPrefix(name, table)) {
+			continue
+		}
+
+		tables = append(tables, pgx.Identifier{schema, name}.Sanitize())
+	}
+
+	return tables, rows.Err()
+}
+
+// existingPartitions returns the partitions currently attached to table. ok is
+// false if the table is not partitioned; e.g. it was created before the data
+// type was partitioned.
+func existingPartitions(ctx context.Context, tx pgx.Tx, table string) (partitions []partitionRange, ok bool, err error) {
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM pg_partitioned_table pt
+JOIN pg_class c ON c.oid = pt.partrelid WHERE c.relname = $1 AND pg_table_is_visible(c.oid))`, table).Scan(&ok); err != nil || !ok {
+		return nil, ok, err
+	}
+
+	rows, err := tx.Query(ctx, `SELECT c.relname, pg_get_expr(c.relpartbound, c.oid) FROM pg_inherits i
+JOIN pg_class c ON c.oid = i.inhrelid
+JOIN pg_class p ON p.oid = i.inhparent
+WHERE p.relname = $1 AND pg_table_is_visible(p.oid)`, table)
+	if err != nil {
+		return nil, true, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, bound string
+		if err := rows.Scan(&name, &bound); err != nil {
+			return nil, true, err
+		}
+
+		match := partitionBoundRegex.FindStringSubmatch(bound)
+		if match == nil {
+			// e.g. a DEFAULT partition
+			continue
+		}
+
+		partition := partitionRange{Name: name}
+		if partition.Start, err = parsePartitionBound(match[1], time.Time{}); err != nil {
+			return nil, true, err
+		}
+
+		if partition.End, err = parsePartitionBound(match[2], time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+			return nil, true, err
+		}
+
+		partitions = append(partitions, partition)
+	}
+
+	return partitions, true, rows.Err()
+}
+
+// managePartitionsWithTransaction uses the specified transaction `tx` to create
+// missing partitions ahead of time and to archive partitions older than the
+// subscription's archiveAfter period
+func (subscription *Subscription) managePartitionsWithTransaction(ctx context.Context, tx pgx.Tx) error {
+	archiveAfter, err := ParseRetention(subscription.Config["archiveAfter"])
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	cutoff := archiveAfter.Cutoff(now)
+
+	for idx, dataTypeName := range subscription.DataTypes {
+		dataType := data.DataTypes[dataTypeName]
+		dataTable := subscription.DataTables[idx]
+
+		// if table is not partitioned skip to next dataType
+		if !dataType.IsPartitioned {
+			continue
+		}
+
+		existing, ok, err := existingPartitions(ctx, tx, dataTable)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			log.Debug().Str("Table", dataTable).Msg("table was created without partitions")
+			continue
+		}
+
+		// create tables for expected date ranges that are not already covered
+		// or archived
+		for _, partition := range partitionRanges(dataTable, dataType.PartitionInterval, now) {
+			if !archiveAfter.IsZero() && !partition.End.After(cutoff) {
+				continue
+			}
+
+			covered := false
+			for _, other := range existing {
+				if partition.overlaps(other) {
+					covered = true
+					break
+				}
+			}
+
+			if covered {
+				continue
+			}
+
+			sql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s');",
+				pgx.Identifier{partition.Name}.Sanitize(), pgx.Identifier{dataTable}.Sanitize(),
+				partition.Start.Format(partitionBoundLayout), partition.End.Format(partitionBoundLayout))
+			log.Debug().Str("SQL", sql).Msg("creating partition table")
+			if _, err := tx.Exec(ctx, sql); err != nil {
+				return err
+			}
+		}
+
+		if archiveAfter.IsZero() {
+			continue
+		}
+
+		// detach partitions that only hold observations older than the cutoff
+		for _, partition := range existing {
+			if partition.End.After(cutoff) {
+				continue
+			}
+
+			log.Info().Str("Table", dataTable).Str("Partition", partition.Name).Time("Cutoff", cutoff).Msg("archiving partition")
+
+			for _, sql := range []string{
+				fmt.Sprintf("ALTER TABLE %s DETACH PARTITION %s", pgx.Identifier{dataTable}.Sanitize(), pgx.Identifier{partition.Name}.Sanitize()),
+				fmt.Sprintf("ALTER TABLE %s SET SCHEMA %s", pgx.Identifier{partition.Name}.Sanitize(), partitionArchiveSchema),
+			} {
+				if _, err := tx.Exec(ctx, sql); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+var (
+	ErrInvalidStatementCacheMode = errors.New("invalid statement cache mode")
+)
+
+// defaultMaxRetries is the number of retries after a transient database error
+// when `db.max_retries` is not set
+const defaultMaxRetries = 3
+
+// retryBackoff is the wait before the first retry; it doubles with each retry
+var retryBackoff = 250 * time.Millisecond
+
+// statementCacheModes maps the names accepted by `db.statement_cache_mode` to
+// pgx query execution modes
+var statementCacheModes = map[string]pgx.QueryExecMode{
+	"cache_statement": pgx.QueryExecModeCacheStatement,
+	"cache_describe":  pgx.QueryExecModeCacheDescribe,
+	"describe_exec":   pgx.QueryExecModeDescribeExec,
+	"exec":            pgx.QueryExecModeExec,
+	"simple_protocol": pgx.QueryExecModeSimpleProtocol,
+}
+
+// PoolConfig tunes the library's connection pool. Zero values use the pgx
+// defaults.
+type PoolConfig struct {
+	MaxConns        int32
+	MinConns        int32
+	MaxConnIdleTime time.Duration
+	MaxConnLifetime time.Duration
+
+	// MaxLockConns is the size of the separate pool run locks take their
+	// connections from. Each locked run holds a connection until it finishes
+	// so they are kept out of the pool used to fetch and save observations.
+	MaxLockConns int32
+
+	// StatementCacheMode is how queries are prepared: cache_statement (the
+	// default), cache_describe, describe_exec, exec, or simple_protocol. Use
+	// exec or simple_protocol behind a connection pooler in transaction mode.
+	StatementCacheMode string
+
+	// MaxRetries is the number of times an operation is retried after a
+	// transient error
+	MaxRetries int
+}
+
+// PoolConfigFromViper reads the pool configuration from the `db` section:
+// max_conns, min_conns, max_conn_idle_time, max_conn_lifetime, max_lock_conns,
+// statement_cache_mode, and max_retries
+func PoolConfigFromViper() PoolConfig {
+	config := PoolConfig{
+		MaxConns:           viper.GetInt32("db.max_conns"),
+		MinConns:           viper.GetInt32("db.min_conns"),
+		MaxConnIdleTime:    viper.GetDuration("db.max_conn_idle_time"),
+		MaxConnLifetime:    viper.GetDuration("db.max_conn_lifetime"),
+		MaxLockConns:       viper.GetInt32("db.max_lock_conns"),
+		StatementCacheMode: viper.GetString("db.statement_cache_mode"),
+		MaxRetries:         defaultMaxRetries,
+	}
+
+	if viper.IsSet("db.max_retries") {
+		config.MaxRetries = viper.GetInt("db.max_retries")
+	}
+
+	return config
+}
+
+// apply sets the non-zero values of config on poolConfig
+func (config PoolConfig) apply(poolConfig *pgxpool.Config) error {
+	if config.MaxConns > 0 {
+		poolConfig.MaxConns = config.MaxConns
+	}
+
+	if config.MinConns > 0 {
+		poolConfig.MinConns = config.MinConns
+	}
+
+	if config.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = config.MaxConnIdleTime
+	}
+
+	if config.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = config.MaxConnLifetime
+	}
+
+	if config.StatementCacheMode != "" {
+		mode, ok := statementCacheModes[config.StatementCacheMode]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrInvalidStatementCacheMode, config.StatementCacheMode)
+		}
+		poolConfig.ConnConfig.DefaultQueryExecMode = mode
+	}
+
+	return nil
+}
+
+// IsTransient returns true if err is a database error that may succeed when
+// retried, e.g. a dropped connection, a serialization failure, or a deadlock
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if pgconn.SafeToRetry(err) || pgconn.Timeout(err) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"53300", // too_many_connections
+			"55P03", // lock_not_available
+			"57P01", // admin_shutdown
+			"57P02", // crash_shutdown
+			"57P03": // cannot_connect_now
+			return true
+		}
+
+		// class 08 is connection exceptions
+		return strings.HasPrefix(pgErr.Code, "08")
+	}
+
+	var connectErr *pgconn.ConnectError
+	return errors.As(err, &connectErr)
+}
+
+// Retry calls fn until it succeeds, it returns an error that is not transient,
+// or the configured number of retries is used up. The wait between attempts
+// doubles after each retry.
+func (myLibrary *Library) Retry(ctx context.Context, fn func(context.Context) error) error {
+	logger := zerolog.Ctx(ctx)
+	backoff := retryBackoff
+
+	for attempt := 0; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= myLibrary.PoolConfig.MaxRetries || !IsTransient(err) {
+			return err
+		}
+
+		logger.Warn().Err(err).Int("Attempt", attempt+1).Dur("Backoff", backoff).Msg("retrying after transient database error")
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		}
+
+		backoff *= 2
+	}
+}
+
+// WithConn acquires a connection for the duration of fn. Transient errors
+// are retried with a new connection. Long-running work, e.g. a provider
+// fetch, should use WithConn or release its connection as soon as it is done
+// with the database rather than hold it for the whole run.
+func (myLibrary *Library) WithConn(ctx context.Context, fn func(*pgxpool.Conn) error) error {
+	return myLibrary.Retry(ctx, func(ctx context.Context) error {
+		conn, err := myLibrary.Pool.Acquire(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Release()
+
+		return fn(conn)
+	})
+}
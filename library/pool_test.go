@@ -0,0 +1,62 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/penny-vault/pvdata/library"
+)
+
+var _ = Describe("Pool", func() {
+	DescribeTable("classifies transient errors",
+		func(err error, expected bool) {
+			Expect(library.IsTransient(err)).To(Equal(expected))
+		},
+		Entry("nil", nil, false),
+		Entry("other errors", errors.New("boom"), false),
+		Entry("deadlocks", &pgconn.PgError{Code: "40P01"}, true),
+		Entry("connection exceptions", &pgconn.PgError{Code: "08006"}, true),
+		Entry("wrapped serialization failures", fmt.Errorf("save: %w", &pgconn.PgError{Code: "40001"}), true),
+		Entry("joined errors", errors.Join(errors.New("boom"), &pgconn.PgError{Code: "57P01"}), true),
+		Entry("constraint violations", &pgconn.PgError{Code: "23505"}, false),
+	)
+
+	It("retries transient errors", func() {
+		myLibrary := &library.Library{PoolConfig: library.PoolConfig{MaxRetries: 1}}
+
+		attempts := 0
+		err := myLibrary.Retry(context.Background(), func(ctx context.Context) error {
+			attempts++
+			return &pgconn.PgError{Code: "40001"}
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(attempts).To(Equal(2))
+
+		attempts = 0
+		err = myLibrary.Retry(context.Background(), func(ctx context.Context) error {
+			attempts++
+			return errors.New("boom")
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(attempts).To(Equal(1))
+	})
+})
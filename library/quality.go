@@ -0,0 +1,254 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// defaultQualityWindow is the number of sessions scored when quality.window
+// is not set, about one year of trading
+const defaultQualityWindow = 252
+
+var (
+	ErrEODTableNotSet = errors.New("default.eod_table not set")
+)
+
+// AssetQuality is the data-quality scorecard of an asset's quotes in an EOD
+// table over a trailing window of sessions
+type AssetQuality struct {
+	EODTable      string `db:"eod_table" json:"eod_table"`
+	CompositeFigi string `db:"composite_figi" json:"composite_figi"`
+	Ticker        string `db:"ticker" json:"ticker"`
+
+	// WindowStart and WindowEnd are the first and last session scored; the
+	// window starts at the asset's listing and ends at its delisting if
+	// either falls inside the trailing window
+	WindowStart time.Time `db:"window_start" json:"window_start"`
+	WindowEnd   time.Time `db:"window_end" json:"window_end"`
+
+	ExpectedSessions int     `db:"expected_sessions" json:"expected_sessions"`
+	ObservedSessions int     `db:"observed_sessions" json:"observed_sessions"`
+	Coverage         float64 `db:"coverage" json:"coverage"`
+
+	// Gaps is the number of stretches of missing sessions before the last quote
+	Gaps int `db:"gaps" json:"gaps"`
+
+	// Corrections is the number of times a provider revised a quote in the window
+	Corrections int `db:"corrections" json:"corrections"`
+
+	// Anomalies is the number of quotes in the window that were quarantined
+	Anomalies int `db:"anomalies" json:"anomalies"`
+
+	// LastQuote is the date of the asset's most recent quote; it is zero if
+	// the table has no quotes for the asset
+	LastQuote time.Time `db:"last_quote" json:"last_quote"`
+
+	// StaleSessions is the number of sessions in the window after the last quote
+	StaleSessions int `db:"stale_sessions" json:"stale_sessions"`
+
+	RefreshedAt time.Time `db:"refreshed_at" json:"refreshed_at"`
+}
+
+// QualityFilter selects assets from the data-quality scorecard. Limits that
+// are zero are not applied.
+type QualityFilter struct {
+	// EODTable is the table the scorecard was computed for; default.eod_table if empty
+	EODTable string
+
+	// CompositeFigis restricts the report to the given assets
+	CompositeFigis []string
+
+	MinCoverage      float64
+	MaxGaps          int
+	MaxCorrections   int
+	MaxAnomalies     int
+	MaxStaleSessions int
+}
+
+// RefreshQuality recomputes the data-quality scorecard of every active or
+// recently delisted asset in default.asset_table over the last quality.window
+// sessions of eodTable and returns the number of assets scored. Sessions are
+// weekdays that are not holidays in default.holiday_table; the window ends at
+// the most recent quote in the table.
+func (myLibrary *Library) RefreshQuality(ctx context.Context, eodTable string) (int, error) {
+	assetTable := viper.GetString("default.asset_table")
+	if assetTable == "" {
+		return 0, data.ErrAssetTableNotSet
+	}
+
+	window := viper.GetInt("quality.window")
+	if window <= 0 {
+		window = defaultQualityWindow
+	}
+
+	holidayFilter := ""
+	if holidayTable := viper.GetString("default.holiday_table"); holidayTable != "" {
+		holidayFilter = fmt.Sprintf(`AND NOT EXISTS (SELECT 1 FROM %s h WHERE h.event_date = d::date
+			AND h.market IN ('NYSE', 'NASDAQ') AND NOT h.early_close)`, pgx.Identifier{holidayTable}.Sanitize())
+	}
+
+	sql := fmt.Sprintf(`WITH sessions AS (
+	SELECT d::date AS session
+	FROM generate_series((SELECT max(event_date) FROM %[1]s) - ($1::int * 7 / 5 + 30), (SELECT max(event_date) FROM %[1]s), interval '1 day') d
+	WHERE extract(isodow FROM d) < 6 %[3]s
+	ORDER BY d DESC LIMIT $1::int
+),
+bounds AS (SELECT min(session) AS window_start, max(session) AS window_end FROM sessions),
+universe AS (
+	SELECT a.composite_figi,
+		(array_agg(a.ticker ORDER BY a.active DESC NULLS LAST, a.last_updated DESC NULLS LAST))[1] AS ticker,
+		min(a.listed)::date AS listed,
+		CASE WHEN bool_or(coalesce(a.active, false)) THEN NULL ELSE max(a.delisted)::date END AS delisted
+	FROM %[2]s a CROSS JOIN bounds b
+	WHERE coalesce(a.composite_figi, '') <> ''
+	GROUP BY a.composite_figi, b.window_start
+	HAVING bool_or(coalesce(a.active, false)) OR max(a.delisted)::date >= b.window_start
+),
+quotes AS (
+	SELECT e.composite_figi::text AS composite_figi, e.event_date, e.num_corrections
+	FROM %[1]s e CROSS JOIN bounds b
+	WHERE e.event_date BETWEEN b.window_start AND b.window_end
+),
+quote_stats AS (
+	SELECT composite_figi, min(event_date) AS first_quote, max(event_date) AS last_quote, sum(num_corrections) AS corrections
+	FROM quotes GROUP BY composite_figi
+),
+spans AS (
+	SELECT u.composite_figi, u.ticker,
+		greatest(b.window_start, coalesce(u.listed,
+			CASE WHEN qs.first_quote IS NULL OR EXISTS (SELECT 1 FROM %[1]s e
+				WHERE e.composite_figi = u.composite_figi AND e.event_date < b.window_start)
+			THEN b.window_start ELSE qs.first_quote END)) AS span_start,
+		least(b.window_end, coalesce(u.delisted, b.window_end)) AS span_end,
+		coalesce(qs.last_quote, (SELECT max(e.event_date) FROM %[1]s e WHERE e.composite_figi = u.composite_figi)) AS last_quote,
+		coalesce(qs.corrections, 0) AS corrections
+	FROM universe u CROSS JOIN bounds b
+	LEFT JOIN quote_stats qs ON qs.composite_figi = u.composite_figi
+),
+calendar AS (
+	SELECT s.composite_figi, c.session, s.last_quote, q.event_date IS NOT NULL AS observed
+	FROM spans s JOIN sessions c ON c.session BETWEEN s.span_start AND s.span_end
+	LEFT JOIN quotes q ON q.composite_figi = s.composite_figi AND q.event_date = c.session
+),
+marked AS (
+	SELECT *, NOT observed AND coalesce(lag(observed) OVER (PARTITION BY composite_figi ORDER BY session), true) AS gap_start
+	FROM calendar
+),
+scores AS (
+	SELECT composite_figi, count(*) AS expected, count(*) FILTER (WHERE observed) AS observed,
+		count(*) FILTER (WHERE gap_start AND session < last_quote) AS gaps,
+		count(*) FILTER (WHERE last_quote IS NULL OR session > last_quote) AS stale
+	FROM marked GROUP BY composite_figi
+),
+anomalies AS (
+	SELECT q.composite_figi, count(*) AS anomalies
+	FROM quarantine q CROSS JOIN bounds b
+	WHERE q.data_type = $3 AND q.event_date BETWEEN b.window_start AND b.window_end
+		AND q.subscription_id IN (SELECT id FROM subscriptions WHERE $2 = ANY(data_tables))
+	GROUP BY q.composite_figi
+)
+INSERT INTO data_quality (eod_table, composite_figi, ticker, window_start, window_end, expected_sessions,
+	observed_sessions, coverage, gaps, corrections, anomalies, last_quote, stale_sessions, refreshed_at)
+SELECT $2, s.composite_figi, s.ticker, s.span_start, s.span_end, coalesce(sc.expected, 0), coalesce(sc.observed, 0),
+	CASE WHEN coalesce(sc.expected, 0) = 0 THEN 0 ELSE sc.observed::real / sc.expected END,
+	coalesce(sc.gaps, 0), s.corrections, coalesce(an.anomalies, 0), s.last_quote, coalesce(sc.stale, 0), $4
+FROM spans s
+LEFT JOIN scores sc ON sc.composite_figi = s.composite_figi
+LEFT JOIN anomalies an ON an.composite_figi = s.composite_figi
+ON CONFLICT (eod_table, composite_figi) DO UPDATE SET ticker = EXCLUDED.ticker, window_start = EXCLUDED.window_start,
+	window_end = EXCLUDED.window_end, expected_sessions = EXCLUDED.expected_sessions,
+	observed_sessions = EXCLUDED.observed_sessions, coverage = EXCLUDED.coverage, gaps = EXCLUDED.gaps,
+	corrections = EXCLUDED.corrections, anomalies = EXCLUDED.anomalies, last_quote = EXCLUDED.last_quote,
+	stale_sessions = EXCLUDED.stale_sessions, refreshed_at = EXCLUDED.refreshed_at`,
+		pgx.Identifier{eodTable}.Sanitize(), pgx.Identifier{assetTable}.Sanitize(), holidayFilter)
+
+	refreshedAt := time.Now()
+	numAssets := 0
+	err := myLibrary.WithConn(ctx, func(conn *pgxpool.Conn) error {
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+				log.Error().Err(err).Msg("could not rollback data quality transaction")
+			}
+		}()
+
+		tag, err := tx.Exec(ctx, sql, window, eodTable, data.EODKey, refreshedAt)
+		if err != nil {
+			return err
+		}
+
+		// assets that are no longer active or were removed from the asset table
+		if _, err := tx.Exec(ctx, `DELETE FROM data_quality WHERE eod_table = $1 AND refreshed_at < $2`,
+			eodTable, refreshedAt); err != nil {
+			return err
+		}
+
+		numAssets = int(tag.RowsAffected())
+		return tx.Commit(ctx)
+	})
+
+	return numAssets, err
+}
+
+// QualityReport returns the data-quality scorecards that pass filter, worst
+// coverage first. Strategies can use it to exclude poorly covered assets.
+// Scorecards are computed by RefreshQuality, which runs after each run that
+// saves quotes.
+func (myLibrary *Library) QualityReport(ctx context.Context, filter QualityFilter) ([]*AssetQuality, error) {
+	eodTable := filter.EODTable
+	if eodTable == "" {
+		eodTable = viper.GetString("default.eod_table")
+	}
+
+	if eodTable == "" {
+		return nil, ErrEODTableNotSet
+	}
+
+	compositeFigis := filter.CompositeFigis
+	if compositeFigis == nil {
+		compositeFigis = []string{}
+	}
+
+	report := []*AssetQuality{}
+	err := myLibrary.WithConn(ctx, func(conn *pgxpool.Conn) error {
+		report = report[:0]
+		return pgxscan.Select(ctx, conn, &report, `SELECT eod_table, composite_figi, ticker, window_start::timestamp,
+window_end::timestamp, expected_sessions, observed_sessions, coverage::float8, gaps, corrections, anomalies,
+coalesce(last_quote, '0001-01-01')::timestamp AS last_quote, stale_sessions, refreshed_at
+FROM data_quality
+WHERE eod_table = $1 AND (cardinality($2::text[]) = 0 OR composite_figi = ANY($2))
+	AND coverage >= $3 AND ($4 = 0 OR gaps <= $4) AND ($5 = 0 OR corrections <= $5)
+	AND ($6 = 0 OR anomalies <= $6) AND ($7 = 0 OR stale_sessions <= $7)
+ORDER BY coverage, ticker`, eodTable, compositeFigis, filter.MinCoverage, filter.MaxGaps, filter.MaxCorrections,
+			filter.MaxAnomalies, filter.MaxStaleSessions)
+	})
+
+	return report, err
+}
@@ -0,0 +1,155 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/db/dbtest"
+	"github.com/penny-vault/pvdata/library"
+)
+
+var _ = Describe("Data quality", func() {
+	var (
+		ctx        context.Context
+		myLibrary  *library.Library
+		assetTable string
+		eodTable   string
+	)
+
+	exec := func(sql string, args ...any) {
+		_, err := myLibrary.Pool.Exec(ctx, sql, args...)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	// createTable creates a migrated table for the data type with a default partition
+	createTable := func(dataType *data.DataType, tbl string) {
+		exec(dataType.ExpandedSchema(tbl))
+		if dataType.IsPartitioned {
+			exec(fmt.Sprintf(`CREATE TABLE %s PARTITION OF %s DEFAULT`, pgx.Identifier{tbl + "_default"}.Sanitize(),
+				pgx.Identifier{tbl}.Sanitize()))
+		}
+
+		for _, sql := range dataType.ExpandedMigrations(tbl) {
+			exec(sql)
+		}
+
+		DeferCleanup(func() {
+			_, err := myLibrary.Pool.Exec(context.Background(), fmt.Sprintf(`DROP TABLE IF EXISTS %s CASCADE`, pgx.Identifier{tbl}.Sanitize()))
+			Expect(err).NotTo(HaveOccurred())
+		})
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		var err error
+		myLibrary, err = dbtest.Library(ctx)
+		if errors.Is(err, dbtest.ErrNotConfigured) {
+			Skip("no test database configured")
+		}
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(myLibrary.Close)
+
+		suffix := strings.ReplaceAll(uuid.NewString(), "-", "")[:8]
+		assetTable = "quality_test_assets_" + suffix
+		eodTable = "quality_test_eod_" + suffix
+
+		createTable(data.DataTypes[data.AssetKey], assetTable)
+
+		previous := map[string]any{
+			"default.asset_table":   viper.Get("default.asset_table"),
+			"default.holiday_table": viper.Get("default.holiday_table"),
+			"quality.window":        viper.Get("quality.window"),
+		}
+
+		viper.Set("default.asset_table", assetTable)
+		viper.Set("default.holiday_table", "")
+		viper.Set("quality.window", 5)
+
+		DeferCleanup(func() {
+			for key, val := range previous {
+				viper.Set(key, val)
+			}
+
+			_, err := myLibrary.Pool.Exec(context.Background(), `DELETE FROM data_quality WHERE eod_table = $1`, eodTable)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		exec(fmt.Sprintf(`INSERT INTO %s (ticker, composite_figi, active) VALUES ('QLTY', 'BBG000QLTY01', true)`,
+			pgx.Identifier{assetTable}.Sanitize()))
+	})
+
+	Context("with quotes", func() {
+		BeforeEach(func() {
+			createTable(data.DataTypes[data.EODKey], eodTable)
+
+			// the week of 2024-01-08 without a quote on Wednesday
+			for _, day := range []string{"2024-01-08", "2024-01-09", "2024-01-11", "2024-01-12"} {
+				exec(fmt.Sprintf(`INSERT INTO %s (ticker, composite_figi, event_date, close) VALUES ('QLTY', 'BBG000QLTY01', $1, 10)`,
+					pgx.Identifier{eodTable}.Sanitize()), day)
+			}
+
+			// a provider revises Thursday's close
+			exec(fmt.Sprintf(`UPDATE %s SET close = 11 WHERE event_date = '2024-01-11'`, pgx.Identifier{eodTable}.Sanitize()))
+		})
+
+		It("scores coverage, gaps, corrections, and staleness", func() {
+			numAssets, err := myLibrary.RefreshQuality(ctx, eodTable)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(numAssets).To(Equal(1))
+
+			report, err := myLibrary.QualityReport(ctx, library.QualityFilter{EODTable: eodTable})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report).To(HaveLen(1))
+
+			quality := report[0]
+			Expect(quality.CompositeFigi).To(Equal("BBG000QLTY01"))
+			Expect(quality.Ticker).To(Equal("QLTY"))
+			Expect(quality.WindowStart.Format("2006-01-02")).To(Equal("2024-01-08"))
+			Expect(quality.WindowEnd.Format("2006-01-02")).To(Equal("2024-01-12"))
+			Expect(quality.ExpectedSessions).To(Equal(5))
+			Expect(quality.ObservedSessions).To(Equal(4))
+			Expect(quality.Coverage).To(BeNumerically("~", 0.8, 1e-6))
+			Expect(quality.Gaps).To(Equal(1))
+			Expect(quality.Corrections).To(Equal(1))
+			Expect(quality.Anomalies).To(Equal(0))
+			Expect(quality.StaleSessions).To(Equal(0))
+		})
+
+		It("filters the report", func() {
+			_, err := myLibrary.RefreshQuality(ctx, eodTable)
+			Expect(err).NotTo(HaveOccurred())
+
+			report, err := myLibrary.QualityReport(ctx, library.QualityFilter{EODTable: eodTable, MinCoverage: 0.9})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report).To(BeEmpty())
+
+			report, err = myLibrary.QualityReport(ctx, library.QualityFilter{EODTable: eodTable, CompositeFigis: []string{"BBG000QLTY01"}, MaxGaps: 1})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report).To(HaveLen(1))
+		})
+	})
+})
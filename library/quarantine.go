@@ -0,0 +1,167 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/penny-vault/pvdata/data"
+)
+
+var (
+	ErrQuarantineNotFound = errors.New("quarantined observation not found or already reviewed")
+)
+
+const (
+	QuarantinePending  = "pending"
+	QuarantineAccepted = "accepted"
+	QuarantineRejected = "rejected"
+)
+
+// QuarantinedObservation is an observation that failed screening and is held
+// for review instead of being saved
+type QuarantinedObservation struct {
+	ID             uuid.UUID
+	SubscriptionID uuid.UUID
+	DataType       string
+	Ticker         string
+	CompositeFigi  string
+	EventDate      time.Time
+	Reasons        []string
+	Observation    *data.Observation
+	Status         string
+	CreatedOn      time.Time
+}
+
+// Quarantine holds obs for review with the reasons it was flagged
+func (myLibrary *Library) Quarantine(ctx context.Context, obs *data.Observation, reasons []string) error {
+	payload, err := json.Marshal(obs)
+	if err != nil {
+		return err
+	}
+
+	var ticker, compositeFigi string
+	var eventDate *time.Time
+	if obs.EodQuote != nil {
+		ticker = obs.EodQuote.Ticker
+		compositeFigi = obs.EodQuote.CompositeFigi
+		eventDate = &obs.EodQuote.Date
+	}
+
+	_, err = myLibrary.Pool.Exec(ctx, `INSERT INTO quarantine ("subscription_id", "data_type", "ticker",
+"composite_figi", "event_date", "reasons", "observation") VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		obs.SubscriptionID, obs.DataType(), ticker, compositeFigi, eventDate, reasons, payload)
+	return err
+}
+
+// QuarantinedObservations returns observations waiting for review
+func (myLibrary *Library) QuarantinedObservations(ctx context.Context) ([]*QuarantinedObservation, error) {
+	rows, err := myLibrary.Pool.Query(ctx, `SELECT id, subscription_id, data_type, coalesce(ticker, '') AS ticker,
+coalesce(composite_figi, '') AS composite_figi, coalesce(event_date, '0001-01-01')::timestamp AS event_date,
+reasons, observation, status, created_on FROM quarantine WHERE status = $1 ORDER BY created_on`, QuarantinePending)
+	if err != nil {
+		return nil, err
+	}
+
+	quarantined := make([]*QuarantinedObservation, 0)
+	err = pgxscan.ScanAll(&quarantined, rows)
+	return quarantined, err
+}
+
+// NumQuarantined returns the number of observations quarantined since the given time by data type
+func (myLibrary *Library) NumQuarantined(ctx context.Context, since time.Time) (map[string]int, error) {
+	rows, err := myLibrary.Pool.Query(ctx, `SELECT data_type, count(*) FROM quarantine WHERE created_on >= $1 GROUP BY data_type`, since)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	var dataType string
+	var count int
+	_, err = pgx.ForEachRow(rows, []any{&dataType, &count}, func() error {
+		counts[dataType] = count
+		return nil
+	})
+
+	return counts, err
+}
+
+// AcceptQuarantined saves the quarantined observation to its subscription's tables
+func (myLibrary *Library) AcceptQuarantined(ctx context.Context, id string) error {
+	quarantined, err := myLibrary.pendingQuarantined(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	subscription, err := myLibrary.SubscriptionFromID(ctx, quarantined.SubscriptionID.String())
+	if err != nil {
+		return err
+	}
+
+	conn, err := myLibrary.Pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if err := myLibrary.SaveObservation(ctx, conn, subscription, quarantined.Observation); err != nil {
+		return err
+	}
+
+	return myLibrary.reviewQuarantined(ctx, quarantined.ID, QuarantineAccepted)
+}
+
+// RejectQuarantined discards the quarantined observation
+func (myLibrary *Library) RejectQuarantined(ctx context.Context, id string) error {
+	quarantined, err := myLibrary.pendingQuarantined(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return myLibrary.reviewQuarantined(ctx, quarantined.ID, QuarantineRejected)
+}
+
+func (myLibrary *Library) pendingQuarantined(ctx context.Context, id string) (*QuarantinedObservation, error) {
+	quarantineID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+
+	quarantined := &QuarantinedObservation{}
+	err = pgxscan.Get(ctx, myLibrary.Pool, quarantined, `SELECT id, subscription_id, data_type,
+coalesce(ticker, '') AS ticker, coalesce(composite_figi, '') AS composite_figi,
+coalesce(event_date, '0001-01-01')::timestamp AS event_date, reasons, observation, status, created_on
+FROM quarantine WHERE id = $1 AND status = $2`, quarantineID, QuarantinePending)
+	if pgxscan.NotFound(err) {
+		return nil, ErrQuarantineNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	quarantined.Observation.SubscriptionID = quarantined.SubscriptionID
+	return quarantined, nil
+}
+
+func (myLibrary *Library) reviewQuarantined(ctx context.Context, id uuid.UUID, status string) error {
+	_, err := myLibrary.Pool.Exec(ctx, `UPDATE quarantine SET status = $1, reviewed_on = now() WHERE id = $2`, status, id)
+	return err
+}
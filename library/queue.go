@@ -0,0 +1,339 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library
+
+import (
+	"encoding/json"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/rs/zerolog/log"
+)
+
+// ObservationQueue decouples providers from the database writer. Observations
+// sent to In are held in memory up to the queue's capacity; once full they are
+// spilled to a file in the spill directory and read back in order as the
+// writer catches up. Without a spill directory In blocks while the queue is full.
+// Observations sent to In are never dropped: if the spill file cannot be written
+// or read the queue stops reading In until the backlog drains.
+type ObservationQueue struct {
+	in  chan *data.Observation
+	out chan *data.Observation
+
+	capacity int
+	spillDir string
+
+	depth     atomic.Int64
+	spilled   atomic.Int64
+	highWater atomic.Int64
+}
+
+// spillRetryInterval is the wait before reading a spill file again after an error
+const spillRetryInterval = time.Second
+
+// QueueStats reports the number of observations waiting to be written
+type QueueStats struct {
+	Depth     int64 // observations held in memory
+	Spilled   int64 // observations waiting on disk
+	HighWater int64 // largest total backlog seen
+}
+
+// NewObservationQueue creates a queue holding up to capacity observations in memory.
+// If spillDir is not empty observations beyond capacity are written to disk.
+func NewObservationQueue(capacity int, spillDir string) *ObservationQueue {
+	if capacity <= 0 {
+		capacity = 1
+	}
+
+	queue := &ObservationQueue{
+		in:       make(chan *data.Observation),
+		out:      make(chan *data.Observation),
+		capacity: capacity,
+		spillDir: spillDir,
+	}
+
+	go queue.run()
+
+	return queue
+}
+
+// In returns the channel providers write observations to. Close it once all
+// providers have finished.
+func (queue *ObservationQueue) In() chan<- *data.Observation {
+	return queue.in
+}
+
+// Out returns the channel observations are read from; it is closed after In is
+// closed and every queued observation has been delivered
+func (queue *ObservationQueue) Out() <-chan *data.Observation {
+	return queue.out
+}
+
+// Stats returns the current queue depth
+func (queue *ObservationQueue) Stats() QueueStats {
+	return QueueStats{
+		Depth:     queue.depth.Load(),
+		Spilled:   queue.spilled.Load(),
+		HighWater: queue.highWater.Load(),
+	}
+}
+
+func (queue *ObservationQueue) run() {
+	defer close(queue.out)
+
+	mem := make([]*data.Observation, 0, queue.capacity)
+	in := queue.in
+
+	var spill *spillFile
+	if queue.spillDir != "" {
+		var err error
+		spill, err = newSpillFile(queue.spillDir)
+		if err != nil {
+			log.Error().Err(err).Str("SpillDir", queue.spillDir).Msg("could not create spill file; queue will block when full")
+		} else {
+			defer spill.Close()
+		}
+	}
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	// held is an observation that could not be spilled; input stops until it
+	// fits in memory behind everything already on disk
+	var held *data.Observation
+	var retry <-chan time.Time
+
+	for {
+		// refill memory from disk once it has drained so observations stay in order
+		if len(mem) == 0 && spill != nil && spill.count > 0 && retry == nil {
+			var err error
+			mem, err = spill.read(mem, queue.capacity)
+			if err != nil {
+				log.Error().Err(err).Dur("RetryIn", spillRetryInterval).Msg("could not read spilled observations")
+				retry = time.After(spillRetryInterval)
+			}
+		}
+
+		if held != nil && len(mem) < queue.capacity && (spill == nil || spill.count == 0) {
+			mem = append(mem, held)
+			held = nil
+		}
+
+		depth := int64(len(mem))
+		if held != nil {
+			depth++
+		}
+
+		queue.depth.Store(depth)
+		if spill != nil {
+			queue.spilled.Store(int64(spill.count))
+		}
+
+		if backlog := queue.depth.Load() + queue.spilled.Load(); backlog > queue.highWater.Load() {
+			queue.highWater.Store(backlog)
+		}
+
+		if in == nil && len(mem) == 0 && held == nil && (spill == nil || spill.count == 0) {
+			return
+		}
+
+		// without somewhere to spill stop reading input while full
+		receive := in
+		if held != nil || (len(mem) >= queue.capacity && spill == nil) {
+			receive = nil
+		}
+
+		var send chan *data.Observation
+		var next *data.Observation
+		if len(mem) > 0 {
+			send = queue.out
+			next = mem[0]
+		}
+
+		select {
+		case obs, ok := <-receive:
+			if !ok {
+				in = nil
+				continue
+			}
+
+			if len(mem) < queue.capacity && (spill == nil || spill.count == 0) {
+				mem = append(mem, obs)
+				continue
+			}
+
+			if err := spill.write(obs); err != nil {
+				log.Error().Err(err).Msg("could not spill observation to disk; blocking until the queue drains")
+				held = obs
+			}
+		case send <- next:
+			mem[0] = nil
+			mem = mem[1:]
+		case <-retry:
+			retry = nil
+		case <-ticker.C:
+			stats := queue.Stats()
+			log.Debug().Int64("Depth", stats.Depth).Int64("Spilled", stats.Spilled).Int64("HighWater", stats.HighWater).Msg("observation queue depth")
+		}
+	}
+}
+
+// spillFile is an append-only file of JSON encoded observations. Writes are
+// buffered in memory and reads resume after the last record decoded, so an
+// I/O error never loses an observation that was written.
+type spillFile struct {
+	file    *os.File
+	pending []byte
+	count   int
+
+	writeOffset int64
+	readOffset  int64 // end of the last record decoded
+	readBase    int64 // offset the decoder started reading at
+	reader      *json.Decoder
+}
+
+// spillRecord is the encoding of an observation in the spill file; it carries
+// the journal sequence, which is not part of the observation's JSON
+type spillRecord struct {
+	JournalSeq  uint64            `json:"journalSeq,omitempty"`
+	Observation *data.Observation `json:"observation"`
+}
+
+// spillBufferSize is the number of bytes buffered before they are written
+const spillBufferSize = 64 * 1024
+
+func newSpillFile(dir string) (*spillFile, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp(dir, "pvdata-spill-*.jsonl")
+	if err != nil {
+		return nil, err
+	}
+
+	spill := &spillFile{
+		file: file,
+	}
+	spill.reset()
+
+	return spill, nil
+}
+
+// write appends obs to the file; obs is not part of the file if an error is returned
+func (spill *spillFile) write(obs *data.Observation) error {
+	buf, err := json.Marshal(spillRecord{JournalSeq: obs.JournalSeq, Observation: obs})
+	if err != nil {
+		return err
+	}
+
+	if len(spill.pending)+len(buf) >= spillBufferSize {
+		if err := spill.flush(); err != nil {
+			return err
+		}
+	}
+
+	spill.pending = append(spill.pending, buf...)
+	spill.pending = append(spill.pending, '\n')
+	spill.count++
+
+	return nil
+}
+
+// flush writes buffered records to the end of the file; whatever could not be
+// written stays buffered
+func (spill *spillFile) flush() error {
+	for len(spill.pending) > 0 {
+		n, err := spill.file.WriteAt(spill.pending, spill.writeOffset)
+		spill.writeOffset += int64(n)
+		spill.pending = spill.pending[n:]
+		if err != nil {
+			return err
+		}
+	}
+
+	spill.pending = nil
+	return nil
+}
+
+func (spill *spillFile) read(mem []*data.Observation, n int) ([]*data.Observation, error) {
+	if err := spill.flush(); err != nil {
+		return mem, err
+	}
+
+	for ; n > 0 && spill.count > 0; n-- {
+		record := spillRecord{}
+		if err := spill.reader.Decode(&record); err != nil {
+			// the next read starts again after the last record decoded
+			spill.readBase = spill.readOffset
+			spill.reader = json.NewDecoder(&offsetReader{file: spill.file, offset: spill.readBase})
+			return mem, err
+		}
+
+		obs := record.Observation
+		if obs == nil {
+			obs = &data.Observation{}
+		}
+		obs.JournalSeq = record.JournalSeq
+
+		mem = append(mem, obs)
+		spill.count--
+		spill.readOffset = spill.readBase + spill.reader.InputOffset()
+	}
+
+	if spill.count == 0 {
+		spill.reset()
+	}
+
+	return mem, nil
+}
+
+// reset truncates the spill file once everything in it has been read
+func (spill *spillFile) reset() {
+	if err := spill.file.Truncate(0); err != nil {
+		log.Error().Err(err).Msg("could not truncate spill file")
+	}
+
+	spill.count = 0
+	spill.pending = nil
+	spill.writeOffset = 0
+	spill.readOffset = 0
+	spill.readBase = 0
+	spill.reader = json.NewDecoder(&offsetReader{file: spill.file})
+}
+
+func (spill *spillFile) Close() {
+	spill.file.Close()
+	os.Remove(spill.file.Name())
+}
+
+// offsetReader reads the spill file from its own position so reads do not
+// disturb writes
+type offsetReader struct {
+	file   *os.File
+	offset int64
+}
+
+func (reader *offsetReader) Read(p []byte) (int, error) {
+	n, err := reader.file.ReadAt(p, reader.offset)
+	reader.offset += int64(n)
+	if err != nil && n > 0 {
+		// the decoder will ask again once the buffered bytes are consumed
+		err = nil
+	}
+	return n, err
+}
@@ -0,0 +1,124 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library_test
+
+import (
+	"fmt"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+)
+
+var _ = Describe("ObservationQueue", func() {
+	var spillDir string
+
+	BeforeEach(func() {
+		spillDir = GinkgoT().TempDir()
+	})
+
+	send := func(queue *library.ObservationQueue, from, to int) {
+		for idx := from; idx < to; idx++ {
+			queue.In() <- &data.Observation{SubscriptionName: fmt.Sprintf("obs-%d", idx)}
+		}
+	}
+
+	receive := func(queue *library.ObservationQueue, n int) []string {
+		names := make([]string, 0, n)
+		for ; n > 0; n-- {
+			obs, ok := <-queue.Out()
+			Expect(ok).To(BeTrue())
+			names = append(names, obs.SubscriptionName)
+		}
+		return names
+	}
+
+	expected := func(from, to int) []string {
+		names := make([]string, 0, to-from)
+		for idx := from; idx < to; idx++ {
+			names = append(names, fmt.Sprintf("obs-%d", idx))
+		}
+		return names
+	}
+
+	spillFiles := func() []string {
+		files, err := filepath.Glob(filepath.Join(spillDir, "pvdata-spill-*.jsonl"))
+		Expect(err).NotTo(HaveOccurred())
+		return files
+	}
+
+	It("keeps observations in order across a spill and refill", func() {
+		queue := library.NewObservationQueue(2, spillDir)
+
+		send(queue, 0, 10)
+		Eventually(func() int64 { return queue.Stats().Spilled }).Should(Equal(int64(8)))
+		Expect(queue.Stats().HighWater).To(Equal(int64(10)))
+
+		// read part of the backlog so memory refills from disk, then spill again
+		Expect(receive(queue, 3)).To(Equal(expected(0, 3)))
+		send(queue, 10, 15)
+		Expect(receive(queue, 12)).To(Equal(expected(3, 15)))
+
+		close(queue.In())
+		Eventually(queue.Out()).Should(BeClosed())
+	})
+
+	It("keeps every observation when the spill file outgrows its write buffer", func() {
+		queue := library.NewObservationQueue(1, spillDir)
+
+		send(queue, 0, 5000)
+		close(queue.In())
+		Eventually(func() int64 { return queue.Stats().HighWater }).Should(Equal(int64(5000)))
+
+		Expect(receive(queue, 5000)).To(Equal(expected(0, 5000)))
+		Eventually(queue.Out()).Should(BeClosed())
+	})
+
+	It("drains queued observations after the input is closed", func() {
+		queue := library.NewObservationQueue(3, "")
+
+		send(queue, 0, 3)
+		close(queue.In())
+
+		Expect(receive(queue, 3)).To(Equal(expected(0, 3)))
+		Eventually(queue.Out()).Should(BeClosed())
+	})
+
+	It("drains spilled observations after the input is closed", func() {
+		queue := library.NewObservationQueue(1, spillDir)
+
+		send(queue, 0, 5)
+		close(queue.In())
+
+		Expect(receive(queue, 5)).To(Equal(expected(0, 5)))
+		Eventually(queue.Out()).Should(BeClosed())
+	})
+
+	It("removes the spill file once the queue is drained", func() {
+		queue := library.NewObservationQueue(1, spillDir)
+
+		send(queue, 0, 4)
+		Eventually(func() int64 { return queue.Stats().Spilled }).Should(Equal(int64(3)))
+		Expect(spillFiles()).To(HaveLen(1))
+
+		close(queue.In())
+		Expect(receive(queue, 4)).To(Equal(expected(0, 4)))
+		Eventually(queue.Out()).Should(BeClosed())
+		Expect(spillFiles()).To(BeEmpty())
+	})
+})
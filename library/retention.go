@@ -0,0 +1,201 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrInvalidRetention = errors.New("invalid retention period; expected a number followed by d, w, m, or y")
+)
+
+// Retention is how long observations are kept; a zero Retention keeps them forever
+type Retention struct {
+	Years  int
+	Months int
+	Days   int
+}
+
+// ParseRetention parses a retention period such as 30d, 12w, 6m, or 2y. An
+// empty string is a zero Retention.
+func ParseRetention(period string) (Retention, error) {
+	period = strings.TrimSpace(strings.ToLower(period))
+	if period == "" {
+		return Retention{}, nil
+	}
+
+	num, err := strconv.Atoi(period[:len(period)-1])
+	if err != nil || num <= 0 {
+		return Retention{}, fmt.Errorf("%w: %q", ErrInvalidRetention, period)
+	}
+
+	switch period[len(period)-1] {
+	case 'd':
+		return Retention{Days: num}, nil
+	case 'w':
+		return Retention{Days: num * 7}, nil
+	case 'm':
+		return Retention{Months: num}, nil
+	case 'y':
+		return Retention{Years: num}, nil
+	default:
+		return Retention{}, fmt.Errorf("%w: %q", ErrInvalidRetention, period)
+	}
+}
+
+// IsZero returns true if observations are kept forever
+func (retention Retention) IsZero() bool {
+	return retention.Years == 0 && retention.Months == 0 && retention.Days == 0
+}
+
+// Cutoff returns the date before which observations are pruned
+func (retention Retention) Cutoff(now time.Time) time.Time {
+	return now.AddDate(-retention.Years, -retention.Months, -retention.Days)
+}
+
+// PruneResult lists the rows removed, or that would be removed, from a table
+type PruneResult struct {
+	SubscriptionID   string
+	SubscriptionName string
+	DataType         string
+	Table            string
+	Cutoff           time.Time
+	NumRows          int64
+}
+
+// Retention returns the subscription's retention period for dataType. The
+// retention.<data type> config value takes precedence over retention.
+func (subscription *Subscription) Retention(dataType string) (Retention, error) {
+	if period, ok := subscription.Config["retention."+dataType]; ok {
+		return ParseRetention(period)
+	}
+
+	return ParseRetention(subscription.Config["retention"])
+}
+
+// Prune deletes rows older than the subscription's retention period. If dryRun
+// is true the rows are counted but not deleted.
+func (subscription *Subscription) Prune(ctx context.Context, now time.Time, dryRun bool) ([]*PruneResult, error) {
+	results := []*PruneResult{}
+
+	if len(subscription.DataTablesMap) == 0 {
+		subscription.ComputeTableNames()
+	}
+
+	for _, dataTypeName := range subscription.DataTypes {
+		retention, err := subscription.Retention(dataTypeName)
+		if err != nil {
+			return results, err
+		}
+
+		dataType, ok := data.DataTypes[dataTypeName]
+		if retention.IsZero() || !ok || dataType.DateColumn == "" {
+			continue
+		}
+
+		result := &PruneResult{
+			SubscriptionID:   subscription.ID.String(),
+			SubscriptionName: subscription.Name,
+			DataType:         dataTypeName,
+			Table:            subscription.DataTablesMap[dataTypeName],
+			Cutoff:           retention.Cutoff(now),
+		}
+
+		table := pgx.Identifier{result.Table}.Sanitize()
+		column := pgx.Identifier{dataType.DateColumn}.Sanitize()
+
+		if dryRun {
+			err = subscription.Library.Pool.QueryRow(ctx, fmt.Sprintf(`SELECT count(*) FROM %s WHERE %s < $1`, table, column),
+				result.Cutoff).Scan(&result.NumRows)
+		} else {
+			var tag pgconn.CommandTag
+			tag, err = subscription.Library.Pool.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE %s < $1`, table, column), result.Cutoff)
+			if err == nil {
+				result.NumRows = tag.RowsAffected()
+			}
+
+			// deleted rows of versioned tables are copied to the history table
+			// by the versioning trigger and are pruned from it as well
+			if err == nil && dataType.Versioned {
+				_, err = subscription.Library.Pool.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE %s < $1`,
+					pgx.Identifier{result.Table + "_history"}.Sanitize(), column), result.Cutoff)
+			}
+		}
+
+		if err != nil {
+			return results, err
+		}
+
+		log.Info().Str("SubscriptionID", result.SubscriptionID).Str("Table", result.Table).Time("Cutoff", result.Cutoff).
+			Int64("NumRows", result.NumRows).Bool("DryRun", dryRun).Msg("pruned observations")
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// PruneFiles removes files under dir that were last modified before cutoff and
+// returns the number of files removed. If dryRun is true the files are counted
+// but not removed.
+func PruneFiles(dir string, cutoff time.Time, dryRun bool) (int, error) {
+	numFiles := 0
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		if !info.ModTime().Before(cutoff) {
+			return nil
+		}
+
+		numFiles++
+		if dryRun {
+			return nil
+		}
+
+		return os.Remove(path)
+	})
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return numFiles, nil
+	}
+
+	return numFiles, err
+}
@@ -0,0 +1,276 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/google/uuid"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrRunCanceled = errors.New("run was canceled")
+	ErrRunNotFound = errors.New("run not found or not in a state that allows the requested action")
+)
+
+type RunState string
+
+const (
+	RunRunning  RunState = "running"
+	RunPaused   RunState = "paused"
+	RunCanceled RunState = "canceled"
+	RunFinished RunState = "finished"
+)
+
+// Run records a single execution of a subscription. Runs are stored in the
+// database so that they may be paused, resumed, or canceled from another process.
+type Run struct {
+	ID              uuid.UUID
+	SubscriptionID  uuid.UUID
+	State           RunState
+	Status          string
+	NumObservations int
+	Counts          map[string]int      `db:"-"`
+	SchemaDrift     []*data.SchemaDrift `db:"-"`
+
+	// EstimatedRequests is the number of API requests the run was expected to make
+	EstimatedRequests int
+
+	StartTime time.Time
+	EndTime   time.Time
+
+	Library *Library
+}
+
+type runControlKey struct{}
+
+// runControl tracks the requested state of a run that is in-flight
+type runControl struct {
+	mu      sync.Mutex
+	state   RunState
+	changed chan struct{}
+}
+
+func (control *runControl) set(state RunState) {
+	control.mu.Lock()
+	defer control.mu.Unlock()
+
+	if control.state == state {
+		return
+	}
+
+	control.state = state
+	close(control.changed)
+	control.changed = make(chan struct{})
+}
+
+func (control *runControl) get() (RunState, <-chan struct{}) {
+	control.mu.Lock()
+	defer control.mu.Unlock()
+	return control.state, control.changed
+}
+
+// StartRun records the start of a new run for subscription that is expected to
+// make estimatedRequests API requests
+func (myLibrary *Library) StartRun(ctx context.Context, subscription *Subscription, estimatedRequests int) (*Run, error) {
+	run := &Run{
+		SubscriptionID:    subscription.ID,
+		State:             RunRunning,
+		EstimatedRequests: estimatedRequests,
+		Library:           myLibrary,
+	}
+
+	err := myLibrary.Pool.QueryRow(ctx, `INSERT INTO runs ("subscription_id", "state", "estimated_requests") VALUES ($1, $2, $3) RETURNING id, start_time`,
+		run.SubscriptionID, run.State, run.EstimatedRequests).Scan(&run.ID, &run.StartTime)
+	if err != nil {
+		return nil, err
+	}
+
+	return run, nil
+}
+
+// Runs returns all runs that have not finished
+func (myLibrary *Library) Runs(ctx context.Context) ([]*Run, error) {
+	var runs []*Run
+	err := pgxscan.Select(ctx, myLibrary.Pool, &runs,
+		`SELECT id, subscription_id, state, coalesce(status, '') AS status, num_observations,
+coalesce(estimated_requests, 0) AS estimated_requests, start_time, coalesce(end_time, '0001-01-01'::timestamp) AS end_time FROM runs
+WHERE state IN ('running', 'paused') ORDER BY start_time`)
+	for _, run := range runs {
+		run.Library = myLibrary
+	}
+	return runs, err
+}
+
+// RequestsUsed returns the estimated number of API requests made by runs of
+// the provider's subscriptions that started within window of now
+func (myLibrary *Library) RequestsUsed(ctx context.Context, provider string, window time.Duration) (int, error) {
+	var used int
+	err := myLibrary.Pool.QueryRow(ctx, `SELECT coalesce(sum(r.estimated_requests), 0) FROM runs r
+JOIN subscriptions s ON s.id = r.subscription_id
+WHERE s.provider = $1 AND r.start_time >= now() - make_interval(secs => $2)`, provider, window.Seconds()).Scan(&used)
+	return used, err
+}
+
+// TimeSinceSuccess returns how long ago the most recent successful run of the
+// subscription started; ok is false if the subscription has never succeeded
+func (myLibrary *Library) TimeSinceSuccess(ctx context.Context, subscriptionID uuid.UUID) (elapsed time.Duration, ok bool, err error) {
+	var seconds *float64
+	err = myLibrary.Pool.QueryRow(ctx, `SELECT extract(epoch FROM now()::timestamp - max(start_time))::float8 FROM runs
+WHERE subscription_id = $1 AND status = $2`, subscriptionID, data.RunSuccess.String()).Scan(&seconds)
+	if err != nil || seconds == nil {
+		return 0, false, err
+	}
+
+	return time.Duration(*seconds * float64(time.Second)), true, nil
+}
+
+// CancelRun requests that the run with the given id stop. Providers stop at their next checkpoint.
+func (myLibrary *Library) CancelRun(ctx context.Context, runID string) error {
+	return myLibrary.setRunState(ctx, runID, RunCanceled, RunRunning, RunPaused)
+}
+
+// PauseRun requests that the run with the given id wait at its next checkpoint until resumed
+func (myLibrary *Library) PauseRun(ctx context.Context, runID string) error {
+	return myLibrary.setRunState(ctx, runID, RunPaused, RunRunning)
+}
+
+// ResumeRun continues a paused run
+func (myLibrary *Library) ResumeRun(ctx context.Context, runID string) error {
+	return myLibrary.setRunState(ctx, runID, RunRunning, RunPaused)
+}
+
+func (myLibrary *Library) setRunState(ctx context.Context, runID string, state RunState, from ...RunState) error {
+	id, err := uuid.Parse(runID)
+	if err != nil {
+		return err
+	}
+
+	fromStates := make([]string, len(from))
+	for idx, fromState := range from {
+		fromStates[idx] = string(fromState)
+	}
+
+	tag, err := myLibrary.Pool.Exec(ctx, `UPDATE runs SET state = $1 WHERE id = $2 AND state = ANY($3)`, state, id, fromStates)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrRunNotFound
+	}
+
+	return nil
+}
+
+// Watch polls the database for changes to the run's state every interval. The
+// returned context is canceled when the run is canceled and carries the
+// run's state for use by Checkpoint. Call the returned stop function once the
+// run is complete.
+func (run *Run) Watch(ctx context.Context, interval time.Duration) (context.Context, func()) {
+	control := &runControl{
+		state:   RunRunning,
+		changed: make(chan struct{}),
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	ctx = context.WithValue(ctx, runControlKey{}, control)
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			var state RunState
+			if err := run.Library.Pool.QueryRow(ctx, `SELECT state FROM runs WHERE id = $1`, run.ID).Scan(&state); err != nil {
+				log.Warn().Err(err).Str("RunID", run.ID.String()).Msg("could not refresh run state")
+				continue
+			}
+
+			if state != control.state {
+				log.Info().Str("RunID", run.ID.String()).Str("State", string(state)).Msg("run state changed")
+			}
+
+			control.set(state)
+			if state == RunCanceled {
+				cancel(ErrRunCanceled)
+				return
+			}
+		}
+	}()
+
+	stop := func() {
+		close(done)
+		cancel(nil)
+	}
+
+	return ctx, stop
+}
+
+// Finish records the outcome of the run. Runs that were canceled keep their canceled state.
+func (run *Run) Finish(ctx context.Context, summary data.RunSummary) error {
+	run.Status = summary.Status.String()
+	run.NumObservations = summary.NumObservations
+	run.Counts = summary.Counts
+	run.SchemaDrift = summary.SchemaDrift
+	run.EndTime = summary.EndTime
+
+	_, err := run.Library.Pool.Exec(ctx, `UPDATE runs SET
+state = CASE WHEN state = 'canceled' THEN state ELSE 'finished' END,
+status = $1, num_observations = $2, counts = $3, schema_drift = $4, end_time = $5 WHERE id = $6`,
+		run.Status, run.NumObservations, run.Counts, run.SchemaDrift, run.EndTime, run.ID)
+	return err
+}
+
+// Checkpoint should be called by providers between units of work. If the run has
+// been paused Checkpoint blocks until it is resumed. A non-nil error means the
+// run was canceled and the provider should stop.
+func Checkpoint(ctx context.Context) error {
+	control, ok := ctx.Value(runControlKey{}).(*runControl)
+	if !ok {
+		return context.Cause(ctx)
+	}
+
+	for {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+
+		state, changed := control.get()
+		if state != RunPaused {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/spf13/viper"
+)
+
+// defaultSearchLimit is the number of matches returned when no limit is set
+const defaultSearchLimit = 20
+
+// AssetMatch is an asset found by SearchAssets
+type AssetMatch struct {
+	Ticker          string         `db:"ticker" json:"ticker"`
+	CompositeFigi   string         `db:"composite_figi" json:"composite_figi"`
+	Name            string         `db:"name" json:"name"`
+	PrimaryExchange data.Exchange  `db:"primary_exchange" json:"primary_exchange"`
+	AssetType       data.AssetType `db:"asset_type" json:"asset_type"`
+	Active          bool           `db:"active" json:"active"`
+
+	// Score ranks the match from 0 to 1; exact ticker matches score highest
+	Score float64 `db:"score" json:"score"`
+}
+
+// AssetSearchOptions controls which assets SearchAssetsWithOptions returns
+type AssetSearchOptions struct {
+	// Table is the asset table to search; default.asset_table if empty
+	Table string
+
+	// Limit is the maximum number of matches; defaults to 20
+	Limit int
+
+	// ActiveOnly excludes delisted assets
+	ActiveOnly bool
+}
+
+// SearchAssets finds assets in the default asset table whose ticker or name
+// matches query, best match first
+func (myLibrary *Library) SearchAssets(ctx context.Context, query string) ([]*AssetMatch, error) {
+	return myLibrary.SearchAssetsWithOptions(ctx, query, AssetSearchOptions{})
+}
+
+// SearchAssetsWithOptions finds assets whose ticker or name matches query. An
+// exact ticker match ranks first, followed by ticker and name prefixes and
+// then fuzzy matches on trigram similarity, which tolerate typos. Active
+// assets rank above delisted assets with the same score.
+func (myLibrary *Library) SearchAssetsWithOptions(ctx context.Context, query string, opts AssetSearchOptions) ([]*AssetMatch, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return []*AssetMatch{}, nil
+	}
+
+	table := opts.Table
+	if table == "" {
+		table = viper.GetString("default.asset_table")
+	}
+
+	if table == "" {
+		return nil, data.ErrAssetTableNotSet
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	activeFilter := ""
+	if opts.ActiveOnly {
+		activeFilter = "AND active"
+	}
+
+	sql := fmt.Sprintf(`SELECT ticker, composite_figi, coalesce(name, '') AS name,
+coalesce(primary_exchange, '') AS primary_exchange, coalesce(asset_type::text, '') AS asset_type,
+coalesce(active, false) AS active, score FROM (
+	SELECT *, greatest(
+		CASE WHEN ticker = $1 THEN 1.0 WHEN ticker LIKE $2 THEN 0.8 ELSE 0.0 END,
+		CASE WHEN lower(name) LIKE $3 THEN 0.7 ELSE 0.0 END,
+		similarity(ticker, $1) * 0.6,
+		word_similarity($1, coalesce(name, '')) * 0.6
+	) AS score
+	FROM %s
+	WHERE (ticker LIKE $2 OR lower(name) LIKE $3 OR ticker %% $1 OR $1 <%% name) %s
+) matches
+ORDER BY score DESC, active DESC, ticker
+LIMIT $4`, pgx.Identifier{table}.Sanitize(), activeFilter)
+
+	ticker := strings.ToUpper(query)
+	matches := make([]*AssetMatch, 0, limit)
+	err := myLibrary.WithConn(ctx, func(conn *pgxpool.Conn) error {
+		matches = matches[:0]
+		return pgxscan.Select(ctx, conn, &matches, sql, ticker, escapeLike(ticker)+"%",
+			escapeLike(strings.ToLower(query))+"%", limit)
+	})
+
+	return matches, err
+}
+
+// escapeLike escapes the LIKE wildcards in value so it is matched literally
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// AssetHistory is the current and previous names of an asset along with the
+// changes recorded in the asset event log
+type AssetHistory struct {
+	CompositeFigi string             `json:"composite_figi"`
+	Names         []*data.AssetName  `json:"names"`
+	Events        []*data.AssetEvent `json:"events"`
+}
+
+// AssetHistory returns the names and recorded changes of the asset with the
+// given composite FIGI in the default asset table
+func (myLibrary *Library) AssetHistory(ctx context.Context, compositeFigi string) (*AssetHistory, error) {
+	history := &AssetHistory{CompositeFigi: compositeFigi}
+	err := myLibrary.WithConn(ctx, func(conn *pgxpool.Conn) error {
+		var err error
+		if history.Names, err = data.AssetNames(ctx, conn, compositeFigi); err != nil {
+			return err
+		}
+
+		history.Events, err = data.AssetEvents(ctx, conn, compositeFigi)
+		return err
+	})
+
+	return history, err
+}
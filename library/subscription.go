@@ -28,6 +28,7 @@ import (
 	"github.com/penny-vault/pvdata/data"
 	"github.com/penny-vault/pvdata/healthcheck"
 	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
 )
 
 type Subscription struct {
@@ -64,11 +65,6 @@ type Subscription struct {
 	Library *Library
 }
 
-type dateRange struct {
-	Start int
-	End   int
-}
-
 // Delete the subscription from database along with all associated tables
 func (subscription *Subscription) Delete(ctx context.Context) error {
 	conn, err := subscription.Library.Pool.Acquire(ctx)
@@ -90,9 +86,24 @@ func (subscription *Subscription) Delete(ctx context.Context) error {
 		}
 	}()
 
-	tables := subscription.PartitionTables()
+	// partitions are listed from the catalog; ranges computed for today miss
+	// older layouts and archived partitions
+	tables := make([]string, 0, len(subscription.DataTables)*20)
+	for _, dataTable := range subscription.DataTables {
+		partitions, err := partitionTables(ctx, tx, dataTable)
+		if err != nil {
+			return err
+		}
+		tables = append(tables, partitions...)
+	}
 	tables = append(tables, subscription.DataTables...)
 
+	for idx, dataTypeName := range subscription.DataTypes {
+		if dataType, ok := data.DataTypes[dataTypeName]; ok && dataType.Versioned {
+			tables = append(tables, subscription.DataTables[idx]+"_history")
+		}
+	}
+
 	// delete tables
 	for _, tblName := range tables {
 		log.Info().Str("TableName", tblName).Msg("delete table")
@@ -113,7 +124,7 @@ func (subscription *Subscription) Delete(ctx context.Context) error {
 
 	// now that all database related modification has succeeded delete any corresponding health check
 	if subscription.HealthCheckID != "" {
-		if err := healthcheck.Delete(subscription.HealthCheckID); err != nil {
+		if err := healthcheck.Delete(ctx, subscription.HealthCheckID); err != nil {
 			return err
 		}
 	}
@@ -153,7 +164,7 @@ func (subscription *Subscription) Activate(ctx context.Context) error {
 
 	// now that all database related modification has succeeded resume any corresponding health check
 	if subscription.HealthCheckID != "" {
-		if err := healthcheck.Resume(subscription.HealthCheckID); err != nil {
+		if err := healthcheck.Resume(ctx, subscription.HealthCheckID); err != nil {
 			return err
 		}
 	}
@@ -194,7 +205,7 @@ func (subscription *Subscription) Deactivate(ctx context.Context) error {
 
 	// now that all database related modification has succeeded pause any corresponding health check
 	if subscription.HealthCheckID != "" {
-		if err := healthcheck.Pause(subscription.HealthCheckID); err != nil {
+		if err := healthcheck.Pause(ctx, subscription.HealthCheckID); err != nil {
 			return err
 		}
 	}
@@ -228,6 +239,9 @@ func (subscription *Subscription) Save(ctx context.Context) error {
 		return err
 	}
 
+	// new tables are created with the latest schema
+	subscription.SchemaVersion = subscription.LatestSchemaVersion()
+
 	// make sure current user is set on subscription
 	if user, err := user.Current(); err != nil {
 		return err
@@ -259,6 +273,19 @@ VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11);`, subscription.ID.String(
 	return nil
 }
 
+// Update saves changes to the subscription's name, config, and schedule
+func (subscription *Subscription) Update(ctx context.Context) error {
+	conn, err := subscription.Library.Pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, `UPDATE subscriptions SET name=$2, config=$3, schedule=$4 WHERE id=$1`,
+		subscription.ID, subscription.Name, subscription.Config, subscription.Schedule)
+	return err
+}
+
 // Compute table names based on subscription data types
 func (subscription *Subscription) ComputeTableNames() {
 	ret := make([]string, len(subscription.DataTypes))
@@ -310,102 +337,110 @@ func (subscription *Subscription) ManagePartitions(ctx context.Context) error {
 	return nil
 }
 
-// managePartitionsWithTransaction uses the specified transaction `tx` to create missing partitions
-func (subscription *Subscription) managePartitionsWithTransaction(ctx context.Context, tx pgx.Tx) error {
-	for idx, dataTypeName := range subscription.DataTypes {
-		dataType := data.DataTypes[dataTypeName]
-		dataTable := subscription.DataTables[idx]
+// LatestSchemaVersion returns the schema version of the subscription's tables
+// once they are up-to-date. It is the sum of the versions of the subscription's
+// data types so that it changes when any one of them changes.
+func (subscription *Subscription) LatestSchemaVersion() int {
+	version := 0
+	for _, dataTypeName := range subscription.DataTypes {
+		if dataType, ok := data.DataTypes[dataTypeName]; ok {
+			version += dataType.Version
+		}
+	}
+	return version
+}
 
-		// if table is not partitioned skip to next dataType
-		if !dataType.IsPartitioned {
-			continue
+// MigrateTables brings the subscription's tables up-to-date with the latest
+// version of each data type's schema. Migrations are only run if the schema
+// version recorded for the subscription is out-of-date; system-versioning is
+// turned on or off whenever it does not match library.versioning.
+func (subscription *Subscription) MigrateTables(ctx context.Context) error {
+	conn, err := subscription.Library.Pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				log.Error().Err(err).Msg("error rollingback tx")
+			}
 		}
+	}()
 
-		// construct a list of date ranges
-		dates := []dateRange{
-			{
-				Start: 1900,
-				End:   2000,
-			},
-			{
-				Start: 2000,
-				End:   2005,
-			},
-			{
-				Start: 2005,
-				End:   2010,
-			},
-			{
-				Start: 2010,
-				End:   2015,
-			},
+	schemaVersion := subscription.LatestSchemaVersion()
+	versioning := viper.GetBool("library.versioning")
+	for idx, dataTypeName := range subscription.DataTypes {
+		dataType := data.DataTypes[dataTypeName]
+		if subscription.SchemaVersion != schemaVersion {
+			for _, sql := range dataType.ExpandedMigrations(subscription.DataTables[idx]) {
+				log.Debug().Str("SQL", sql).Msg("migrating table")
+				if _, err := tx.Exec(ctx, sql); err != nil {
+					return err
+				}
+			}
 		}
 
-		today := time.Now()
-		year := today.Year() + 1
+		sql := dataType.ExpandedVersioning(subscription.DataTables[idx], versioning)
+		if sql == "" {
+			continue
+		}
 
-		for ii := 2015; ii < year; ii += 5 {
-			dates = append(dates, dateRange{Start: ii, End: ii + 5})
+		enabled, err := versioningEnabled(ctx, tx, subscription.DataTables[idx])
+		if err != nil {
+			return err
 		}
 
-		// create tables for expected date ranges
-		for _, dt := range dates {
-			tableName := fmt.Sprintf("%s_%d_%d", dataTable, dt.Start, dt.End)
-			sql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%d-01-01') TO ('%d-01-01');",
-				tableName, dataTable, dt.Start, dt.End)
-			log.Debug().Str("SQL", sql).Msg("creating partition table")
+		if enabled != versioning {
 			if _, err := tx.Exec(ctx, sql); err != nil {
 				return err
 			}
 		}
 	}
+
+	if subscription.SchemaVersion != schemaVersion {
+		if _, err := tx.Exec(ctx, "UPDATE subscriptions SET schema_version=$1 WHERE id=$2", schemaVersion, subscription.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	subscription.SchemaVersion = schemaVersion
+
 	return nil
 }
 
+// versioningEnabled reports whether the system-versioning trigger is installed on table
+func versioningEnabled(ctx context.Context, tx pgx.Tx, table string) (bool, error) {
+	enabled := false
+	err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = $1 AND tgrelid = to_regclass($2))`,
+		table+"_versioning", pgx.Identifier{table}.Sanitize()).Scan(&enabled)
+	return enabled, err
+}
+
 // PartitionTables returns the table names for all paritions in the set
 func (subscription *Subscription) PartitionTables() []string {
 	tables := make([]string, 0, 10)
 
 	for idx, dataTypeName := range subscription.DataTypes {
 		dataType := data.DataTypes[dataTypeName]
-		dataTable := subscription.DataTables[idx]
 
 		// if table is not partitioned skip to next dataType
 		if !dataType.IsPartitioned {
 			continue
 		}
 
-		// construct a list of date ranges
-		dates := []dateRange{
-			{
-				Start: 1900,
-				End:   2000,
-			},
-			{
-				Start: 2000,
-				End:   2005,
-			},
-			{
-				Start: 2005,
-				End:   2010,
-			},
-			{
-				Start: 2010,
-				End:   2015,
-			},
-		}
-
-		today := time.Now()
-		year := today.Year() + 1
-
-		for ii := 2015; ii < year; ii++ {
-			dates = append(dates, dateRange{Start: ii, End: ii + 1})
-		}
-
-		// create tables for expected date ranges
-		for _, dt := range dates {
-			tableName := fmt.Sprintf("%s_%d_%d", dataTable, dt.Start, dt.End)
-			tables = append(tables, tableName)
+		for _, partition := range partitionRanges(subscription.DataTables[idx], dataType.PartitionInterval, time.Now().UTC()) {
+			tables = append(tables, partition.Name)
 		}
 	}
 
@@ -420,6 +455,19 @@ func (subscription *Subscription) createTables(ctx context.Context, tx pgx.Tx) e
 		if err != nil {
 			return err
 		}
+
+		// migrations are idempotent and add columns shared by all data types, e.g. lineage
+		for _, sql := range dataType.ExpandedMigrations(subscription.DataTables[idx]) {
+			if _, err := tx.Exec(ctx, sql); err != nil {
+				return err
+			}
+		}
+
+		if sql := dataType.ExpandedVersioning(subscription.DataTables[idx], viper.GetBool("library.versioning")); sql != "" {
+			if _, err := tx.Exec(ctx, sql); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
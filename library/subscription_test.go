@@ -0,0 +1,97 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/db/dbtest"
+	"github.com/penny-vault/pvdata/library"
+)
+
+var _ = Describe("Subscription", func() {
+	var (
+		ctx       context.Context
+		myLibrary *library.Library
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		var err error
+		myLibrary, err = dbtest.Library(ctx)
+		if errors.Is(err, dbtest.ErrNotConfigured) {
+			Skip("no test database configured")
+		}
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(myLibrary.Close)
+	})
+
+	exists := func(schema, table string) bool {
+		var found bool
+		Expect(myLibrary.Pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM pg_tables WHERE schemaname = $1 AND tablename = $2)`,
+			schema, table).Scan(&found)).To(Succeed())
+		return found
+	}
+
+	It("drops archived partitions and partitions of old layouts when it is deleted", func() {
+		subscription := &library.Subscription{
+			ID:        uuid.New(),
+			Name:      "partition test",
+			Provider:  "test",
+			Dataset:   "EOD",
+			DataTypes: []string{data.EODKey},
+			Config:    map[string]string{},
+			Library:   myLibrary,
+		}
+		subscription.ComputeTableNames()
+		Expect(subscription.Save(ctx)).To(Succeed())
+
+		eodTable := subscription.DataTables[0]
+		DeferCleanup(func() {
+			_, err := myLibrary.Pool.Exec(context.Background(), fmt.Sprintf(`DROP TABLE IF EXISTS %s CASCADE`, pgx.Identifier{eodTable}.Sanitize()))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		// a partition of a layout partitionRanges no longer produces and one
+		// that ManagePartitions archived
+		oldLayout := eodTable + "_1850_1900"
+		archived := eodTable + "_2000_2005"
+
+		for _, sql := range []string{
+			fmt.Sprintf(`CREATE TABLE %s PARTITION OF %s FOR VALUES FROM ('1850-01-01') TO ('1900-01-01')`,
+				pgx.Identifier{oldLayout}.Sanitize(), pgx.Identifier{eodTable}.Sanitize()),
+			fmt.Sprintf(`ALTER TABLE %s DETACH PARTITION %s`, pgx.Identifier{eodTable}.Sanitize(), pgx.Identifier{archived}.Sanitize()),
+			fmt.Sprintf(`ALTER TABLE %s SET SCHEMA archive`, pgx.Identifier{archived}.Sanitize()),
+		} {
+			_, err := myLibrary.Pool.Exec(ctx, sql)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		Expect(subscription.Delete(ctx)).To(Succeed())
+
+		Expect(exists("public", eodTable)).To(BeFalse())
+		Expect(exists("public", oldLayout)).To(BeFalse())
+		Expect(exists("archive", archived)).To(BeFalse())
+	})
+})
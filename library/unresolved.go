@@ -0,0 +1,204 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base32"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+// UnresolvedPolicy determines what happens to active assets whose composite
+// FIGI could not be found
+type UnresolvedPolicy string
+
+const (
+	// UnresolvedDrop discards the asset
+	UnresolvedDrop UnresolvedPolicy = "drop"
+
+	// UnresolvedPending discards the asset and records it in the unresolved_assets
+	// table until a later run resolves it
+	UnresolvedPending UnresolvedPolicy = "pending"
+
+	// UnresolvedSynthetic records the asset like UnresolvedPending and saves it
+	// with a synthetic FIGI. Once the asset is resolved the synthetic asset is
+	// deactivated.
+	UnresolvedSynthetic UnresolvedPolicy = "synthetic"
+)
+
+// syntheticFigiPrefix distinguishes synthetic identifiers from FIGIs, which
+// always start with BBG
+const syntheticFigiPrefix = "PVS"
+
+// UnresolvedAsset is an asset whose composite FIGI could not be found
+type UnresolvedAsset struct {
+	Ticker          string     `db:"ticker"`
+	PrimaryExchange string     `db:"primary_exchange"`
+	AssetType       string     `db:"asset_type"`
+	Name            string     `db:"name"`
+	SyntheticFigi   *string    `db:"synthetic_figi"`
+	ResolvedFigi    *string    `db:"resolved_figi"`
+	Attempts        int        `db:"attempts"`
+	FirstSeen       time.Time  `db:"first_seen"`
+	LastAttempt     time.Time  `db:"last_attempt"`
+	ResolvedOn      *time.Time `db:"resolved_on"`
+}
+
+// UnresolvedAssetPolicy returns the policy configured with `figi.unresolved_policy`;
+// pending is used if it is not set
+func UnresolvedAssetPolicy() UnresolvedPolicy {
+	switch policy := UnresolvedPolicy(strings.ToLower(viper.GetString("figi.unresolved_policy"))); policy {
+	case UnresolvedDrop, UnresolvedSynthetic:
+		return policy
+	default:
+		return UnresolvedPending
+	}
+}
+
+// SyntheticFigi returns a 12 character identifier for an asset derived from
+// its ticker and primary exchange. The same asset always receives the same
+// identifier.
+func SyntheticFigi(asset *data.Asset) string {
+	sum := sha256.Sum256([]byte(asset.Ticker + ":" + string(asset.PrimaryExchange)))
+	return syntheticFigiPrefix + base32.StdEncoding.EncodeToString(sum[:])[:12-len(syntheticFigiPrefix)]
+}
+
+// IsSyntheticFigi returns true if figi was created by SyntheticFigi
+func IsSyntheticFigi(figi string) bool {
+	return strings.HasPrefix(figi, syntheticFigiPrefix)
+}
+
+// ResolveAssets applies the unresolved asset policy to assets after they have
+// been enriched with FIGIs and returns the assets that should be saved. Active
+// assets without a composite FIGI are recorded in the unresolved_assets table
+// and, depending on the policy, dropped or given a synthetic FIGI. Assets that
+// were previously unresolved and now have a FIGI are marked resolved; if the
+// asset was saved with a synthetic FIGI a deactivated copy of the synthetic
+// asset is returned as well.
+func (myLibrary *Library) ResolveAssets(ctx context.Context, assets []*data.Asset) []*data.Asset {
+	logger := zerolog.Ctx(ctx)
+	policy := UnresolvedAssetPolicy()
+
+	drop := func() []*data.Asset {
+		return slices.DeleteFunc(assets, func(asset *data.Asset) bool {
+			return asset.CompositeFigi == ""
+		})
+	}
+
+	if policy == UnresolvedDrop {
+		return drop()
+	}
+
+	unresolved, err := myLibrary.UnresolvedAssets(ctx, 0)
+	if err != nil {
+		logger.Error().Err(err).Msg("could not load unresolved assets; assets without a FIGI are dropped")
+		return drop()
+	}
+
+	known := make(map[string]*UnresolvedAsset, len(unresolved))
+	for _, asset := range unresolved {
+		known[asset.Ticker+":"+asset.PrimaryExchange] = asset
+	}
+
+	now := time.Now()
+	numDropped := 0
+	resolved := make([]*data.Asset, 0, len(assets))
+	for _, asset := range assets {
+		pending, ok := known[asset.Ticker+":"+string(asset.PrimaryExchange)]
+
+		if asset.CompositeFigi != "" {
+			resolved = append(resolved, asset)
+
+			if ok && !IsSyntheticFigi(asset.CompositeFigi) {
+				if _, err := myLibrary.Pool.Exec(ctx, `UPDATE unresolved_assets SET resolved_figi=$3, resolved_on=$4
+WHERE ticker=$1 AND primary_exchange=$2`, pending.Ticker, pending.PrimaryExchange, asset.CompositeFigi, now); err != nil {
+					logger.Error().Err(err).Str("Ticker", asset.Ticker).Msg("could not mark asset resolved")
+					continue
+				}
+
+				logger.Info().Str("Ticker", asset.Ticker).Str("CompositeFigi", asset.CompositeFigi).Int("Attempts", pending.Attempts).Msg("resolved FIGI of previously unresolved asset")
+
+				if pending.SyntheticFigi != nil {
+					retired := *asset
+					retired.CompositeFigi = *pending.SyntheticFigi
+					retired.ShareClassFigi = ""
+					retired.Active = false
+					retired.DelistingDate = now.Format(time.RFC3339)
+					resolved = append(resolved, &retired)
+				}
+			}
+
+			continue
+		}
+
+		// only active assets are enriched so inactive assets cannot be resolved
+		if !asset.Active {
+			numDropped++
+			continue
+		}
+
+		var synthetic *string
+		if policy == UnresolvedSynthetic {
+			figi := SyntheticFigi(asset)
+			synthetic = &figi
+		}
+
+		if _, err := myLibrary.Pool.Exec(ctx, `INSERT INTO unresolved_assets
+(ticker, primary_exchange, asset_type, name, synthetic_figi, attempts, first_seen, last_attempt)
+VALUES ($1, $2, $3, $4, $5, 1, $6, $6)
+ON CONFLICT (ticker, primary_exchange) DO UPDATE SET
+	asset_type = EXCLUDED.asset_type,
+	name = EXCLUDED.name,
+	synthetic_figi = coalesce(unresolved_assets.synthetic_figi, EXCLUDED.synthetic_figi),
+	attempts = unresolved_assets.attempts + 1,
+	last_attempt = EXCLUDED.last_attempt,
+	resolved_figi = NULL,
+	resolved_on = NULL`, asset.Ticker, string(asset.PrimaryExchange), string(asset.AssetType), asset.Name, synthetic, now); err != nil {
+			logger.Error().Err(err).Str("Ticker", asset.Ticker).Msg("could not record unresolved asset")
+		}
+
+		if synthetic == nil {
+			numDropped++
+			continue
+		}
+
+		asset.CompositeFigi = *synthetic
+		resolved = append(resolved, asset)
+	}
+
+	if numDropped > 0 {
+		logger.Warn().Int("NumDropped", numDropped).Str("Policy", string(policy)).Msg("assets without a composite FIGI were not saved")
+	}
+
+	return resolved
+}
+
+// UnresolvedAssets returns assets that have not been resolved after at least
+// minAttempts attempts, most attempted first
+func (myLibrary *Library) UnresolvedAssets(ctx context.Context, minAttempts int) ([]*UnresolvedAsset, error) {
+	unresolved := make([]*UnresolvedAsset, 0)
+	err := pgxscan.Select(ctx, myLibrary.Pool, &unresolved, `SELECT ticker, primary_exchange, asset_type,
+name, synthetic_figi, resolved_figi, attempts, first_seen, last_attempt, resolved_on
+FROM unresolved_assets WHERE resolved_figi IS NULL AND attempts >= $1 ORDER BY attempts DESC, ticker`, minAttempts)
+	return unresolved, err
+}
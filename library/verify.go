@@ -0,0 +1,275 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package library
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/rs/zerolog"
+)
+
+const defaultVerifySamples = 20
+
+var (
+	ErrVerifyFetchFailed = errors.New("provider fetch failed")
+)
+
+// VerifyFetch downloads the observations of a subscription; it has the same
+// signature as the Fetch function of a dataset
+type VerifyFetch func(context.Context, *Subscription, chan<- *data.Observation, chan<- data.RunSummary)
+
+// VerifyOptions controls which rows are sampled and how they are compared
+type VerifyOptions struct {
+	// Samples is the number of rows sampled from each data type
+	Samples int
+
+	// Start and End limit the sample to rows the provider returns when it is
+	// fetched, typically the date range of the dataset
+	Start time.Time
+	End   time.Time
+
+	// Tolerance and MaxExamples have the same meaning as in DiffOptions
+	Tolerance   float64
+	MaxExamples int
+}
+
+// VerifyReport compares rows sampled from the library (A) with the same rows
+// fetched from the provider (B). Sampled rows the provider did not return are
+// counted as missing from B.
+type VerifyReport struct {
+	SubscriptionID   uuid.UUID       `json:"subscriptionId"`
+	SubscriptionName string          `json:"subscriptionName"`
+	Start            time.Time       `json:"start"`
+	End              time.Time       `json:"end"`
+	Tolerance        float64         `json:"tolerance"`
+	DataTypes        []*DataTypeDiff `json:"dataTypes"`
+}
+
+// HasMismatches reports if any sampled row differs from the provider or was
+// not returned by it
+func (report *VerifyReport) HasMismatches() bool {
+	for _, dataType := range report.DataTypes {
+		if dataType.MissingFromB > 0 || dataType.Differing > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify samples rows stored by the subscription, refetches the subscription
+// from its provider, and compares the sampled rows with the refetched ones.
+// Refetched observations are written to temporary tables and never change the
+// library.
+func (subscription *Subscription) Verify(ctx context.Context, fetch VerifyFetch, opts VerifyOptions) (*VerifyReport, error) {
+	logger := zerolog.Ctx(ctx)
+
+	if opts.Samples <= 0 {
+		opts.Samples = defaultVerifySamples
+	}
+
+	if opts.Tolerance <= 0 {
+		opts.Tolerance = defaultDiffTolerance
+	}
+
+	if opts.MaxExamples <= 0 {
+		opts.MaxExamples = defaultDiffMaxExamples
+	}
+
+	conn, err := subscription.Library.Pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	verifySubscription := *subscription
+	verifySubscription.Config = make(map[string]string, len(subscription.Config))
+	for key, val := range subscription.Config {
+		// asset files are not compared
+		if key != "filer" {
+			verifySubscription.Config[key] = val
+		}
+	}
+
+	verifySubscription.DataTablesMap = make(map[string]string, len(subscription.DataTypes))
+	verifySubscription.DataTables = make([]string, 0, len(subscription.DataTypes))
+
+	sampleTables := make(map[string]string, len(subscription.DataTypes))
+	columns := make(map[string][]string, len(subscription.DataTypes))
+
+	defer func() {
+		for _, tbl := range verifySubscription.DataTables {
+			if _, err := conn.Exec(context.Background(), fmt.Sprintf("DROP TABLE IF EXISTS %[1]s, %[1]s_sample", tbl)); err != nil {
+				logger.Warn().Err(err).Str("Table", tbl).Msg("could not drop verify table")
+			}
+		}
+	}()
+
+	for idx, dataTypeName := range subscription.DataTypes {
+		dataType, ok := data.DataTypes[dataTypeName]
+		if !ok || len(dataType.KeyColumns()) == 0 {
+			continue
+		}
+
+		table := subscription.DataTables[idx]
+		verifyTable := fmt.Sprintf("pvdata_verify_%d", idx)
+
+		cols, err := verifyColumns(ctx, conn, table)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := conn.Exec(ctx, fmt.Sprintf("CREATE TEMP TABLE %s (LIKE %s INCLUDING DEFAULTS INCLUDING INDEXES)",
+			verifyTable, pgx.Identifier{table}.Sanitize())); err != nil {
+			return nil, err
+		}
+
+		verifySubscription.DataTables = append(verifySubscription.DataTables, verifyTable)
+		verifySubscription.DataTablesMap[dataTypeName] = verifyTable
+
+		if _, err := conn.Exec(ctx, fmt.Sprintf("CREATE TEMP TABLE %s_sample AS SELECT * FROM %s %s ORDER BY random() LIMIT %d",
+			verifyTable, pgx.Identifier{table}.Sanitize(), verifyRange(dataType.DateColumn, opts), opts.Samples)); err != nil {
+			return nil, err
+		}
+
+		sampleTables[dataTypeName] = verifyTable + "_sample"
+		columns[dataTypeName] = cols
+	}
+
+	// save refetched observations to the temporary tables
+	out := make(chan *data.Observation, 100)
+	exitNotification := make(chan data.RunSummary, 1)
+
+	go func() {
+		fetch(ctx, &verifySubscription, out, exitNotification)
+		close(out)
+	}()
+
+	for obs := range out {
+		if _, ok := verifySubscription.DataTablesMap[obs.DataType()]; !ok {
+			continue
+		}
+
+		if err := subscription.Library.SaveObservation(ctx, conn, &verifySubscription, obs); err != nil {
+			logger.Warn().Err(err).Msg("could not save refetched observation")
+		}
+	}
+
+	summary := <-exitNotification
+	if summary.Status == data.RunFailed || summary.Status == data.RunCanceled {
+		return nil, fmt.Errorf("%w: %s", ErrVerifyFetchFailed, summary.Status)
+	}
+
+	report := &VerifyReport{
+		SubscriptionID:   subscription.ID,
+		SubscriptionName: subscription.Name,
+		Start:            opts.Start,
+		End:              opts.End,
+		Tolerance:        opts.Tolerance,
+		DataTypes:        make([]*DataTypeDiff, 0, len(sampleTables)),
+	}
+
+	diffOpts := DiffOptions{Tolerance: opts.Tolerance, MaxExamples: opts.MaxExamples}
+
+	for _, dataTypeName := range subscription.DataTypes {
+		sampleTable, ok := sampleTables[dataTypeName]
+		if !ok {
+			continue
+		}
+
+		dataType := data.DataTypes[dataTypeName]
+		keyColumns := dataType.KeyColumns()
+
+		sampled, err := verifyRows(ctx, conn, columns[dataTypeName], keyColumns,
+			fmt.Sprintf("SELECT %s FROM %s", quoteColumns(columns[dataTypeName]), sampleTable))
+		if err != nil {
+			return nil, err
+		}
+
+		refetched, err := verifyRows(ctx, conn, columns[dataTypeName], keyColumns,
+			fmt.Sprintf("SELECT %s FROM %s JOIN %s USING (%s)", qualifyColumns("v", columns[dataTypeName]),
+				verifySubscription.DataTablesMap[dataTypeName]+" v", sampleTable, quoteColumns(keyColumns)))
+		if err != nil {
+			return nil, err
+		}
+
+		report.DataTypes = append(report.DataTypes, diffRows(dataType, sampled, refetched, diffOpts))
+	}
+
+	return report, nil
+}
+
+// verifyRange limits the sample to rows dated within the options' range
+func verifyRange(dateColumn string, opts VerifyOptions) string {
+	if dateColumn == "" {
+		return ""
+	}
+
+	conditions := make([]string, 0, 2)
+	if !opts.Start.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("%s >= '%s'", pgx.Identifier{dateColumn}.Sanitize(), opts.Start.Format(time.DateOnly)))
+	}
+
+	if !opts.End.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("%s < '%s'", pgx.Identifier{dateColumn}.Sanitize(), opts.End.AddDate(0, 0, 1).Format(time.DateOnly)))
+	}
+
+	if len(conditions) == 0 {
+		return ""
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
+
+func verifyColumns(ctx context.Context, conn *pgxpool.Conn, table string) ([]string, error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	return tableColumns(ctx, tx, table)
+}
+
+// verifyRows reads the result of query into a map keyed by primary key
+func verifyRows(ctx context.Context, conn *pgxpool.Conn, columns, keyColumns []string, query string) (map[string]diffRow, error) {
+	var buf bytes.Buffer
+	if _, err := conn.Conn().PgConn().CopyTo(ctx, &buf, fmt.Sprintf("COPY (%s) TO STDOUT", query)); err != nil {
+		return nil, err
+	}
+
+	rows := make(map[string]diffRow)
+	err := readCopyText(&buf, columns, func(row diffRow) {
+		rows[diffKey(row, keyColumns)] = row
+	})
+
+	return rows, err
+}
+
+func qualifyColumns(alias string, columns []string) string {
+	qualified := make([]string, len(columns))
+	for idx, column := range columns {
+		qualified[idx] = alias + "." + pgx.Identifier{column}.Sanitize()
+	}
+	return strings.Join(qualified, ", ")
+}
@@ -0,0 +1,145 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/penny-vault/pvdata/httpclient"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+var (
+	ErrStatus = errors.New("status code is invalid")
+)
+
+// Message is a notification sent to each configured notifier
+type Message struct {
+	Subject string
+	Body    string
+
+	// ContentType of Body, e.g. text/markdown, text/html, or application/json
+	ContentType string
+}
+
+// Notifier delivers messages to a destination
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, msg *Message) error
+}
+
+// FromConfig returns the notifiers configured in the `notify` section of the
+// config file. Messages are always written to the log.
+func FromConfig() []Notifier {
+	notifiers := []Notifier{&Log{}}
+
+	if url := viper.GetString("notify.webhook_url"); url != "" {
+		notifiers = append(notifiers, &Webhook{URL: url})
+	}
+
+	if dir := viper.GetString("notify.dir"); dir != "" {
+		notifiers = append(notifiers, &File{Dir: dir})
+	}
+
+	return notifiers
+}
+
+// Send delivers msg to each notifier. Failures are logged and do not prevent
+// delivery to the remaining notifiers.
+func Send(ctx context.Context, notifiers []Notifier, msg *Message) {
+	for _, notifier := range notifiers {
+		if err := notifier.Notify(ctx, msg); err != nil {
+			log.Error().Err(err).Str("Notifier", notifier.Name()).Str("Subject", msg.Subject).Msg("could not send notification")
+		}
+	}
+}
+
+// Log writes the message subject to the log
+type Log struct{}
+
+func (notifier *Log) Name() string {
+	return "log"
+}
+
+func (notifier *Log) Notify(ctx context.Context, msg *Message) error {
+	log.Info().Str("Subject", msg.Subject).Int("BodyLength", len(msg.Body)).Msg("notification")
+	return nil
+}
+
+// Webhook posts the message as JSON to URL. The `text` field makes the payload
+// compatible with Slack and Mattermost incoming webhooks.
+type Webhook struct {
+	URL string
+}
+
+func (notifier *Webhook) Name() string {
+	return "webhook"
+}
+
+func (notifier *Webhook) Notify(ctx context.Context, msg *Message) error {
+	resp, err := httpclient.New(ctx).R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(map[string]string{
+			"subject":      msg.Subject,
+			"text":         msg.Body,
+			"content_type": msg.ContentType,
+		}).
+		Post(notifier.URL)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode() >= 300 {
+		return fmt.Errorf("%w: %d", ErrStatus, resp.StatusCode())
+	}
+
+	return nil
+}
+
+// File writes each message to a new file in Dir
+type File struct {
+	Dir string
+}
+
+func (notifier *File) Name() string {
+	return "file"
+}
+
+func (notifier *File) Notify(ctx context.Context, msg *Message) error {
+	if err := os.MkdirAll(notifier.Dir, 0o755); err != nil {
+		return err
+	}
+
+	ext := ".txt"
+	switch {
+	case strings.Contains(msg.ContentType, "markdown"):
+		ext = ".md"
+	case strings.Contains(msg.ContentType, "html"):
+		ext = ".html"
+	case strings.Contains(msg.ContentType, "json"):
+		ext = ".json"
+	}
+
+	fn := filepath.Join(notifier.Dir, time.Now().Format("20060102T150405")+ext)
+	return os.WriteFile(fn, []byte(msg.Body), 0o644)
+}
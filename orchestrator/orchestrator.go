@@ -0,0 +1,663 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/healthcheck"
+	"github.com/penny-vault/pvdata/httpclient"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/penny-vault/pvdata/notify"
+	"github.com/penny-vault/pvdata/provider"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	// runPollInterval is how often in-flight runs check for pause and cancel requests
+	runPollInterval = 5 * time.Second
+
+	// quotaWindow is the period provider quotas are measured over
+	quotaWindow = 24 * time.Hour
+)
+
+var (
+	ErrDependencyCycle = errors.New("subscriptions have a circular dependency")
+	ErrQuotaExceeded   = errors.New("run would exceed the provider's remaining daily quota")
+)
+
+// Orchestrator runs a set of subscriptions within a single scheduling cycle.
+// Subscriptions are executed in dependency order: a subscription whose dataset
+// depends on a data type runs after every subscription in the cycle that
+// produces that data type. If a prerequisite fails its dependents are skipped.
+// Independent subscriptions run concurrently subject to the orchestrator's Limits.
+type Orchestrator struct {
+	Library *library.Library
+	Limits  Limits
+
+	// Notifiers are alerted when a subscription unexpectedly returns no observations
+	Notifiers []notify.Notifier
+
+	// EnforceQuota refuses to run subscriptions whose estimated requests exceed
+	// the provider's remaining daily quota; otherwise a warning is logged
+	EnforceQuota bool
+
+	// EnforceRefresh skips subscriptions whose last successful run was more
+	// recent than their dataset's minimum refresh interval; otherwise a warning
+	// is logged
+	EnforceRefresh bool
+
+	// WaitForLock queues subscriptions that another process is running until
+	// that run finishes; otherwise they are skipped
+	WaitForLock bool
+
+	// Barrier, if set, holds the dependents of a subscription until the sinks
+	// have handled every observation the subscription produced; otherwise
+	// dependents start as soon as the fetch returns
+	Barrier Barrier
+}
+
+// Barrier reports when observations written to the orchestrator's output have
+// been handled downstream
+type Barrier interface {
+	// Wait blocks until count observations produced by the run of the
+	// subscription have been written or acknowledged
+	Wait(ctx context.Context, subscriptionID, runID uuid.UUID, count int) error
+}
+
+// Limits bound the resources used by concurrently running subscriptions. A zero
+// value means the limit is not set.
+type Limits struct {
+	// MaxConcurrent is the maximum number of subscriptions running at once;
+	// defaults to 1 which runs subscriptions sequentially
+	MaxConcurrent int
+
+	// MaxHTTPConcurrency is the maximum number of HTTP requests in-flight across
+	// all providers
+	MaxHTTPConcurrency int
+
+	// ProviderConcurrency is the maximum number of subscriptions running at once
+	// for each provider; providers not listed run one subscription at a time
+	ProviderConcurrency map[string]int
+}
+
+// New creates a new orchestrator for the library
+func New(myLibrary *library.Library) *Orchestrator {
+	return &Orchestrator{
+		Library: myLibrary,
+		Limits: Limits{
+			MaxConcurrent: 1,
+		},
+	}
+}
+
+// Run executes each subscription and writes observations to out. The run summary
+// of every subscription, including those that were skipped, is returned in
+// dependency order.
+func (orchestrator *Orchestrator) Run(ctx context.Context, subscriptions []*library.Subscription, out chan<- *data.Observation) ([]data.RunSummary, error) {
+	ordered, prerequisites, err := Order(subscriptions)
+	if err != nil {
+		return nil, err
+	}
+
+	plans := orchestrator.plan(ctx, subscriptions)
+
+	if orchestrator.Limits.MaxHTTPConcurrency > 0 {
+		ctx = httpclient.WithLimiter(ctx, httpclient.NewLimiter(orchestrator.Limits.MaxHTTPConcurrency))
+	}
+
+	maxConcurrent := orchestrator.Limits.MaxConcurrent
+	if maxConcurrent <= 0 {
+		maxConcurrent = 1
+	}
+
+	slots := make(chan struct{}, maxConcurrent)
+	providerSlots := make(map[string]chan struct{})
+	for _, subscription := range ordered {
+		if _, ok := providerSlots[subscription.Provider]; !ok {
+			limit := orchestrator.Limits.ProviderConcurrency[subscription.Provider]
+			if limit <= 0 {
+				limit = 1
+			}
+			providerSlots[subscription.Provider] = make(chan struct{}, limit)
+		}
+	}
+
+	// done is closed once a subscription finishes; succeeded is only read after
+	// the corresponding done channel is closed
+	done := make(map[*library.Subscription]chan struct{}, len(ordered))
+	succeeded := make(map[*library.Subscription]bool, len(ordered))
+	summaries := make([]data.RunSummary, len(ordered))
+	for _, subscription := range ordered {
+		done[subscription] = make(chan struct{})
+	}
+
+	hasDependents := make(map[*library.Subscription]bool, len(ordered))
+	for _, subscription := range ordered {
+		for _, prerequisite := range prerequisites[subscription] {
+			hasDependents[prerequisite] = true
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for idx, subscription := range ordered {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(done[subscription])
+
+			skip := false
+			for _, prerequisite := range prerequisites[subscription] {
+				<-done[prerequisite]
+
+				mu.Lock()
+				ok := succeeded[prerequisite]
+				mu.Unlock()
+
+				if !ok {
+					log.Warn().Str("SubscriptionID", subscription.ID.String()).Str("PrerequisiteID", prerequisite.ID.String()).
+						Msg("skipping subscription because a prerequisite did not succeed")
+					skip = true
+					break
+				}
+			}
+
+			if skip {
+				now := time.Now()
+				summaries[idx] = data.RunSummary{
+					StartTime:        now,
+					EndTime:          now,
+					Status:           data.RunSkipped,
+					SubscriptionID:   subscription.ID,
+					SubscriptionName: subscription.Name,
+				}
+				return
+			}
+
+			var summary data.RunSummary
+			var runID uuid.UUID
+			var emitted int
+
+			// a subscription skipped because it succeeded recently still has the
+			// data its dependents need
+			fresh := false
+
+			// take the lock before a slot so that subscriptions waiting on
+			// another process do not keep others from running
+			lock, err := orchestrator.lock(ctx, subscription)
+			if err != nil {
+				now := time.Now()
+				summary = data.RunSummary{
+					StartTime:        now,
+					EndTime:          now,
+					Status:           data.RunSkipped,
+					SubscriptionID:   subscription.ID,
+					SubscriptionName: subscription.Name,
+				}
+			} else {
+				providerSlot := providerSlots[subscription.Provider]
+				providerSlot <- struct{}{}
+				slots <- struct{}{}
+
+				if err := orchestrator.checkRefresh(ctx, plans[subscription]); err != nil {
+					now := time.Now()
+					summary = data.RunSummary{
+						StartTime:        now,
+						EndTime:          now,
+						Status:           data.RunSkipped,
+						SubscriptionID:   subscription.ID,
+						SubscriptionName: subscription.Name,
+					}
+					fresh = true
+				} else if err := orchestrator.checkQuota(ctx, plans[subscription]); err != nil {
+					now := time.Now()
+					summary = data.RunSummary{
+						StartTime:        now,
+						EndTime:          now,
+						Status:           data.RunFailed,
+						SubscriptionID:   subscription.ID,
+						SubscriptionName: subscription.Name,
+					}
+				} else {
+					summary, runID, emitted = runSubscription(ctx, subscription, plans[subscription].Requests, out)
+				}
+
+				<-slots
+				<-providerSlot
+
+				lock.Unlock(context.WithoutCancel(ctx))
+			}
+
+			orchestrator.alert(ctx, subscription, summary)
+
+			// dependents only run after a successful run; a run whose status was
+			// never set is not a success
+			satisfied := summary.Status == data.RunSuccess || fresh
+
+			// dependents read what the subscription saved so wait for its
+			// observations to reach the sinks
+			if satisfied && hasDependents[subscription] && orchestrator.Barrier != nil && emitted > 0 {
+				if err := orchestrator.Barrier.Wait(ctx, subscription.ID, runID, emitted); err != nil {
+					log.Error().Err(err).Str("SubscriptionID", subscription.ID.String()).
+						Msg("observations were not written before dependents started")
+					satisfied = false
+				}
+			}
+
+			mu.Lock()
+			succeeded[subscription] = satisfied
+			mu.Unlock()
+
+			summaries[idx] = summary
+		}()
+	}
+
+	wg.Wait()
+
+	return summaries, nil
+}
+
+// lock takes the run lock of the subscription so that runs started by other
+// processes, e.g. an overlapping cron job, do not fetch it at the same time. If
+// the lock cannot be taken for a reason other than a run in progress the
+// subscription runs unlocked.
+func (orchestrator *Orchestrator) lock(ctx context.Context, subscription *library.Subscription) (*library.RunLock, error) {
+	logger := log.With().Str("SubscriptionID", subscription.ID.String()).Logger()
+
+	if orchestrator.WaitForLock {
+		lock, err := orchestrator.Library.LockRun(ctx, subscription.ID, false)
+		if errors.Is(err, library.ErrRunInProgress) {
+			logger.Info().Err(err).Msg("waiting for the run in progress to finish")
+			lock, err = orchestrator.Library.LockRun(ctx, subscription.ID, true)
+		}
+
+		if err != nil && ctx.Err() != nil {
+			return nil, err
+		}
+
+		if err != nil {
+			logger.Warn().Err(err).Msg("could not take run lock; running without it")
+		}
+
+		return lock, nil
+	}
+
+	lock, err := orchestrator.Library.LockRun(ctx, subscription.ID, false)
+	if errors.Is(err, library.ErrRunInProgress) {
+		logger.Warn().Err(err).Msg("skipping subscription")
+		return nil, err
+	}
+
+	if err != nil {
+		logger.Warn().Err(err).Msg("could not take run lock; running without it")
+	}
+
+	return lock, nil
+}
+
+// plan estimates the cost of each subscription; subscriptions that cannot be
+// planned are estimated to make no requests
+func (orchestrator *Orchestrator) plan(ctx context.Context, subscriptions []*library.Subscription) map[*library.Subscription]*PlannedRun {
+	plans := make(map[*library.Subscription]*PlannedRun, len(subscriptions))
+	for _, subscription := range subscriptions {
+		plans[subscription] = &PlannedRun{Subscription: subscription}
+	}
+
+	assets := []*data.Asset{}
+	if viper.GetString("default.asset_table") != "" {
+		conn, err := orchestrator.Library.Pool.Acquire(ctx)
+		if err != nil {
+			log.Warn().Err(err).Msg("could not acquire database connection to estimate requests")
+			return plans
+		}
+
+		assets = data.ActiveAssets(ctx, conn)
+		conn.Release()
+	}
+
+	plan, err := Plan(subscriptions, assets)
+	if err != nil {
+		log.Warn().Err(err).Msg("could not estimate requests")
+		return plans
+	}
+
+	for _, planned := range plan {
+		plans[planned.Subscription] = planned
+	}
+
+	return plans
+}
+
+// checkQuota compares the estimated requests of a run to the provider's
+// remaining daily quota. An error is returned if the run should not start.
+func (orchestrator *Orchestrator) checkQuota(ctx context.Context, planned *PlannedRun) error {
+	logger := log.With().Str("SubscriptionID", planned.Subscription.ID.String()).Int("EstimatedRequests", planned.Requests).Logger()
+
+	if planned.DailyQuota <= 0 {
+		logger.Info().Msg("estimated requests")
+		return nil
+	}
+
+	used, err := orchestrator.Library.RequestsUsed(ctx, planned.Subscription.Provider, quotaWindow)
+	if err != nil {
+		logger.Warn().Err(err).Msg("could not determine requests used by earlier runs")
+		return nil
+	}
+
+	remaining := planned.DailyQuota - used
+	logger = logger.With().Int("DailyQuota", planned.DailyQuota).Int("RemainingQuota", remaining).Logger()
+
+	if planned.Requests <= remaining {
+		logger.Info().Msg("estimated requests")
+		return nil
+	}
+
+	if orchestrator.EnforceQuota {
+		logger.Error().Err(ErrQuotaExceeded).Msg("refusing to run subscription")
+		return ErrQuotaExceeded
+	}
+
+	logger.Warn().Msg("run is expected to exceed the provider's remaining daily quota")
+	return nil
+}
+
+// checkRefresh compares the time since the subscription last succeeded with
+// its dataset's minimum refresh interval. ErrRefreshTooSoon is returned if the
+// run would not produce new observations and refresh intervals are enforced.
+func (orchestrator *Orchestrator) checkRefresh(ctx context.Context, planned *PlannedRun) error {
+	if planned.MinRefresh <= 0 {
+		return nil
+	}
+
+	logger := log.With().Str("SubscriptionID", planned.Subscription.ID.String()).
+		Str("MinRefresh", planned.MinRefresh.String()).Logger()
+
+	elapsed, ok, err := orchestrator.Library.TimeSinceSuccess(ctx, planned.Subscription.ID)
+	if err != nil {
+		logger.Warn().Err(err).Msg("could not determine when the subscription last succeeded")
+		return nil
+	}
+
+	if !ok || elapsed >= refreshThreshold(planned.MinRefresh) {
+		return nil
+	}
+
+	logger = logger.With().Str("SinceLastSuccess", elapsed.Round(time.Second).String()).Logger()
+
+	if orchestrator.EnforceRefresh {
+		logger.Info().Err(ErrRefreshTooSoon).Msg("skipping subscription")
+		return ErrRefreshTooSoon
+	}
+
+	logger.Warn().Msg("subscription ran recently; the dataset is unlikely to have changed")
+	return nil
+}
+
+// RunSubscription prepares the subscription's tables and fetches its dataset.
+// estimatedRequests is recorded with the run.
+func RunSubscription(ctx context.Context, subscription *library.Subscription, estimatedRequests int, out chan<- *data.Observation) data.RunSummary {
+	summary, _, _ := runSubscription(ctx, subscription, estimatedRequests, out)
+	return summary
+}
+
+// runSubscription runs the subscription and returns the ID its observations
+// were stamped with along with the number of observations written to out
+func runSubscription(ctx context.Context, subscription *library.Subscription, estimatedRequests int, out chan<- *data.Observation) (data.RunSummary, uuid.UUID, int) {
+	fetchLogger := log.With().Str("SubscriptionID", subscription.ID.String()).Logger()
+	ctx = fetchLogger.WithContext(ctx)
+
+	failed := data.RunSummary{
+		StartTime:        time.Now(),
+		EndTime:          time.Now(),
+		Status:           data.RunFailed,
+		SubscriptionID:   subscription.ID,
+		SubscriptionName: subscription.Name,
+	}
+
+	dataset, err := subscriptionDataset(subscription)
+	if err != nil {
+		fetchLogger.Error().Err(err).Str("ProviderKey", subscription.Provider).Str("DatasetKey", subscription.Dataset).
+			Msg("subscription is mis-configured")
+		return failed, uuid.Nil, 0
+	}
+
+	// create any needed partitions
+	if err := subscription.ManagePartitions(ctx); err != nil {
+		fetchLogger.Error().Err(err).Msg("ManagePartitions returned an error")
+	}
+
+	// bring tables up-to-date with the current schema
+	if err := subscription.MigrateTables(ctx); err != nil {
+		fetchLogger.Error().Err(err).Msg("MigrateTables returned an error")
+	}
+
+	// record the run so it can be paused or canceled while in-flight
+	run, err := subscription.Library.StartRun(ctx, subscription, estimatedRequests)
+	if err != nil {
+		fetchLogger.Warn().Err(err).Msg("could not record run; run controls are unavailable")
+	}
+
+	fetchCtx := ctx
+	if run != nil {
+		var stop func()
+		fetchCtx, stop = run.Watch(ctx, runPollInterval)
+		defer stop()
+		fetchLogger.Info().Str("RunID", run.ID.String()).Msg("started run")
+	}
+
+	// stamp the lineage of each observation as it leaves the provider
+	var runID uuid.UUID
+	if run != nil {
+		runID = run.ID
+	}
+
+	var emitted int
+	stamped := make(chan *data.Observation)
+	stampDone := make(chan struct{})
+	go func() {
+		defer close(stampDone)
+		for obs := range stamped {
+			emitted++
+			obs.SubscriptionID = subscription.ID
+			obs.RunID = runID
+			obs.Provider = subscription.Provider
+			obs.FetchedAt = time.Now()
+			out <- obs
+		}
+	}()
+
+	exitChan := make(chan data.RunSummary, 1)
+	dataset.Fetch(fetchCtx, subscription, stamped, exitChan)
+	close(stamped)
+	<-stampDone
+
+	// read the exit message from exitChan
+	summary := <-exitChan
+	if errors.Is(context.Cause(fetchCtx), library.ErrRunCanceled) {
+		summary.Status = data.RunCanceled
+	}
+
+	summary.NoData = classifyNoData(ctx, subscription, summary)
+
+	if run != nil {
+		if err := run.Finish(ctx, summary); err != nil {
+			fetchLogger.Error().Err(err).Msg("could not record run result")
+		}
+	}
+
+	fetchLogger.Info().Time("StartTime", summary.StartTime).Time("EndTime", summary.EndTime).
+		Str("RunTime", summary.EndTime.Sub(summary.StartTime).String()).Int("NumObservations", summary.NumObservations).
+		Interface("Counts", summary.Counts).Msg("finished running subscription")
+
+	for _, drift := range summary.SchemaDrift {
+		fetchLogger.Warn().Str("Source", drift.Source).Strs("UnknownFields", drift.UnknownFields).
+			Strs("MissingFields", drift.MissingFields).Strs("TypeChanges", drift.TypeChanges).
+			Msg("provider response format changed; check the provider before trusting new observations")
+	}
+
+	return summary, runID, emitted
+}
+
+// classifyNoData determines if a successful run without observations covered a
+// day the market was closed. The run covers the most recent session that had
+// closed when the run started.
+func classifyNoData(ctx context.Context, subscription *library.Subscription, summary data.RunSummary) data.NoDataType {
+	if summary.NumObservations > 0 || summary.Status == data.RunFailed || summary.Status == data.RunCanceled {
+		return data.NoDataNotApplicable
+	}
+
+	// datasets without data types, e.g. maintenance, never produce observations
+	if len(subscription.DataTypes) == 0 {
+		return data.NoDataNotApplicable
+	}
+
+	sessionDate := data.MarketDateOf(summary.StartTime, data.NYSEExchange)
+	if summary.StartTime.Before(sessionDate.Close(data.NYSEExchange)) {
+		sessionDate = sessionDate.AddDays(-1)
+	}
+
+	conn, err := subscription.Library.Pool.Acquire(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("could not acquire database connection to check market calendar")
+		return data.NoDataUnexpected
+	}
+	defer conn.Release()
+
+	tradingDay, err := data.IsTradingDay(ctx, conn, sessionDate.Time())
+	if err != nil {
+		log.Warn().Err(err).Msg("could not check market calendar")
+		return data.NoDataUnexpected
+	}
+
+	if tradingDay {
+		return data.NoDataUnexpected
+	}
+
+	return data.NoDataExpected
+}
+
+// alert reports the outcome of a run to its health check and notifies when a
+// run returned no observations on a trading day
+func (orchestrator *Orchestrator) alert(ctx context.Context, subscription *library.Subscription, summary data.RunSummary) {
+	logger := log.With().Str("SubscriptionID", subscription.ID.String()).Logger()
+
+	switch summary.NoData {
+	case data.NoDataExpected:
+		logger.Info().Msg("subscription returned no observations because the market was closed")
+	case data.NoDataUnexpected:
+		logger.Error().Msg("subscription returned no observations on a trading day")
+		notify.Send(ctx, orchestrator.Notifiers, &notify.Message{
+			Subject:     fmt.Sprintf("%s returned no observations", subscription.Name),
+			Body:        fmt.Sprintf("Subscription %s (%s) finished on %s without any observations even though the market was open.", subscription.Name, subscription.ID, summary.EndTime.Format("2006-01-02 15:04")),
+			ContentType: "text/plain",
+		})
+	}
+
+	if subscription.HealthCheckID != "" && summary.Status != data.RunCanceled {
+		failed := summary.Status == data.RunFailed || summary.NoData == data.NoDataUnexpected
+		if err := healthcheck.Ping(ctx, subscription.HealthCheckID, failed); err != nil {
+			logger.Warn().Err(err).Msg("could not ping health check")
+		}
+	}
+}
+
+// Order sorts subscriptions so that each subscription comes after the subscriptions
+// producing the data types it depends on. The returned map lists the prerequisites
+// of each subscription.
+func Order(subscriptions []*library.Subscription) ([]*library.Subscription, map[*library.Subscription][]*library.Subscription, error) {
+	producers := make(map[string][]*library.Subscription)
+	for _, subscription := range subscriptions {
+		for _, dataType := range subscription.DataTypes {
+			producers[dataType] = append(producers[dataType], subscription)
+		}
+	}
+
+	prerequisites := make(map[*library.Subscription][]*library.Subscription, len(subscriptions))
+	dependents := make(map[*library.Subscription][]*library.Subscription, len(subscriptions))
+	inDegree := make(map[*library.Subscription]int, len(subscriptions))
+
+	for _, subscription := range subscriptions {
+		inDegree[subscription] += 0
+
+		dataset, err := subscriptionDataset(subscription)
+		if err != nil {
+			// mis-configured subscriptions have no dependencies; they fail when run
+			continue
+		}
+
+		for _, dataType := range dataset.DependsOn {
+			for _, producer := range producers[dataType] {
+				if producer == subscription {
+					continue
+				}
+
+				prerequisites[subscription] = append(prerequisites[subscription], producer)
+				dependents[producer] = append(dependents[producer], subscription)
+				inDegree[subscription]++
+			}
+		}
+	}
+
+	// Kahn's algorithm; subscriptions without dependencies keep their original order
+	ordered := make([]*library.Subscription, 0, len(subscriptions))
+	queue := make([]*library.Subscription, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		if inDegree[subscription] == 0 {
+			queue = append(queue, subscription)
+		}
+	}
+
+	for len(queue) > 0 {
+		subscription := queue[0]
+		queue = queue[1:]
+		ordered = append(ordered, subscription)
+
+		for _, dependent := range dependents[subscription] {
+			inDegree[dependent]--
+			if inDegree[dependent] == 0 {
+				queue = append(queue, dependent)
+			}
+		}
+	}
+
+	if len(ordered) != len(subscriptions) {
+		return nil, nil, ErrDependencyCycle
+	}
+
+	return ordered, prerequisites, nil
+}
+
+func subscriptionDataset(subscription *library.Subscription) (provider.Dataset, error) {
+	subProvider, ok := provider.Map[subscription.Provider]
+	if !ok {
+		return provider.Dataset{}, provider.ErrProviderNotFound
+	}
+
+	subDataset, ok := subProvider.Datasets()[subscription.Dataset]
+	if !ok {
+		return provider.Dataset{}, provider.ErrDatasetNotFound
+	}
+
+	return subDataset, nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package orchestrator_test
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/penny-vault/pvdata/orchestrator"
+)
+
+var _ = Describe("Order", func() {
+	var (
+		eod    *library.Subscription
+		assets *library.Subscription
+		fred   *library.Subscription
+	)
+
+	BeforeEach(func() {
+		eod = &library.Subscription{ID: uuid.New(), Provider: "tiingo", Dataset: "EOD", DataTypes: []string{data.EODKey}}
+		assets = &library.Subscription{ID: uuid.New(), Provider: "tiingo", Dataset: "Stock Tickers", DataTypes: []string{data.AssetKey}}
+		fred = &library.Subscription{ID: uuid.New(), Provider: "fred", Dataset: "Economic Indicators", DataTypes: []string{data.EconomicIndicatorKey}}
+	})
+
+	It("runs producers before their dependents", func() {
+		ordered, prerequisites, err := orchestrator.Order([]*library.Subscription{eod, fred, assets})
+		Expect(err).To(BeNil())
+		Expect(ordered).To(Equal([]*library.Subscription{fred, assets, eod}))
+		Expect(prerequisites[eod]).To(ConsistOf(assets))
+		Expect(prerequisites[fred]).To(BeEmpty())
+	})
+
+	It("keeps the original order of independent subscriptions", func() {
+		ordered, _, err := orchestrator.Order([]*library.Subscription{fred, assets})
+		Expect(err).To(BeNil())
+		Expect(ordered).To(Equal([]*library.Subscription{fred, assets}))
+	})
+
+	It("runs dependents without prerequisites in the cycle", func() {
+		ordered, prerequisites, err := orchestrator.Order([]*library.Subscription{eod})
+		Expect(err).To(BeNil())
+		Expect(ordered).To(Equal([]*library.Subscription{eod}))
+		Expect(prerequisites[eod]).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Plan", func() {
+	var assets []*data.Asset
+
+	BeforeEach(func() {
+		assets = []*data.Asset{
+			{Ticker: "AAPL", AssetType: data.CommonStock},
+			{Ticker: "SPY", AssetType: data.ETF},
+			{Ticker: "VFIAX", AssetType: data.MutualFund},
+			{Ticker: "GLD", AssetType: data.ETF},
+		}
+	})
+
+	It("estimates requests from the assets the dataset supports", func() {
+		eod := &library.Subscription{ID: uuid.New(), Provider: "polygon", Dataset: "EOD", DataTypes: []string{data.EODKey},
+			Config: map[string]string{"rateLimit": "6"}}
+
+		plan, err := orchestrator.Plan([]*library.Subscription{eod}, assets)
+		Expect(err).To(BeNil())
+		Expect(plan).To(HaveLen(1))
+		Expect(plan[0].NumAssets).To(Equal(3))
+		Expect(plan[0].Requests).To(Equal(9))
+		Expect(plan[0].Duration.Seconds()).To(BeNumerically("~", 90))
+		Expect(plan[0].Warnings).To(BeEmpty())
+	})
+
+	It("warns when the estimate exceeds the daily quota", func() {
+		eod := &library.Subscription{ID: uuid.New(), Provider: "tiingo", Dataset: "EOD", DataTypes: []string{data.EODKey},
+			Config: map[string]string{"dailyQuota": "3"}}
+
+		plan, err := orchestrator.Plan([]*library.Subscription{eod}, assets)
+		Expect(err).To(BeNil())
+		Expect(plan[0].Requests).To(Equal(4))
+		Expect(plan[0].DailyQuota).To(Equal(3))
+		Expect(plan[0].Warnings).To(HaveLen(1))
+	})
+
+	It("warns when there are no assets to fetch", func() {
+		eod := &library.Subscription{ID: uuid.New(), Provider: "polygon", Dataset: "EOD", DataTypes: []string{data.EODKey}}
+		missing := &library.Subscription{ID: uuid.New(), Provider: "polygon", Dataset: "Options", DataTypes: []string{data.EODKey}}
+
+		plan, err := orchestrator.Plan([]*library.Subscription{eod, missing}, []*data.Asset{})
+		Expect(err).To(BeNil())
+		Expect(plan[0].Warnings).To(HaveLen(1))
+		Expect(plan[1].Warnings).To(HaveLen(1))
+	})
+
+	It("warns when a dataset is scheduled more often than it changes", func() {
+		fred := &library.Subscription{ID: uuid.New(), Provider: "fred", Dataset: "Economic Indicators",
+			DataTypes: []string{data.EconomicIndicatorKey}, Schedule: "0 * * * *"}
+
+		plan, err := orchestrator.Plan([]*library.Subscription{fred}, assets)
+		Expect(err).To(BeNil())
+		Expect(plan[0].ScheduleInterval).To(Equal(time.Hour))
+		Expect(plan[0].Warnings).To(HaveLen(1))
+	})
+})
+
+var _ = Describe("ScheduleInterval", func() {
+	DescribeTable("finds the shortest time between runs",
+		func(schedule string, expected time.Duration) {
+			interval, err := orchestrator.ScheduleInterval(schedule)
+			Expect(err).To(BeNil())
+			Expect(interval).To(Equal(expected))
+		},
+		Entry("every five minutes", "*/5 * * * *", 5*time.Minute),
+		Entry("hourly", "@hourly", time.Hour),
+		Entry("twice a day", "0 6,18 * * *", 12*time.Hour),
+		Entry("weekdays", "30 18 * * 1-5", 24*time.Hour),
+		Entry("weekly", "0 3 * * 0", 7*24*time.Hour),
+		Entry("monthly", "0 0 1 * *", 28*24*time.Hour),
+	)
+
+	It("rejects malformed schedules", func() {
+		_, err := orchestrator.ScheduleInterval("0 25 * * *")
+		Expect(err).To(MatchError(orchestrator.ErrInvalidSchedule))
+
+		_, err = orchestrator.ScheduleInterval("daily")
+		Expect(err).To(MatchError(orchestrator.ErrInvalidSchedule))
+	})
+})
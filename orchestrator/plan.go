@@ -0,0 +1,139 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package orchestrator
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+)
+
+// PlannedRun is the estimated cost of running a subscription
+type PlannedRun struct {
+	Subscription *library.Subscription
+
+	// NumAssets is the number of active assets the dataset has observations for
+	NumAssets int
+
+	// Requests is the estimated number of API requests the run makes
+	Requests int
+
+	// DailyQuota is the number of requests the provider allows per day; 0 if
+	// there is no daily limit
+	DailyQuota int
+
+	// Duration is the estimated run time given the subscription's rateLimit
+	// in requests per minute; zero if the subscription is not rate limited
+	Duration time.Duration
+
+	// MinRefresh is the shortest interval between runs that can produce new
+	// observations; 0 if the dataset has no natural frequency
+	MinRefresh time.Duration
+
+	// ScheduleInterval is the shortest time between two scheduled runs; 0 if
+	// the schedule could not be parsed
+	ScheduleInterval time.Duration
+
+	// Warnings describe problems that will cause the run to fail or produce
+	// no observations
+	Warnings []string
+}
+
+// Plan estimates the cost of running subscriptions, in the order they will run,
+// from the capabilities of their datasets and the library's active assets
+func Plan(subscriptions []*library.Subscription, assets []*data.Asset) ([]*PlannedRun, error) {
+	ordered, _, err := Order(subscriptions)
+	if err != nil {
+		return nil, err
+	}
+
+	producers := make(map[string]bool)
+	for _, subscription := range subscriptions {
+		for _, dataType := range subscription.DataTypes {
+			producers[dataType] = true
+		}
+	}
+
+	plan := make([]*PlannedRun, 0, len(ordered))
+	for _, subscription := range ordered {
+		planned := &PlannedRun{
+			Subscription: subscription,
+			Warnings:     []string{},
+		}
+
+		plan = append(plan, planned)
+
+		dataset, err := subscriptionDataset(subscription)
+		if err != nil {
+			planned.Warnings = append(planned.Warnings, fmt.Sprintf("%s %s: %s", subscription.Provider, subscription.Dataset, err))
+			continue
+		}
+
+		capabilities := dataset.Capabilities
+		for _, asset := range assets {
+			if len(capabilities.AssetTypes) == 0 || capabilities.SupportsAssetType(asset.AssetType) {
+				planned.NumAssets++
+			}
+		}
+
+		planned.Requests = capabilities.Cost.Estimate(planned.NumAssets)
+
+		planned.DailyQuota = capabilities.Cost.DailyQuota
+		if dailyQuota, err := strconv.Atoi(subscription.Config["dailyQuota"]); err == nil && dailyQuota >= 0 {
+			planned.DailyQuota = dailyQuota
+		}
+
+		if planned.DailyQuota > 0 && planned.Requests > planned.DailyQuota {
+			planned.Warnings = append(planned.Warnings, fmt.Sprintf("%d requests exceed the daily quota of %d", planned.Requests, planned.DailyQuota))
+		}
+
+		if rateLimit, err := strconv.Atoi(subscription.Config["rateLimit"]); err == nil && rateLimit > 0 {
+			planned.Duration = time.Duration(float64(planned.Requests) / float64(rateLimit) * float64(time.Minute))
+		}
+
+		planned.MinRefresh = capabilities.MinRefresh()
+		if subscription.Schedule != "" {
+			interval, err := ScheduleInterval(subscription.Schedule)
+			if err != nil {
+				planned.Warnings = append(planned.Warnings, err.Error())
+			}
+			planned.ScheduleInterval = interval
+		}
+
+		if planned.ScheduleInterval > 0 && planned.ScheduleInterval < refreshThreshold(planned.MinRefresh) {
+			planned.Warnings = append(planned.Warnings, fmt.Sprintf("scheduled every %s but the %s dataset only changes every %s",
+				planned.ScheduleInterval, capabilities.Granularity, planned.MinRefresh))
+		}
+
+		if capabilities.Cost.PerAsset > 0 && planned.NumAssets == 0 {
+			for _, dataType := range dataset.DependsOn {
+				if dataType == data.AssetKey && !producers[data.AssetKey] {
+					planned.Warnings = append(planned.Warnings, "no active assets of a supported asset type")
+				}
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// refreshThreshold is the shortest interval between runs that is not considered
+// wasteful; scheduled runs drift by a few minutes so some slack is allowed
+func refreshThreshold(minRefresh time.Duration) time.Duration {
+	return minRefresh - minRefresh/10
+}
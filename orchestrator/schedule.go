@@ -0,0 +1,187 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package orchestrator
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidSchedule = errors.New("invalid cron schedule")
+	ErrRefreshTooSoon  = errors.New("dataset has not had time to change since the last successful run")
+)
+
+// scheduleDescriptors are the cron shorthands accepted in place of five fields
+var scheduleDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ScheduleInterval returns the shortest time between two consecutive runs of a
+// five field cron schedule (minute, hour, day of month, month, day of week)
+func ScheduleInterval(schedule string) (time.Duration, error) {
+	if expanded, ok := scheduleDescriptors[strings.TrimSpace(schedule)]; ok {
+		schedule = expanded
+	}
+
+	fields := strings.Fields(schedule)
+	if len(fields) != 5 {
+		return 0, fmt.Errorf("%w: %q must have 5 fields", ErrInvalidSchedule, schedule)
+	}
+
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	sets := make([][]bool, 5)
+	for idx, field := range fields {
+		set, err := parseCronField(field, bounds[idx][0], bounds[idx][1])
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q: %w", ErrInvalidSchedule, schedule, err)
+		}
+		sets[idx] = set
+	}
+
+	minutes, hours, daysOfMonth, months, daysOfWeek := sets[0], sets[1], sets[2], sets[3], sets[4]
+
+	// 0 and 7 are both Sunday
+	daysOfWeek[0] = daysOfWeek[0] || daysOfWeek[7]
+
+	// cron runs on a day matching either field when both are restricted
+	restrictedDOM := !strings.HasPrefix(fields[2], "*")
+	restrictedDOW := !strings.HasPrefix(fields[4], "*")
+
+	// times of day the schedule runs, in minutes after midnight
+	times := make([]int, 0)
+	for hour := 0; hour < 24; hour++ {
+		for minute := 0; minute < 60; minute++ {
+			if hours[hour] && minutes[minute] {
+				times = append(times, hour*60+minute)
+			}
+		}
+	}
+
+	if len(times) == 0 {
+		return 0, fmt.Errorf("%w: %q never runs", ErrInvalidSchedule, schedule)
+	}
+
+	shortest := time.Duration(0)
+	consider := func(gap time.Duration) {
+		if shortest == 0 || gap < shortest {
+			shortest = gap
+		}
+	}
+
+	for idx := 1; idx < len(times); idx++ {
+		consider(time.Duration(times[idx]-times[idx-1]) * time.Minute)
+	}
+
+	// walk enough days to cover leap years and schedules that run once a year
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var previous time.Time
+	for range 366 * 4 {
+		matchDOM := daysOfMonth[day.Day()]
+		matchDOW := daysOfWeek[int(day.Weekday())]
+
+		runs := matchDOM && matchDOW
+		if restrictedDOM && restrictedDOW {
+			runs = matchDOM || matchDOW
+		}
+
+		if runs && months[int(day.Month())] {
+			first := day.Add(time.Duration(times[0]) * time.Minute)
+			if !previous.IsZero() {
+				consider(first.Sub(previous))
+			}
+			previous = day.Add(time.Duration(times[len(times)-1]) * time.Minute)
+		}
+
+		day = day.AddDate(0, 0, 1)
+	}
+
+	if previous.IsZero() {
+		return 0, fmt.Errorf("%w: %q never runs", ErrInvalidSchedule, schedule)
+	}
+
+	if shortest == 0 {
+		// the schedule runs once in the period walked
+		shortest = 4 * 366 * 24 * time.Hour
+	}
+
+	return shortest, nil
+}
+
+// parseCronField returns the values in [low, high] matched by a comma
+// separated list of *, values, ranges, and steps
+func parseCronField(field string, low, high int) ([]bool, error) {
+	set := make([]bool, high+1)
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		base, stepStr, hasStep := strings.Cut(part, "/")
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step %q", stepStr)
+			}
+			part = base
+		}
+
+		start, end := low, high
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			startStr, endStr, _ := strings.Cut(part, "-")
+			var err1, err2 error
+			start, err1 = strconv.Atoi(startStr)
+			end, err2 = strconv.Atoi(endStr)
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			value, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+
+			start = value
+			end = value
+			if hasStep {
+				end = high
+			}
+		}
+
+		if start < low || end > high || start > end {
+			return nil, fmt.Errorf("%q is out of range %d-%d", part, low, high)
+		}
+
+		for value := start; value <= end; value += step {
+			set[value] = true
+		}
+	}
+
+	if !slices.Contains(set, true) {
+		return nil, fmt.Errorf("%q matches nothing", field)
+	}
+
+	return set, nil
+}
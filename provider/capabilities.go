@@ -0,0 +1,121 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/penny-vault/pvdata/data"
+)
+
+// Granularity is the frequency of the observations a dataset produces
+type Granularity string
+
+const (
+	GranularityHourly    Granularity = "hourly"
+	GranularityDaily     Granularity = "daily"
+	GranularityWeekly    Granularity = "weekly"
+	GranularityMonthly   Granularity = "monthly"
+	GranularityQuarterly Granularity = "quarterly"
+
+	// GranularityEvent datasets produce observations when something happens,
+	// e.g. an earnings announcement or news article
+	GranularityEvent Granularity = "event"
+
+	// GranularityReference datasets describe the current state of an entity,
+	// e.g. the list of tradeable assets
+	GranularityReference Granularity = "reference"
+)
+
+// Capabilities describe what a dataset covers in a form the CLI and
+// orchestrator can reason about
+type Capabilities struct {
+	// AssetTypes the dataset has observations for; empty if the dataset is not
+	// about assets, e.g. economic indicators
+	AssetTypes []data.AssetType
+
+	// Geographies are the ISO 3166 country codes of the markets covered;
+	// "*" means global coverage
+	Geographies []string
+
+	Granularity Granularity
+
+	// RefreshInterval is how often the source data changes. If zero the period
+	// of Granularity is used; event and reference datasets without a refresh
+	// interval may be run as often as desired.
+	RefreshInterval time.Duration
+
+	// Backfill is true if the dataset retrieves full history on request rather
+	// than a short window of recent observations
+	Backfill bool
+
+	Cost Cost
+}
+
+// Cost estimates the number of API requests a single run makes
+type Cost struct {
+	// PerRun requests are made regardless of the number of assets
+	PerRun int
+
+	// PerAsset requests are made for each active asset
+	PerAsset int
+
+	// DailyQuota is the number of requests the provider allows per day on its
+	// entry-level plan; 0 if there is no daily limit. Subscriptions override it
+	// with the dailyQuota config value.
+	DailyQuota int
+}
+
+// Estimate returns the number of requests a run makes when numAssets are active
+func (cost Cost) Estimate(numAssets int) int {
+	return cost.PerRun + cost.PerAsset*numAssets
+}
+
+// SupportsAssetType returns true if the dataset has observations for assetType
+func (capabilities Capabilities) SupportsAssetType(assetType data.AssetType) bool {
+	return slices.Contains(capabilities.AssetTypes, assetType)
+}
+
+// CoversGeography returns true if the dataset covers the market of country
+func (capabilities Capabilities) CoversGeography(country string) bool {
+	return slices.ContainsFunc(capabilities.Geographies, func(geo string) bool {
+		return geo == "*" || strings.EqualFold(geo, country)
+	})
+}
+
+// MinRefresh returns the shortest interval between runs that can produce new
+// observations; 0 if the dataset has no natural frequency
+func (capabilities Capabilities) MinRefresh() time.Duration {
+	if capabilities.RefreshInterval > 0 {
+		return capabilities.RefreshInterval
+	}
+
+	switch capabilities.Granularity {
+	case GranularityHourly:
+		return time.Hour
+	case GranularityDaily:
+		return 24 * time.Hour
+	case GranularityWeekly:
+		return 7 * 24 * time.Hour
+	case GranularityMonthly:
+		return 28 * 24 * time.Hour
+	case GranularityQuarterly:
+		return 90 * 24 * time.Hour
+	default:
+		return 0
+	}
+}
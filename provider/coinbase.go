@@ -0,0 +1,111 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/penny-vault/pvdata/data"
+)
+
+type Coinbase struct{}
+
+func (coinbase *Coinbase) Name() string {
+	return "Coinbase"
+}
+
+func (coinbase *Coinbase) ConfigSchema() ConfigSchema {
+	return ConfigSchema{
+		{Name: "markets", Prompt: "Enter the markets to download, comma separated:", Type: ConfigString, Default: cryptoDefaultMarkets},
+	}
+}
+
+func (coinbase *Coinbase) Description() string {
+	return `Coinbase Exchange publishes OHLCV candles for the cryptocurrency markets it trades.`
+}
+
+func (coinbase *Coinbase) Datasets() map[string]Dataset {
+	return map[string]Dataset{
+		"Daily Candles": {
+			Name:        "Daily Candles",
+			Description: "Daily OHLCV candles for the configured markets.",
+			DataTypes:   []*data.DataType{data.DataTypes[data.CryptoQuoteKey]},
+			DateRange: func() (time.Time, time.Time) {
+				return time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC), time.Now().UTC()
+			},
+			Capabilities: Capabilities{
+				Geographies: []string{"*"},
+				Granularity: GranularityDaily,
+				Cost:        Cost{PerRun: 2},
+			},
+			Fetch: downloadCryptoCandles("coinbase", cryptoDaily, coinbaseCandles),
+		},
+
+		"Hourly Candles": {
+			Name:        "Hourly Candles",
+			Description: "Hourly OHLCV candles for the configured markets.",
+			DataTypes:   []*data.DataType{data.DataTypes[data.CryptoQuoteKey]},
+			DateRange: func() (time.Time, time.Time) {
+				return time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC), time.Now().UTC()
+			},
+			Capabilities: Capabilities{
+				Geographies: []string{"*"},
+				Granularity: GranularityHourly,
+				Cost:        Cost{PerRun: 2},
+			},
+			Fetch: downloadCryptoCandles("coinbase", cryptoHourly, coinbaseCandles),
+		},
+	}
+}
+
+// coinbaseCandles downloads the most recent 300 candles of market. Coinbase
+// returns each candle as [time, low, high, open, close, volume].
+func coinbaseCandles(ctx context.Context, client *resty.Client, market string, interval cryptoInterval) ([]*data.CryptoQuote, error) {
+	candles := make([][]float64, 0, 300)
+	resp, err := client.R().
+		SetContext(ctx).
+		SetQueryParam("granularity", strconv.Itoa(int(interval.Duration.Seconds()))).
+		SetResult(&candles).
+		Get(fmt.Sprintf("https://api.exchange.coinbase.com/products/%s/candles", market))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() >= 300 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidStatusCode, resp.StatusCode())
+	}
+
+	quotes := make([]*data.CryptoQuote, 0, len(candles))
+	for _, candle := range candles {
+		if len(candle) < 6 {
+			continue
+		}
+
+		quotes = append(quotes, &data.CryptoQuote{
+			EventTime: time.Unix(int64(candle[0]), 0).UTC(),
+			Low:       candle[1],
+			High:      candle[2],
+			Open:      candle[3],
+			Close:     candle[4],
+			Volume:    candle[5],
+		})
+	}
+
+	return quotes, nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrConfigRequired = errors.New("config value is required")
+	ErrConfigInvalid  = errors.New("config value is invalid")
+)
+
+// ConfigType is the type of a provider configuration value. Values are stored
+// in the subscription as strings and must parse as their type.
+type ConfigType string
+
+const (
+	ConfigString ConfigType = "string"
+	ConfigInt    ConfigType = "int"
+	ConfigFloat  ConfigType = "float"
+	ConfigBool   ConfigType = "bool"
+)
+
+// maskedValue is displayed in place of secret config values
+const maskedValue = "********"
+
+// ConfigField describes a single configuration value of a provider
+type ConfigField struct {
+	Name string
+
+	// Prompt is the question `pvdata subscribe` asks for the value
+	Prompt string
+
+	Type     ConfigType
+	Required bool
+
+	// Default is the value the provider uses when none is configured
+	Default string
+
+	// Secret values, e.g. API keys, are masked when displayed
+	Secret bool
+
+	// Pattern is a regular expression the whole value must match
+	Pattern string
+}
+
+// ConfigSchema lists the configuration values a provider understands
+type ConfigSchema []ConfigField
+
+// Validate checks that value is set if required and parses as the field's type
+func (field ConfigField) Validate(value string) error {
+	if value == "" {
+		if field.Required {
+			return fmt.Errorf("%w: %s", ErrConfigRequired, field.Name)
+		}
+		return nil
+	}
+
+	var err error
+	switch field.Type {
+	case ConfigInt:
+		_, err = strconv.Atoi(value)
+	case ConfigFloat:
+		_, err = strconv.ParseFloat(value, 64)
+	case ConfigBool:
+		_, err = strconv.ParseBool(value)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %s must be %s", ErrConfigInvalid, field.Name, field.Type)
+	}
+
+	if field.Pattern != "" {
+		matched, err := regexp.MatchString(`^(?:`+field.Pattern+`)$`, value)
+		if err != nil {
+			return err
+		}
+
+		if !matched {
+			return fmt.Errorf("%w: %s must match %s", ErrConfigInvalid, field.Name, field.Pattern)
+		}
+	}
+
+	return nil
+}
+
+// Field returns the field with the given name; names are matched case-insensitively
+func (schema ConfigSchema) Field(name string) (ConfigField, bool) {
+	idx := slices.IndexFunc(schema, func(field ConfigField) bool { return strings.EqualFold(field.Name, name) })
+	if idx == -1 {
+		return ConfigField{}, false
+	}
+	return schema[idx], true
+}
+
+// Validate checks every field of the schema against config. Keys that are not
+// part of the schema are ignored as they may configure the subscription
+// itself, e.g. sinks or retention.
+func (schema ConfigSchema) Validate(config map[string]string) error {
+	var errs error
+	for _, field := range schema {
+		errs = errors.Join(errs, field.Validate(config[field.Name]))
+	}
+	return errs
+}
+
+// Mask returns a copy of config with secret values hidden
+func (schema ConfigSchema) Mask(config map[string]string) map[string]string {
+	masked := make(map[string]string, len(config))
+	for key, value := range config {
+		if field, ok := schema.Field(key); ok && field.Secret && value != "" {
+			value = maskedValue
+		}
+		masked[key] = value
+	}
+	return masked
+}
+
+// Descriptions returns the prompt of each field keyed by name
+func (schema ConfigSchema) Descriptions() map[string]string {
+	descriptions := make(map[string]string, len(schema))
+	for _, field := range schema {
+		descriptions[field.Name] = field.Prompt
+	}
+	return descriptions
+}
+
+// DescribedConfig builds a schema of optional string fields from a map of
+// field names to prompts. It lets providers written against the untyped
+// configuration descriptions satisfy the Provider interface unchanged.
+func DescribedConfig(descriptions map[string]string) ConfigSchema {
+	schema := make(ConfigSchema, 0, len(descriptions))
+	for name, prompt := range descriptions {
+		schema = append(schema, ConfigField{Name: name, Prompt: prompt, Type: ConfigString})
+	}
+
+	slices.SortFunc(schema, func(a, b ConfigField) int { return strings.Compare(a.Name, b.Name) })
+	return schema
+}
@@ -0,0 +1,53 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/penny-vault/pvdata/provider"
+)
+
+var _ = Describe("ConfigSchema", func() {
+	schema := provider.ConfigSchema{
+		{Name: "apiKey", Type: provider.ConfigString, Required: true, Secret: true},
+		{Name: "rateLimit", Type: provider.ConfigInt},
+		{Name: "format", Type: provider.ConfigString, Pattern: "csv|parquet"},
+	}
+
+	It("accepts valid configurations", func() {
+		Expect(schema.Validate(map[string]string{"apiKey": "abc", "rateLimit": "60", "format": "csv", "sinks": "postgres"})).To(Succeed())
+	})
+
+	It("requires required fields", func() {
+		Expect(schema.Validate(map[string]string{"rateLimit": "60"})).To(MatchError(provider.ErrConfigRequired))
+	})
+
+	It("checks types and patterns", func() {
+		Expect(schema.Validate(map[string]string{"apiKey": "abc", "rateLimit": "fast"})).To(MatchError(provider.ErrConfigInvalid))
+		Expect(schema.Validate(map[string]string{"apiKey": "abc", "format": "csv.gz"})).To(MatchError(provider.ErrConfigInvalid))
+	})
+
+	It("masks secrets", func() {
+		Expect(schema.Mask(map[string]string{"apikey": "abc", "rateLimit": "60"})).To(Equal(map[string]string{"apikey": "********", "rateLimit": "60"}))
+	})
+
+	It("converts untyped descriptions", func() {
+		described := provider.DescribedConfig(map[string]string{"token": "Enter your token:"})
+		Expect(described).To(Equal(provider.ConfigSchema{{Name: "token", Prompt: "Enter your token:", Type: provider.ConfigString}}))
+		Expect(described.Validate(map[string]string{})).To(Succeed())
+	})
+})
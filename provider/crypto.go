@@ -0,0 +1,124 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog"
+)
+
+const cryptoDefaultMarkets = "BTC-USD,ETH-USD"
+
+// cryptoInterval is the duration of the candles a dataset downloads
+type cryptoInterval struct {
+	Name     string
+	Duration time.Duration
+}
+
+var (
+	cryptoHourly = cryptoInterval{Name: "1h", Duration: time.Hour}
+	cryptoDaily  = cryptoInterval{Name: "1d", Duration: 24 * time.Hour}
+)
+
+// cryptoCandleFunc downloads the most recent candles of market from an exchange
+type cryptoCandleFunc func(ctx context.Context, client *resty.Client, market string, interval cryptoInterval) ([]*data.CryptoQuote, error)
+
+// cryptoMarkets returns the markets configured for the subscription in
+// BASE-QUOTE form, e.g. BTC-USD
+func cryptoMarkets(subscription *library.Subscription) []string {
+	configured := subscription.Config["markets"]
+	if strings.TrimSpace(configured) == "" {
+		configured = cryptoDefaultMarkets
+	}
+
+	markets := make([]string, 0, 4)
+	for _, market := range strings.Split(configured, ",") {
+		market = strings.ToUpper(strings.TrimSpace(strings.ReplaceAll(market, "/", "-")))
+		if market != "" {
+			markets = append(markets, market)
+		}
+	}
+
+	return markets
+}
+
+// downloadCryptoCandles returns a fetch function that downloads candles for each
+// configured market with fetchCandles. Candles that have not closed yet are skipped.
+func downloadCryptoCandles(exchange string, interval cryptoInterval, fetchCandles cryptoCandleFunc) func(context.Context, *library.Subscription, chan<- *data.Observation, chan<- data.RunSummary) {
+	return func(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
+		logger := zerolog.Ctx(ctx)
+
+		runSummary := data.RunSummary{
+			StartTime:        time.Now(),
+			SubscriptionID:   subscription.ID,
+			SubscriptionName: subscription.Name,
+		}
+
+		numObs := 0
+
+		defer func() {
+			runSummary.EndTime = time.Now()
+			runSummary.NumObservations = numObs
+			exitNotification <- runSummary
+		}()
+
+		client := newClient(ctx)
+		now := time.Now()
+
+		for _, market := range cryptoMarkets(subscription) {
+			if err := library.Checkpoint(ctx); err != nil {
+				logger.Info().Err(err).Str("Exchange", exchange).Msg("stopping crypto candle download")
+				runSummary.Status = data.RunCanceled
+				return
+			}
+
+			quotes, err := fetchCandles(ctx, client, market, interval)
+			if err != nil {
+				logger.Error().Err(err).Str("Exchange", exchange).Str("Market", market).Msg("could not download crypto candles")
+				runSummary.Status = data.RunFailed
+				continue
+			}
+
+			for _, quote := range quotes {
+				if quote.EventTime.Add(interval.Duration).After(now) {
+					continue
+				}
+
+				quote.Market = market
+				quote.Exchange = exchange
+				quote.Interval = interval.Name
+
+				out <- &data.Observation{
+					CryptoQuote:      quote,
+					ObservationDate:  time.Now(),
+					SubscriptionID:   subscription.ID,
+					SubscriptionName: subscription.Name,
+				}
+
+				numObs++
+			}
+		}
+
+		if runSummary.Status != data.RunFailed {
+			runSummary.Status = data.RunSuccess
+		}
+	}
+}
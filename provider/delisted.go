@@ -0,0 +1,86 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+)
+
+// delistedDefaultLookback is the number of days after delisting that the final
+// quotes of an asset are fetched for
+const delistedDefaultLookback = 30
+
+// delistedConfig are the configuration values of EOD datasets that can fetch
+// the final quotes of delisted assets
+var delistedConfig = ConfigSchema{
+	{Name: "includeDelisted", Prompt: "Fetch the final quotes of recently delisted assets?", Type: ConfigBool, Default: "false"},
+	{Name: "delistedLookback", Prompt: "For how many days after delisting should missing final quotes be fetched?", Type: ConfigInt, Default: strconv.Itoa(delistedDefaultLookback)},
+}
+
+// eodTarget is an asset EOD quotes are fetched for and the dates to fetch.
+// End is zero for active assets, whose quotes are fetched through today.
+type eodTarget struct {
+	Asset *data.Asset
+	Start data.MarketDate
+	End   data.MarketDate
+}
+
+// eodTargets returns the active assets with quotes fetched from lookbackDays
+// ago. If the subscription's includeDelisted option is set it also returns the
+// assets delisted in the last delistedLookback days whose stored quotes end
+// before their delisting date so that their history is complete through the
+// delisting date. Their quotes are fetched from the day after the last stored
+// quote, or lookbackDays before delisting if none are stored, through the
+// delisting date.
+func eodTargets(ctx context.Context, subscription *library.Subscription, conn *pgxpool.Conn, lookbackDays int) ([]*eodTarget, error) {
+	today := data.Today(data.NYSEExchange)
+	assets := data.ActiveAssets(ctx, conn)
+	targets := make([]*eodTarget, 0, len(assets))
+	for _, asset := range assets {
+		targets = append(targets, &eodTarget{
+			Asset: asset,
+			Start: today.AddDays(-lookbackDays),
+		})
+	}
+
+	if includeDelisted, err := strconv.ParseBool(subscription.Config["includeDelisted"]); err != nil || !includeDelisted {
+		return targets, nil
+	}
+
+	delistedLookback, err := strconv.Atoi(subscription.Config["delistedLookback"])
+	if err != nil || delistedLookback <= 0 {
+		delistedLookback = delistedDefaultLookback
+	}
+
+	delisted, err := data.DelistedAssets(ctx, conn, today.AddDays(-delistedLookback), subscription.DataTablesMap[data.EODKey])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, asset := range delisted {
+		targets = append(targets, &eodTarget{
+			Asset: asset.Asset,
+			Start: asset.QuoteStart(lookbackDays, delistedLookback),
+			End:   asset.Delisted,
+		})
+	}
+
+	return targets, nil
+}
@@ -15,9 +15,18 @@
 package provider
 
 var Map = map[string]Provider{
-	"fred":     &Fred{},
-	"polygon":  &Polygon{},
-	"sharadar": &Sharadar{},
-	"tiingo":   &Tiingo{},
-	"zacks":    &Zacks{},
+	"coinbase":    &Coinbase{},
+	"finnhub":     &Finnhub{},
+	"fred":        &Fred{},
+	"french":      &French{},
+	"iex":         &IEXCloud{},
+	"import":      &Import{},
+	"kraken":      &Kraken{},
+	"maintenance": &Maintenance{},
+	"polygon":     &Polygon{},
+	"resample":    &Resample{},
+	"sharadar":    &Sharadar{},
+	"stooq":       &Stooq{},
+	"tiingo":      &Tiingo{},
+	"zacks":       &Zacks{},
 }
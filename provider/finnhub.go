@@ -0,0 +1,800 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/figi"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog"
+	"golang.org/x/time/rate"
+)
+
+var (
+	ErrFinnhubForbidden = errors.New("endpoint is not included in the finnhub plan")
+)
+
+const finnhubBaseURL = "https://finnhub.io/api/v1"
+
+type Finnhub struct{}
+
+func (finnhub *Finnhub) Name() string {
+	return "Finnhub"
+}
+
+func (finnhub *Finnhub) ConfigSchema() ConfigSchema {
+	return ConfigSchema{
+		{Name: "apiKey", Prompt: "Enter your Finnhub API key:", Type: ConfigString, Required: true, Secret: true},
+		{Name: "rateLimit", Prompt: "What is the maximum number of requests per minute?", Type: ConfigInt, Default: "60"},
+		{Name: "tickers", Prompt: "Limit news, peers, and ETF holdings to these tickers, comma separated (default: all active stocks):", Type: ConfigString},
+	}
+}
+
+func (finnhub *Finnhub) Description() string {
+	return `Finnhub provides real-time and fundamental data for stocks, including earnings and IPO calendars, company news, peers, and ETF holdings, with a generous free tier.`
+}
+
+func (finnhub *Finnhub) Datasets() map[string]Dataset {
+	return map[string]Dataset{
+		"Earnings Calendar": {
+			Name:        "Earnings Calendar",
+			Description: "Upcoming and recently reported quarterly earnings with EPS and revenue estimates.",
+			DataTypes:   []*data.DataType{data.DataTypes[data.EarningsKey]},
+			DependsOn:   []string{data.AssetKey},
+			DateRange: func() (time.Time, time.Time) {
+				return time.Now().AddDate(0, 0, -7).UTC(), time.Now().AddDate(0, 3, 0).UTC()
+			},
+			Capabilities: Capabilities{
+				AssetTypes:  []data.AssetType{data.CommonStock, data.ADRC},
+				Geographies: []string{"US"},
+				Granularity: GranularityEvent,
+				Cost:        Cost{PerRun: 15},
+			},
+			Fetch: downloadFinnhubEarnings,
+		},
+
+		"Company News": {
+			Name:        "Company News",
+			Description: "News articles about active stocks from the last week scored with the company's news sentiment.",
+			DataTypes:   []*data.DataType{data.DataTypes[data.NewsKey]},
+			DependsOn:   []string{data.AssetKey},
+			DateRange: func() (time.Time, time.Time) {
+				return time.Now().AddDate(0, 0, -7).UTC(), time.Now().UTC()
+			},
+			Capabilities: Capabilities{
+				AssetTypes:  []data.AssetType{data.CommonStock, data.ADRC},
+				Geographies: []string{"US"},
+				Granularity: GranularityEvent,
+				Cost:        Cost{PerAsset: 2},
+			},
+			Fetch: downloadFinnhubNews,
+		},
+
+		"ETF Holdings": {
+			Name:        "ETF Holdings",
+			Description: "Constituents of each active ETF with their portfolio weight and number of shares held.",
+			DataTypes:   []*data.DataType{data.DataTypes[data.ETFHoldingKey]},
+			DependsOn:   []string{data.AssetKey},
+			DateRange: func() (time.Time, time.Time) {
+				return time.Now().UTC(), time.Now().UTC()
+			},
+			Capabilities: Capabilities{
+				AssetTypes:  []data.AssetType{data.ETF},
+				Geographies: []string{"US"},
+				Granularity: GranularityDaily,
+				Cost:        Cost{PerAsset: 1},
+			},
+			Fetch: downloadFinnhubETFHoldings,
+		},
+
+		"IPO Calendar": {
+			Name:        "IPO Calendar",
+			Description: "Upcoming and recent IPOs and direct listings; new stocks are added to the default asset table on their first day of trading.",
+			DataTypes:   []*data.DataType{data.DataTypes[data.ListingEventKey]},
+			DependsOn:   []string{data.AssetKey},
+			DateRange: func() (time.Time, time.Time) {
+				return time.Now().AddDate(0, 0, -30).UTC(), time.Now().AddDate(0, 3, 0).UTC()
+			},
+			Capabilities: Capabilities{
+				AssetTypes:  []data.AssetType{data.CommonStock, data.ADRC},
+				Geographies: []string{"US"},
+				Granularity: GranularityEvent,
+				Cost:        Cost{PerRun: 1},
+			},
+			Fetch: downloadFinnhubIPOCalendar,
+		},
+
+		"Peers": {
+			Name:        "Peers",
+			Description: "Companies in the same country and sub-industry as each active stock.",
+			DataTypes:   []*data.DataType{data.DataTypes[data.PeersKey]},
+			DependsOn:   []string{data.AssetKey},
+			DateRange: func() (time.Time, time.Time) {
+				return time.Now().UTC(), time.Now().UTC()
+			},
+			Capabilities: Capabilities{
+				AssetTypes:  []data.AssetType{data.CommonStock, data.ADRC},
+				Geographies: []string{"US"},
+				Granularity: GranularityReference,
+				Cost:        Cost{PerAsset: 1},
+			},
+			Fetch: downloadFinnhubPeers,
+		},
+	}
+}
+
+// Private interface
+
+type finnhubEarningsCalendar struct {
+	EarningsCalendar []*finnhubEarnings `json:"earningsCalendar"`
+}
+
+type finnhubEarnings struct {
+	Date            string   `json:"date"`
+	Symbol          string   `json:"symbol"`
+	Year            int      `json:"year"`
+	Quarter         int      `json:"quarter"`
+	Hour            string   `json:"hour"`
+	EpsEstimate     *float64 `json:"epsEstimate"`
+	EpsActual       *float64 `json:"epsActual"`
+	RevenueEstimate *float64 `json:"revenueEstimate"`
+	RevenueActual   *float64 `json:"revenueActual"`
+}
+
+type finnhubIPOCalendar struct {
+	IPOCalendar []*finnhubIPO `json:"ipoCalendar"`
+}
+
+type finnhubIPO struct {
+	Date           string `json:"date"`
+	Exchange       string `json:"exchange"`
+	Name           string `json:"name"`
+	NumberOfShares int64  `json:"numberOfShares"`
+	Price          string `json:"price"`
+	Status         string `json:"status"`
+	Symbol         string `json:"symbol"`
+}
+
+type finnhubNews struct {
+	ID       int64  `json:"id"`
+	Datetime int64  `json:"datetime"`
+	Category string `json:"category"`
+	Headline string `json:"headline"`
+	Source   string `json:"source"`
+	Summary  string `json:"summary"`
+	URL      string `json:"url"`
+}
+
+type finnhubETFHoldings struct {
+	Symbol   string `json:"symbol"`
+	AtDate   string `json:"atDate"`
+	Holdings []*struct {
+		Symbol  string  `json:"symbol"`
+		Percent float64 `json:"percent"`
+		Share   float64 `json:"share"`
+	} `json:"holdings"`
+}
+
+type finnhubSentiment struct {
+	Symbol    string `json:"symbol"`
+	Sentiment struct {
+		BearishPercent float64 `json:"bearishPercent"`
+		BullishPercent float64 `json:"bullishPercent"`
+	} `json:"sentiment"`
+}
+
+// finnhubClient returns a client authenticated with the subscription's api key
+// and a limiter configured with its rate limit
+func finnhubClient(ctx context.Context, subscription *library.Subscription) (*resty.Client, *rate.Limiter) {
+	rateLimit, err := strconv.Atoi(subscription.Config["rateLimit"])
+	if err != nil || rateLimit <= 0 {
+		rateLimit = 60
+	}
+
+	client := newClient(ctx).
+		SetBaseURL(finnhubBaseURL).
+		SetHeader("X-Finnhub-Token", subscription.Config["apiKey"])
+	return client, rateLimiter(subscription, rateLimit)
+}
+
+// finnhubGet requests path and decodes the response into result
+func finnhubGet(ctx context.Context, client *resty.Client, limiter *rate.Limiter, path string, params map[string]string, result any) error {
+	if err := limiter.Wait(ctx); err != nil {
+		return err
+	}
+
+	resp, err := client.R().SetQueryParams(params).SetResult(result).Get(path)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case resp.StatusCode() == http.StatusForbidden || resp.StatusCode() == http.StatusUnauthorized:
+		return ErrFinnhubForbidden
+	case resp.StatusCode() >= 300:
+		return fmt.Errorf("%w: %d", ErrInvalidStatusCode, resp.StatusCode())
+	}
+
+	return nil
+}
+
+// finnhubAssets returns the active US assets of the given types that data is
+// downloaded for; if the subscription lists tickers only those assets are returned
+func finnhubAssets(ctx context.Context, subscription *library.Subscription, assetTypes ...data.AssetType) ([]*data.Asset, error) {
+	conn, err := subscription.Library.Pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	assets := data.ActiveAssets(ctx, conn)
+
+	var tickers []string
+	for _, ticker := range strings.Split(subscription.Config["tickers"], ",") {
+		if ticker = strings.TrimSpace(ticker); ticker != "" {
+			tickers = append(tickers, strings.ToUpper(ticker))
+		}
+	}
+
+	assets = slices.DeleteFunc(assets, func(asset *data.Asset) bool {
+		if len(tickers) > 0 {
+			return !slices.Contains(tickers, asset.Ticker)
+		}
+
+		return !slices.Contains(assetTypes, asset.AssetType) ||
+			asset.PrimaryExchange.IsOTC() || asset.PrimaryExchange.Info().Timezone != "America/New_York"
+	})
+
+	return assets, nil
+}
+
+func downloadFinnhubEarnings(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
+	logger := zerolog.Ctx(ctx)
+
+	runSummary := data.RunSummary{
+		StartTime:        time.Now(),
+		SubscriptionID:   subscription.ID,
+		SubscriptionName: subscription.Name,
+	}
+
+	numObs := 0
+
+	defer func() {
+		runSummary.EndTime = time.Now()
+		runSummary.NumObservations = numObs
+		exitNotification <- runSummary
+	}()
+
+	client, limiter := finnhubClient(ctx, subscription)
+
+	conn, err := subscription.Library.Pool.Acquire(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("could not acquire database connection")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	assets := make(map[string]*data.Asset)
+	for _, asset := range data.ActiveAssets(ctx, conn) {
+		assets[data.DenormalizeTicker("finnhub", asset.Ticker)] = asset
+	}
+
+	conn.Release()
+
+	// request the calendar a week at a time to stay within the per-request limit
+	// on the number of announcements
+	today := data.Today(data.NYSEExchange)
+	from := today.AddDays(-7)
+	end := today.AddMonths(3)
+
+	for ; from.Before(end); from = from.AddDays(7) {
+		if err := library.Checkpoint(ctx); err != nil {
+			logger.Info().Err(err).Msg("stopping finnhub earnings download")
+			runSummary.Status = data.RunCanceled
+			return
+		}
+
+		var calendar finnhubEarningsCalendar
+		if err := finnhubGet(ctx, client, limiter, "/calendar/earnings", map[string]string{
+			"from": from.String(),
+			"to":   from.AddDays(6).String(),
+		}, &calendar); err != nil {
+			logger.Error().Err(err).Msg("could not download earnings calendar from finnhub")
+			runSummary.Status = data.RunFailed
+			return
+		}
+
+		for _, announcement := range calendar.EarningsCalendar {
+			asset, ok := assets[announcement.Symbol]
+			if !ok {
+				continue
+			}
+
+			eventDate, err := data.ParseMarketDate(announcement.Date)
+			if err != nil {
+				logger.Error().Err(err).Str("finnhubDate", announcement.Date).Msg("could not parse date from finnhub earnings")
+				continue
+			}
+
+			out <- &data.Observation{
+				Earnings: &data.Earnings{
+					Ticker:          asset.Ticker,
+					CompositeFigi:   asset.CompositeFigi,
+					EventDate:       eventDate.Time(),
+					FiscalYear:      announcement.Year,
+					FiscalQuarter:   announcement.Quarter,
+					Hour:            announcement.Hour,
+					EpsEstimate:     announcement.EpsEstimate,
+					EpsActual:       announcement.EpsActual,
+					RevenueEstimate: announcement.RevenueEstimate,
+					RevenueActual:   announcement.RevenueActual,
+				},
+				ObservationDate:  time.Now(),
+				SubscriptionID:   subscription.ID,
+				SubscriptionName: subscription.Name,
+			}
+
+			numObs++
+		}
+	}
+
+	runSummary.Status = data.RunSuccess
+}
+
+func downloadFinnhubNews(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
+	logger := zerolog.Ctx(ctx)
+
+	runSummary := data.RunSummary{
+		StartTime:        time.Now(),
+		SubscriptionID:   subscription.ID,
+		SubscriptionName: subscription.Name,
+	}
+
+	numObs := 0
+
+	defer func() {
+		runSummary.EndTime = time.Now()
+		runSummary.NumObservations = numObs
+		exitNotification <- runSummary
+	}()
+
+	client, limiter := finnhubClient(ctx, subscription)
+
+	assets, err := finnhubAssets(ctx, subscription, data.CommonStock, data.ADRC)
+	if err != nil {
+		logger.Error().Err(err).Msg("could not get list of assets")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	// news sentiment is only available on paid plans; it is skipped once finnhub
+	// reports the endpoint is not included in the subscription's plan
+	scoreSentiment := true
+
+	today := data.Today(data.NYSEExchange)
+	from := today.AddDays(-7).String()
+	to := today.String()
+
+	for _, asset := range assets {
+		if err := library.Checkpoint(ctx); err != nil {
+			logger.Info().Err(err).Msg("stopping finnhub news download")
+			runSummary.Status = data.RunCanceled
+			return
+		}
+
+		symbol := data.DenormalizeTicker("finnhub", asset.Ticker)
+
+		articles := make([]*finnhubNews, 0)
+		if err := finnhubGet(ctx, client, limiter, "/company-news", map[string]string{
+			"symbol": symbol,
+			"from":   from,
+			"to":     to,
+		}, &articles); err != nil {
+			if ctx.Err() != nil {
+				runSummary.Status = data.RunCanceled
+				return
+			}
+
+			logger.Error().Err(err).Str("Symbol", symbol).Msg("could not download company news from finnhub")
+			continue
+		}
+
+		if len(articles) == 0 {
+			continue
+		}
+
+		var sentiment *float64
+		if scoreSentiment {
+			var score finnhubSentiment
+			err := finnhubGet(ctx, client, limiter, "/news-sentiment", map[string]string{"symbol": symbol}, &score)
+			switch {
+			case errors.Is(err, ErrFinnhubForbidden):
+				logger.Info().Msg("finnhub plan does not include news sentiment; news will not be scored")
+				scoreSentiment = false
+			case err != nil:
+				logger.Warn().Err(err).Str("Symbol", symbol).Msg("could not download news sentiment from finnhub")
+			case score.Symbol != "":
+				val := score.Sentiment.BullishPercent - score.Sentiment.BearishPercent
+				sentiment = &val
+			}
+		}
+
+		for _, article := range articles {
+			out <- &data.Observation{
+				News: &data.News{
+					Ticker:        asset.Ticker,
+					CompositeFigi: asset.CompositeFigi,
+					PublishedAt:   time.Unix(article.Datetime, 0),
+					NewsID:        strconv.FormatInt(article.ID, 10),
+					Source:        article.Source,
+					Category:      article.Category,
+					Headline:      article.Headline,
+					Summary:       article.Summary,
+					URL:           article.URL,
+					Sentiment:     sentiment,
+				},
+				ObservationDate:  time.Now(),
+				SubscriptionID:   subscription.ID,
+				SubscriptionName: subscription.Name,
+			}
+
+			numObs++
+		}
+	}
+
+	runSummary.Status = data.RunSuccess
+}
+
+func downloadFinnhubPeers(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
+	logger := zerolog.Ctx(ctx)
+
+	runSummary := data.RunSummary{
+		StartTime:        time.Now(),
+		SubscriptionID:   subscription.ID,
+		SubscriptionName: subscription.Name,
+	}
+
+	numObs := 0
+
+	defer func() {
+		runSummary.EndTime = time.Now()
+		runSummary.NumObservations = numObs
+		exitNotification <- runSummary
+	}()
+
+	client, limiter := finnhubClient(ctx, subscription)
+
+	assets, err := finnhubAssets(ctx, subscription, data.CommonStock, data.ADRC)
+	if err != nil {
+		logger.Error().Err(err).Msg("could not get list of assets")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	eventDate := data.Today(data.NYSEExchange).Time()
+
+	for _, asset := range assets {
+		if err := library.Checkpoint(ctx); err != nil {
+			logger.Info().Err(err).Msg("stopping finnhub peers download")
+			runSummary.Status = data.RunCanceled
+			return
+		}
+
+		symbol := data.DenormalizeTicker("finnhub", asset.Ticker)
+
+		peers := make([]string, 0)
+		if err := finnhubGet(ctx, client, limiter, "/stock/peer", map[string]string{"symbol": symbol}, &peers); err != nil {
+			if ctx.Err() != nil {
+				runSummary.Status = data.RunCanceled
+				return
+			}
+
+			logger.Error().Err(err).Str("Symbol", symbol).Msg("could not download peers from finnhub")
+			continue
+		}
+
+		// finnhub includes the company in its list of peers
+		peers = slices.DeleteFunc(peers, func(peer string) bool {
+			return peer == symbol
+		})
+
+		if len(peers) == 0 {
+			continue
+		}
+
+		out <- &data.Observation{
+			Peers: &data.Peers{
+				Ticker:        asset.Ticker,
+				CompositeFigi: asset.CompositeFigi,
+				EventDate:     eventDate,
+				Peers:         peers,
+			},
+			ObservationDate:  time.Now(),
+			SubscriptionID:   subscription.ID,
+			SubscriptionName: subscription.Name,
+		}
+
+		numObs++
+	}
+
+	runSummary.Status = data.RunSuccess
+}
+
+func downloadFinnhubETFHoldings(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
+	logger := zerolog.Ctx(ctx)
+
+	runSummary := data.RunSummary{
+		StartTime:        time.Now(),
+		SubscriptionID:   subscription.ID,
+		SubscriptionName: subscription.Name,
+	}
+
+	numObs := 0
+
+	defer func() {
+		runSummary.EndTime = time.Now()
+		runSummary.NumObservations = numObs
+		exitNotification <- runSummary
+	}()
+
+	client, limiter := finnhubClient(ctx, subscription)
+
+	etfs, err := finnhubAssets(ctx, subscription, data.ETF)
+	if err != nil {
+		logger.Error().Err(err).Msg("could not get list of assets")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	// constituents are matched to assets in the library by ticker; holdings
+	// that are not in the library, such as cash and bonds, are skipped
+	conn, err := subscription.Library.Pool.Acquire(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("could not acquire database connection")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	constituents := make(map[string]*data.Asset)
+	for _, asset := range data.ActiveAssets(ctx, conn) {
+		constituents[data.DenormalizeTicker("finnhub", asset.Ticker)] = asset
+	}
+
+	conn.Release()
+
+	for _, etf := range etfs {
+		if err := library.Checkpoint(ctx); err != nil {
+			logger.Info().Err(err).Msg("stopping finnhub etf holdings download")
+			runSummary.Status = data.RunCanceled
+			return
+		}
+
+		symbol := data.DenormalizeTicker("finnhub", etf.Ticker)
+
+		var holdings finnhubETFHoldings
+		err := finnhubGet(ctx, client, limiter, "/etf/holdings", map[string]string{"symbol": symbol}, &holdings)
+		switch {
+		case ctx.Err() != nil:
+			runSummary.Status = data.RunCanceled
+			return
+		case errors.Is(err, ErrFinnhubForbidden):
+			logger.Error().Err(err).Msg("finnhub plan does not include etf holdings")
+			runSummary.Status = data.RunFailed
+			return
+		case err != nil:
+			logger.Error().Err(err).Str("Symbol", symbol).Msg("could not download etf holdings from finnhub")
+			continue
+		}
+
+		eventDate, err := time.Parse("2006-01-02", holdings.AtDate)
+		if err != nil {
+			logger.Error().Err(err).Str("Symbol", symbol).Str("finnhubDate", holdings.AtDate).Msg("could not parse date from finnhub etf holdings")
+			continue
+		}
+
+		skipped := 0
+		for _, holding := range holdings.Holdings {
+			constituent, ok := constituents[holding.Symbol]
+			if !ok {
+				skipped++
+				continue
+			}
+
+			out <- &data.Observation{
+				ETFHolding: &data.ETFHolding{
+					Ticker:            etf.Ticker,
+					CompositeFigi:     etf.CompositeFigi,
+					EventDate:         eventDate,
+					ConstituentTicker: constituent.Ticker,
+					ConstituentFigi:   constituent.CompositeFigi,
+					Weight:            holding.Percent / 100,
+					Shares:            holding.Share,
+				},
+				ObservationDate:  time.Now(),
+				SubscriptionID:   subscription.ID,
+				SubscriptionName: subscription.Name,
+			}
+
+			numObs++
+		}
+
+		if skipped > 0 {
+			logger.Debug().Str("Symbol", symbol).Int("NumSkipped", skipped).Msg("skipped etf holdings that are not in the library")
+		}
+	}
+
+	runSummary.Status = data.RunSuccess
+}
+
+func downloadFinnhubIPOCalendar(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
+	logger := zerolog.Ctx(ctx)
+
+	runSummary := data.RunSummary{
+		StartTime:        time.Now(),
+		SubscriptionID:   subscription.ID,
+		SubscriptionName: subscription.Name,
+	}
+
+	numObs := 0
+
+	defer func() {
+		runSummary.EndTime = time.Now()
+		runSummary.NumObservations = numObs
+		exitNotification <- runSummary
+	}()
+
+	client, limiter := finnhubClient(ctx, subscription)
+
+	conn, err := subscription.Library.Pool.Acquire(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("could not acquire database connection")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	listed := make(map[string]bool)
+	for _, asset := range data.ActiveAssets(ctx, conn) {
+		listed[asset.Ticker] = true
+	}
+
+	conn.Release()
+
+	today := data.Today(data.NYSEExchange)
+
+	var calendar finnhubIPOCalendar
+	if err := finnhubGet(ctx, client, limiter, "/calendar/ipo", map[string]string{
+		"from": today.AddDays(-30).String(),
+		"to":   today.AddMonths(3).String(),
+	}, &calendar); err != nil {
+		logger.Error().Err(err).Msg("could not download ipo calendar from finnhub")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	for _, ipo := range calendar.IPOCalendar {
+		if ipo.Symbol == "" {
+			continue
+		}
+
+		eventDate, err := data.ParseMarketDate(ipo.Date)
+		if err != nil {
+			logger.Error().Err(err).Str("finnhubDate", ipo.Date).Msg("could not parse date from finnhub ipo calendar")
+			continue
+		}
+
+		ticker := data.NormalizeTicker("finnhub", ipo.Symbol)
+		priceLow, priceHigh := finnhubPriceRange(ipo.Price)
+
+		out <- &data.Observation{
+			ListingEvent: &data.ListingEvent{
+				Ticker:    ticker,
+				Name:      ipo.Name,
+				EventDate: eventDate.Time(),
+				Exchange:  ipo.Exchange,
+				PriceLow:  priceLow,
+				PriceHigh: priceHigh,
+				NumShares: ipo.NumberOfShares,
+				Status:    ipo.Status,
+			},
+			ObservationDate:  time.Now(),
+			SubscriptionID:   subscription.ID,
+			SubscriptionName: subscription.Name,
+		}
+
+		numObs++
+
+		// add stocks to the asset table on their first day of trading rather
+		// than waiting for the next sync of the asset provider
+		if eventDate != today || ipo.Status != data.ListingPriced || listed[ticker] {
+			continue
+		}
+
+		asset := &data.Asset{
+			Ticker:          ticker,
+			Name:            ipo.Name,
+			PrimaryExchange: finnhubExchange(ipo.Exchange),
+			AssetType:       data.UnknownAsset,
+			Active:          true,
+			ListingDate:     ipo.Date,
+			LastUpdated:     time.Now(),
+		}
+
+		figi.Enrich(ctx, asset)
+		if asset.CompositeFigi == "" {
+			logger.Warn().Str("Ticker", ticker).Msg("could not find composite figi of newly listed stock")
+			continue
+		}
+
+		out <- &data.Observation{
+			AssetObject:      asset,
+			ObservationDate:  time.Now(),
+			SubscriptionID:   subscription.ID,
+			SubscriptionName: subscription.Name,
+		}
+
+		logger.Info().Str("Ticker", ticker).Str("CompositeFigi", asset.CompositeFigi).Msg("added newly listed stock")
+	}
+
+	runSummary.Status = data.RunSuccess
+}
+
+// finnhubPriceRange parses the offer price of an IPO, e.g. 10.00-12.00; a
+// single price is returned as both the low and the high of the range
+func finnhubPriceRange(price string) (*float64, *float64) {
+	parts := strings.SplitN(price, "-", 2)
+
+	low, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return nil, nil
+	}
+
+	high := low
+	if len(parts) == 2 {
+		if high, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64); err != nil {
+			high = low
+		}
+	}
+
+	return &low, &high
+}
+
+// finnhubExchange converts the exchange names used in finnhub's ipo calendar,
+// e.g. NASDAQ Global, to an exchange
+func finnhubExchange(name string) data.Exchange {
+	name = strings.ToUpper(name)
+
+	switch {
+	case strings.HasPrefix(name, "NASDAQ"):
+		return data.NasdaqExchange
+	case strings.Contains(name, "AMERICAN") || strings.Contains(name, "MKT"):
+		return data.NYSEMktExchange
+	case strings.Contains(name, "ARCA"):
+		return data.ARCAExchange
+	case strings.HasPrefix(name, "NYSE"):
+		return data.NYSEExchange
+	default:
+		return data.UnknownExchange
+	}
+}
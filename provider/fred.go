@@ -20,7 +20,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/penny-vault/pvdata/data"
 	"github.com/penny-vault/pvdata/library"
 	"github.com/rs/zerolog"
@@ -34,10 +33,10 @@ func (fred *Fred) Name() string {
 	return "FRED"
 }
 
-func (fred *Fred) ConfigDescription() map[string]string {
-	return map[string]string{
-		"seriesIds": "Enter all series to retrieve from FRED (e.g. UNRATE, DTB3):",
-		"apiKey":    "What is your FRED api key?",
+func (fred *Fred) ConfigSchema() ConfigSchema {
+	return ConfigSchema{
+		{Name: "seriesIds", Prompt: "Enter all series to retrieve from FRED (e.g. UNRATE, DTB3):", Type: ConfigString, Required: true},
+		{Name: "apiKey", Prompt: "What is your FRED api key?", Type: ConfigString, Required: true, Secret: true},
 	}
 }
 
@@ -54,6 +53,12 @@ func (fred *Fred) Datasets() map[string]Dataset {
 			DateRange: func() (time.Time, time.Time) {
 				return time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC), time.Now().UTC()
 			},
+			Capabilities: Capabilities{
+				Geographies: []string{"US"},
+				Granularity: GranularityMonthly,
+				Backfill:    true,
+				Cost:        Cost{PerRun: 10},
+			},
 			Fetch: downloadAllFredIndicators,
 		},
 	}
@@ -76,6 +81,12 @@ func downloadAllFredIndicators(ctx context.Context, subscription *library.Subscr
 
 	seriesIds := strings.Split(subscription.Config["seriesIds"], ",")
 	for _, seriesId := range seriesIds {
+		if err := library.Checkpoint(ctx); err != nil {
+			zerolog.Ctx(ctx).Info().Err(err).Msg("stopping FRED download")
+			runSummary.Status = data.RunCanceled
+			return
+		}
+
 		seriesId = strings.TrimSpace(seriesId)
 		downloadIndicator(ctx, subscription, out, seriesId)
 	}
@@ -93,7 +104,7 @@ func downloadIndicator(ctx context.Context, subscription *library.Subscription,
 
 	var resp fredResponse
 
-	client := resty.New().SetQueryParam("api_key", subscription.Config["apiKey"])
+	client := newClient(ctx).SetQueryParam("api_key", subscription.Config["apiKey"])
 	req, err := client.R().
 		SetQueryParam("file_type", "json").
 		SetQueryParam("series_id", seriesId).
@@ -0,0 +1,250 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog"
+)
+
+var (
+	ErrEmptyArchive = errors.New("archive does not contain any files")
+)
+
+const (
+	frenchBaseURL      = "https://mba.tuck.dartmouth.edu/pages/faculty/ken.french/ftp/"
+	frenchDefaultFiles = "F-F_Research_Data_Factors_daily,F-F_Momentum_Factor_daily,F-F_Research_Data_5_Factors_2x3_daily"
+
+	// frenchMissing marks missing values in the data library
+	frenchMissing = -99.99
+)
+
+// French imports factor returns from the Kenneth R. French data library. Each
+// factor is saved as an economic indicator named `<file>/<factor>`, e.g.
+// F-F_Research_Data_Factors_daily/SMB, with returns expressed as a decimal.
+type French struct{}
+
+func (french *French) Name() string {
+	return "Kenneth French Data Library"
+}
+
+func (french *French) ConfigSchema() ConfigSchema {
+	return ConfigSchema{
+		{Name: "files", Prompt: "Enter the data library files to retrieve without the _CSV.zip suffix:", Type: ConfigString, Default: frenchDefaultFiles},
+		{Name: "lookback", Prompt: "How many days of history should be saved each run? (default: all)", Type: ConfigInt},
+	}
+}
+
+func (french *French) Description() string {
+	return `The Kenneth R. French data library publishes the Fama/French factors, momentum, and portfolio returns.`
+}
+
+func (french *French) Datasets() map[string]Dataset {
+	return map[string]Dataset{
+		"Factors": {
+			Name:        "Factors",
+			Description: "Download Fama/French factor returns.",
+			DataTypes:   []*data.DataType{data.DataTypes[data.EconomicIndicatorKey]},
+			DateRange: func() (time.Time, time.Time) {
+				return time.Date(1926, 7, 1, 0, 0, 0, 0, time.UTC), time.Now().UTC()
+			},
+			Capabilities: Capabilities{
+				Geographies: []string{"US"},
+				Granularity: GranularityDaily,
+				Backfill:    true,
+				Cost:        Cost{PerRun: 3},
+			},
+			Fetch: downloadFrenchFactors,
+		},
+	}
+}
+
+func downloadFrenchFactors(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
+	logger := zerolog.Ctx(ctx)
+
+	runSummary := data.RunSummary{
+		StartTime:        time.Now(),
+		SubscriptionID:   subscription.ID,
+		SubscriptionName: subscription.Name,
+	}
+
+	numObs := 0
+
+	defer func() {
+		runSummary.EndTime = time.Now()
+		runSummary.NumObservations = numObs
+		exitNotification <- runSummary
+	}()
+
+	files := subscription.Config["files"]
+	if strings.TrimSpace(files) == "" {
+		files = frenchDefaultFiles
+	}
+
+	var since time.Time
+	if lookback, err := strconv.Atoi(subscription.Config["lookback"]); err == nil && lookback > 0 {
+		since = time.Now().AddDate(0, 0, -lookback)
+	}
+
+	client := newClient(ctx)
+	runSummary.Status = data.RunSuccess
+
+	for _, file := range strings.Split(files, ",") {
+		if err := library.Checkpoint(ctx); err != nil {
+			logger.Info().Err(err).Msg("stopping French data library download")
+			runSummary.Status = data.RunCanceled
+			return
+		}
+
+		file = strings.TrimSpace(file)
+
+		resp, err := client.R().Get(fmt.Sprintf("%s%s_CSV.zip", frenchBaseURL, file))
+		if err != nil {
+			logger.Error().Err(err).Str("File", file).Msg("downloading factor returns failed")
+			runSummary.Status = data.RunFailed
+			return
+		}
+
+		if resp.StatusCode() >= 300 {
+			logger.Error().Int("StatusCode", resp.StatusCode()).Str("File", file).Msg("downloading factor returns returned error status code")
+			runSummary.Status = data.RunFailed
+			continue
+		}
+
+		indicators, err := parseFrenchArchive(resp.Body(), file)
+		if err != nil {
+			logger.Error().Err(err).Str("File", file).Msg("could not parse factor returns")
+			runSummary.Status = data.RunFailed
+			continue
+		}
+
+		for _, indicator := range indicators {
+			if indicator.EventDate.Before(since) {
+				continue
+			}
+
+			out <- &data.Observation{
+				EconomicIndicator: indicator,
+				ObservationDate:   time.Now(),
+				SubscriptionID:    subscription.ID,
+				SubscriptionName:  subscription.Name,
+			}
+
+			numObs++
+		}
+	}
+}
+
+// parseFrenchArchive reads the CSV file contained in a zip archive downloaded
+// from the data library
+func parseFrenchArchive(archive []byte, file string) ([]*data.EconomicIndicator, error) {
+	zipReader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(zipReader.File) == 0 {
+		return nil, ErrEmptyArchive
+	}
+
+	fh, err := zipReader.File[0].Open()
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	return parseFrenchCSV(fh, file)
+}
+
+// parseFrenchCSV parses the first table in a data library CSV file. Files begin
+// with a description followed by a header row whose first column is empty and
+// rows of returns in percent keyed by YYYYMMDD (daily) or YYYYMM (monthly).
+// Later tables, e.g. annual returns, are ignored.
+func parseFrenchCSV(r io.Reader, file string) ([]*data.EconomicIndicator, error) {
+	nyc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		header     []string
+		indicators []*data.EconomicIndicator
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ",")
+		for idx := range fields {
+			fields[idx] = strings.TrimSpace(fields[idx])
+		}
+
+		if header == nil {
+			if len(fields) > 1 && fields[0] == "" {
+				header = fields[1:]
+			}
+			continue
+		}
+
+		var eventDate time.Time
+		switch len(fields[0]) {
+		case 8:
+			eventDate, err = time.ParseInLocation("20060102", fields[0], nyc)
+		case 6:
+			eventDate, err = time.ParseInLocation("200601", fields[0], nyc)
+			eventDate = eventDate.AddDate(0, 1, -1)
+		default:
+			err = strconv.ErrSyntax
+		}
+
+		if err != nil {
+			if len(indicators) > 0 {
+				// end of the first table
+				break
+			}
+			continue
+		}
+
+		for idx, column := range header {
+			if idx+1 >= len(fields) {
+				break
+			}
+
+			val, err := strconv.ParseFloat(fields[idx+1], 64)
+			if err != nil || val == frenchMissing {
+				continue
+			}
+
+			indicators = append(indicators, &data.EconomicIndicator{
+				Series:    fmt.Sprintf("%s/%s", file, column),
+				EventDate: eventDate,
+				Value:     val / 100,
+			})
+		}
+	}
+
+	return indicators, scanner.Err()
+}
@@ -0,0 +1,78 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/penny-vault/pvdata/httpclient"
+	"github.com/penny-vault/pvdata/library"
+	"golang.org/x/time/rate"
+)
+
+// rateLimiters holds the request rate limiter of each provider account;
+// subscriptions share it so that running them concurrently does not multiply
+// the provider's rate limit
+var (
+	rateLimitersMu sync.Mutex
+	rateLimiters   = make(map[string]*rate.Limiter)
+)
+
+// rateLimiter returns the limiter shared by every subscription of the provider
+// that uses the subscription's API key. A subscription configured with a lower
+// rate limit lowers it for all of them.
+func rateLimiter(subscription *library.Subscription, requestsPerMinute int) *rate.Limiter {
+	limit := rate.Limit(float64(requestsPerMinute) / float64(61))
+	key := subscription.Provider + "\x00" + subscription.Config["apiKey"]
+
+	rateLimitersMu.Lock()
+	defer rateLimitersMu.Unlock()
+
+	limiter, ok := rateLimiters[key]
+	if !ok {
+		limiter = rate.NewLimiter(limit, 1)
+		rateLimiters[key] = limiter
+	} else if limit < limiter.Limit() {
+		limiter.SetLimit(limit)
+	}
+
+	return limiter
+}
+
+// newClient returns a resty client configured with any limits and snapshot set
+// on the context
+func newClient(ctx context.Context) *resty.Client {
+	client := resty.New()
+
+	var transport http.RoundTripper = http.DefaultTransport
+
+	if snapshot, ok := ctx.Value(snapshotKey{}).(*Snapshot); ok && snapshot != nil {
+		transport = &snapshotTransport{
+			snapshot: snapshot,
+			next:     transport,
+		}
+	}
+
+	transport = httpclient.Transport(ctx, transport)
+
+	if transport != http.DefaultTransport {
+		client.SetTransport(transport)
+	}
+
+	return client
+}
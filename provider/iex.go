@@ -0,0 +1,449 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/figi"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog"
+	"golang.org/x/time/rate"
+)
+
+var (
+	ErrMessageBudgetExhausted = errors.New("iex cloud message budget exhausted")
+)
+
+// IEX Cloud charges requests by message weight rather than by call. The
+// weights below are used to estimate the cost of a request before it is made;
+// the actual cost reported by IEX Cloud is recorded afterwards.
+const (
+	iexRefDataWeight  = 100
+	iexChartDayWeight = 10
+	iexDividendWeight = 10
+
+	// iexChartDays is the number of trading days of quotes requested per asset
+	iexChartDays = 5
+)
+
+var iexExchangeMap = map[string]data.Exchange{
+	"NAS":  data.NasdaqExchange,
+	"NYS":  data.NYSEExchange,
+	"PSE":  data.ARCAExchange,
+	"ASE":  data.NYSEMktExchange,
+	"BATS": data.BATSExchange,
+}
+
+var iexAssetTypeMap = map[string]data.AssetType{
+	"cs":  data.CommonStock,
+	"ad":  data.ADRC,
+	"et":  data.ETF,
+	"cef": data.CEF,
+	"oef": data.MutualFund,
+}
+
+type IEXCloud struct{}
+
+func (iex *IEXCloud) Name() string {
+	return "IEX Cloud"
+}
+
+func (iex *IEXCloud) ConfigSchema() ConfigSchema {
+	return ConfigSchema{
+		{Name: "apiKey", Prompt: "Enter your IEX Cloud secret token:", Type: ConfigString, Required: true, Secret: true},
+		{Name: "rateLimit", Prompt: "What is the maximum number of requests per minute?", Type: ConfigInt, Default: "6000"},
+		{Name: "messageBudget", Prompt: "What is the maximum number of messages a single run may use? (0 for no limit)", Type: ConfigInt, Default: "0"},
+		{Name: "sandbox", Prompt: "Use the IEX Cloud sandbox environment?", Type: ConfigBool, Default: "false"},
+	}
+}
+
+func (iex *IEXCloud) Description() string {
+	return `IEX Cloud provides reference data, historical prices, and corporate actions for US securities. Requests are billed in messages weighted by the amount of data returned.`
+}
+
+func (iex *IEXCloud) Datasets() map[string]Dataset {
+	return map[string]Dataset{
+		"EOD": {
+			Name:        "EOD",
+			Description: "Get end-of-day stock prices and dividends for active assets.",
+			DataTypes:   []*data.DataType{data.DataTypes[data.EODKey]},
+			DependsOn:   []string{data.AssetKey},
+			DateRange: func() (time.Time, time.Time) {
+				return time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC), time.Now().UTC()
+			},
+			Capabilities: Capabilities{
+				AssetTypes:  []data.AssetType{data.CommonStock, data.ADRC, data.ETF, data.CEF, data.MutualFund},
+				Geographies: []string{"US"},
+				Granularity: GranularityDaily,
+				Cost:        Cost{PerAsset: 2},
+			},
+			Fetch: downloadIEXEODQuotes,
+		},
+
+		"Stock Tickers": {
+			Name:        "Stock Tickers",
+			Description: "Reference data for stocks, ADRs, ETFs, and funds listed on US exchanges.",
+			DataTypes:   []*data.DataType{data.DataTypes[data.AssetKey]},
+			DateRange: func() (time.Time, time.Time) {
+				return time.Now().UTC(), time.Now().UTC()
+			},
+			Capabilities: Capabilities{
+				AssetTypes:      []data.AssetType{data.CommonStock, data.ADRC, data.ETF, data.CEF, data.MutualFund},
+				Geographies:     []string{"US"},
+				Granularity:     GranularityReference,
+				RefreshInterval: 24 * time.Hour,
+				Cost:            Cost{PerRun: 2},
+			},
+			Fetch: downloadIEXAssets,
+		},
+	}
+}
+
+// Private interface
+
+type iexSymbol struct {
+	Symbol    string `json:"symbol"`
+	Exchange  string `json:"exchange"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Region    string `json:"region"`
+	Currency  string `json:"currency"`
+	IsEnabled bool   `json:"isEnabled"`
+	CIK       string `json:"cik"`
+}
+
+type iexChart struct {
+	Date   string  `json:"date"`
+	Open   float64 `json:"open"`
+	High   float64 `json:"high"`
+	Low    float64 `json:"low"`
+	Close  float64 `json:"close"`
+	Volume float64 `json:"volume"`
+}
+
+type iexDividend struct {
+	ExDate   string  `json:"exDate"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+// iexBudget tracks the messages used by a run against the subscription's budget
+type iexBudget struct {
+	mu    sync.Mutex
+	limit int64
+	used  int64
+}
+
+func newIEXBudget(subscription *library.Subscription) *iexBudget {
+	limit, err := strconv.ParseInt(subscription.Config["messageBudget"], 10, 64)
+	if err != nil || limit < 0 {
+		limit = 0
+	}
+
+	return &iexBudget{limit: limit}
+}
+
+// Reserve returns ErrMessageBudgetExhausted if a request estimated to cost
+// weight messages would exceed the budget
+func (budget *iexBudget) Reserve(weight int64) error {
+	budget.mu.Lock()
+	defer budget.mu.Unlock()
+
+	if budget.limit > 0 && budget.used+weight > budget.limit {
+		return fmt.Errorf("%w: used %d of %d messages", ErrMessageBudgetExhausted, budget.used, budget.limit)
+	}
+
+	return nil
+}
+
+// Record adds the messages a request used to the total; IEX Cloud reports the
+// cost of each request in the iexcloud-messages-used header. If the header is
+// missing the estimated weight is used.
+func (budget *iexBudget) Record(resp *resty.Response, weight int64) {
+	if used, err := strconv.ParseInt(resp.Header().Get("iexcloud-messages-used"), 10, 64); err == nil {
+		weight = used
+	}
+
+	budget.mu.Lock()
+	defer budget.mu.Unlock()
+	budget.used += weight
+}
+
+// Used returns the number of messages used by the run
+func (budget *iexBudget) Used() int64 {
+	budget.mu.Lock()
+	defer budget.mu.Unlock()
+	return budget.used
+}
+
+// iexClient returns a client authenticated with the subscription's token and a
+// limiter configured with its rate limit
+func iexClient(ctx context.Context, subscription *library.Subscription) (*resty.Client, *rate.Limiter) {
+	rateLimit, err := strconv.Atoi(subscription.Config["rateLimit"])
+	if err != nil || rateLimit <= 0 {
+		rateLimit = 6000
+	}
+
+	baseURL := "https://cloud.iexapis.com/stable"
+	if sandbox, _ := strconv.ParseBool(subscription.Config["sandbox"]); sandbox {
+		baseURL = "https://sandbox.iexapis.com/stable"
+	}
+
+	client := newClient(ctx).
+		SetBaseURL(baseURL).
+		SetQueryParam("token", subscription.Config["apiKey"])
+	return client, rateLimiter(subscription, rateLimit)
+}
+
+// iexGet requests path if the budget allows for weight messages and decodes the
+// response into result
+func iexGet(ctx context.Context, client *resty.Client, limiter *rate.Limiter, budget *iexBudget, weight int64, path string, params map[string]string, result any) error {
+	if err := budget.Reserve(weight); err != nil {
+		return err
+	}
+
+	if err := limiter.Wait(ctx); err != nil {
+		return err
+	}
+
+	resp, err := client.R().SetQueryParams(params).SetResult(result).Get(path)
+	if err != nil {
+		return err
+	}
+
+	budget.Record(resp, weight)
+
+	if resp.StatusCode() >= 300 {
+		return fmt.Errorf("%w: %d", ErrInvalidStatusCode, resp.StatusCode())
+	}
+
+	return nil
+}
+
+func downloadIEXEODQuotes(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
+	logger := zerolog.Ctx(ctx)
+
+	runSummary := data.RunSummary{
+		StartTime:        time.Now(),
+		SubscriptionID:   subscription.ID,
+		SubscriptionName: subscription.Name,
+	}
+
+	numObs := 0
+	budget := newIEXBudget(subscription)
+
+	defer func() {
+		runSummary.EndTime = time.Now()
+		runSummary.NumObservations = numObs
+		logger.Info().Int64("MessagesUsed", budget.Used()).Msg("iex cloud messages used")
+		exitNotification <- runSummary
+	}()
+
+	client, limiter := iexClient(ctx, subscription)
+
+	conn, err := subscription.Library.Pool.Acquire(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("could not acquire database connection")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	assets := data.ActiveAssets(ctx, conn)
+	conn.Release()
+
+	logger.Debug().Int("NumAssets", len(assets)).Msg("downloading EOD quotes from IEX Cloud")
+
+	for _, asset := range assets {
+		if asset.PrimaryExchange.IsOTC() || asset.PrimaryExchange.Info().Timezone != "America/New_York" {
+			continue
+		}
+
+		if err := library.Checkpoint(ctx); err != nil {
+			logger.Info().Err(err).Msg("stopping iex cloud EOD download")
+			runSummary.Status = data.RunCanceled
+			return
+		}
+
+		symbol := data.DenormalizeTicker("iex", asset.Ticker)
+
+		quotes := make([]*iexChart, 0, iexChartDays)
+		err := iexGet(ctx, client, limiter, budget, iexChartDays*iexChartDayWeight,
+			fmt.Sprintf("/stock/%s/chart/%dd", symbol, iexChartDays), nil, &quotes)
+
+		var dividends []*iexDividend
+		if err == nil {
+			// dividends are weighted per dividend returned; assets rarely pay more than one a month
+			err = iexGet(ctx, client, limiter, budget, iexDividendWeight,
+				fmt.Sprintf("/stock/%s/dividends/1m", symbol), nil, &dividends)
+		}
+
+		switch {
+		case errors.Is(err, ErrMessageBudgetExhausted):
+			logger.Error().Err(err).Msg("stopping iex cloud EOD download")
+			runSummary.Status = data.RunFailed
+			return
+		case ctx.Err() != nil:
+			runSummary.Status = data.RunCanceled
+			return
+		case err != nil:
+			logger.Error().Err(err).Str("Symbol", symbol).Msg("could not download EOD quotes from iex cloud")
+			continue
+		}
+
+		dividendsByDate := make(map[string]float64, len(dividends))
+		for _, dividend := range dividends {
+			dividendsByDate[dividend.ExDate] += dividend.Amount
+		}
+
+		for _, quote := range quotes {
+			quoteDate, err := time.Parse("2006-01-02", quote.Date)
+			if err != nil {
+				logger.Error().Err(err).Str("iexDate", quote.Date).Msg("could not parse date from iex cloud chart")
+				continue
+			}
+
+			out <- &data.Observation{
+				EodQuote: &data.Eod{
+					Date:          asset.PrimaryExchange.CloseTime(quoteDate),
+					Ticker:        asset.Ticker,
+					CompositeFigi: asset.CompositeFigi,
+					Open:          quote.Open,
+					High:          quote.High,
+					Low:           quote.Low,
+					Close:         quote.Close,
+					Volume:        quote.Volume,
+					Dividend:      dividendsByDate[quote.Date],
+					Split:         1,
+					Currency:      asset.PriceCurrency,
+				},
+				ObservationDate:  time.Now(),
+				SubscriptionID:   subscription.ID,
+				SubscriptionName: subscription.Name,
+			}
+
+			numObs++
+		}
+	}
+
+	runSummary.Status = data.RunSuccess
+}
+
+func downloadIEXAssets(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
+	logger := zerolog.Ctx(ctx)
+
+	runSummary := data.RunSummary{
+		StartTime:        time.Now(),
+		SubscriptionID:   subscription.ID,
+		SubscriptionName: subscription.Name,
+	}
+
+	numObs := 0
+	budget := newIEXBudget(subscription)
+
+	defer func() {
+		runSummary.EndTime = time.Now()
+		runSummary.NumObservations = numObs
+		logger.Info().Int64("MessagesUsed", budget.Used()).Msg("iex cloud messages used")
+		exitNotification <- runSummary
+	}()
+
+	client, limiter := iexClient(ctx, subscription)
+
+	symbols := make([]*iexSymbol, 0)
+	if err := iexGet(ctx, client, limiter, budget, iexRefDataWeight, "/ref-data/symbols", nil, &symbols); err != nil {
+		logger.Error().Err(err).Msg("could not download reference data from iex cloud")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	assets := make([]*data.Asset, 0, len(symbols))
+	for _, symbol := range symbols {
+		exchange, ok := iexExchangeMap[symbol.Exchange]
+		if !ok || !symbol.IsEnabled || !strings.EqualFold(symbol.Region, "US") {
+			continue
+		}
+
+		assetType, ok := iexAssetTypeMap[symbol.Type]
+		if !ok {
+			continue
+		}
+
+		assets = append(assets, &data.Asset{
+			Ticker:          data.NormalizeTicker("iex", symbol.Symbol),
+			Name:            symbol.Name,
+			PrimaryExchange: exchange,
+			AssetType:       assetType,
+			CIK:             symbol.CIK,
+			PriceCurrency:   strings.ToUpper(symbol.Currency),
+			Active:          true,
+			LastUpdated:     time.Now(),
+		})
+	}
+
+	logger.Debug().Int("NumAssetsToEnrich", len(assets)).Msg("number of assets to enrich with Composite FIGI")
+	figi.Enrich(ctx, assets...)
+	assets = subscription.Library.ResolveAssets(ctx, assets)
+
+	listed := make(map[string]bool, len(assets))
+	for _, asset := range assets {
+		if asset.CompositeFigi != "" {
+			listed[asset.CompositeFigi] = true
+		}
+	}
+
+	// assets that are no longer in the reference data have been delisted
+	conn, err := subscription.Library.Pool.Acquire(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("could not acquire database connection")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	for _, dbAsset := range data.ActiveAssets(ctx, conn, subscription.DataTablesMap[data.AssetKey]) {
+		if !listed[dbAsset.CompositeFigi] {
+			dbAsset.Active = false
+			dbAsset.DelistingDate = data.Today(data.NYSEExchange).String()
+			assets = append(assets, dbAsset)
+		}
+	}
+
+	conn.Release()
+
+	for _, asset := range assets {
+		if asset.CompositeFigi == "" {
+			continue
+		}
+
+		out <- &data.Observation{
+			AssetObject:      asset,
+			ObservationDate:  time.Now(),
+			SubscriptionID:   subscription.ID,
+			SubscriptionName: subscription.Name,
+		}
+
+		numObs++
+	}
+
+	runSummary.Status = data.RunSuccess
+}
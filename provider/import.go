@@ -0,0 +1,392 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/figi"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+var (
+	ErrNoCompositeFigi = errors.New("composite figi is not set and ticker is not in the asset table")
+	ErrInvalidQuote    = errors.New("quote must have a positive close and a low below its high")
+	ErrMissingTicker   = errors.New("ticker is not set")
+)
+
+var (
+	importEODFields   = []string{"date", "ticker", "compositeFigi", "open", "high", "low", "close", "volume", "dividend", "split", "currency"}
+	importAssetFields = []string{"ticker", "name", "description", "primaryExchange", "assetType", "compositeFigi", "shareClassFigi",
+		"active", "cik", "listingDate", "delistingDate", "industry", "sector", "currency"}
+)
+
+// Import loads historical data from CSV or Parquet files supplied by the user,
+// e.g. to seed a library with an archive from another system. Columns are mapped
+// to fields with the `columns` config value.
+type Import struct{}
+
+func (imp *Import) Name() string {
+	return "Import"
+}
+
+func (imp *Import) ConfigSchema() ConfigSchema {
+	return ConfigSchema{
+		{Name: "path", Prompt: "Path of the files to import; glob patterns are allowed (e.g. /data/eod/*.csv):", Type: ConfigString, Required: true},
+		{Name: "format", Prompt: "Format of the files (default: determined by file extension):", Type: ConfigString, Pattern: "csv|parquet"},
+		{Name: "columns", Prompt: "Map fields to file columns, e.g. date=Date,ticker=Symbol,close=Adj Close (unmapped fields use a column with the field name):", Type: ConfigString},
+		{Name: "dateFormat", Prompt: "Layout of dates in CSV files as a Go time layout:", Type: ConfigString, Default: "2006-01-02"},
+	}
+}
+
+func (imp *Import) Description() string {
+	return `Import historical EOD quotes or assets from CSV or Parquet files.`
+}
+
+func (imp *Import) Datasets() map[string]Dataset {
+	return map[string]Dataset{
+		"EOD": {
+			Name:        "EOD",
+			Description: "Import end-of-day quotes.",
+			DataTypes:   []*data.DataType{data.DataTypes[data.EODKey]},
+			DependsOn:   []string{data.AssetKey},
+			DateRange: func() (time.Time, time.Time) {
+				return time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC), time.Now().UTC()
+			},
+			Capabilities: Capabilities{
+				Geographies: []string{"*"},
+				Granularity: GranularityDaily,
+				Backfill:    true,
+			},
+			Fetch: importEODQuotes,
+		},
+		"Assets": {
+			Name:        "Assets",
+			Description: "Import asset descriptions; assets without a composite FIGI are looked up on OpenFIGI.",
+			DataTypes:   []*data.DataType{data.DataTypes[data.AssetKey]},
+			DateRange: func() (time.Time, time.Time) {
+				return time.Now().UTC(), time.Now().UTC()
+			},
+			Capabilities: Capabilities{
+				Geographies: []string{"*"},
+				Granularity: GranularityReference,
+				Backfill:    true,
+			},
+			Fetch: importAssets,
+		},
+	}
+}
+
+// importSettings reads the files and column mapping configured for the subscription
+func importSettings(subscription *library.Subscription, fields []string) (files []string, columns map[string]string, dateFormat string, err error) {
+	files, err = importFiles(subscription.Config["path"])
+	if err != nil {
+		return
+	}
+
+	columns, err = parseColumnMap(subscription.Config["columns"], fields)
+	if err != nil {
+		return
+	}
+
+	dateFormat = subscription.Config["dateFormat"]
+	if dateFormat == "" {
+		dateFormat = "2006-01-02"
+	}
+
+	return
+}
+
+func importEODQuotes(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
+	logger := zerolog.Ctx(ctx)
+
+	runSummary := data.RunSummary{
+		StartTime:        time.Now(),
+		SubscriptionID:   subscription.ID,
+		SubscriptionName: subscription.Name,
+	}
+
+	numObs := 0
+
+	defer func() {
+		runSummary.EndTime = time.Now()
+		runSummary.NumObservations = numObs
+		exitNotification <- runSummary
+	}()
+
+	files, columns, dateFormat, err := importSettings(subscription, importEODFields)
+	if err != nil {
+		logger.Error().Err(err).Msg("invalid import configuration")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	// quotes without a composite figi are matched to assets by ticker
+	assets := make(map[string]*data.Asset)
+	if viper.GetString("default.asset_table") != "" {
+		conn, err := subscription.Library.Pool.Acquire(ctx)
+		if err != nil {
+			logger.Error().Err(err).Msg("could not acquire database connection")
+			runSummary.Status = data.RunFailed
+			return
+		}
+
+		for _, asset := range data.ActiveAssets(ctx, conn) {
+			assets[asset.Ticker] = asset
+		}
+
+		conn.Release()
+	} else {
+		logger.Warn().Msg("default.asset_table not set; quotes without a composite figi will be skipped")
+	}
+
+	for _, fn := range files {
+		if err := library.Checkpoint(ctx); err != nil {
+			logger.Info().Err(err).Msg("stopping EOD import")
+			runSummary.Status = data.RunCanceled
+			return
+		}
+
+		rows, err := readImportFile(fn, subscription.Config["format"])
+		if err != nil {
+			logger.Error().Err(err).Str("FileName", fn).Msg("could not read import file")
+			runSummary.Status = data.RunFailed
+			return
+		}
+
+		numSkipped := 0
+		for _, row := range rows {
+			eod, err := importEODRow(row, columns, dateFormat, assets)
+			if err != nil {
+				logger.Debug().Err(err).Str("FileName", fn).Str("Ticker", row.String(columns["ticker"])).Msg("skipping invalid quote")
+				numSkipped++
+				continue
+			}
+
+			out <- &data.Observation{
+				EodQuote:         eod,
+				ObservationDate:  time.Now(),
+				SubscriptionID:   subscription.ID,
+				SubscriptionName: subscription.Name,
+			}
+
+			numObs++
+		}
+
+		logger.Info().Str("FileName", fn).Int("NumRows", len(rows)).Int("NumSkipped", numSkipped).Msg("imported EOD quotes")
+	}
+
+	runSummary.Status = data.RunSuccess
+}
+
+// importEODRow validates a row and converts it to a quote
+func importEODRow(row importRow, columns map[string]string, dateFormat string, assets map[string]*data.Asset) (*data.Eod, error) {
+	eod := &data.Eod{
+		Ticker:        strings.ToUpper(row.String(columns["ticker"])),
+		CompositeFigi: row.String(columns["compositeFigi"]),
+		Currency:      row.String(columns["currency"]),
+		Split:         1,
+	}
+
+	date, err := row.Time(columns["date"], dateFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	exchange := data.UnknownExchange
+	if asset, ok := assets[eod.Ticker]; ok {
+		if eod.CompositeFigi == "" {
+			eod.CompositeFigi = asset.CompositeFigi
+		}
+
+		if eod.Currency == "" {
+			eod.Currency = asset.PriceCurrency
+		}
+
+		exchange = asset.PrimaryExchange
+	}
+
+	if eod.CompositeFigi == "" {
+		return nil, ErrNoCompositeFigi
+	}
+
+	if eod.Currency == "" {
+		eod.Currency = "USD"
+	}
+
+	eod.Date = exchange.CloseTime(date)
+
+	for field, dest := range map[string]*float64{
+		"open":     &eod.Open,
+		"high":     &eod.High,
+		"low":      &eod.Low,
+		"close":    &eod.Close,
+		"volume":   &eod.Volume,
+		"dividend": &eod.Dividend,
+		"split":    &eod.Split,
+	} {
+		if _, ok := row[columns[field]]; !ok {
+			continue
+		}
+
+		if *dest, err = row.Float(columns[field]); err != nil {
+			return nil, err
+		}
+	}
+
+	if eod.Close <= 0 || eod.Low > eod.High {
+		return nil, ErrInvalidQuote
+	}
+
+	return eod, nil
+}
+
+func importAssets(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
+	logger := zerolog.Ctx(ctx)
+
+	runSummary := data.RunSummary{
+		StartTime:        time.Now(),
+		SubscriptionID:   subscription.ID,
+		SubscriptionName: subscription.Name,
+	}
+
+	numObs := 0
+
+	defer func() {
+		runSummary.EndTime = time.Now()
+		runSummary.NumObservations = numObs
+		exitNotification <- runSummary
+	}()
+
+	files, columns, dateFormat, err := importSettings(subscription, importAssetFields)
+	if err != nil {
+		logger.Error().Err(err).Msg("invalid import configuration")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	for _, fn := range files {
+		if err := library.Checkpoint(ctx); err != nil {
+			logger.Info().Err(err).Msg("stopping asset import")
+			runSummary.Status = data.RunCanceled
+			return
+		}
+
+		rows, err := readImportFile(fn, subscription.Config["format"])
+		if err != nil {
+			logger.Error().Err(err).Str("FileName", fn).Msg("could not read import file")
+			runSummary.Status = data.RunFailed
+			return
+		}
+
+		assets := make([]*data.Asset, 0, len(rows))
+		for _, row := range rows {
+			asset, err := importAssetRow(row, columns, dateFormat)
+			if err != nil {
+				logger.Debug().Err(err).Str("FileName", fn).Str("Ticker", row.String(columns["ticker"])).Msg("skipping invalid asset")
+				continue
+			}
+
+			assets = append(assets, asset)
+		}
+
+		figi.Enrich(ctx, assets...)
+		assets = subscription.Library.ResolveAssets(ctx, assets)
+
+		numSkipped := len(rows) - len(assets)
+		for _, asset := range assets {
+			if asset.CompositeFigi == "" {
+				numSkipped++
+				continue
+			}
+
+			out <- &data.Observation{
+				AssetObject:      asset,
+				ObservationDate:  time.Now(),
+				SubscriptionID:   subscription.ID,
+				SubscriptionName: subscription.Name,
+			}
+
+			numObs++
+		}
+
+		logger.Info().Str("FileName", fn).Int("NumRows", len(rows)).Int("NumSkipped", numSkipped).Msg("imported assets")
+	}
+
+	runSummary.Status = data.RunSuccess
+}
+
+// importAssetRow validates a row and converts it to an asset
+func importAssetRow(row importRow, columns map[string]string, dateFormat string) (*data.Asset, error) {
+	asset := &data.Asset{
+		Ticker:          strings.ToUpper(row.String(columns["ticker"])),
+		Name:            row.String(columns["name"]),
+		Description:     row.String(columns["description"]),
+		PrimaryExchange: data.Exchange(row.String(columns["primaryExchange"])),
+		AssetType:       data.AssetType(row.String(columns["assetType"])),
+		CompositeFigi:   row.String(columns["compositeFigi"]),
+		ShareClassFigi:  row.String(columns["shareClassFigi"]),
+		CIK:             row.String(columns["cik"]),
+		Industry:        row.String(columns["industry"]),
+		Sector:          row.String(columns["sector"]),
+		PriceCurrency:   row.String(columns["currency"]),
+		LastUpdated:     time.Now(),
+	}
+
+	if asset.Ticker == "" {
+		return nil, ErrMissingTicker
+	}
+
+	if asset.AssetType == "" {
+		asset.AssetType = data.UnknownAsset
+	}
+
+	if asset.PrimaryExchange == "" {
+		asset.PrimaryExchange = data.UnknownExchange
+	}
+
+	if asset.PriceCurrency == "" {
+		asset.PriceCurrency = "USD"
+	}
+
+	var err error
+	if asset.Active, err = row.Bool(columns["active"], true); err != nil {
+		return nil, err
+	}
+
+	for field, dest := range map[string]*string{
+		"listingDate":   &asset.ListingDate,
+		"delistingDate": &asset.DelistingDate,
+	} {
+		if row.String(columns[field]) == "" {
+			continue
+		}
+
+		date, err := row.Time(columns[field], dateFormat)
+		if err != nil {
+			return nil, err
+		}
+
+		*dest = date.Format("2006-01-02")
+	}
+
+	return asset, nil
+}
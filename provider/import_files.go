@@ -0,0 +1,289 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+var (
+	ErrNoImportFiles      = errors.New("no files match import path")
+	ErrUnknownImportField = errors.New("unknown import field")
+	ErrUnknownFileFormat  = errors.New("unknown import file format")
+	ErrMissingColumn      = errors.New("import file does not have mapped column")
+)
+
+// importRow is a single record of an import file keyed by column name
+type importRow map[string]any
+
+// importFiles returns the files matching the glob pattern in sorted order
+func importFiles(pattern string) ([]string, error) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoImportFiles, pattern)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// parseColumnMap parses a column mapping of the form field=Column,field=Column.
+// Fields that are not mapped are read from a column with the same name.
+func parseColumnMap(mapping string, fields []string) (map[string]string, error) {
+	columns := make(map[string]string, len(fields))
+	for _, field := range fields {
+		columns[field] = field
+	}
+
+	for _, pair := range strings.Split(mapping, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		field, column, _ := strings.Cut(pair, "=")
+		field = strings.TrimSpace(field)
+		if _, ok := columns[field]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownImportField, field)
+		}
+
+		columns[field] = strings.TrimSpace(column)
+	}
+
+	return columns, nil
+}
+
+// readImportFile reads all rows of a CSV or Parquet file; the format is
+// determined by the file extension unless format is set
+func readImportFile(fn, format string) ([]importRow, error) {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(fn)), ".")
+	}
+
+	switch format {
+	case "csv":
+		return readCSVFile(fn)
+	case "parquet":
+		return readParquetFile(fn)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFileFormat, format)
+	}
+}
+
+func readCSVFile(fn string) ([]importRow, error) {
+	fh, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	return readCSV(fh)
+}
+
+// readCSV reads all records of a CSV document that starts with a header row
+func readCSV(r io.Reader) ([]importRow, error) {
+	csvReader := csv.NewReader(r)
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := range header {
+		header[idx] = strings.TrimSpace(strings.TrimPrefix(header[idx], "\ufeff"))
+	}
+
+	rows := make([]importRow, 0, 1024)
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		row := make(importRow, len(header))
+		for idx, val := range record {
+			if idx < len(header) {
+				row[header[idx]] = strings.TrimSpace(val)
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// readParquetFile reads the top-level columns of a parquet file. Date and
+// timestamp columns are converted to time.Time.
+func readParquetFile(fn string) ([]importRow, error) {
+	fr, err := local.NewLocalFileReader(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer fr.Close()
+
+	pr, err := reader.NewParquetColumnReader(fr, 4)
+	if err != nil {
+		return nil, err
+	}
+	defer pr.ReadStop()
+
+	numRows := pr.GetNumRows()
+	rows := make([]importRow, numRows)
+	for idx := range rows {
+		rows[idx] = make(importRow)
+	}
+
+	schemaHandler := pr.SchemaHandler
+	for _, inPath := range schemaHandler.ValueColumns {
+		exPath := common.StrToPath(schemaHandler.InPathToExPath[inPath])
+		if len(exPath) != 2 {
+			// nested columns are not supported
+			continue
+		}
+
+		values, _, _, err := pr.ReadColumnByPath(inPath, numRows)
+		if err != nil {
+			return nil, err
+		}
+
+		element := schemaHandler.SchemaElements[schemaHandler.MapIndex[inPath]]
+		for idx, val := range values {
+			if idx < len(rows) && val != nil {
+				rows[idx][exPath[1]] = parquetValue(element, val)
+			}
+		}
+	}
+
+	return rows, nil
+}
+
+// parquetValue converts logical date and time types to time.Time
+func parquetValue(element *parquet.SchemaElement, val any) any {
+	switch {
+	case element.ConvertedType != nil && *element.ConvertedType == parquet.ConvertedType_DATE:
+		if days, ok := val.(int32); ok {
+			return time.Unix(int64(days)*24*60*60, 0).UTC()
+		}
+	case element.ConvertedType != nil && *element.ConvertedType == parquet.ConvertedType_TIMESTAMP_MILLIS:
+		if millis, ok := val.(int64); ok {
+			return time.UnixMilli(millis).UTC()
+		}
+	case element.ConvertedType != nil && *element.ConvertedType == parquet.ConvertedType_TIMESTAMP_MICROS:
+		if micros, ok := val.(int64); ok {
+			return time.UnixMicro(micros).UTC()
+		}
+	case element.LogicalType != nil && element.LogicalType.IsSetTIMESTAMP():
+		if ts, ok := val.(int64); ok {
+			unit := element.LogicalType.TIMESTAMP.Unit
+			switch {
+			case unit.IsSetMILLIS():
+				return time.UnixMilli(ts).UTC()
+			case unit.IsSetMICROS():
+				return time.UnixMicro(ts).UTC()
+			default:
+				return time.Unix(0, ts).UTC()
+			}
+		}
+	}
+
+	return val
+}
+
+// String returns the value of column as a string
+func (row importRow) String(column string) string {
+	switch val := row[column].(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case time.Time:
+		return val.Format("2006-01-02")
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// Float returns the value of column as a float; missing values are 0
+func (row importRow) Float(column string) (float64, error) {
+	switch val := row[column].(type) {
+	case nil:
+		return 0, nil
+	case string:
+		if val == "" {
+			return 0, nil
+		}
+		return strconv.ParseFloat(strings.ReplaceAll(val, ",", ""), 64)
+	case float64:
+		return val, nil
+	case float32:
+		return float64(val), nil
+	case int32:
+		return float64(val), nil
+	case int64:
+		return float64(val), nil
+	default:
+		return strconv.ParseFloat(fmt.Sprint(val), 64)
+	}
+}
+
+// Time returns the value of column as a time; strings are parsed with layout
+func (row importRow) Time(column, layout string) (time.Time, error) {
+	switch val := row[column].(type) {
+	case time.Time:
+		return val, nil
+	case nil:
+		return time.Time{}, fmt.Errorf("%w: %s", ErrMissingColumn, column)
+	default:
+		return time.Parse(layout, row.String(column))
+	}
+}
+
+// Bool returns the value of column as a bool; missing values are def
+func (row importRow) Bool(column string, def bool) (bool, error) {
+	switch val := row[column].(type) {
+	case nil:
+		return def, nil
+	case bool:
+		return val, nil
+	default:
+		str := row.String(column)
+		if str == "" {
+			return def, nil
+		}
+		return strconv.ParseBool(str)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/penny-vault/pvdata/data"
+)
+
+var (
+	ErrKraken = errors.New("kraken returned an error")
+)
+
+type Kraken struct{}
+
+func (kraken *Kraken) Name() string {
+	return "Kraken"
+}
+
+func (kraken *Kraken) ConfigSchema() ConfigSchema {
+	return ConfigSchema{
+		{Name: "markets", Prompt: "Enter the markets to download, comma separated:", Type: ConfigString, Default: cryptoDefaultMarkets},
+	}
+}
+
+func (kraken *Kraken) Description() string {
+	return `Kraken publishes OHLCV candles for the cryptocurrency markets it trades.`
+}
+
+func (kraken *Kraken) Datasets() map[string]Dataset {
+	return map[string]Dataset{
+		"Daily Candles": {
+			Name:        "Daily Candles",
+			Description: "Daily OHLCV candles for the configured markets.",
+			DataTypes:   []*data.DataType{data.DataTypes[data.CryptoQuoteKey]},
+			DateRange: func() (time.Time, time.Time) {
+				return time.Date(2013, 1, 1, 0, 0, 0, 0, time.UTC), time.Now().UTC()
+			},
+			Capabilities: Capabilities{
+				Geographies: []string{"*"},
+				Granularity: GranularityDaily,
+				Cost:        Cost{PerRun: 2},
+			},
+			Fetch: downloadCryptoCandles("kraken", cryptoDaily, krakenCandles),
+		},
+
+		"Hourly Candles": {
+			Name:        "Hourly Candles",
+			Description: "Hourly OHLCV candles for the configured markets.",
+			DataTypes:   []*data.DataType{data.DataTypes[data.CryptoQuoteKey]},
+			DateRange: func() (time.Time, time.Time) {
+				return time.Date(2013, 1, 1, 0, 0, 0, 0, time.UTC), time.Now().UTC()
+			},
+			Capabilities: Capabilities{
+				Geographies: []string{"*"},
+				Granularity: GranularityHourly,
+				Cost:        Cost{PerRun: 2},
+			},
+			Fetch: downloadCryptoCandles("kraken", cryptoHourly, krakenCandles),
+		},
+	}
+}
+
+type krakenOHLCResponse struct {
+	Error  []string                   `json:"error"`
+	Result map[string]json.RawMessage `json:"result"`
+}
+
+// krakenCandles downloads the most recent 720 candles of market. Kraken returns
+// each candle as [time, open, high, low, close, vwap, volume, count] with prices
+// encoded as strings; results are keyed by Kraken's name for the pair.
+func krakenCandles(ctx context.Context, client *resty.Client, market string, interval cryptoInterval) ([]*data.CryptoQuote, error) {
+	var ohlc krakenOHLCResponse
+	resp, err := client.R().
+		SetContext(ctx).
+		SetQueryParam("pair", strings.ReplaceAll(market, "-", "")).
+		SetQueryParam("interval", strconv.Itoa(int(interval.Duration.Minutes()))).
+		SetResult(&ohlc).
+		Get("https://api.kraken.com/0/public/OHLC")
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode() >= 300 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidStatusCode, resp.StatusCode())
+	}
+
+	if len(ohlc.Error) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrKraken, strings.Join(ohlc.Error, ", "))
+	}
+
+	quotes := make([]*data.CryptoQuote, 0, 720)
+	for pair, raw := range ohlc.Result {
+		if pair == "last" {
+			continue
+		}
+
+		var candles [][]any
+		if err := json.Unmarshal(raw, &candles); err != nil {
+			return nil, err
+		}
+
+		for _, candle := range candles {
+			if len(candle) < 7 {
+				continue
+			}
+
+			ts, ok := candle[0].(float64)
+			if !ok {
+				continue
+			}
+
+			quote := &data.CryptoQuote{
+				EventTime: time.Unix(int64(ts), 0).UTC(),
+			}
+
+			for idx, dest := range map[int]*float64{1: &quote.Open, 2: &quote.High, 3: &quote.Low, 4: &quote.Close, 6: &quote.Volume} {
+				str, _ := candle[idx].(string)
+				if *dest, err = strconv.ParseFloat(str, 64); err != nil {
+					break
+				}
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			quotes = append(quotes, quote)
+		}
+	}
+
+	return quotes, nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog"
+)
+
+// maintenanceDefaultDeadRatio is the fraction of dead rows at which a table is vacuumed
+const maintenanceDefaultDeadRatio = 0.2
+
+// Maintenance keeps the library's database healthy. It is a provider so that
+// it can be scheduled like any other subscription; it does not produce observations.
+type Maintenance struct{}
+
+func (maintenance *Maintenance) Name() string {
+	return "Maintenance"
+}
+
+func (maintenance *Maintenance) ConfigSchema() ConfigSchema {
+	return ConfigSchema{
+		{Name: "deadRatio", Prompt: "Vacuum tables when this fraction of their rows are dead:", Type: ConfigFloat, Default: strconv.FormatFloat(maintenanceDefaultDeadRatio, 'f', -1, 64)},
+	}
+}
+
+func (maintenance *Maintenance) Description() string {
+	return `Database maintenance for the library: vacuums bloated tables, refreshes planner statistics, and reports tables that are missing indexes.`
+}
+
+func (maintenance *Maintenance) Datasets() map[string]Dataset {
+	return map[string]Dataset{
+		"Database Maintenance": {
+			Name:        "Database Maintenance",
+			Description: "Vacuum and analyze bloated tables and log index recommendations.",
+			DataTypes:   []*data.DataType{},
+			DateRange: func() (time.Time, time.Time) {
+				return time.Now().UTC(), time.Now().UTC()
+			},
+			Fetch: runMaintenance,
+		},
+	}
+}
+
+func runMaintenance(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
+	logger := zerolog.Ctx(ctx)
+
+	runSummary := data.RunSummary{
+		StartTime:        time.Now(),
+		SubscriptionID:   subscription.ID,
+		SubscriptionName: subscription.Name,
+		Status:           data.RunSuccess,
+	}
+
+	defer func() {
+		runSummary.EndTime = time.Now()
+		exitNotification <- runSummary
+	}()
+
+	deadRatio, err := strconv.ParseFloat(subscription.Config["deadRatio"], 64)
+	if err != nil || deadRatio <= 0 {
+		deadRatio = maintenanceDefaultDeadRatio
+	}
+
+	bloat, err := subscription.Library.Bloat(ctx, deadRatio)
+	if err != nil {
+		logger.Error().Err(err).Msg("could not measure table bloat")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	for _, table := range bloat {
+		if err := library.Checkpoint(ctx); err != nil {
+			logger.Info().Err(err).Msg("stopping database maintenance")
+			runSummary.Status = data.RunCanceled
+			return
+		}
+
+		logger.Info().Str("Table", table.Table).Int64("DeadRows", table.DeadRows).Float64("DeadRatio", table.DeadRatio).
+			Time("LastVacuum", table.LastVacuum).Msg("vacuuming table")
+
+		if err := subscription.Library.Vacuum(ctx, table.Table); err != nil {
+			logger.Error().Err(err).Str("Table", table.Table).Msg("could not vacuum table")
+			runSummary.Status = data.RunFailed
+		}
+	}
+
+	candidates, err := subscription.Library.IndexCandidates(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("could not check for missing indexes")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	for _, candidate := range candidates {
+		logger.Warn().Str("Table", candidate.Table).Str("Reason", candidate.Reason).Str("SQL", candidate.Statement).
+			Msg("table may be missing an index")
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/penny-vault/pvdata/library"
+	"golang.org/x/time/rate"
+)
+
+// defaultMaxPages bounds pagination when MaxPages is not set
+const defaultMaxPages = 1000
+
+var (
+	ErrTooManyPages    = errors.New("pagination exceeded the maximum number of pages")
+	ErrPaginationCycle = errors.New("api returned a cursor that was already requested")
+)
+
+// PageStyle is how an API identifies the pages of a result
+type PageStyle int
+
+const (
+	// PageCursor APIs return a cursor, or the URL, of the next page with each
+	// page; the last page has no cursor
+	PageCursor PageStyle = iota
+
+	// PageNumber APIs are asked for page 1, 2, ... of PageSize items
+	PageNumber
+
+	// PageOffset APIs are asked for PageSize items starting at an offset
+	PageOffset
+)
+
+// Pagination configures Paginate
+type Pagination struct {
+	Style PageStyle
+
+	// PageSize is the number of items requested per page. Numbered and offset
+	// pagination end at the first page with fewer items; if PageSize is not
+	// set they end at the first empty page.
+	PageSize int
+
+	// ZeroBased numbers the first page 0 instead of 1
+	ZeroBased bool
+
+	// MaxPages stops runaway pagination with ErrTooManyPages; defaults to 1000
+	MaxPages int
+
+	// Limiter, if set, is waited on before each page is requested
+	Limiter *rate.Limiter
+}
+
+// PageRequest identifies the page to fetch. Only the field of the pagination
+// style is set: Cursor is empty for the first page.
+type PageRequest struct {
+	Cursor string
+	Number int
+	Offset int
+	Size   int
+}
+
+// Page is a decoded page of results
+type Page[T any] struct {
+	Items []T
+
+	// Next is the cursor or URL of the next page for cursor pagination; empty
+	// on the last page
+	Next string
+
+	// Total is the number of items in the result if the API reports it;
+	// pagination stops once Total items have been read
+	Total int
+}
+
+// Paginate fetches pages until the last page and passes the items of each page
+// to handle. Between pages Paginate waits on the limiter and stops if the run
+// was paused or canceled, see library.Checkpoint. The number of pages fetched
+// is returned; an error from fetch or handle stops pagination and is returned
+// as is.
+func Paginate[T any](ctx context.Context, pagination Pagination, fetch func(context.Context, PageRequest) (*Page[T], error),
+	handle func([]T) error) (int, error) {
+	maxPages := pagination.MaxPages
+	if maxPages <= 0 {
+		maxPages = defaultMaxPages
+	}
+
+	req := PageRequest{Size: pagination.PageSize}
+	if pagination.Style == PageNumber && !pagination.ZeroBased {
+		req.Number = 1
+	}
+
+	requested := make(map[string]bool)
+	numItems := 0
+	for numPages := 0; ; numPages++ {
+		if numPages == maxPages {
+			return numPages, fmt.Errorf("%w: %d", ErrTooManyPages, maxPages)
+		}
+
+		if err := library.Checkpoint(ctx); err != nil {
+			return numPages, err
+		}
+
+		if pagination.Limiter != nil {
+			if err := pagination.Limiter.Wait(ctx); err != nil {
+				return numPages, err
+			}
+		}
+
+		page, err := fetch(ctx, req)
+		if err != nil {
+			return numPages, err
+		}
+
+		if err := handle(page.Items); err != nil {
+			return numPages + 1, err
+		}
+
+		numItems += len(page.Items)
+		if page.Total > 0 && numItems >= page.Total {
+			return numPages + 1, nil
+		}
+
+		switch pagination.Style {
+		case PageCursor:
+			if page.Next == "" {
+				return numPages + 1, nil
+			}
+
+			requested[req.Cursor] = true
+			if requested[page.Next] {
+				return numPages + 1, fmt.Errorf("%w: %s", ErrPaginationCycle, page.Next)
+			}
+
+			req.Cursor = page.Next
+		case PageNumber, PageOffset:
+			if len(page.Items) == 0 || len(page.Items) < pagination.PageSize {
+				return numPages + 1, nil
+			}
+
+			req.Number++
+			req.Offset += len(page.Items)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/penny-vault/pvdata/provider"
+)
+
+var _ = Describe("Paginate", func() {
+	var items []int
+
+	collect := func(page []int) error {
+		items = append(items, page...)
+		return nil
+	}
+
+	BeforeEach(func() {
+		items = nil
+	})
+
+	It("follows cursors until the last page", func() {
+		pages := map[string]*provider.Page[int]{
+			"":  {Items: []int{1, 2}, Next: "b"},
+			"b": {Items: []int{3}, Next: "c"},
+			"c": {Items: []int{4}},
+		}
+
+		numPages, err := provider.Paginate(context.Background(), provider.Pagination{Style: provider.PageCursor},
+			func(ctx context.Context, req provider.PageRequest) (*provider.Page[int], error) {
+				return pages[req.Cursor], nil
+			}, collect)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(numPages).To(Equal(3))
+		Expect(items).To(Equal([]int{1, 2, 3, 4}))
+	})
+
+	It("stops when a cursor repeats", func() {
+		_, err := provider.Paginate(context.Background(), provider.Pagination{Style: provider.PageCursor},
+			func(ctx context.Context, req provider.PageRequest) (*provider.Page[int], error) {
+				return &provider.Page[int]{Items: []int{1}, Next: "same"}, nil
+			}, collect)
+
+		Expect(err).To(MatchError(provider.ErrPaginationCycle))
+		Expect(items).To(Equal([]int{1, 1}))
+	})
+
+	It("requests numbered pages until a short page", func() {
+		var requested []int
+		_, err := provider.Paginate(context.Background(), provider.Pagination{Style: provider.PageNumber, PageSize: 2},
+			func(ctx context.Context, req provider.PageRequest) (*provider.Page[int], error) {
+				requested = append(requested, req.Number)
+				if req.Number == 3 {
+					return &provider.Page[int]{Items: []int{5}}, nil
+				}
+				return &provider.Page[int]{Items: []int{req.Number*2 - 1, req.Number * 2}}, nil
+			}, collect)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requested).To(Equal([]int{1, 2, 3}))
+		Expect(items).To(Equal([]int{1, 2, 3, 4, 5}))
+	})
+
+	It("advances offsets by the items received and stops at the total", func() {
+		var offsets []int
+		_, err := provider.Paginate(context.Background(), provider.Pagination{Style: provider.PageOffset, PageSize: 2},
+			func(ctx context.Context, req provider.PageRequest) (*provider.Page[int], error) {
+				offsets = append(offsets, req.Offset)
+				return &provider.Page[int]{Items: []int{req.Offset, req.Offset + 1}, Total: 4}, nil
+			}, collect)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(offsets).To(Equal([]int{0, 2}))
+	})
+
+	It("gives up after the maximum number of pages", func() {
+		numPages, err := provider.Paginate(context.Background(), provider.Pagination{Style: provider.PageNumber, PageSize: 1, MaxPages: 5},
+			func(ctx context.Context, req provider.PageRequest) (*provider.Page[int], error) {
+				return &provider.Page[int]{Items: []int{req.Number}}, nil
+			}, collect)
+
+		Expect(err).To(MatchError(provider.ErrTooManyPages))
+		Expect(numPages).To(Equal(5))
+	})
+})
@@ -28,14 +28,30 @@ import (
 	"github.com/go-resty/resty/v2"
 	"github.com/goccy/go-json"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/penny-vault/pvdata/data"
 	"github.com/penny-vault/pvdata/figi"
 	"github.com/penny-vault/pvdata/library"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
 	"golang.org/x/time/rate"
 )
 
+const (
+	// polygonDefaultCloseTolerance is the relative difference between a stored
+	// close and the official close that is flagged when closeTolerance is not set
+	polygonDefaultCloseTolerance = 0.0005
+
+	// polygonCloseValidationDays is the number of most recent weekdays that are
+	// validated each run
+	polygonCloseValidationDays = 5
+
+	// polygonEODNumDays is the number of most recent weekdays EOD quotes are
+	// fetched for each run
+	polygonEODNumDays = 3
+)
+
 var (
 	ErrInvalidStatusCode = errors.New("invalid status code received")
 	polygonExchangeMap   = map[string]data.Exchange{
@@ -53,12 +69,13 @@ func (polygon *Polygon) Name() string {
 	return "polygon"
 }
 
-func (polygon *Polygon) ConfigDescription() map[string]string {
-	return map[string]string{
-		"apiKey":    "Enter your polygon.io API key:",
-		"rateLimit": "What is the maximum number of requests per minute?",
-		"filer":     "Where should logos and icons be saved? (e.g. file:///path/)",
-	}
+func (polygon *Polygon) ConfigSchema() ConfigSchema {
+	return append(ConfigSchema{
+		{Name: "apiKey", Prompt: "Enter your polygon.io API key:", Type: ConfigString, Required: true, Secret: true},
+		{Name: "rateLimit", Prompt: "What is the maximum number of requests per minute?", Type: ConfigInt, Required: true},
+		{Name: "filer", Prompt: "Where should logos and icons be saved? (e.g. file:///path/)", Type: ConfigString},
+		{Name: "closeTolerance", Prompt: "Flag stored closes that differ from the official close by more than this fraction:", Type: ConfigFloat, Default: strconv.FormatFloat(polygonDefaultCloseTolerance, 'f', -1, 64)},
+	}, delistedConfig...)
 }
 
 func (polygon *Polygon) Description() string {
@@ -67,6 +84,58 @@ func (polygon *Polygon) Description() string {
 
 func (polygon *Polygon) Datasets() map[string]Dataset {
 	return map[string]Dataset{
+		"Close Validation": {
+			Name:        "Close Validation",
+			Description: "Compare stored end-of-day closes in default.eod_table with the official close of each asset's primary exchange and flag discrepancies.",
+			DataTypes:   []*data.DataType{data.DataTypes[data.CloseDiscrepancyKey]},
+			DependsOn:   []string{data.EODKey},
+			DateRange: func() (time.Time, time.Time) {
+				return time.Now().AddDate(0, 0, -polygonCloseValidationDays-2).UTC(), time.Now().UTC()
+			},
+			Capabilities: Capabilities{
+				AssetTypes:  []data.AssetType{data.CommonStock, data.ADRC, data.ETF},
+				Geographies: []string{"US"},
+				Granularity: GranularityDaily,
+				Cost:        Cost{PerRun: polygonCloseValidationDays},
+			},
+			Fetch: validatePolygonCloses,
+		},
+
+		"Dividends": {
+			Name:        "Dividends",
+			Description: "Get declared dividends with their declaration, ex, record, and pay dates, including upcoming dividends.",
+			DataTypes:   []*data.DataType{data.DataTypes[data.DividendEventKey]},
+			DependsOn:   []string{data.AssetKey},
+			DateRange: func() (time.Time, time.Time) {
+				return time.Now().AddDate(0, 0, -14).UTC(), time.Now().AddDate(0, 3, 0).UTC()
+			},
+			Capabilities: Capabilities{
+				AssetTypes:  []data.AssetType{data.CommonStock, data.ADRC, data.ETF},
+				Geographies: []string{"US"},
+				Granularity: GranularityEvent,
+				Cost:        Cost{PerRun: 10},
+			},
+			Fetch: downloadPolygonDividends,
+		},
+
+		"EOD": {
+			Name:        "EOD",
+			Description: "Get end-of-day stock prices, including pre-market open and after-hours close, for active assets.",
+			DataTypes:   []*data.DataType{data.DataTypes[data.EODKey]},
+			DependsOn:   []string{data.AssetKey},
+			DateRange: func() (time.Time, time.Time) {
+				days := polygonEODDays()
+				return days[len(days)-1].Time(), time.Now().UTC()
+			},
+			Capabilities: Capabilities{
+				AssetTypes:  []data.AssetType{data.CommonStock, data.ADRC, data.ETF},
+				Geographies: []string{"US"},
+				Granularity: GranularityDaily,
+				Cost:        Cost{PerAsset: polygonEODNumDays},
+			},
+			Fetch: downloadPolygonEODQuotes,
+		},
+
 		"Market Holidays": {
 			Name:        "Market Holidays",
 			Description: "Get upcoming market holidays and their open/close times.",
@@ -74,6 +143,11 @@ func (polygon *Polygon) Datasets() map[string]Dataset {
 			DateRange: func() (time.Time, time.Time) {
 				return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Now().UTC()
 			},
+			Capabilities: Capabilities{
+				Geographies: []string{"US"},
+				Granularity: GranularityEvent,
+				Cost:        Cost{PerRun: 1},
+			},
 			Fetch: downloadPolygonMarketHolidays,
 		},
 
@@ -84,6 +158,13 @@ func (polygon *Polygon) Datasets() map[string]Dataset {
 			DateRange: func() (time.Time, time.Time) {
 				return time.Date(1949, 4, 19, 0, 0, 0, 0, time.UTC), time.Now().UTC()
 			},
+			Capabilities: Capabilities{
+				AssetTypes:      []data.AssetType{data.CommonStock, data.ADRC, data.ETF},
+				Geographies:     []string{"US"},
+				Granularity:     GranularityReference,
+				RefreshInterval: 24 * time.Hour,
+				Cost:            Cost{PerRun: 30},
+			},
 			Fetch: downloadPolygonAssets,
 		},
 	}
@@ -100,6 +181,48 @@ type polygonHoliday struct {
 	Status   string `json:"status"`
 }
 
+type polygonDividend struct {
+	Ticker          string  `json:"ticker"`
+	CashAmount      float64 `json:"cash_amount"`
+	Currency        string  `json:"currency"`
+	DeclarationDate string  `json:"declaration_date"`
+	ExDividendDate  string  `json:"ex_dividend_date"`
+	RecordDate      string  `json:"record_date"`
+	PayDate         string  `json:"pay_date"`
+	Frequency       int     `json:"frequency"`
+	DividendType    string  `json:"dividend_type"`
+}
+
+type polygonOpenClose struct {
+	Status     string  `json:"status"`
+	From       string  `json:"from"`
+	Symbol     string  `json:"symbol"`
+	Open       float64 `json:"open"`
+	High       float64 `json:"high"`
+	Low        float64 `json:"low"`
+	Close      float64 `json:"close"`
+	Volume     float64 `json:"volume"`
+	PreMarket  float64 `json:"preMarket"`
+	AfterHours float64 `json:"afterHours"`
+}
+
+type polygonGroupedDaily struct {
+	ResultsCount int                   `json:"resultsCount"`
+	Results      []*polygonDailyResult `json:"results"`
+}
+
+type polygonDailyResult struct {
+	Ticker string  `json:"T"`
+	Close  float64 `json:"c"`
+}
+
+type polygonStoredClose struct {
+	Ticker          string  `db:"ticker"`
+	CompositeFigi   string  `db:"composite_figi"`
+	PrimaryExchange string  `db:"primary_exchange"`
+	Close           float64 `db:"close"`
+}
+
 type polygonAssetFetcher struct {
 	subscription *library.Subscription
 	client       *resty.Client
@@ -177,8 +300,8 @@ func downloadPolygonAssets(ctx context.Context, subscription *library.Subscripti
 
 	api := &polygonAssetFetcher{
 		subscription: subscription,
-		client:       resty.New().SetQueryParam("apiKey", subscription.Config["apiKey"]),
-		limiter:      rate.NewLimiter(rate.Limit(float64(rateLimit)/float64(61)), 1),
+		client:       newClient(ctx).SetQueryParam("apiKey", subscription.Config["apiKey"]),
+		limiter:      rateLimiter(subscription, rateLimit),
 		publishChan:  out,
 	}
 
@@ -214,6 +337,469 @@ func downloadPolygonAssets(ctx context.Context, subscription *library.Subscripti
 	}
 }
 
+func downloadPolygonEODQuotes(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
+	logger := zerolog.Ctx(ctx)
+
+	runSummary := data.RunSummary{
+		StartTime:        time.Now(),
+		SubscriptionID:   subscription.ID,
+		SubscriptionName: subscription.Name,
+	}
+
+	numObs := 0
+
+	defer func() {
+		runSummary.EndTime = time.Now()
+		runSummary.NumObservations = numObs
+		exitNotification <- runSummary
+	}()
+
+	rateLimit, err := strconv.Atoi(subscription.Config["rateLimit"])
+	if err != nil {
+		logger.Error().Err(err).Str("configRateLimit", subscription.Config["rateLimit"]).Msg("could not convert rateLimit configuration parameter to an integer")
+		return
+	}
+
+	if rateLimit <= 0 {
+		rateLimit = 5000
+	}
+
+	client := newClient(ctx).SetQueryParam("apiKey", subscription.Config["apiKey"])
+	limiter := rateLimiter(subscription, rateLimit)
+
+	// Get a list of active assets
+	conn, err := subscription.Library.Pool.Acquire(ctx)
+	if err != nil {
+		log.Panic().Msg("could not acquire database connection")
+	}
+
+	// delisted assets without stored quotes are fetched from 5 days before delisting
+	targets, err := eodTargets(ctx, subscription, conn, 5)
+	conn.Release()
+	if err != nil {
+		logger.Error().Err(err).Msg("could not list assets to download EOD quotes for")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	days := polygonEODDays()
+
+	log.Debug().Int("NumAssets", len(targets)).Int("NumDays", len(days)).Msg("downloading EOD quotes from polygon")
+
+	for _, target := range targets {
+		if err := library.Checkpoint(ctx); err != nil {
+			log.Info().Err(err).Msg("stopping polygon EOD download")
+			runSummary.Status = data.RunCanceled
+			return
+		}
+
+		asset := target.Asset
+		ticker := data.DenormalizeTicker("polygon", asset.Ticker)
+
+		// delisted assets are fetched for each weekday through the delisting date
+		targetDays := days
+		if !target.End.IsZero() {
+			targetDays = make([]data.MarketDate, 0)
+			for day := target.Start; !day.After(target.End); day = day.AddDays(1) {
+				if !day.IsWeekend() {
+					targetDays = append(targetDays, day)
+				}
+			}
+		}
+
+		for _, day := range targetDays {
+			if err := limiter.Wait(ctx); err != nil {
+				log.Info().Err(err).Msg("stopping polygon EOD download")
+				runSummary.Status = data.RunCanceled
+				return
+			}
+
+			url := fmt.Sprintf("https://api.polygon.io/v1/open-close/%s/%s", ticker, day)
+
+			var respContent polygonOpenClose
+			resp, err := client.R().
+				SetQueryParam("adjusted", "false").
+				SetResult(&respContent).
+				Get(url)
+			if err != nil {
+				logger.Error().Err(err).Msg("resty returned an error when querying open-close")
+				runSummary.Status = data.RunFailed
+				return
+			}
+
+			// polygon returns not found for days the market was closed
+			if resp.StatusCode() == 404 {
+				continue
+			}
+
+			if resp.StatusCode() >= 300 {
+				logger.Error().Int("StatusCode", resp.StatusCode()).Str("Ticker", ticker).Str("URL", url).Msg("polygon returned an invalid HTTP response")
+				continue
+			}
+
+			quoteDate, err := data.ParseMarketDate(respContent.From)
+			if err != nil {
+				logger.Error().Err(err).Str("polygonDate", respContent.From).Msg("could not parse date from polygon open-close object")
+				continue
+			}
+
+			// the open-close endpoint does not report dividends or splits so
+			// the values stored by other providers are kept
+			out <- &data.Observation{
+				EodQuote: &data.Eod{
+					Date:            quoteDate.Close(asset.PrimaryExchange),
+					Ticker:          asset.Ticker,
+					CompositeFigi:   asset.CompositeFigi,
+					Open:            respContent.Open,
+					High:            respContent.High,
+					Low:             respContent.Low,
+					Close:           respContent.Close,
+					Volume:          respContent.Volume,
+					Split:           1.0,
+					Currency:        data.USD,
+					PreMarketOpen:   respContent.PreMarket,
+					AfterHoursClose: respContent.AfterHours,
+
+					OmitCorporateActions: true,
+				},
+				ObservationDate:  time.Now(),
+				SubscriptionID:   subscription.ID,
+				SubscriptionName: subscription.Name,
+			}
+
+			numObs++
+		}
+	}
+
+	runSummary.Status = data.RunSuccess
+}
+
+// polygonEODDays returns the weekdays EOD quotes are fetched for, most recent
+// first. The open-close endpoint returns a single day per request so only the
+// last polygonEODNumDays weekdays are fetched.
+func polygonEODDays() []data.MarketDate {
+	days := make([]data.MarketDate, 0, polygonEODNumDays)
+	for day := data.Today(data.NYSEExchange).AddDays(-1); len(days) < polygonEODNumDays; day = day.AddDays(-1) {
+		if !day.IsWeekend() {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// validatePolygonCloses compares the closes stored in default.eod_table for the
+// most recent weekdays with polygon's official daily close. Consolidated closes
+// from other providers occasionally differ from the closing auction print;
+// stored closes that differ by more than closeTolerance are saved as close
+// discrepancies.
+func validatePolygonCloses(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
+	logger := zerolog.Ctx(ctx)
+
+	runSummary := data.RunSummary{
+		StartTime:        time.Now(),
+		SubscriptionID:   subscription.ID,
+		SubscriptionName: subscription.Name,
+	}
+
+	numObs := 0
+
+	defer func() {
+		runSummary.EndTime = time.Now()
+		runSummary.NumObservations = numObs
+		exitNotification <- runSummary
+	}()
+
+	eodTable := viper.GetString("default.eod_table")
+	if eodTable == "" {
+		logger.Error().Msg("default.eod_table must be set to validate close prices")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	tolerance, err := strconv.ParseFloat(subscription.Config["closeTolerance"], 64)
+	if err != nil || tolerance <= 0 {
+		tolerance = polygonDefaultCloseTolerance
+	}
+
+	rateLimit, err := strconv.Atoi(subscription.Config["rateLimit"])
+	if err != nil {
+		logger.Error().Err(err).Str("configRateLimit", subscription.Config["rateLimit"]).Msg("could not convert rateLimit configuration parameter to an integer")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	if rateLimit <= 0 {
+		rateLimit = 5000
+	}
+
+	client := newClient(ctx).SetQueryParam("apiKey", subscription.Config["apiKey"])
+	limiter := rateLimiter(subscription, rateLimit)
+
+	// the exchange of each asset is read from the asset table when one is configured
+	sql := fmt.Sprintf(`SELECT ticker, composite_figi, '' AS primary_exchange, close FROM %s WHERE event_date = $1`,
+		pgx.Identifier{eodTable}.Sanitize())
+	if assetTable := viper.GetString("default.asset_table"); assetTable != "" {
+		sql = fmt.Sprintf(`SELECT e.ticker, e.composite_figi, coalesce(a.primary_exchange, '') AS primary_exchange, e.close
+FROM %s e LEFT JOIN %s a ON a.ticker = e.ticker AND a.composite_figi = e.composite_figi
+WHERE e.event_date = $1`, pgx.Identifier{eodTable}.Sanitize(), pgx.Identifier{assetTable}.Sanitize())
+	}
+
+	checked := make(map[data.Exchange]int)
+	flagged := make(map[data.Exchange]int)
+
+	day := data.Today(data.NYSEExchange)
+	for numDays := 0; numDays < polygonCloseValidationDays; {
+		day = day.AddDays(-1)
+		if day.IsWeekend() {
+			continue
+		}
+
+		numDays++
+
+		if err := library.Checkpoint(ctx); err != nil {
+			logger.Info().Err(err).Msg("stopping polygon close validation")
+			runSummary.Status = data.RunCanceled
+			return
+		}
+
+		if err := limiter.Wait(ctx); err != nil {
+			logger.Info().Err(err).Msg("stopping polygon close validation")
+			runSummary.Status = data.RunCanceled
+			return
+		}
+
+		url := fmt.Sprintf("https://api.polygon.io/v2/aggs/grouped/locale/us/market/stocks/%s", day)
+
+		var respContent polygonGroupedDaily
+		resp, err := client.R().
+			SetQueryParam("adjusted", "false").
+			SetResult(&respContent).
+			Get(url)
+		if err != nil {
+			logger.Error().Err(err).Msg("resty returned an error when querying grouped daily")
+			runSummary.Status = data.RunFailed
+			return
+		}
+
+		if resp.StatusCode() >= 300 {
+			logger.Error().Int("StatusCode", resp.StatusCode()).Str("URL", url).Msg("polygon returned an invalid HTTP response")
+			runSummary.Status = data.RunFailed
+			return
+		}
+
+		// polygon returns no results for days the market was closed
+		if respContent.ResultsCount == 0 {
+			continue
+		}
+
+		officialCloses := make(map[string]float64, len(respContent.Results))
+		for _, result := range respContent.Results {
+			officialCloses[result.Ticker] = result.Close
+		}
+
+		stored := make([]*polygonStoredClose, 0)
+		if err := subscription.Library.WithConn(ctx, func(conn *pgxpool.Conn) error {
+			stored = stored[:0]
+			return pgxscan.Select(ctx, conn, &stored, sql, day.Time())
+		}); err != nil {
+			logger.Error().Err(err).Str("SQL", sql).Msg("could not read stored closes")
+			runSummary.Status = data.RunFailed
+			return
+		}
+
+		for _, storedClose := range stored {
+			officialClose, ok := officialCloses[data.DenormalizeTicker("polygon", storedClose.Ticker)]
+			if !ok || officialClose <= 0 {
+				continue
+			}
+
+			exchange := data.Exchange(storedClose.PrimaryExchange)
+			checked[exchange]++
+
+			difference := (storedClose.Close - officialClose) / officialClose
+			if math.Abs(difference) <= tolerance {
+				continue
+			}
+
+			flagged[exchange]++
+
+			out <- &data.Observation{
+				CloseDiscrepancy: &data.CloseDiscrepancy{
+					Ticker:        storedClose.Ticker,
+					CompositeFigi: storedClose.CompositeFigi,
+					EventDate:     day.Time(),
+					Exchange:      exchange,
+					StoredClose:   storedClose.Close,
+					OfficialClose: officialClose,
+					Difference:    difference,
+					Source:        "polygon",
+				},
+				ObservationDate:  time.Now(),
+				SubscriptionID:   subscription.ID,
+				SubscriptionName: subscription.Name,
+			}
+
+			numObs++
+		}
+	}
+
+	for exchange, numChecked := range checked {
+		event := logger.Info()
+		if flagged[exchange] > 0 {
+			event = logger.Warn()
+		}
+
+		event.Str("Exchange", string(exchange)).Int("NumChecked", numChecked).Int("NumFlagged", flagged[exchange]).Msg("validated close prices")
+	}
+
+	runSummary.Status = data.RunSuccess
+}
+
+func downloadPolygonDividends(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
+	logger := zerolog.Ctx(ctx)
+
+	runSummary := data.RunSummary{
+		StartTime:        time.Now(),
+		SubscriptionID:   subscription.ID,
+		SubscriptionName: subscription.Name,
+	}
+
+	numObs := 0
+
+	defer func() {
+		runSummary.EndTime = time.Now()
+		runSummary.NumObservations = numObs
+		exitNotification <- runSummary
+	}()
+
+	rateLimit, err := strconv.Atoi(subscription.Config["rateLimit"])
+	if err != nil {
+		logger.Error().Err(err).Str("configRateLimit", subscription.Config["rateLimit"]).Msg("could not convert rateLimit configuration parameter to an integer")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	if rateLimit <= 0 {
+		rateLimit = 5000
+	}
+
+	client := newClient(ctx).SetQueryParam("apiKey", subscription.Config["apiKey"])
+	limiter := rateLimiter(subscription, rateLimit)
+
+	conn, err := subscription.Library.Pool.Acquire(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("could not acquire database connection")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	assets := make(map[string]*data.Asset)
+	for _, asset := range data.ActiveAssets(ctx, conn) {
+		assets[asset.Ticker] = asset
+	}
+
+	conn.Release()
+
+	// include dividends that recently went ex so that changes to their record
+	// and pay dates are picked up
+	fetch := func(ctx context.Context, page PageRequest) (*Page[*polygonDividend], error) {
+		req := client.R().SetContext(ctx)
+		url := page.Cursor
+		if url == "" {
+			url = "https://api.polygon.io/v3/reference/dividends"
+			req.SetQueryParam("ex_dividend_date.gte", data.Today(data.NYSEExchange).AddDays(-14).String()).
+				SetQueryParam("order", "asc").
+				SetQueryParam("sort", "ex_dividend_date").
+				SetQueryParam("limit", "1000")
+		}
+
+		var respContent polygonResponse
+		resp, err := req.SetResult(&respContent).Get(url)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode() >= 300 {
+			return nil, fmt.Errorf("%w (%d): %s", ErrInvalidStatusCode, resp.StatusCode(), url)
+		}
+
+		dividends := make([]*polygonDividend, 0, 1000)
+		if respContent.Results != nil {
+			if err := json.Unmarshal(*respContent.Results, &dividends); err != nil {
+				return nil, err
+			}
+		}
+
+		// next_url already includes the query parameters of the request
+		return &Page[*polygonDividend]{Items: dividends, Next: respContent.Next}, nil
+	}
+
+	_, err = Paginate(ctx, Pagination{Style: PageCursor, Limiter: limiter}, fetch, func(dividends []*polygonDividend) error {
+		for _, dividend := range dividends {
+			asset, ok := assets[data.NormalizeTicker("polygon", dividend.Ticker)]
+			if !ok {
+				continue
+			}
+
+			exDate, err := time.Parse("2006-01-02", dividend.ExDividendDate)
+			if err != nil {
+				logger.Error().Err(err).Str("polygonDate", dividend.ExDividendDate).Msg("could not parse ex-dividend date from polygon object")
+				continue
+			}
+
+			out <- &data.Observation{
+				DividendEvent: &data.DividendEvent{
+					Ticker:          asset.Ticker,
+					CompositeFigi:   asset.CompositeFigi,
+					ExDate:          exDate,
+					DeclarationDate: polygonOptionalDate(dividend.DeclarationDate),
+					RecordDate:      polygonOptionalDate(dividend.RecordDate),
+					PayDate:         polygonOptionalDate(dividend.PayDate),
+					CashAmount:      dividend.CashAmount,
+					Currency:        dividend.Currency,
+					Frequency:       dividend.Frequency,
+					DividendType:    dividend.DividendType,
+				},
+				ObservationDate:  time.Now(),
+				SubscriptionID:   subscription.ID,
+				SubscriptionName: subscription.Name,
+			}
+
+			numObs++
+		}
+
+		return nil
+	})
+
+	if err != nil && ctx.Err() != nil {
+		logger.Info().Err(err).Msg("stopping polygon dividends download")
+		runSummary.Status = data.RunCanceled
+		return
+	}
+
+	if err != nil {
+		logger.Error().Err(err).Msg("could not download polygon dividends")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	runSummary.Status = data.RunSuccess
+}
+
+// polygonOptionalDate parses dates polygon may leave empty
+func polygonOptionalDate(val string) *time.Time {
+	if val == "" {
+		return nil
+	}
+
+	date, err := time.Parse("2006-01-02", val)
+	if err != nil {
+		return nil
+	}
+
+	return &date
+}
+
 func downloadPolygonMarketHolidays(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
 	logger := zerolog.Ctx(ctx)
 
@@ -242,8 +828,8 @@ func downloadPolygonMarketHolidays(ctx context.Context, subscription *library.Su
 		rateLimit = 5000
 	}
 
-	client := resty.New().SetQueryParam("apiKey", subscription.Config["apiKey"])
-	limiter := rate.NewLimiter(rate.Limit(float64(rateLimit)/float64(61)), 1)
+	client := newClient(ctx).SetQueryParam("apiKey", subscription.Config["apiKey"])
+	limiter := rateLimiter(subscription, rateLimit)
 
 	// get nyc timezone
 	nyc, err := time.LoadLocation("America/New_York")
@@ -308,9 +894,9 @@ func downloadPolygonMarketHolidays(ctx context.Context, subscription *library.Su
 	}
 }
 
-func (api *polygonAssetFetcher) publish(asset *data.Asset) {
+func (api *polygonAssetFetcher) publish(ctx context.Context, asset *data.Asset) {
 	if asset.CompositeFigi == "" {
-		figi.Enrich(asset)
+		figi.Enrich(ctx, asset)
 	}
 
 	if asset.CompositeFigi == "" {
@@ -320,7 +906,7 @@ func (api *polygonAssetFetcher) publish(asset *data.Asset) {
 	// make a copy of the asset and fix ticker to match pv-data standard
 	// e.g. BRK.A -> BRK/A
 	asset2 := *asset
-	asset2.Ticker = polygonTicker2PvTicker(asset2.Ticker)
+	asset2.Ticker = data.NormalizeTicker("polygon", asset2.Ticker)
 
 	api.publishChan <- &data.Observation{
 		AssetObject:      &asset2,
@@ -439,13 +1025,6 @@ func (api *polygonAssetFetcher) filterAssetsByLastUpdated(ctx context.Context, a
 	assetDetail := make([]*data.Asset, 0, len(assets))
 	assetUpdate := make([]*data.Asset, 0, len(assets))
 
-	dbConn, err := api.subscription.Library.Pool.Acquire(ctx)
-	if err != nil {
-		logger.Error().Err(err).Msg("error getting database connection")
-		return nil, err
-	}
-	defer dbConn.Release()
-
 	// Enrich any assets that have no figi
 	toEnrich := make([]*data.Asset, 0, len(assets)/2)
 	for _, asset := range assets {
@@ -455,7 +1034,17 @@ func (api *polygonAssetFetcher) filterAssetsByLastUpdated(ctx context.Context, a
 	}
 
 	log.Debug().Int("NumAssetsToEnrich", len(toEnrich)).Msg("Enriching assets with FIGI")
-	figi.Enrich(toEnrich...)
+	figi.Enrich(ctx, toEnrich...)
+	assets = api.subscription.Library.ResolveAssets(ctx, assets)
+
+	// the connection is acquired after enrichment so that it is not held
+	// during the OpenFIGI requests
+	dbConn, err := api.subscription.Library.Pool.Acquire(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("error getting database connection")
+		return nil, err
+	}
+	defer dbConn.Release()
 
 	// for each asset determine if details need to be queried
 	for _, asset := range assets {
@@ -471,7 +1060,7 @@ func (api *polygonAssetFetcher) filterAssetsByLastUpdated(ctx context.Context, a
 			ctx,
 			sql,
 			asset.CompositeFigi,
-			polygonTicker2PvTicker(asset.Ticker),
+			data.NormalizeTicker("polygon", asset.Ticker),
 		).Scan(&lastUpdated)
 		if err != nil {
 			if errors.Is(err, pgx.ErrNoRows) {
@@ -525,7 +1114,6 @@ func (api *polygonAssetFetcher) delistedAssets(ctx context.Context, assets []*da
 		logger.Error().Err(err).Msg("error getting database connection")
 		return err
 	}
-	defer dbConn.Release()
 
 	assetMap := make(map[string]*data.Asset, len(assets))
 	for _, asset := range assets {
@@ -553,11 +1141,13 @@ func (api *polygonAssetFetcher) delistedAssets(ctx context.Context, assets []*da
 		other_identifiers,
 		similar_tickers,
 		tags,
+		coalesce(price_currency, 'USD') as price_currency,
 		coalesce(to_char(listed, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"'), '') as listed,
 		coalesce(to_char(delisted, 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"'), '') as delisted,
 		last_updated
 	FROM %s WHERE active=true`, api.subscription.DataTablesMap[data.AssetKey]))
 	if err != nil {
+		dbConn.Release()
 		logger.Error().Err(err).Msg("error when querying database for active tickers")
 		return err
 	}
@@ -568,6 +1158,8 @@ func (api *polygonAssetFetcher) delistedAssets(ctx context.Context, assets []*da
 		logger.Error().Err(err).Msg("error when scanning values into dbActiveAssets")
 	}
 
+	dbConn.Release()
+
 	// for all active database assets that are not in the response
 	// of active assets in polygon, add to the potentially inactive list
 	for _, asset := range dbActiveAssets {
@@ -652,7 +1244,7 @@ func (api *polygonAssetFetcher) delistedAssets(ctx context.Context, assets []*da
 					inactiveAsset.LastUpdated = lastUpdated
 					inactiveAsset.Active = false
 					deactivated[asset.ID()] = inactiveAsset
-					api.publish(inactiveAsset)
+					api.publish(ctx, inactiveAsset)
 					updatedCount++
 				}
 			}
@@ -694,7 +1286,7 @@ func (api *polygonAssetFetcher) delistedAssets(ctx context.Context, assets []*da
 				// inactive
 				possibleInactiveAsset.LastUpdated = time.Now().In(nyc)
 				possibleInactiveAsset.Active = false
-				api.publish(possibleInactiveAsset)
+				api.publish(ctx, possibleInactiveAsset)
 			}
 		}
 	}
@@ -715,7 +1307,7 @@ func (api *polygonAssetFetcher) assetDetails(ctx context.Context, assets []*data
 			continue
 		}
 
-		api.publish(fullAsset)
+		api.publish(ctx, fullAsset)
 
 		sometimes.Do(func() {
 			secondsPerItem := time.Since(started) / time.Duration(idx+1)
@@ -827,7 +1419,3 @@ func (api *polygonAssetFetcher) assetDetail(ctx context.Context, asset *data.Ass
 
 	return assetDetail, nil
 }
-
-func polygonTicker2PvTicker(ticker string) string {
-	return strings.ReplaceAll(ticker, ".", "/")
-}
@@ -24,7 +24,7 @@ import (
 
 type Provider interface {
 	Name() string
-	ConfigDescription() map[string]string
+	ConfigSchema() ConfigSchema
 	Description() string
 	Datasets() map[string]Dataset
 }
@@ -35,6 +35,13 @@ type Dataset struct {
 	DataTypes   []*data.DataType
 	DateRange   func() (time.Time, time.Time)
 
+	// DependsOn lists the data types that must be refreshed before the dataset
+	// is fetched; e.g. EOD quotes are fetched for assets in the asset table
+	DependsOn []string
+
+	// Capabilities describe the coverage and cost of the dataset
+	Capabilities Capabilities
+
 	// Fetch is called when pvdata wants to retrieve measurements from the dataset. It
 	// passes a config with the provider configuration, a channel to write results to,
 	// a logger to write log messages to, and a channel to write progress.
@@ -0,0 +1,256 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+const (
+	// resampleDefaultPeriods are the bar periods materialized when the
+	// subscription does not configure any
+	resampleDefaultPeriods = "week,month"
+
+	// resampleDefaultLookback is the number of stored periods before the most
+	// recent one that are rebuilt on each run to pick up corrected quotes
+	resampleDefaultLookback = 1
+)
+
+// Resample derives weekly and monthly bars from the daily quotes in the
+// library. It is a provider so that bars are materialized on a schedule like
+// any other subscription; it does not make API requests.
+type Resample struct{}
+
+func (resample *Resample) Name() string {
+	return "Resample"
+}
+
+func (resample *Resample) ConfigSchema() ConfigSchema {
+	return ConfigSchema{
+		{Name: "periods", Prompt: "Which bar periods should be built (week, month)?", Type: ConfigString, Default: resampleDefaultPeriods},
+		{Name: "lookback", Prompt: "How many stored periods should be rebuilt to pick up corrected quotes?", Type: ConfigInt, Default: strconv.Itoa(resampleDefaultLookback)},
+	}
+}
+
+func (resample *Resample) Description() string {
+	return `Resample builds weekly and monthly OHLCV bars from the end-of-day quotes in default.eod_table so that consumers share one calendar-correct implementation.`
+}
+
+func (resample *Resample) Datasets() map[string]Dataset {
+	return map[string]Dataset{
+		"Bars": {
+			Name:        "Bars",
+			Description: "Weekly and monthly OHLCV bars resampled from the daily quotes of every asset in default.asset_table.",
+			DataTypes:   []*data.DataType{data.DataTypes[data.BarKey]},
+			DependsOn:   []string{data.AssetKey, data.EODKey},
+			DateRange: func() (time.Time, time.Time) {
+				return time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC), time.Now().UTC()
+			},
+			Capabilities: Capabilities{
+				Granularity: GranularityDaily,
+				Backfill:    true,
+			},
+			Fetch: resampleBars,
+		},
+	}
+}
+
+// resampleBars materializes bars incrementally. For each asset and period the
+// most recent stored bar, which may be incomplete, and lookback bars before it
+// are rebuilt along with any newer periods; assets without bars are built from
+// their first quote.
+func resampleBars(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
+	logger := zerolog.Ctx(ctx)
+
+	runSummary := data.RunSummary{
+		StartTime:        time.Now(),
+		SubscriptionID:   subscription.ID,
+		SubscriptionName: subscription.Name,
+		Counts:           make(map[string]int),
+	}
+
+	numObs := 0
+
+	defer func() {
+		runSummary.EndTime = time.Now()
+		runSummary.NumObservations = numObs
+		exitNotification <- runSummary
+	}()
+
+	eodTable := viper.GetString("default.eod_table")
+	assetTable := viper.GetString("default.asset_table")
+	if eodTable == "" || assetTable == "" {
+		logger.Error().Msg("default.eod_table and default.asset_table must be set to resample bars")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	periodNames := subscription.Config["periods"]
+	if periodNames == "" {
+		periodNames = resampleDefaultPeriods
+	}
+
+	periods := make([]data.BarPeriod, 0, 2)
+	for _, name := range strings.Split(periodNames, ",") {
+		period, err := data.ParseBarPeriod(name)
+		if err != nil {
+			logger.Error().Err(err).Msg("subscription is mis-configured")
+			runSummary.Status = data.RunFailed
+			return
+		}
+		periods = append(periods, period)
+	}
+
+	lookback, err := strconv.Atoi(subscription.Config["lookback"])
+	if err != nil || lookback < 0 {
+		lookback = resampleDefaultLookback
+	}
+
+	var figis []string
+	latest := make(map[data.BarPeriod]map[string]data.MarketDate, len(periods))
+	err = subscription.Library.WithConn(ctx, func(conn *pgxpool.Conn) error {
+		figis = figis[:0]
+		if err := pgxscan.Select(ctx, conn, &figis, fmt.Sprintf(`SELECT DISTINCT composite_figi FROM %s
+WHERE composite_figi <> '' ORDER BY composite_figi`, pgx.Identifier{assetTable}.Sanitize())); err != nil {
+			return err
+		}
+
+		stored, err := latestBars(ctx, conn, subscription.DataTablesMap[data.BarKey])
+		latest = stored
+		return err
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("could not read assets and stored bars")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	asOf := data.Today(data.NYSEExchange)
+	for _, figi := range figis {
+		if err := library.Checkpoint(ctx); err != nil {
+			logger.Info().Err(err).Msg("stopping bar resampling")
+			runSummary.Status = data.RunCanceled
+			return
+		}
+
+		// rebuild each period from the start of its oldest stale bar
+		from := make(map[data.BarPeriod]data.MarketDate, len(periods))
+		var start data.MarketDate
+		for _, period := range periods {
+			periodFrom := data.MarketDate{}
+			if last, ok := latest[period][figi]; ok {
+				periodFrom = resampleLookback(period, last, lookback)
+			}
+
+			from[period] = periodFrom
+			if start.IsZero() || periodFrom.Before(start) {
+				start = periodFrom
+			}
+		}
+
+		if start.IsZero() {
+			start = data.NewMarketDate(1900, time.January, 1)
+		}
+
+		var quotes []*data.Eod
+		err := subscription.Library.WithConn(ctx, func(conn *pgxpool.Conn) error {
+			var err error
+			quotes, err = data.EodQuotes(ctx, conn, eodTable, &data.EodQuery{
+				CompositeFigi: figi,
+				Start:         start.Time(),
+				End:           asOf.Time(),
+			})
+			return err
+		})
+		if err != nil {
+			logger.Error().Err(err).Str("CompositeFigi", figi).Msg("could not read eod quotes")
+			runSummary.Status = data.RunFailed
+			return
+		}
+
+		for _, period := range periods {
+			for _, bar := range data.ResampleBars(quotes, period, asOf) {
+				if data.MarketDateOf(bar.PeriodStart, data.NYSEExchange).Before(from[period]) {
+					continue
+				}
+
+				out <- &data.Observation{
+					Bar:              bar,
+					ObservationDate:  time.Now(),
+					SubscriptionID:   subscription.ID,
+					SubscriptionName: subscription.Name,
+				}
+
+				numObs++
+				runSummary.Counts[string(period)]++
+			}
+		}
+	}
+
+	logger.Info().Int("NumAssets", len(figis)).Interface("Counts", runSummary.Counts).Msg("resampled bars")
+	runSummary.Status = data.RunSuccess
+}
+
+// latestBars returns the start of the most recent stored bar of each asset by period
+func latestBars(ctx context.Context, conn *pgxpool.Conn, barTable string) (map[data.BarPeriod]map[string]data.MarketDate, error) {
+	rows, err := conn.Query(ctx, fmt.Sprintf(`SELECT period, composite_figi, max(period_start) FROM %s
+GROUP BY period, composite_figi`, pgx.Identifier{barTable}.Sanitize()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	latest := make(map[data.BarPeriod]map[string]data.MarketDate)
+	for rows.Next() {
+		var period data.BarPeriod
+		var figi string
+		var periodStart data.MarketDate
+		if err := rows.Scan(&period, &figi, &periodStart); err != nil {
+			return nil, err
+		}
+
+		if _, ok := latest[period]; !ok {
+			latest[period] = make(map[string]data.MarketDate)
+		}
+		latest[period][figi] = periodStart
+	}
+
+	return latest, rows.Err()
+}
+
+// resampleLookback returns the start of the period lookback periods before the
+// period starting on last
+func resampleLookback(period data.BarPeriod, last data.MarketDate, lookback int) data.MarketDate {
+	switch period {
+	case data.BarWeekly:
+		return last.AddDays(-7 * lookback)
+	case data.BarMonthly:
+		return last.AddMonths(-lookback)
+	default:
+		return last
+	}
+}
@@ -0,0 +1,405 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gocarina/gocsv"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/rs/zerolog"
+)
+
+var (
+	ErrSchemaDrift = errors.New("provider response does not match the expected schema")
+)
+
+const (
+	// schemaSampleSize is the number of bytes of a drifted response kept as a sample
+	schemaSampleSize = 1024
+
+	// schemaMaxRecords is the number of records of a JSON array that are checked
+	schemaMaxRecords = 10
+)
+
+// fieldKind is the JSON type of a field; CSV fields are checked against the
+// same kinds by parsing their values
+type fieldKind string
+
+const (
+	kindString  fieldKind = "string"
+	kindNumber  fieldKind = "number"
+	kindBoolean fieldKind = "boolean"
+	kindArray   fieldKind = "array"
+	kindObject  fieldKind = "object"
+	kindAny     fieldKind = "any"
+)
+
+// Schema is the format a provider expects a response to have. It is derived
+// from the struct the response is decoded into so that the two cannot
+// disagree.
+type Schema struct {
+	Source string
+
+	fields   map[string]fieldKind
+	optional map[string]bool
+	ignored  map[string]bool
+}
+
+// NewSchema derives the expected fields of a response from the json or csv
+// tags, selected by tag, of example which is a struct or a pointer to one
+func NewSchema(source string, tag string, example any) *Schema {
+	schema := &Schema{
+		Source:   source,
+		fields:   make(map[string]fieldKind),
+		optional: make(map[string]bool),
+		ignored:  make(map[string]bool),
+	}
+
+	structType := reflect.TypeOf(example)
+	for structType.Kind() == reflect.Pointer {
+		structType = structType.Elem()
+	}
+
+	for idx := 0; idx < structType.NumField(); idx++ {
+		field := structType.Field(idx)
+		name, opts, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.fields[name] = kindOf(field.Type)
+		if strings.Contains(opts, "omitempty") {
+			schema.optional[name] = true
+		}
+	}
+
+	return schema
+}
+
+// Optional marks fields the provider does not always send
+func (schema *Schema) Optional(fields ...string) *Schema {
+	for _, field := range fields {
+		schema.optional[field] = true
+	}
+	return schema
+}
+
+// Ignore marks fields the provider sends that are deliberately not read
+func (schema *Schema) Ignore(fields ...string) *Schema {
+	for _, field := range fields {
+		schema.ignored[field] = true
+	}
+	return schema
+}
+
+// kindOf maps a Go type to the JSON type it is decoded from
+func kindOf(goType reflect.Type) fieldKind {
+	for goType.Kind() == reflect.Pointer {
+		goType = goType.Elem()
+	}
+
+	switch goType.Kind() {
+	case reflect.String:
+		return kindString
+	case reflect.Bool:
+		return kindBoolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return kindNumber
+	case reflect.Slice, reflect.Array:
+		return kindArray
+	case reflect.Map:
+		return kindObject
+	case reflect.Struct:
+		// structs such as time.Time decode themselves from other types
+		if goType.Implements(reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()) ||
+			reflect.PointerTo(goType).Implements(reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()) {
+			return kindAny
+		}
+		return kindObject
+	default:
+		return kindAny
+	}
+}
+
+// jsonKind returns the JSON type of a decoded value; ok is false for null
+func jsonKind(value any) (kind fieldKind, ok bool) {
+	switch value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return kindString, true
+	case json.Number, float64:
+		return kindNumber, true
+	case bool:
+		return kindBoolean, true
+	case []any:
+		return kindArray, true
+	default:
+		return kindObject, true
+	}
+}
+
+// CheckJSON compares a JSON object, or the first records of an array of
+// objects, with the schema. nil is returned if the payload matches or is not
+// an object or array of objects, which the decoder reports itself.
+func (schema *Schema) CheckJSON(payload []byte) *data.SchemaDrift {
+	var records []map[string]any
+	var record map[string]any
+	if err := json.Unmarshal(payload, &records); err != nil {
+		if err := json.Unmarshal(payload, &record); err != nil {
+			return nil
+		}
+		records = []map[string]any{record}
+	}
+
+	if len(records) > schemaMaxRecords {
+		records = records[:schemaMaxRecords]
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	unknown := make(map[string]bool)
+	seen := make(map[string]bool)
+	changed := make(map[string]string)
+	for _, record := range records {
+		for name, value := range record {
+			seen[name] = true
+
+			expected, ok := schema.fields[name]
+			if !ok {
+				if !schema.ignored[name] {
+					unknown[name] = true
+				}
+				continue
+			}
+
+			if actual, ok := jsonKind(value); ok && expected != kindAny && actual != expected {
+				changed[name] = fmt.Sprintf("%s: expected %s, got %s", name, expected, actual)
+			}
+		}
+	}
+
+	return schema.drift(unknown, seen, changed, payload)
+}
+
+// CheckCSV compares a CSV header and the first data row, which may be nil,
+// with the schema. Values of non-string fields must parse as their type.
+func (schema *Schema) CheckCSV(header []string, row []string) *data.SchemaDrift {
+	unknown := make(map[string]bool)
+	seen := make(map[string]bool)
+	changed := make(map[string]string)
+	for idx, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		seen[name] = true
+
+		expected, ok := schema.fields[name]
+		if !ok {
+			if !schema.ignored[name] {
+				unknown[name] = true
+			}
+			continue
+		}
+
+		if idx >= len(row) || row[idx] == "" {
+			continue
+		}
+
+		var err error
+		switch expected {
+		case kindNumber:
+			_, err = strconv.ParseFloat(row[idx], 64)
+		case kindBoolean:
+			_, err = strconv.ParseBool(row[idx])
+		}
+
+		if err != nil {
+			changed[name] = fmt.Sprintf("%s: expected %s, got %q", name, expected, row[idx])
+		}
+	}
+
+	sample := strings.Join(header, ",")
+	if row != nil {
+		sample += "\n" + strings.Join(row, ",")
+	}
+
+	return schema.drift(unknown, seen, changed, []byte(sample))
+}
+
+// drift builds the drift report of a check; nil if nothing changed
+func (schema *Schema) drift(unknown, seen map[string]bool, changed map[string]string, payload []byte) *data.SchemaDrift {
+	drift := &data.SchemaDrift{
+		Source: schema.Source,
+	}
+
+	for name := range unknown {
+		drift.UnknownFields = append(drift.UnknownFields, name)
+	}
+
+	for name := range schema.fields {
+		if !seen[name] && !schema.optional[name] {
+			drift.MissingFields = append(drift.MissingFields, name)
+		}
+	}
+
+	for _, change := range changed {
+		drift.TypeChanges = append(drift.TypeChanges, change)
+	}
+
+	if len(drift.UnknownFields) == 0 && len(drift.MissingFields) == 0 && len(drift.TypeChanges) == 0 {
+		return nil
+	}
+
+	slices.Sort(drift.UnknownFields)
+	slices.Sort(drift.MissingFields)
+	slices.Sort(drift.TypeChanges)
+
+	if len(payload) > schemaSampleSize {
+		payload = payload[:schemaSampleSize]
+	}
+	drift.Sample = string(payload)
+
+	return drift
+}
+
+// SchemaGuard collects the schema drift found during a run. Each source is
+// reported once per run so that a changed format does not flood the log.
+type SchemaGuard struct {
+	mu    sync.Mutex
+	drift []*data.SchemaDrift
+	seen  map[string]bool
+}
+
+// NewSchemaGuard creates a guard for a single run
+func NewSchemaGuard() *SchemaGuard {
+	return &SchemaGuard{
+		seen: make(map[string]bool),
+	}
+}
+
+// CheckJSON compares payload with schema and records any drift. ErrSchemaDrift
+// is returned if fields are missing or have changed type, in which case
+// decoding the payload would produce zero values; unknown fields are only
+// recorded.
+func (guard *SchemaGuard) CheckJSON(ctx context.Context, schema *Schema, payload []byte) error {
+	drift := schema.CheckJSON(payload)
+	guard.record(ctx, drift)
+	return breakingDrift(drift)
+}
+
+// breakingDrift returns ErrSchemaDrift if drift would corrupt decoded values
+func breakingDrift(drift *data.SchemaDrift) error {
+	if drift == nil || (len(drift.MissingFields) == 0 && len(drift.TypeChanges) == 0) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrSchemaDrift, drift.Source)
+}
+
+// CSVDecoder returns a gocsv decoder for reader that checks the header and the
+// first row against schema before they are decoded. Decoding stops with
+// ErrSchemaDrift if columns are missing or have changed type.
+func (guard *SchemaGuard) CSVDecoder(ctx context.Context, schema *Schema, reader *csv.Reader) gocsv.SimpleDecoder {
+	return &checkedCSVDecoder{
+		SimpleDecoder: gocsv.NewSimpleDecoderFromCSVReader(reader),
+		ctx:           ctx,
+		guard:         guard,
+		schema:        schema,
+	}
+}
+
+// Drift returns the drift recorded so far
+func (guard *SchemaGuard) Drift() []*data.SchemaDrift {
+	guard.mu.Lock()
+	defer guard.mu.Unlock()
+	return slices.Clone(guard.drift)
+}
+
+func (guard *SchemaGuard) record(ctx context.Context, drift *data.SchemaDrift) {
+	if drift == nil {
+		return
+	}
+
+	guard.mu.Lock()
+	defer guard.mu.Unlock()
+
+	if guard.seen[drift.Source] {
+		return
+	}
+
+	guard.seen[drift.Source] = true
+	guard.drift = append(guard.drift, drift)
+
+	zerolog.Ctx(ctx).Warn().Str("Source", drift.Source).Strs("UnknownFields", drift.UnknownFields).
+		Strs("MissingFields", drift.MissingFields).Strs("TypeChanges", drift.TypeChanges).Str("Sample", drift.Sample).
+		Msg("provider response does not match the expected schema")
+}
+
+// checkedCSVDecoder checks the header and first row read by a gocsv decoder
+type checkedCSVDecoder struct {
+	gocsv.SimpleDecoder
+
+	ctx     context.Context
+	guard   *SchemaGuard
+	schema  *Schema
+	header  []string
+	checked bool
+}
+
+func (decoder *checkedCSVDecoder) GetCSVRow() ([]string, error) {
+	row, err := decoder.SimpleDecoder.GetCSVRow()
+	if decoder.checked {
+		return row, err
+	}
+
+	if decoder.header == nil {
+		if err == nil {
+			// the reader may reuse the record's backing array
+			decoder.header = slices.Clone(row)
+		}
+		return row, err
+	}
+
+	decoder.checked = true
+	if err != nil && !errors.Is(err, io.EOF) {
+		return row, err
+	}
+
+	drift := decoder.schema.CheckCSV(decoder.header, row)
+	decoder.guard.record(decoder.ctx, drift)
+	if driftErr := breakingDrift(drift); driftErr != nil {
+		return nil, driftErr
+	}
+
+	return row, err
+}
@@ -0,0 +1,76 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider_test
+
+import (
+	"context"
+	"encoding/csv"
+	"strings"
+
+	"github.com/gocarina/gocsv"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/penny-vault/pvdata/provider"
+)
+
+type quote struct {
+	Date   string  `json:"date" csv:"date"`
+	Close  float64 `json:"close" csv:"close"`
+	Volume float64 `json:"volume,omitempty" csv:"volume,omitempty"`
+}
+
+var _ = Describe("Schema", func() {
+	var schema *provider.Schema
+
+	BeforeEach(func() {
+		schema = provider.NewSchema("test quotes", "json", quote{}).Ignore("adjClose")
+	})
+
+	It("accepts responses that match", func() {
+		Expect(schema.CheckJSON([]byte(`[{"date": "2024-03-08", "close": 10.5, "adjClose": 10.1}]`))).To(BeNil())
+	})
+
+	It("does not require optional fields", func() {
+		Expect(schema.CheckJSON([]byte(`{"date": "2024-03-08", "close": 10.5}`))).To(BeNil())
+	})
+
+	It("reports unknown, missing, and changed fields", func() {
+		drift := schema.CheckJSON([]byte(`[{"date": "2024-03-08", "closePrice": 10.5, "volume": "1,000"}]`))
+		Expect(drift).NotTo(BeNil())
+		Expect(drift.Source).To(Equal("test quotes"))
+		Expect(drift.UnknownFields).To(Equal([]string{"closePrice"}))
+		Expect(drift.MissingFields).To(Equal([]string{"close"}))
+		Expect(drift.TypeChanges).To(Equal([]string{"volume: expected number, got string"}))
+		Expect(drift.Sample).To(ContainSubstring("closePrice"))
+	})
+
+	It("stops decoding csv files that are missing columns", func() {
+		csvSchema := provider.NewSchema("test quotes", "csv", quote{})
+		guard := provider.NewSchemaGuard()
+		reader := csv.NewReader(strings.NewReader("date,price\n2024-03-08,10.5\n"))
+
+		decoded := 0
+		err := gocsv.UnmarshalDecoderToCallback(guard.CSVDecoder(context.Background(), csvSchema, reader), func(q quote) {
+			decoded++
+		})
+
+		Expect(err).To(MatchError(provider.ErrSchemaDrift))
+		Expect(decoded).To(Equal(0))
+		Expect(guard.Drift()).To(HaveLen(1))
+		Expect(guard.Drift()[0].MissingFields).To(Equal([]string{"close"}))
+		Expect(guard.Drift()[0].UnknownFields).To(Equal([]string{"price"}))
+	})
+})
@@ -20,16 +20,20 @@ import (
 	"github.com/penny-vault/pvdata/data"
 )
 
+// nasdaqDataLinkDailyQuota is the number of calls per day Nasdaq Data Link
+// allows an authenticated user
+const nasdaqDataLinkDailyQuota = 50000
+
 type Sharadar struct{}
 
 func (sharadar *Sharadar) Name() string {
 	return "Sharadar"
 }
 
-func (sharadar *Sharadar) ConfigDescription() map[string]string {
-	return map[string]string{
-		"apiKey":    "Enter your Nasdaq Data Link API key:",
-		"rateLimit": "What is the maximum number of requests per minute?",
+func (sharadar *Sharadar) ConfigSchema() ConfigSchema {
+	return ConfigSchema{
+		{Name: "apiKey", Prompt: "Enter your Nasdaq Data Link API key:", Type: ConfigString, Required: true, Secret: true},
+		{Name: "rateLimit", Prompt: "What is the maximum number of requests per minute?", Type: ConfigInt},
 	}
 }
 
@@ -43,9 +47,17 @@ func (sharadar *Sharadar) Datasets() map[string]Dataset {
 			Name:        "Fundamentals",
 			Description: "Download stock fundamentals.",
 			DataTypes:   []*data.DataType{data.DataTypes[data.FundamentalsKey]},
+			DependsOn:   []string{data.AssetKey},
 			DateRange: func() (time.Time, time.Time) {
 				return time.Date(2007, 1, 1, 0, 0, 0, 0, time.UTC), time.Now().UTC()
 			},
+			Capabilities: Capabilities{
+				AssetTypes:  []data.AssetType{data.CommonStock, data.ADRC},
+				Geographies: []string{"US"},
+				Granularity: GranularityQuarterly,
+				Backfill:    true,
+				Cost:        Cost{PerAsset: 1, DailyQuota: nasdaqDataLinkDailyQuota},
+			},
 			Fetch: downloadAllSharadarFundamentals,
 		},
 
@@ -53,9 +65,16 @@ func (sharadar *Sharadar) Datasets() map[string]Dataset {
 			Name:        "Metrics",
 			Description: "Download daily stock metrics.",
 			DataTypes:   []*data.DataType{data.DataTypes[data.MetricKey]},
+			DependsOn:   []string{data.AssetKey},
 			DateRange: func() (time.Time, time.Time) {
 				return time.Date(2007, 1, 1, 0, 0, 0, 0, time.UTC), time.Now().UTC()
 			},
+			Capabilities: Capabilities{
+				AssetTypes:  []data.AssetType{data.CommonStock, data.ADRC},
+				Geographies: []string{"US"},
+				Granularity: GranularityDaily,
+				Cost:        Cost{PerRun: 10, DailyQuota: nasdaqDataLinkDailyQuota},
+			},
 			Fetch: downloadAllSharadarMetrics,
 		},
 
@@ -66,6 +85,13 @@ func (sharadar *Sharadar) Datasets() map[string]Dataset {
 			DateRange: func() (time.Time, time.Time) {
 				return time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC), time.Now().UTC()
 			},
+			Capabilities: Capabilities{
+				AssetTypes:      []data.AssetType{data.CommonStock, data.ADRC, data.ETF, data.ETN, data.CEF},
+				Geographies:     []string{"US"},
+				Granularity:     GranularityReference,
+				RefreshInterval: 24 * time.Hour,
+				Cost:            Cost{PerRun: 10, DailyQuota: nasdaqDataLinkDailyQuota},
+			},
 			Fetch: downloadAllSharadarTickers,
 		},
 	}
@@ -18,7 +18,6 @@ import (
 	"context"
 	"time"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/penny-vault/pvdata/data"
 	"github.com/penny-vault/pvdata/library"
 	"github.com/rs/zerolog"
@@ -158,6 +157,12 @@ func downloadAllSharadarFundamentals(ctx context.Context, subscription *library.
 
 	cursor := ""
 	for {
+		if err := library.Checkpoint(ctx); err != nil {
+			log.Info().Err(err).Msg("stopping sharadar fundamentals download")
+			runSummary.Status = data.RunCanceled
+			return
+		}
+
 		log.Info().Str("cursor", cursor).Msg("Fetching next page sharadar fundamentals")
 		cursor = downloadSharadarFundamentals(ctx, subscription, cursor, out)
 		if cursor == "" {
@@ -175,16 +180,15 @@ func downloadSharadarFundamentals(ctx context.Context, subscription *library.Sub
 		log.Panic().Msg("could not acquire database connection")
 	}
 
-	defer conn.Release()
-
 	assets := data.ActiveAssets(ctx, conn)
+	conn.Release()
 	figiMap := make(map[string]string, len(assets))
 	for _, asset := range assets {
 		figiMap[asset.Ticker] = asset.CompositeFigi
 	}
 
 	url := "https://data.nasdaq.com/api/v3/datatables/SHARADAR/SF1"
-	client := resty.New().SetQueryParam("api_key", subscription.Config["apiKey"])
+	client := newClient(ctx).SetQueryParam("api_key", subscription.Config["apiKey"])
 
 	if cursor != "" {
 		client.SetQueryParam("qopts.cursor_id", cursor)
@@ -18,7 +18,6 @@ import (
 	"context"
 	"time"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/penny-vault/pvdata/data"
 	"github.com/penny-vault/pvdata/library"
 	"github.com/rs/zerolog"
@@ -69,16 +68,15 @@ func downloadAllSharadarMetrics(ctx context.Context, subscription *library.Subsc
 		log.Panic().Msg("could not acquire database connection")
 	}
 
-	defer conn.Release()
-
 	assets := data.ActiveAssets(ctx, conn)
+	conn.Release()
 	figiMap := make(map[string]string, len(assets))
 	for _, asset := range assets {
 		figiMap[asset.Ticker] = asset.CompositeFigi
 	}
 
 	// get a map of sp500 constituents
-	client := resty.New().SetQueryParam("api_key", subscription.Config["apiKey"])
+	client := newClient(ctx).SetQueryParam("api_key", subscription.Config["apiKey"])
 	sp500Url := "https://data.nasdaq.com/api/v3/datatables/SHARADAR/SP500"
 	resp, err := client.R().SetQueryParam("action", "current").Get(sp500Url)
 	if err != nil {
@@ -108,6 +106,12 @@ func downloadAllSharadarMetrics(ctx context.Context, subscription *library.Subsc
 
 	cursor := ""
 	for {
+		if err := library.Checkpoint(ctx); err != nil {
+			log.Info().Err(err).Msg("stopping sharadar metrics download")
+			runSummary.Status = data.RunCanceled
+			return
+		}
+
 		log.Info().Str("cursor", cursor).Msg("Fetching next page sharadar tickers")
 		cursor = downloadSharadarMetrics(ctx, subscription, cursor, out, currDate, sp500Map, figiMap)
 		if cursor == "" {
@@ -126,7 +130,7 @@ func downloadSharadarMetrics(ctx context.Context, subscription *library.Subscrip
 		return ""
 	}
 
-	client := resty.New().SetQueryParam("api_key", subscription.Config["apiKey"])
+	client := newClient(ctx).SetQueryParam("api_key", subscription.Config["apiKey"])
 
 	// download daily metrics
 	tickerUrl := "https://data.nasdaq.com/api/v3/datatables/SHARADAR/DAILY"
@@ -21,7 +21,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/penny-vault/pvdata/data"
 	"github.com/penny-vault/pvdata/figi"
 	"github.com/penny-vault/pvdata/library"
@@ -78,6 +77,12 @@ func downloadAllSharadarTickers(ctx context.Context, subscription *library.Subsc
 
 	cursor := ""
 	for {
+		if err := library.Checkpoint(ctx); err != nil {
+			log.Info().Err(err).Msg("stopping sharadar tickers download")
+			runSummary.Status = data.RunCanceled
+			return
+		}
+
 		log.Info().Str("cursor", cursor).Msg("Fetching next page sharadar tickers")
 		cursor = downloadSharadarTickers(ctx, subscription, cursor, out)
 		if cursor == "" {
@@ -100,7 +105,7 @@ func downloadSharadarTickers(ctx context.Context, subscription *library.Subscrip
 	}
 
 	tickerUrl := "https://data.nasdaq.com/api/v3/datatables/SHARADAR/TICKERS"
-	client := resty.New().SetQueryParam("api_key", subscription.Config["apiKey"])
+	client := newClient(ctx).SetQueryParam("api_key", subscription.Config["apiKey"])
 
 	if cursor != "" {
 		client.SetQueryParam("qopts.cursor_id", cursor)
@@ -205,7 +210,8 @@ func downloadSharadarTickers(ctx context.Context, subscription *library.Subscrip
 	}
 
 	// enrich assets
-	figi.Enrich(enrichAssets...)
+	figi.Enrich(ctx, enrichAssets...)
+	allAssets = subscription.Library.ResolveAssets(ctx, allAssets)
 
 	for _, asset := range allAssets {
 		out <- &data.Observation{
@@ -239,7 +245,7 @@ func (ticker *sharadarTicker) ToAsset() *data.Asset {
 	}
 
 	// fix ticker
-	asset.Ticker = strings.ReplaceAll(asset.Ticker, ".", "/")
+	asset.Ticker = data.NormalizeTicker("sharadar", asset.Ticker)
 
 	// cusips
 	ticker.CUSIPs = strings.TrimSpace(ticker.CUSIPs)
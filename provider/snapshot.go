@@ -0,0 +1,245 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrUnknownSnapshotMode = errors.New("unknown snapshot mode")
+	ErrSnapshotNotFound    = errors.New("no snapshot recorded for request")
+)
+
+// SnapshotMode selects whether provider HTTP traffic is recorded or replayed
+type SnapshotMode string
+
+const (
+	// SnapshotRecord sends requests to the provider and saves each response
+	SnapshotRecord SnapshotMode = "record"
+
+	// SnapshotReplay serves previously recorded responses without contacting the provider
+	SnapshotReplay SnapshotMode = "replay"
+)
+
+// snapshotRedactedParams are query parameters that hold credentials; they are
+// removed before a request is saved or matched against a snapshot
+var snapshotRedactedParams = map[string]bool{
+	"apikey":  true,
+	"api_key": true,
+	"token":   true,
+}
+
+type snapshotKey struct{}
+
+// Snapshot records provider HTTP responses to fixture files in Dir or serves
+// them back. Fixtures are JSON documents stored in a directory per host.
+type Snapshot struct {
+	Dir  string
+	Mode SnapshotMode
+}
+
+// snapshotFixture is a recorded request and its response
+type snapshotFixture struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	StatusCode   int         `json:"statusCode"`
+	Header       http.Header `json:"header"`
+	Body         string      `json:"body"`
+	BodyEncoding string      `json:"bodyEncoding,omitempty"`
+}
+
+// NewSnapshot validates mode and, when recording, creates dir
+func NewSnapshot(dir string, mode SnapshotMode) (*Snapshot, error) {
+	switch mode {
+	case SnapshotRecord:
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	case SnapshotReplay:
+		if _, err := os.Stat(dir); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSnapshotMode, mode)
+	}
+
+	return &Snapshot{
+		Dir:  dir,
+		Mode: mode,
+	}, nil
+}
+
+// WithSnapshot returns a context that causes provider HTTP clients to record
+// or replay their traffic with snapshot
+func WithSnapshot(ctx context.Context, snapshot *Snapshot) context.Context {
+	return context.WithValue(ctx, snapshotKey{}, snapshot)
+}
+
+// redactedURL returns the request URL with credentials removed and the query
+// sorted so that equivalent requests produce the same fixture
+func redactedURL(u *url.URL) string {
+	redacted := *u
+	query := redacted.Query()
+	for param := range query {
+		if snapshotRedactedParams[strings.ToLower(param)] {
+			query.Del(param)
+		}
+	}
+
+	redacted.RawQuery = query.Encode()
+	redacted.User = nil
+
+	return redacted.String()
+}
+
+// path returns the fixture file used for a request
+func (snapshot *Snapshot) path(req *http.Request, reqURL string) (string, error) {
+	hash := sha256.New()
+	hash.Write([]byte(req.Method))
+	hash.Write([]byte(reqURL))
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+
+		if body != nil {
+			defer body.Close()
+
+			if _, err := io.Copy(hash, body); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	name := hex.EncodeToString(hash.Sum(nil))[:16] + ".json"
+	return filepath.Join(snapshot.Dir, req.URL.Hostname(), name), nil
+}
+
+// snapshotTransport records or replays the responses of next
+type snapshotTransport struct {
+	snapshot *Snapshot
+	next     http.RoundTripper
+}
+
+func (transport *snapshotTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqURL := redactedURL(req.URL)
+
+	fn, err := transport.snapshot.path(req, reqURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if transport.snapshot.Mode == SnapshotReplay {
+		return replaySnapshot(req, fn, reqURL)
+	}
+
+	resp, err := transport.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	fixture := snapshotFixture{
+		Method:     req.Method,
+		URL:        reqURL,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       string(body),
+	}
+
+	fixture.Header.Del("Set-Cookie")
+
+	if !utf8.Valid(body) {
+		fixture.Body = base64.StdEncoding.EncodeToString(body)
+		fixture.BodyEncoding = "base64"
+	}
+
+	if err := writeSnapshot(fn, &fixture); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func writeSnapshot(fn string, fixture *snapshotFixture) error {
+	if err := os.MkdirAll(filepath.Dir(fn), 0o755); err != nil {
+		return err
+	}
+
+	buf, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(fn, buf, 0o644)
+}
+
+func replaySnapshot(req *http.Request, fn, reqURL string) (*http.Response, error) {
+	buf, err := os.ReadFile(fn)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s %s", ErrSnapshotNotFound, req.Method, reqURL)
+	} else if err != nil {
+		return nil, err
+	}
+
+	var fixture snapshotFixture
+	if err := json.Unmarshal(buf, &fixture); err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	body := []byte(fixture.Body)
+	if fixture.BodyEncoding == "base64" {
+		body, err = base64.StdEncoding.DecodeString(fixture.Body)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fn, err)
+		}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fixture.StatusCode, http.StatusText(fixture.StatusCode)),
+		StatusCode:    fixture.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        fixture.Header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/penny-vault/pvdata/provider"
+)
+
+// fetchAll runs the dataset's fetch function to completion and returns the
+// observations it produced along with its run summary
+func fetchAll(ctx context.Context, dataset provider.Dataset, subscription *library.Subscription) ([]*data.Observation, data.RunSummary) {
+	out := make(chan *data.Observation)
+	exit := make(chan data.RunSummary, 1)
+
+	go func() {
+		dataset.Fetch(ctx, subscription, out, exit)
+		close(out)
+	}()
+
+	observations := []*data.Observation{}
+	for obs := range out {
+		observations = append(observations, obs)
+	}
+
+	return observations, <-exit
+}
+
+var _ = Describe("Snapshot", func() {
+	var ctx context.Context
+
+	BeforeEach(func() {
+		snapshot, err := provider.NewSnapshot("testdata/snapshots", provider.SnapshotReplay)
+		Expect(err).NotTo(HaveOccurred())
+
+		ctx = provider.WithSnapshot(context.Background(), snapshot)
+	})
+
+	It("rejects unknown modes", func() {
+		_, err := provider.NewSnapshot("testdata/snapshots", "rewind")
+		Expect(err).To(MatchError(provider.ErrUnknownSnapshotMode))
+	})
+
+	It("replays recorded responses to fetch functions", func() {
+		dataset := provider.Map["french"].Datasets()["Factors"]
+		observations, summary := fetchAll(ctx, dataset, &library.Subscription{
+			Name:   "French",
+			Config: map[string]string{"files": "F-F_Research_Data_Factors_daily"},
+		})
+
+		Expect(summary.Status).To(Equal(data.RunSuccess))
+		Expect(observations).To(HaveLen(11))
+		Expect(summary.NumObservations).To(Equal(11))
+
+		Expect(observations[0].EconomicIndicator.Series).To(Equal("F-F_Research_Data_Factors_daily/Mkt-RF"))
+		Expect(observations[0].EconomicIndicator.EventDate.Format("2006-01-02")).To(Equal("2024-01-02"))
+		Expect(observations[0].EconomicIndicator.Value).To(BeNumerically("~", -0.0071, 1e-9))
+	})
+
+	It("fails requests that were not recorded", func() {
+		dataset := provider.Map["french"].Datasets()["Factors"]
+		observations, summary := fetchAll(ctx, dataset, &library.Subscription{
+			Name:   "French",
+			Config: map[string]string{"files": "F-F_Momentum_Factor_daily"},
+		})
+
+		Expect(summary.Status).To(Equal(data.RunFailed))
+		Expect(observations).To(BeEmpty())
+	})
+})
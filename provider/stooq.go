@@ -0,0 +1,243 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog"
+)
+
+// stooqMarkets maps exchanges to the suffix stooq appends to tickers
+var stooqMarkets = map[data.Exchange]string{
+	data.NasdaqExchange:   "us",
+	data.NYSEExchange:     "us",
+	data.NYSEMktExchange:  "us",
+	data.ARCAExchange:     "us",
+	data.BATSExchange:     "us",
+	data.NMFQSExchange:    "us",
+	data.LSEExchange:      "uk",
+	data.XetraExchange:    "de",
+	data.TokyoExchange:    "jp",
+	data.HongKongExchange: "hk",
+}
+
+type Stooq struct{}
+
+func (stooq *Stooq) Name() string {
+	return "Stooq"
+}
+
+func (stooq *Stooq) ConfigSchema() ConfigSchema {
+	return append(ConfigSchema{
+		{Name: "rateLimit", Prompt: "What is the maximum number of requests per minute?", Type: ConfigInt, Default: "60"},
+	}, delistedConfig...)
+}
+
+func (stooq *Stooq) Description() string {
+	return `Stooq publishes free daily quotes for stocks, ETFs, indices, and currencies traded on US and major international markets.`
+}
+
+func (stooq *Stooq) Datasets() map[string]Dataset {
+	return map[string]Dataset{
+		"EOD": {
+			Name:        "EOD",
+			Description: "Get split and dividend adjusted end-of-day prices for active assets.",
+			DataTypes:   []*data.DataType{data.DataTypes[data.EODKey]},
+			DependsOn:   []string{data.AssetKey},
+			DateRange: func() (time.Time, time.Time) {
+				return time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC), time.Now().UTC()
+			},
+			Capabilities: Capabilities{
+				AssetTypes:  []data.AssetType{data.CommonStock, data.ETF},
+				Geographies: []string{"US", "GB", "DE", "JP", "HK"},
+				Granularity: GranularityDaily,
+				Cost:        Cost{PerAsset: 1},
+			},
+			Fetch: downloadStooqEODQuotes,
+		},
+	}
+}
+
+// stooqSymbol returns the symbol stooq uses for the asset; ok is false if
+// stooq does not carry the asset's market
+func stooqSymbol(asset *data.Asset) (symbol string, ok bool) {
+	market, ok := stooqMarkets[asset.PrimaryExchange]
+	if !ok {
+		return "", false
+	}
+
+	ticker := data.DenormalizeTicker("stooq", asset.Ticker)
+	return fmt.Sprintf("%s.%s", ticker, market), true
+}
+
+func downloadStooqEODQuotes(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
+	logger := zerolog.Ctx(ctx)
+
+	runSummary := data.RunSummary{
+		StartTime:        time.Now(),
+		SubscriptionID:   subscription.ID,
+		SubscriptionName: subscription.Name,
+	}
+
+	numObs := 0
+
+	defer func() {
+		runSummary.EndTime = time.Now()
+		runSummary.NumObservations = numObs
+		exitNotification <- runSummary
+	}()
+
+	rateLimit, err := strconv.Atoi(subscription.Config["rateLimit"])
+	if err != nil || rateLimit <= 0 {
+		rateLimit = 60
+	}
+
+	client := newClient(ctx)
+	limiter := rateLimiter(subscription, rateLimit)
+
+	conn, err := subscription.Library.Pool.Acquire(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("could not acquire database connection")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	// lookback 14 days in the past
+	targets, err := eodTargets(ctx, subscription, conn, 14)
+	conn.Release()
+	if err != nil {
+		logger.Error().Err(err).Msg("could not list assets to download EOD quotes for")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	logger.Debug().Int("NumAssets", len(targets)).Msg("downloading EOD quotes from stooq")
+
+	today := data.Today(data.NYSEExchange)
+	for _, target := range targets {
+		asset := target.Asset
+		end := target.End
+		if end.IsZero() {
+			end = today
+		}
+
+		symbol, ok := stooqSymbol(asset)
+		if !ok {
+			continue
+		}
+
+		if err := library.Checkpoint(ctx); err != nil {
+			logger.Info().Err(err).Msg("stopping stooq EOD download")
+			runSummary.Status = data.RunCanceled
+			return
+		}
+
+		if err := limiter.Wait(ctx); err != nil {
+			logger.Info().Err(err).Msg("stopping stooq EOD download")
+			runSummary.Status = data.RunCanceled
+			return
+		}
+
+		resp, err := client.R().
+			SetQueryParam("s", symbol).
+			SetQueryParam("i", "d").
+			SetQueryParam("d1", target.Start.Format("20060102")).
+			SetQueryParam("d2", end.Format("20060102")).
+			Get("https://stooq.com/q/d/l/")
+		if err != nil {
+			logger.Error().Err(err).Msg("resty returned an error when querying eod prices")
+			runSummary.Status = data.RunFailed
+			return
+		}
+
+		if resp.StatusCode() >= 300 {
+			logger.Error().Int("StatusCode", resp.StatusCode()).Str("Symbol", symbol).Msg("stooq returned an invalid HTTP response")
+			continue
+		}
+
+		body := resp.Body()
+		if !bytes.HasPrefix(body, []byte("Date,")) {
+			// stooq responds with a plain text message when there is no data or
+			// the daily request limit has been exceeded
+			msg := strings.TrimSpace(string(body))
+			if strings.Contains(strings.ToLower(msg), "limit") {
+				logger.Error().Str("Response", msg).Msg("stooq request limit exceeded")
+				runSummary.Status = data.RunFailed
+				return
+			}
+
+			logger.Debug().Str("Symbol", symbol).Str("Response", msg).Msg("stooq returned no quotes")
+			continue
+		}
+
+		rows, err := readCSV(bytes.NewReader(body))
+		if err != nil {
+			logger.Error().Err(err).Str("Symbol", symbol).Msg("could not parse stooq response")
+			continue
+		}
+
+		for _, row := range rows {
+			quoteDate, err := row.Time("Date", "2006-01-02")
+			if err != nil {
+				logger.Error().Err(err).Str("stooqDate", row.String("Date")).Msg("could not parse date from stooq quote")
+				continue
+			}
+
+			eodQuote := &data.Eod{
+				Date:          asset.PrimaryExchange.CloseTime(quoteDate),
+				Ticker:        asset.Ticker,
+				CompositeFigi: asset.CompositeFigi,
+				Split:         1,
+				Currency:      asset.PriceCurrency,
+			}
+
+			for column, dest := range map[string]*float64{
+				"Open":   &eodQuote.Open,
+				"High":   &eodQuote.High,
+				"Low":    &eodQuote.Low,
+				"Close":  &eodQuote.Close,
+				"Volume": &eodQuote.Volume,
+			} {
+				if *dest, err = row.Float(column); err != nil {
+					break
+				}
+			}
+
+			if err != nil {
+				logger.Error().Err(err).Str("Symbol", symbol).Msg("could not parse stooq quote")
+				continue
+			}
+
+			out <- &data.Observation{
+				EodQuote:         eodQuote,
+				ObservationDate:  time.Now(),
+				SubscriptionID:   subscription.ID,
+				SubscriptionName: subscription.Name,
+			}
+
+			numObs++
+		}
+	}
+
+	runSummary.Status = data.RunSuccess
+}
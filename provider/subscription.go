@@ -39,6 +39,10 @@ func NewSubscription(providerName, datasetName string, config map[string]string,
 		return nil, ErrDatasetNotFound
 	}
 
+	if err := providerObj.ConfigSchema().Validate(config); err != nil {
+		return nil, err
+	}
+
 	dataTypes := datasetObj.DataTypes
 
 	subscription := &library.Subscription{
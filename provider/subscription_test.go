@@ -0,0 +1,88 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/penny-vault/pvdata/db/dbtest"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/penny-vault/pvdata/provider"
+)
+
+var _ = Describe("Subscriptions", func() {
+	var (
+		ctx       context.Context
+		myLibrary *library.Library
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		var err error
+		myLibrary, err = dbtest.Library(ctx)
+		if errors.Is(err, dbtest.ErrNotConfigured) {
+			Skip("no test database configured")
+		}
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(myLibrary.Close)
+	})
+
+	DescribeTable("saves subscriptions to",
+		func(providerName, datasetName string) {
+			// fill required settings with placeholders; nothing is fetched
+			config := make(map[string]string)
+			for _, field := range provider.Map[providerName].ConfigSchema() {
+				config[field.Name] = field.Default
+				if config[field.Name] == "" && field.Required {
+					config[field.Name] = "test"
+					if field.Type == provider.ConfigInt {
+						config[field.Name] = "1"
+					}
+				}
+			}
+
+			subscription, err := provider.NewSubscription(providerName, datasetName, config, myLibrary)
+			Expect(err).NotTo(HaveOccurred())
+			subscription.Dataset = datasetName
+
+			Expect(subscription.Save(ctx)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(subscription.Delete(context.Background())).To(Succeed())
+			})
+
+			saved, err := myLibrary.SubscriptionFromID(ctx, subscription.ID.String())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(saved.DataTypes).To(Equal(subscription.DataTypes))
+		},
+		Entry("french factors", "french", "Factors"),
+		Entry("fred economic indicators", "fred", "Economic Indicators"),
+		Entry("zacks screener data", "zacks", "Zacks Screener Data"),
+		Entry("finnhub earnings", "finnhub", "Earnings Calendar"),
+		Entry("finnhub news", "finnhub", "Company News"),
+		Entry("finnhub peers", "finnhub", "Peers"),
+		Entry("coinbase daily candles", "coinbase", "Daily Candles"),
+		Entry("kraken daily candles", "kraken", "Daily Candles"),
+		Entry("finnhub etf holdings", "finnhub", "ETF Holdings"),
+		Entry("polygon dividends", "polygon", "Dividends"),
+		Entry("finnhub ipo calendar", "finnhub", "IPO Calendar"),
+		Entry("polygon close validation", "polygon", "Close Validation"),
+		Entry("resampled bars", "resample", "Bars"),
+	)
+})
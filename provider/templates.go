@@ -0,0 +1,72 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package provider
+
+import (
+	"github.com/penny-vault/pvdata/library"
+)
+
+// Recommended schedules; times are in the timezone pvdata runs in
+const (
+	scheduleAssetsDaily        = "0 5 * * 1-5"
+	scheduleEODNightly         = "30 18 * * 1-5"
+	scheduleMetricsNightly     = "0 20 * * 1-5"
+	scheduleFundamentalsWeekly = "0 7 * * 6"
+	scheduleHolidaysWeekly     = "0 6 * * 6"
+)
+
+var defaultTemplates = map[string][]*library.SubscriptionTemplate{
+	"tiingo": {
+		{Name: "Tiingo Assets", Dataset: "Stock Tickers", Schedule: scheduleAssetsDaily, Config: map[string]string{"rateLimit": "50"}},
+		{Name: "Tiingo EOD", Dataset: "EOD", Schedule: scheduleEODNightly, Config: map[string]string{"rateLimit": "50"}},
+	},
+	"iex": {
+		{Name: "IEX Cloud Assets", Dataset: "Stock Tickers", Schedule: scheduleAssetsDaily},
+		{Name: "IEX Cloud EOD", Dataset: "EOD", Schedule: scheduleEODNightly},
+	},
+	// EOD quotes take three requests per asset, more than the free plan's
+	// 5 requests per minute allows, and are left out of the templates
+	"polygon": {
+		{Name: "Polygon Assets", Dataset: "Stock Tickers", Schedule: scheduleAssetsDaily, Config: map[string]string{"rateLimit": "5"}},
+		{Name: "Polygon Market Holidays", Dataset: "Market Holidays", Schedule: scheduleHolidaysWeekly, Config: map[string]string{"rateLimit": "5"}},
+	},
+	"sharadar": {
+		{Name: "Sharadar Assets", Dataset: "Stock Tickers", Schedule: scheduleAssetsDaily, Config: map[string]string{"rateLimit": "300"}},
+		{Name: "Sharadar Metrics", Dataset: "Metrics", Schedule: scheduleMetricsNightly, Config: map[string]string{"rateLimit": "300"}},
+		{Name: "Sharadar Fundamentals", Dataset: "Fundamentals", Schedule: scheduleFundamentalsWeekly, Config: map[string]string{"rateLimit": "300"}},
+	},
+	"finnhub": {
+		{Name: "Finnhub Earnings Calendar", Dataset: "Earnings Calendar", Schedule: scheduleEODNightly, Config: map[string]string{"rateLimit": "60"}},
+		{Name: "Finnhub Peers", Dataset: "Peers", Schedule: scheduleFundamentalsWeekly, Config: map[string]string{"rateLimit": "60"}},
+	},
+	"fred": {
+		{Name: "FRED Economic Indicators", Dataset: "Economic Indicators", Schedule: scheduleEODNightly,
+			Config: map[string]string{"seriesIds": "DTB3,DGS10,UNRATE,CPIAUCSL"}},
+	},
+}
+
+func init() {
+	// fill in the data types of each template from its dataset
+	for providerName, templates := range defaultTemplates {
+		datasets := Map[providerName].Datasets()
+		for _, template := range templates {
+			for _, dataType := range datasets[template.Dataset].DataTypes {
+				template.DataTypes = append(template.DataTypes, dataType.Name)
+			}
+		}
+
+		library.RegisterTemplates(providerName, templates...)
+	}
+}
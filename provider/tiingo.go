@@ -16,46 +16,92 @@ package provider
 
 import (
 	"archive/zip"
-	"bytes"
 	"context"
+	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/gocarina/gocsv"
 	"github.com/penny-vault/pvdata/data"
 	"github.com/penny-vault/pvdata/figi"
 	"github.com/penny-vault/pvdata/library"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
-	"golang.org/x/time/rate"
 )
 
+var (
+	ErrTiingoStatus = errors.New("tiingo returned an error status")
+
+	// tiingoShareClassSuffix and tiingoFifthLetterSuffix match tickers of
+	// warrants, preferred shares, and units
+	tiingoShareClassSuffix  = regexp.MustCompile(`^[A-Za-z0-9]+-[WPU]{1}.*$`)
+	tiingoFifthLetterSuffix = regexp.MustCompile(`^[A-Za-z0-9]{4}[WPU]{1}.*$`)
+)
+
+// tiingoAdjustedFields are adjusted prices in tiingo's EOD response; the
+// library calculates adjusted prices itself
+var tiingoAdjustedFields = []string{"adjOpen", "adjHigh", "adjLow", "adjClose", "adjVolume"}
+
+// Schemas of the tiingo responses
+var (
+	tiingoEodSchema   = NewSchema("tiingo eod", "json", tiingoEod{}).Optional("ticker", "compositeFigi").Ignore(tiingoAdjustedFields...)
+	tiingoFXSchema    = NewSchema("tiingo fx", "json", tiingoFX{})
+	tiingoAssetSchema = NewSchema("tiingo supported tickers", "csv", tiingoAsset{})
+)
+
+// tiingoFXHistoryStart is the first date tiingo publishes fx rates for; the
+// full history is downloaded for currencies without stored rates
+var tiingoFXHistoryStart = data.NewMarketDate(1990, time.January, 1)
+
+// tiingoDailyQuota is the number of requests per day allowed on Tiingo's free plan
+const tiingoDailyQuota = 1000
+
+// tiingoDefaultExchanges are the US exchanges assets are listed on when the
+// exchanges option is not set
+const tiingoDefaultExchanges = "BATS,NASDAQ,NMFQS,NYSE,NYSE ARCA,NYSE MKT"
+
 type Tiingo struct {
 }
 
 var tiingoExchangeMap = map[string]data.Exchange{
+	"ASX":       data.ASXExchange,
 	"BATS":      data.BATSExchange,
+	"LSE":       data.LSEExchange,
 	"NASDAQ":    data.NasdaqExchange,
 	"NMFQS":     data.NMFQSExchange,
 	"NYSE":      data.NYSEExchange,
 	"NYSE ARCA": data.ARCAExchange,
 	"NYSE MKT":  data.NYSEMktExchange,
+	"OTCBB":     data.OTCExchange,
+	"OTCMKTS":   data.OTCExchange,
+	"OTCQB":     data.OTCQBExchange,
+	"OTCQX":     data.OTCQXExchange,
+	"PINK":      data.PinkExchange,
+	"SHE":       data.ShenzhenExchange,
+	"SHG":       data.ShanghaiExchange,
+	"TSX":       data.TSXExchange,
+	"TSXV":      data.TSXVExchange,
+	"XETRA":     data.XetraExchange,
 }
 
 func (tiingo *Tiingo) Name() string {
 	return "tiingo"
 }
 
-func (tiingo *Tiingo) ConfigDescription() map[string]string {
-	return map[string]string{
-		"apiKey":    "Enter your tiingo API key:",
-		"rateLimit": "What is the maximum number of requests per minute?",
-	}
+func (tiingo *Tiingo) ConfigSchema() ConfigSchema {
+	return append(ConfigSchema{
+		{Name: "apiKey", Prompt: "Enter your tiingo API key:", Type: ConfigString, Required: true, Secret: true},
+		{Name: "rateLimit", Prompt: "What is the maximum number of requests per minute?", Type: ConfigInt, Required: true},
+		{Name: "includeOTC", Prompt: "Include assets traded on OTC markets (OTCQX, OTCQB, Pink)?", Type: ConfigBool, Default: "false"},
+		{Name: "exchanges", Prompt: "Which exchanges should assets be listed on? (comma separated tiingo exchange codes, * for all)", Type: ConfigString, Default: tiingoDefaultExchanges},
+	}, delistedConfig...)
 }
 
 func (tiingo *Tiingo) Description() string {
@@ -68,12 +114,35 @@ func (tiingo *Tiingo) Datasets() map[string]Dataset {
 			Name:        "EOD",
 			Description: "Get end-of-day stock prices for active assets.",
 			DataTypes:   []*data.DataType{data.DataTypes[data.EODKey]},
+			DependsOn:   []string{data.AssetKey},
 			DateRange: func() (time.Time, time.Time) {
 				return time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC), time.Now().UTC()
 			},
+			Capabilities: Capabilities{
+				AssetTypes:  []data.AssetType{data.CommonStock, data.ADRC, data.ETF, data.MutualFund},
+				Geographies: []string{"US"},
+				Granularity: GranularityDaily,
+				Cost:        Cost{PerAsset: 1, DailyQuota: tiingoDailyQuota},
+			},
 			Fetch: downloadTiingoEODQuotes,
 		},
 
+		"FX Rates": {
+			Name:        "FX Rates",
+			Description: "Get daily USD exchange rates for the currencies active assets are priced in.",
+			DataTypes:   []*data.DataType{data.DataTypes[data.FXRateKey]},
+			DependsOn:   []string{data.AssetKey},
+			DateRange: func() (time.Time, time.Time) {
+				return tiingoFXHistoryStart.Time(), time.Now().UTC()
+			},
+			Capabilities: Capabilities{
+				Geographies: []string{"*"},
+				Granularity: GranularityDaily,
+				Cost:        Cost{PerRun: 10, DailyQuota: tiingoDailyQuota},
+			},
+			Fetch: downloadTiingoFXRates,
+		},
+
 		"Stock Tickers": {
 			Name:        "Stock Tickers",
 			Description: "Details about tradeable stocks, ADRs, Mutual Funds and ETFs.",
@@ -81,6 +150,13 @@ func (tiingo *Tiingo) Datasets() map[string]Dataset {
 			DateRange: func() (time.Time, time.Time) {
 				return time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC), time.Now().UTC()
 			},
+			Capabilities: Capabilities{
+				AssetTypes:      []data.AssetType{data.CommonStock, data.ADRC, data.ETF, data.MutualFund},
+				Geographies:     []string{"US"},
+				Granularity:     GranularityReference,
+				RefreshInterval: 24 * time.Hour,
+				Cost:            Cost{PerRun: 1, DailyQuota: tiingoDailyQuota},
+			},
 			Fetch: downloadTiingoAssets,
 		},
 	}
@@ -101,6 +177,15 @@ type tiingoEod struct {
 	Split         float64 `json:"splitFactor"`
 }
 
+type tiingoFX struct {
+	Date   string  `json:"date"`
+	Ticker string  `json:"ticker"`
+	Open   float64 `json:"open"`
+	High   float64 `json:"high"`
+	Low    float64 `json:"low"`
+	Close  float64 `json:"close"`
+}
+
 type tiingoAsset struct {
 	Ticker        string `json:"ticker" csv:"ticker"`
 	Exchange      string `json:"exchange" csv:"exchange"`
@@ -120,16 +205,19 @@ func downloadTiingoEODQuotes(ctx context.Context, subscription *library.Subscrip
 	}
 
 	numObs := 0
+	guard := NewSchemaGuard()
 
 	defer func() {
 		runSummary.EndTime = time.Now()
 		runSummary.NumObservations = numObs
+		runSummary.SchemaDrift = guard.Drift()
 		exitNotification <- runSummary
 	}()
 
 	rateLimit, err := strconv.Atoi(subscription.Config["rateLimit"])
 	if err != nil {
 		logger.Error().Err(err).Str("configRateLimit", subscription.Config["rateLimit"]).Msg("could not convert rateLimit configuration parameter to an integer")
+		runSummary.Status = data.RunFailed
 		return
 	}
 
@@ -137,49 +225,63 @@ func downloadTiingoEODQuotes(ctx context.Context, subscription *library.Subscrip
 		rateLimit = 5000
 	}
 
-	client := resty.New().SetQueryParam("token", subscription.Config["apiKey"])
-	limiter := rate.NewLimiter(rate.Limit(float64(rateLimit)/float64(61)), 1)
-
-	// get nyc timezone
-	nyc, err := time.LoadLocation("America/New_York")
-	if err != nil {
-		logger.Panic().Err(err).Msg("could not load timezone")
-		return
-	}
+	client := newClient(ctx).SetQueryParam("token", subscription.Config["apiKey"])
+	limiter := rateLimiter(subscription, rateLimit)
 
 	// fetch ticker EOD prices
 	if err := limiter.Wait(ctx); err != nil {
-		log.Panic().Err(err).Msg("rate limit wait failed")
+		log.Info().Err(err).Msg("stopping tiingo EOD download")
+		runSummary.Status = data.RunCanceled
+		return
 	}
 
 	// Get a list of active assets
 	conn, err := subscription.Library.Pool.Acquire(ctx)
 	if err != nil {
-		log.Panic().Msg("could not acquire database connection")
+		logger.Error().Err(err).Msg("could not acquire database connection")
+		runSummary.Status = data.RunFailed
+		return
 	}
 
-	defer conn.Release()
+	// lookback 14 days in the past
+	targets, err := eodTargets(ctx, subscription, conn, 14)
+	conn.Release()
+	if err != nil {
+		logger.Error().Err(err).Msg("could not list assets to download EOD quotes for")
+		runSummary.Status = data.RunFailed
+		return
+	}
 
-	assets := data.ActiveAssets(ctx, conn)
+	if !tiingoIncludeOTC(subscription) {
+		targets = slices.DeleteFunc(targets, func(target *eodTarget) bool {
+			return target.Asset.PrimaryExchange.IsOTC()
+		})
+	}
 
-	log.Debug().Int("NumAssets", len(assets)).Msg("downloading EOD quotes from Tiingo")
+	log.Debug().Int("NumAssets", len(targets)).Msg("downloading EOD quotes from Tiingo")
 
-	// lookback 14 days in the past
-	startDate := time.Now().Add(-14 * 24 * time.Hour)
-	startDateStr := startDate.Format("2006-01-02")
+	for _, target := range targets {
+		asset := target.Asset
+		if err := library.Checkpoint(ctx); err != nil {
+			log.Info().Err(err).Msg("stopping tiingo EOD download")
+			runSummary.Status = data.RunCanceled
+			return
+		}
 
-	for _, asset := range assets {
 		// reformat ticker for tiingo
-		ticker := strings.ReplaceAll(asset.Ticker, "/", "-")
+		ticker := data.DenormalizeTicker("tiingo", asset.Ticker)
 		url := fmt.Sprintf("https://api.tiingo.com/tiingo/daily/%s/prices", ticker)
 
+		req := client.R().SetQueryParam("startDate", target.Start.String())
+		if !target.End.IsZero() {
+			req.SetQueryParam("endDate", target.End.String())
+		}
+
 		respContent := make([]*tiingoEod, 0)
-		resp, err := client.R().
-			SetQueryParam("startDate", startDateStr).
-			SetResult(&respContent).
-			Get(url)
+		resp, err := req.SetResult(&respContent).Get(url)
 		if err != nil {
 			logger.Error().Err(err).Msg("resty returned an error when querying eod prices")
+			runSummary.Status = data.RunFailed
 			return
 		}
 
@@ -188,18 +290,22 @@ func downloadTiingoEODQuotes(ctx context.Context, subscription *library.Subscrip
 			continue
 		}
 
+		if err := guard.CheckJSON(ctx, tiingoEodSchema, resp.Body()); err != nil {
+			logger.Error().Err(err).Msg("stopping tiingo EOD download")
+			runSummary.Status = data.RunFailed
+			return
+		}
+
 		for _, quote := range respContent {
-			quoteDate, err := time.Parse(time.RFC3339Nano, quote.Date)
+			quoteDate, err := data.ParseMarketDate(quote.Date)
 			if err != nil {
 				logger.Error().Err(err).Str("tiingoDate", quote.Date).Msg("could not parse date from tiingo eod object")
 				continue
 			}
 
-			// set tiingo date to correct time zone and market close
-			quoteDate = time.Date(quoteDate.Year(), quoteDate.Month(), quoteDate.Day(), 16, 0, 0, 0, nyc)
-
+			// set tiingo date to the close of the asset's primary exchange
 			eodQuote := &data.Eod{
-				Date:          quoteDate,
+				Date:          quoteDate.Close(asset.PrimaryExchange),
 				Ticker:        asset.Ticker,
 				CompositeFigi: asset.CompositeFigi,
 				Open:          quote.Open,
@@ -209,6 +315,7 @@ func downloadTiingoEODQuotes(ctx context.Context, subscription *library.Subscrip
 				Volume:        quote.Volume,
 				Dividend:      quote.Dividend,
 				Split:         quote.Split,
+				Currency:      asset.PriceCurrency,
 			}
 
 			out <- &data.Observation{
@@ -217,11 +324,15 @@ func downloadTiingoEODQuotes(ctx context.Context, subscription *library.Subscrip
 				SubscriptionID:   subscription.ID,
 				SubscriptionName: subscription.Name,
 			}
+
+			numObs++
 		}
 	}
+
+	runSummary.Status = data.RunSuccess
 }
 
-func downloadTiingoAssets(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
+func downloadTiingoFXRates(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
 	logger := zerolog.Ctx(ctx)
 
 	runSummary := data.RunSummary{
@@ -231,178 +342,291 @@ func downloadTiingoAssets(ctx context.Context, subscription *library.Subscriptio
 	}
 
 	numObs := 0
+	guard := NewSchemaGuard()
 
 	defer func() {
 		runSummary.EndTime = time.Now()
 		runSummary.NumObservations = numObs
+		runSummary.SchemaDrift = guard.Drift()
 		exitNotification <- runSummary
 	}()
 
-	// get nyc timezone
-	nyc, err := time.LoadLocation("America/New_York")
+	rateLimit, err := strconv.Atoi(subscription.Config["rateLimit"])
 	if err != nil {
-		logger.Panic().Err(err).Msg("could not load timezone")
+		logger.Error().Err(err).Str("configRateLimit", subscription.Config["rateLimit"]).Msg("could not convert rateLimit configuration parameter to an integer")
+		runSummary.Status = data.RunFailed
 		return
 	}
 
-	tickerUrl := "https://apimedia.tiingo.com/docs/tiingo/daily/supported_tickers.zip"
-	client := resty.New()
-	assets := []*tiingoAsset{}
-
-	resp, err := client.R().Get(tickerUrl)
-	if err != nil {
-		logger.Error().Err(err).Msg("failed to download tickers")
+	if rateLimit <= 0 {
+		rateLimit = 5000
 	}
 
-	if resp.StatusCode() >= 400 {
-		logger.Error().Int("StatusCode", resp.StatusCode()).Str("Url", tickerUrl).Bytes("Body", resp.Body()).Msg("error when requesting tiingo supported_tickers.zip")
-		return
-	}
+	client := newClient(ctx).SetQueryParam("token", subscription.Config["apiKey"])
+	limiter := rateLimiter(subscription, rateLimit)
 
-	// unzip downloaded data
-	body := resp.Body()
+	conn, err := subscription.Library.Pool.Acquire(ctx)
 	if err != nil {
-		logger.Error().Err(err).Msg("could not read response body when downloading supported tickers from tiingo")
+		logger.Error().Err(err).Msg("could not acquire database connection")
+		runSummary.Status = data.RunFailed
 		return
 	}
 
-	zipReader, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	currencies, err := data.ActiveCurrencies(ctx, conn)
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to read tiingo supported tickers zip file")
+		conn.Release()
+		logger.Error().Err(err).Msg("could not get list of currencies")
+		runSummary.Status = data.RunFailed
 		return
 	}
 
-	// Read all the files from zip archive
-	var tickerCsvBytes []byte
-	if len(zipReader.File) == 0 {
-		logger.Error().Msg("no files contained in tiingo supported tickers zip file")
-		return
-	}
-
-	zipFile := zipReader.File[0]
-	tickerCsvBytes, err = readZipFile(zipFile)
+	latest, err := data.LatestFXRates(ctx, conn, subscription.DataTablesMap[data.FXRateKey])
+	conn.Release()
 	if err != nil {
-		logger.Error().Err(err).Msg("failed to read ticker csv from tiingo supported tickers zip file")
+		logger.Error().Err(err).Msg("could not get most recent fx rates")
+		runSummary.Status = data.RunFailed
 		return
 	}
 
-	if err := gocsv.UnmarshalBytes(tickerCsvBytes, &assets); err != nil {
-		logger.Error().Err(err).Msg("failed to unmarshal tiingo supported tickers csv")
-		return
-	}
+	log.Debug().Strs("Currencies", currencies).Msg("downloading fx rates from Tiingo")
 
-	validExchanges := []string{"BATS", "NASDAQ", "NMFQS", "NYSE", "NYSE ARCA", "NYSE MKT"}
-	commonAssets := make([]*data.Asset, 0, 25000)
-	for _, tiingoAsset := range assets {
-		// remove assets on invalid exchanges
-		keep := false
-		for _, exchange := range validExchanges {
-			if tiingoAsset.Exchange == exchange {
-				keep = true
-			}
+	// lookback 14 days in the past; currencies without stored rates are backfilled
+	// from the start of tiingo's history and those that have not been updated in
+	// a while from their most recent rate
+	lookback := data.Today(data.NYSEExchange).AddDays(-14)
+
+	for _, currency := range currencies {
+		startDate := lookback
+		if last, ok := latest[currency]; !ok {
+			startDate = tiingoFXHistoryStart
+		} else if last.Before(startDate) {
+			startDate = last
 		}
-		if !keep {
-			continue
+
+		if err := library.Checkpoint(ctx); err != nil {
+			log.Info().Err(err).Msg("stopping tiingo fx rate download")
+			runSummary.Status = data.RunCanceled
+			return
 		}
 
-		// If both the start date and end date are not set skip it
-		if tiingoAsset.StartDate == "" && tiingoAsset.EndDate == "" {
-			continue
+		if err := limiter.Wait(ctx); err != nil {
+			log.Info().Err(err).Msg("stopping tiingo fx rate download")
+			runSummary.Status = data.RunCanceled
+			return
 		}
 
-		// filter out tickers we should ignore
-		if tiingoIgnoreTicker(tiingoAsset.Ticker) {
-			continue
+		url := fmt.Sprintf("https://api.tiingo.com/tiingo/fx/%susd/prices", strings.ToLower(currency))
+
+		respContent := make([]*tiingoFX, 0)
+		resp, err := client.R().
+			SetQueryParam("startDate", startDate.String()).
+			SetQueryParam("resampleFreq", "1day").
+			SetResult(&respContent).
+			Get(url)
+		if err != nil {
+			logger.Error().Err(err).Msg("resty returned an error when querying fx rates")
+			runSummary.Status = data.RunFailed
+			return
 		}
 
-		tiingoAsset.Ticker = strings.ReplaceAll(tiingoAsset.Ticker, "-", "/")
-		pvAsset := &data.Asset{
-			Ticker:          tiingoAsset.Ticker,
-			ListingDate:     tiingoAsset.StartDate,
-			DelistingDate:   tiingoAsset.EndDate,
-			PrimaryExchange: tiingoExchangeMap[tiingoAsset.Exchange],
-			LastUpdated:     time.Now(),
+		if resp.StatusCode() >= 300 {
+			logger.Error().Int("StatusCode", resp.StatusCode()).Str("Currency", currency).Str("URL", resp.Request.URL).Msg("tiingo returned an invalid HTTP response")
+			continue
 		}
 
-		switch tiingoAsset.AssetType {
-		case "Stock":
-			pvAsset.AssetType = data.CommonStock
-		case "ETF":
-			pvAsset.AssetType = data.ETF
-		case "Mutual Fund":
-			pvAsset.AssetType = data.MutualFund
+		if err := guard.CheckJSON(ctx, tiingoFXSchema, resp.Body()); err != nil {
+			logger.Error().Err(err).Msg("stopping tiingo fx rate download")
+			runSummary.Status = data.RunFailed
+			return
 		}
 
-		if tiingoAsset.EndDate != "" {
-			endDate, err := time.Parse("2006-01-02", tiingoAsset.EndDate)
+		for _, quote := range respContent {
+			quoteDate, err := data.ParseMarketDate(quote.Date)
 			if err != nil {
-				log.Warn().Str("EndDate", tiingoAsset.EndDate).Err(err).Msg("could not parse end date")
+				logger.Error().Err(err).Str("tiingoDate", quote.Date).Msg("could not parse date from tiingo fx object")
+				continue
 			}
 
-			endDate = endDate.In(nyc)
-
-			now := time.Now().In(nyc)
-			age := now.Sub(endDate)
-			if age < (time.Hour * 24 * 7) {
-				pvAsset.DelistingDate = ""
-			} else {
-				pvAsset.DelistingDate = endDate.Format(time.RFC3339)
+			out <- &data.Observation{
+				FXRate: &data.FXRate{
+					Currency:  currency,
+					EventDate: quoteDate.Close(data.NYSEExchange),
+					Rate:      quote.Close,
+				},
+				ObservationDate:  time.Now(),
+				SubscriptionID:   subscription.ID,
+				SubscriptionName: subscription.Name,
 			}
-		}
 
-		if pvAsset.DelistingDate == "" {
-			pvAsset.Active = true
-			commonAssets = append(commonAssets, pvAsset)
+			numObs++
 		}
 	}
 
+	runSummary.Status = data.RunSuccess
+}
+
+func downloadTiingoAssets(ctx context.Context, subscription *library.Subscription, out chan<- *data.Observation, exitNotification chan<- data.RunSummary) {
+	logger := zerolog.Ctx(ctx)
+
+	runSummary := data.RunSummary{
+		StartTime:        time.Now(),
+		SubscriptionID:   subscription.ID,
+		SubscriptionName: subscription.Name,
+	}
+
+	numObs := 0
+	guard := NewSchemaGuard()
+
+	defer func() {
+		runSummary.EndTime = time.Now()
+		runSummary.NumObservations = numObs
+		runSummary.SchemaDrift = guard.Drift()
+		exitNotification <- runSummary
+	}()
+
+	// get nyc timezone
+	nyc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		logger.Error().Err(err).Msg("could not load timezone")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
+	commonAssets, err := readTiingoAssets(ctx, subscription, nyc, guard)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to read tiingo supported tickers")
+		runSummary.Status = data.RunFailed
+		return
+	}
+
 	log.Debug().Int("NumAssetsToEnrich", len(commonAssets)).Msg("number of assets to enrich with Composite FIGI")
-	figi.Enrich(commonAssets...)
+	figi.Enrich(ctx, commonAssets...)
+	commonAssets = subscription.Library.ResolveAssets(ctx, commonAssets)
 
-	pvAssetMap := make(map[string]*data.Asset, len(commonAssets))
+	activeFigis := make(map[string]struct{}, len(commonAssets))
 	for _, asset := range commonAssets {
 		if asset.CompositeFigi != "" {
-			pvAssetMap[asset.CompositeFigi] = asset
+			activeFigis[asset.CompositeFigi] = struct{}{}
 		}
 	}
 
 	// get a list of assets already in the database
 	conn, err := subscription.Library.Pool.Acquire(ctx)
 	if err != nil {
-		log.Panic().Msg("could not acquire database connection")
+		logger.Error().Err(err).Msg("could not acquire database connection")
+		runSummary.Status = data.RunFailed
+		return
 	}
 
-	defer conn.Release()
-
 	activeDBAssets := data.ActiveAssets(ctx, conn, subscription.DataTablesMap[data.AssetKey])
+	conn.Release()
 
-	// determine which assets are no longer active
+	storedAssets := make(map[string]*data.Asset, len(activeDBAssets))
 	for _, dbAsset := range activeDBAssets {
-		_, ok := pvAssetMap[dbAsset.CompositeFigi]
-		if !ok {
-			dbAsset.Active = false
-			dbAsset.DelistingDate = time.Now().In(nyc).Format(time.RFC3339)
-			commonAssets = append(commonAssets, dbAsset)
-		}
+		storedAssets[dbAsset.ID()] = dbAsset
 	}
 
+	// only assets that are new or differ from the database are saved
+	counts := make(map[string]int)
+	changedAssets := make([]*data.Asset, 0)
 	for _, asset := range commonAssets {
 		if asset.CompositeFigi == "" {
 			continue
 		}
 
-		// make a copy of the asset and fix ticker to match pv-data standard
-		// e.g. BRK.A -> BRK/A
-		asset2 := *asset
-		asset2.Ticker = strings.ReplaceAll(asset2.Ticker, "-", "/")
+		// fix ticker to match pv-data standard, e.g. BRK-A -> BRK/A
+		asset.Ticker = data.NormalizeTicker("tiingo", asset.Ticker)
+
+		dbAsset, ok := storedAssets[asset.ID()]
+		switch {
+		case !ok:
+			counts[data.AssetsCreated]++
+		case asset.ChangedFrom(dbAsset):
+			counts[data.AssetsChanged]++
+		default:
+			counts[data.AssetsUnchanged]++
+			continue
+		}
+
+		changedAssets = append(changedAssets, asset)
+	}
+
+	// determine which assets are no longer active
+	for _, dbAsset := range activeDBAssets {
+		_, ok := activeFigis[dbAsset.CompositeFigi]
+		if !ok {
+			dbAsset.Active = false
+			dbAsset.DelistingDate = data.Today(data.NYSEExchange).String()
+			changedAssets = append(changedAssets, dbAsset)
+			counts[data.AssetsDelisted]++
+		}
+	}
+
+	logger.Info().Int("Created", counts[data.AssetsCreated]).Int("Changed", counts[data.AssetsChanged]).
+		Int("Delisted", counts[data.AssetsDelisted]).Int("Unchanged", counts[data.AssetsUnchanged]).Msg("compared tiingo assets with the database")
+
+	runSummary.Counts = counts
+
+	for _, asset := range changedAssets {
+		if err := library.Checkpoint(ctx); err != nil {
+			logger.Info().Err(err).Msg("stopping tiingo asset download")
+			runSummary.Status = data.RunCanceled
+			return
+		}
 
 		out <- &data.Observation{
-			AssetObject:      &asset2,
+			AssetObject:      asset,
 			ObservationDate:  time.Now(),
 			SubscriptionID:   subscription.ID,
 			SubscriptionName: subscription.Name,
 		}
+
+		numObs++
+	}
+
+	runSummary.Status = data.RunSuccess
+}
+
+// tiingoValidExchanges returns the tiingo exchange codes that assets are read
+// from: the exchanges option, or every exchange in tiingoExchangeMap if it is
+// *, plus the OTC markets if includeOTC is set
+func tiingoValidExchanges(subscription *library.Subscription) map[string]bool {
+	exchanges := subscription.Config["exchanges"]
+	if exchanges == "" {
+		exchanges = tiingoDefaultExchanges
+	}
+
+	includeOTC := tiingoIncludeOTC(subscription)
+	validExchanges := make(map[string]bool, len(tiingoExchangeMap))
+	for code, exchange := range tiingoExchangeMap {
+		if exchange.IsOTC() {
+			validExchanges[code] = includeOTC
+		} else if exchanges == "*" {
+			validExchanges[code] = true
+		}
 	}
+
+	if exchanges != "*" {
+		for _, code := range strings.Split(exchanges, ",") {
+			code = strings.ToUpper(strings.TrimSpace(code))
+			if exchange, ok := tiingoExchangeMap[code]; !ok {
+				log.Warn().Str("Exchange", code).Msg("ignoring exchange that tiingo does not list assets on")
+			} else if !exchange.IsOTC() {
+				validExchanges[code] = true
+			}
+		}
+	}
+
+	return validExchanges
+}
+
+// tiingoIncludeOTC returns true if the subscription opted-in to OTC markets
+func tiingoIncludeOTC(subscription *library.Subscription) bool {
+	includeOTC, err := strconv.ParseBool(subscription.Config["includeOTC"])
+	if err != nil {
+		return false
+	}
+	return includeOTC
 }
 
 // tiingoIgnoreTicker interprets the structure of the ticker to identify
@@ -413,19 +637,128 @@ func tiingoIgnoreTicker(ticker string) bool {
 	ignore = ignore || strings.HasPrefix(ticker, "NTEST")
 	ignore = ignore || strings.HasPrefix(ticker, "PTEST")
 	ignore = ignore || strings.Contains(ticker, " ")
-	matcher := regexp.MustCompile(`^[A-Za-z0-9]+-[WPU]{1}.*$`)
-	ignore = ignore || matcher.Match([]byte(ticker))
-	matcher = regexp.MustCompile(`^[A-Za-z0-9]{4}[WPU]{1}.*$`)
-	ignore = ignore || matcher.Match([]byte(ticker))
+	ignore = ignore || tiingoShareClassSuffix.MatchString(ticker)
+	ignore = ignore || tiingoFifthLetterSuffix.MatchString(ticker)
 
 	return ignore
 }
 
-func readZipFile(zf *zip.File) ([]byte, error) {
-	f, err := zf.Open()
+// readTiingoAssets downloads the list of tickers supported by tiingo and
+// returns the assets that are actively traded on the subscription's exchanges.
+// The zip file is spooled to disk and its CSV is filtered as it is decoded so
+// that only the assets that are kept are held in memory.
+func readTiingoAssets(ctx context.Context, subscription *library.Subscription, nyc *time.Location, guard *SchemaGuard) ([]*data.Asset, error) {
+	tickerUrl := "https://apimedia.tiingo.com/docs/tiingo/daily/supported_tickers.zip"
+	client := newClient(ctx)
+
+	resp, err := client.R().SetDoNotParseResponse(true).Get(tickerUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	body := resp.RawBody()
+	defer body.Close()
+
+	if resp.StatusCode() >= 400 {
+		msg, _ := io.ReadAll(io.LimitReader(body, 4096))
+		zerolog.Ctx(ctx).Error().Int("StatusCode", resp.StatusCode()).Str("Url", tickerUrl).Bytes("Body", msg).Msg("error when requesting tiingo supported_tickers.zip")
+		return nil, fmt.Errorf("%w: %d", ErrTiingoStatus, resp.StatusCode())
+	}
+
+	spool, err := os.CreateTemp("", "tiingo-supported-tickers-*.zip")
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+
+	size, err := io.Copy(spool, body)
+	if err != nil {
+		return nil, err
+	}
+
+	zipReader, err := zip.NewReader(spool, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(zipReader.File) == 0 {
+		return nil, ErrEmptyArchive
+	}
+
+	tickerCsv, err := zipReader.File[0].Open()
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-	return io.ReadAll(f)
+	defer tickerCsv.Close()
+
+	validExchanges := tiingoValidExchanges(subscription)
+
+	csvReader := csv.NewReader(tickerCsv)
+	csvReader.ReuseRecord = true
+
+	now := time.Now()
+	assets := make([]*data.Asset, 0, 25000)
+	err = gocsv.UnmarshalDecoderToCallback(guard.CSVDecoder(ctx, tiingoAssetSchema, csvReader), func(tiingoAsset tiingoAsset) {
+		if asset := tiingoAsset.activeAsset(validExchanges, nyc, now); asset != nil {
+			assets = append(assets, asset)
+		}
+	})
+
+	return assets, err
+}
+
+// activeAsset converts the tiingo asset to a pv-data asset. nil is returned if the
+// asset is not listed on one of validExchanges, is an unsupported share type, or
+// was delisted more than a week ago.
+func (tiingoAsset *tiingoAsset) activeAsset(validExchanges map[string]bool, nyc *time.Location, now time.Time) *data.Asset {
+	// remove assets on invalid exchanges
+	if !validExchanges[tiingoAsset.Exchange] {
+		return nil
+	}
+
+	// If both the start date and end date are not set skip it
+	if tiingoAsset.StartDate == "" && tiingoAsset.EndDate == "" {
+		return nil
+	}
+
+	// filter out tickers we should ignore
+	if tiingoIgnoreTicker(tiingoAsset.Ticker) {
+		return nil
+	}
+
+	pvAsset := &data.Asset{
+		Ticker:          data.NormalizeTicker("tiingo", tiingoAsset.Ticker),
+		ListingDate:     tiingoAsset.StartDate,
+		PrimaryExchange: tiingoExchangeMap[tiingoAsset.Exchange],
+		PriceCurrency:   strings.ToUpper(tiingoAsset.PriceCurrency),
+		LastUpdated:     now,
+		Active:          true,
+	}
+
+	switch tiingoAsset.AssetType {
+	case "Stock":
+		pvAsset.AssetType = data.CommonStock
+	case "ETF":
+		pvAsset.AssetType = data.ETF
+	case "Mutual Fund":
+		pvAsset.AssetType = data.MutualFund
+	}
+
+	if tiingoAsset.EndDate != "" {
+		endDate, err := time.Parse("2006-01-02", tiingoAsset.EndDate)
+		if err != nil {
+			log.Warn().Str("EndDate", tiingoAsset.EndDate).Err(err).Msg("could not parse end date")
+		}
+
+		// assets that stopped trading within the last week are still considered active
+		if now.In(nyc).Sub(endDate.In(nyc)) >= (time.Hour * 24 * 7) {
+			return nil
+		}
+	}
+
+	return pvAsset
 }
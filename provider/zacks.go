@@ -42,10 +42,10 @@ func (zacks *Zacks) Name() string {
 	return "Zacks"
 }
 
-func (zacks *Zacks) ConfigDescription() map[string]string {
-	return map[string]string{
-		"username": "What is your Zacks username?",
-		"password": "What is your Zacks password?",
+func (zacks *Zacks) ConfigSchema() ConfigSchema {
+	return ConfigSchema{
+		{Name: "username", Prompt: "What is your Zacks username?", Type: ConfigString, Required: true},
+		{Name: "password", Prompt: "What is your Zacks password?", Type: ConfigString, Required: true, Secret: true},
 	}
 }
 
@@ -59,9 +59,16 @@ func (zacks *Zacks) Datasets() map[string]Dataset {
 			Name:        "Zacks Screener Data",
 			Description: "Download data using Zacks stock screener tool.",
 			DataTypes:   []*data.DataType{data.DataTypes[data.RatingKey], data.DataTypes[data.CustomKey]},
+			DependsOn:   []string{data.AssetKey},
 			DateRange: func() (time.Time, time.Time) {
 				return time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), time.Now().UTC()
 			},
+			Capabilities: Capabilities{
+				AssetTypes:  []data.AssetType{data.CommonStock},
+				Geographies: []string{"US"},
+				Granularity: GranularityDaily,
+				Cost:        Cost{PerRun: 1},
+			},
 			Fetch: downloadZacksData,
 		},
 	}
@@ -120,9 +127,8 @@ func downloadZacksData(ctx context.Context, subscription *library.Subscription,
 		log.Panic().Msg("could not acquire database connection")
 	}
 
-	defer conn.Release()
-
 	assets := data.ActiveAssets(ctx, conn)
+	conn.Release()
 	figiMap := make(map[string]string, len(assets))
 	for _, asset := range assets {
 		figiMap[asset.Ticker] = asset.CompositeFigi
@@ -436,7 +442,7 @@ func loadZacksRatings(ratingsData []byte, dateStr string) []*ZacksRecord {
 
 	// cleanup records
 	for _, r := range records {
-		r.Ticker = strings.ReplaceAll(r.Ticker, ".", "/")
+		r.Ticker = data.NormalizeTicker("zacks", r.Ticker)
 
 		// set event date
 		r.EventDateStr = dateStr
@@ -0,0 +1,369 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/notify"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatJSON     = "json"
+)
+
+// Report summarizes a scheduling cycle
+type Report struct {
+	Date time.Time `json:"date"`
+
+	Runs         []*Run         `json:"runs"`
+	Observations map[string]int `json:"observations"`
+	Quarantined  map[string]int `json:"quarantined"`
+
+	NewListings   []*Listing `json:"new_listings"`
+	NewDelistings []*Listing `json:"new_delistings"`
+	Gaps          []*Gap     `json:"gaps"`
+	Movers        []*Mover   `json:"movers"`
+
+	// Unresolved lists assets whose FIGI has repeatedly failed to resolve
+	Unresolved []*Unresolved `json:"unresolved"`
+}
+
+// Run is the outcome of a single subscription
+type Run struct {
+	SubscriptionName string              `json:"subscription_name"`
+	Status           string              `json:"status"`
+	NumObservations  int                 `json:"num_observations"`
+	Counts           map[string]int      `json:"counts,omitempty"`
+	SchemaDrift      []*data.SchemaDrift `json:"schema_drift,omitempty"`
+	RunTime          time.Duration       `json:"run_time"`
+}
+
+// Listing is an asset that was listed or delisted
+type Listing struct {
+	Ticker        string    `json:"ticker"`
+	CompositeFigi string    `json:"composite_figi"`
+	Name          string    `json:"name"`
+	Date          time.Time `json:"date"`
+}
+
+// Gap is an active asset that was quoted recently but is missing a quote for
+// the most recent trading day
+type Gap struct {
+	Ticker        string    `json:"ticker"`
+	CompositeFigi string    `json:"composite_figi"`
+	LastDate      time.Time `json:"last_date"`
+}
+
+// Mover is an asset with a large one-day return
+type Mover struct {
+	Ticker        string  `json:"ticker"`
+	CompositeFigi string  `json:"composite_figi"`
+	PrevClose     float64 `json:"prev_close"`
+	Close         float64 `json:"close"`
+	Return        float64 `json:"return"`
+}
+
+// Unresolved is an asset whose composite FIGI could not be found
+type Unresolved struct {
+	Ticker          string    `json:"ticker"`
+	PrimaryExchange string    `json:"primary_exchange"`
+	Attempts        int       `json:"attempts"`
+	FirstSeen       time.Time `json:"first_seen"`
+}
+
+// Options control which tables the report is generated from
+type Options struct {
+	// Since is the start of the reporting period; listings and delistings on
+	// or after Since are reported
+	Since time.Time
+
+	AssetTable string
+	EODTable   string
+
+	// NumMovers is the number of biggest movers to include; defaults to 10
+	NumMovers int
+
+	// MinUnresolvedAttempts is the number of failed FIGI lookups after which an
+	// asset is reported as unresolvable; defaults to 5
+	MinUnresolvedAttempts int
+
+	Runs         []data.RunSummary
+	Observations map[string]int
+
+	// Quarantined is the number of observations held for review by data type
+	Quarantined map[string]int
+}
+
+// Generate builds a report from the library. Sections whose tables are not
+// set are left empty.
+func Generate(ctx context.Context, dbConn *pgxpool.Conn, opts *Options) (*Report, error) {
+	report := &Report{
+		Date:          time.Now(),
+		Observations:  opts.Observations,
+		Quarantined:   opts.Quarantined,
+		Runs:          make([]*Run, 0, len(opts.Runs)),
+		NewListings:   []*Listing{},
+		NewDelistings: []*Listing{},
+		Gaps:          []*Gap{},
+		Movers:        []*Mover{},
+		Unresolved:    []*Unresolved{},
+	}
+
+	if report.Observations == nil {
+		report.Observations = map[string]int{}
+	}
+
+	if report.Quarantined == nil {
+		report.Quarantined = map[string]int{}
+	}
+
+	for _, summary := range opts.Runs {
+		status := summary.Status.String()
+		switch summary.NoData {
+		case data.NoDataExpected:
+			status += " (no data, market closed)"
+		case data.NoDataUnexpected:
+			status += " (no data on a trading day)"
+		}
+
+		report.Runs = append(report.Runs, &Run{
+			SubscriptionName: summary.SubscriptionName,
+			Status:           status,
+			NumObservations:  summary.NumObservations,
+			Counts:           summary.Counts,
+			SchemaDrift:      summary.SchemaDrift,
+			RunTime:          summary.EndTime.Sub(summary.StartTime),
+		})
+	}
+
+	numMovers := opts.NumMovers
+	if numMovers <= 0 {
+		numMovers = 10
+	}
+
+	minUnresolvedAttempts := opts.MinUnresolvedAttempts
+	if minUnresolvedAttempts <= 0 {
+		minUnresolvedAttempts = 5
+	}
+
+	if err := pgxscan.Select(ctx, dbConn, &report.Unresolved, `SELECT ticker, primary_exchange, attempts, first_seen
+FROM unresolved_assets WHERE resolved_figi IS NULL AND attempts >= $1 ORDER BY attempts DESC, ticker`, minUnresolvedAttempts); err != nil {
+		return nil, err
+	}
+
+	if opts.AssetTable != "" {
+		assetTable := pgx.Identifier{opts.AssetTable}.Sanitize()
+
+		if err := pgxscan.Select(ctx, dbConn, &report.NewListings, fmt.Sprintf(`SELECT ticker, composite_figi,
+coalesce(name, '') AS name, listed AS date FROM %s WHERE listed >= $1 ORDER BY listed, ticker`, assetTable), opts.Since); err != nil {
+			return nil, err
+		}
+
+		if err := pgxscan.Select(ctx, dbConn, &report.NewDelistings, fmt.Sprintf(`SELECT ticker, composite_figi,
+coalesce(name, '') AS name, delisted AS date FROM %s WHERE delisted >= $1 ORDER BY delisted, ticker`, assetTable), opts.Since); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.AssetTable != "" && opts.EODTable != "" {
+		if err := pgxscan.Select(ctx, dbConn, &report.Gaps, fmt.Sprintf(`SELECT a.ticker, a.composite_figi,
+max(e.event_date)::timestamp AS last_date
+FROM %[1]s a JOIN %[2]s e ON e.composite_figi = a.composite_figi
+WHERE a.active AND e.event_date >= (SELECT max(event_date) FROM %[2]s) - 30
+GROUP BY a.ticker, a.composite_figi
+HAVING max(e.event_date) < (SELECT max(event_date) FROM %[2]s)
+ORDER BY a.ticker`, pgx.Identifier{opts.AssetTable}.Sanitize(), pgx.Identifier{opts.EODTable}.Sanitize())); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.EODTable != "" {
+		// returns are adjusted for splits on the most recent day
+		if err := pgxscan.Select(ctx, dbConn, &report.Movers, fmt.Sprintf(`WITH days AS (
+	SELECT DISTINCT event_date FROM %[1]s WHERE event_date >= (SELECT max(event_date) FROM %[1]s) - 14
+	ORDER BY event_date DESC LIMIT 2
+)
+SELECT cur.ticker, cur.composite_figi, prev.close::float8 AS prev_close, cur.close::float8 AS close,
+	(cur.close * cur.split_factor / prev.close - 1)::float8 AS return
+FROM %[1]s cur JOIN %[1]s prev ON prev.composite_figi = cur.composite_figi
+WHERE cur.event_date = (SELECT max(event_date) FROM days)
+	AND prev.event_date = (SELECT min(event_date) FROM days)
+	AND prev.event_date < cur.event_date
+	AND prev.close > 0
+ORDER BY abs(cur.close * cur.split_factor / prev.close - 1) DESC
+LIMIT $1`, pgx.Identifier{opts.EODTable}.Sanitize()), numMovers); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// Render formats the report as markdown, html, or json
+func (report *Report) Render(format string) (string, error) {
+	switch format {
+	case FormatJSON:
+		buf, err := json.MarshalIndent(report, "", "  ")
+		return string(buf), err
+	case FormatHTML:
+		var buf bytes.Buffer
+		md := goldmark.New(goldmark.WithExtensions(extension.Table))
+		if err := md.Convert([]byte(report.Markdown()), &buf); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	default:
+		return report.Markdown(), nil
+	}
+}
+
+// Message creates a notification containing the report in format
+func (report *Report) Message(format string) (*notify.Message, error) {
+	body, err := report.Render(format)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := "text/markdown"
+	switch format {
+	case FormatJSON:
+		contentType = "application/json"
+	case FormatHTML:
+		contentType = "text/html"
+	}
+
+	return &notify.Message{
+		Subject:     fmt.Sprintf("pvdata summary for %s", report.Date.Format("2006-01-02")),
+		Body:        body,
+		ContentType: contentType,
+	}, nil
+}
+
+var markdownTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"date":    func(t time.Time) string { return t.Format("2006-01-02") },
+	"percent": func(v float64) string { return fmt.Sprintf("%.2f%%", v*100) },
+	"money":   func(v float64) string { return fmt.Sprintf("%.2f", v) },
+	"runtime": func(d time.Duration) string { return d.Round(time.Second).String() },
+	"join":    strings.Join,
+	"counts": func(counts map[string]int) string {
+		kinds := make([]string, 0, len(counts))
+		for kind := range counts {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+
+		parts := make([]string, 0, len(kinds))
+		for _, kind := range kinds {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[kind], kind))
+		}
+		return strings.Join(parts, ", ")
+	},
+}).Parse(`# pvdata summary for {{ date .Date }}
+{{ if .Runs }}
+## Runs
+
+| Subscription | Status | Observations | Run Time |
+|--------------|--------|-------------:|---------:|
+{{ range .Runs }}| {{ .SubscriptionName }} | {{ .Status }} | {{ .NumObservations }}{{ with .Counts }} ({{ counts . }}){{ end }} | {{ runtime .RunTime }} |
+{{ end }}{{ end }}{{ range .Runs }}{{ $run := . }}{{ range .SchemaDrift }}
+**Schema drift in {{ .Source }}** ({{ $run.SubscriptionName }}){{ with .MissingFields }}; missing: {{ join . ", " }}{{ end }}{{ with .TypeChanges }}; changed: {{ join . ", " }}{{ end }}{{ with .UnknownFields }}; unknown: {{ join . ", " }}{{ end }}
+{{ end }}{{ end }}
+## Observations Ingested
+{{ if .ObservationKeys }}
+| Data Type | Observations |
+|-----------|-------------:|
+{{ range .ObservationKeys }}| {{ . }} | {{ index $.Observations . }} |
+{{ end }}{{ else }}
+No observations were ingested.
+{{ end }}
+## Quarantined
+{{ if .Quarantined }}
+| Data Type | Observations |
+|-----------|-------------:|
+{{ range $dataType, $count := .Quarantined }}| {{ $dataType }} | {{ $count }} |
+{{ end }}
+Review quarantined observations with ` + "`pvdata quarantine`" + `.
+{{ else }}
+None
+{{ end }}
+## New Listings
+{{ if .NewListings }}
+{{ range .NewListings }}* {{ .Ticker }} ({{ .CompositeFigi }}) {{ .Name }} on {{ date .Date }}
+{{ end }}{{ else }}
+None
+{{ end }}
+## New Delistings
+{{ if .NewDelistings }}
+{{ range .NewDelistings }}* {{ .Ticker }} ({{ .CompositeFigi }}) {{ .Name }} on {{ date .Date }}
+{{ end }}{{ else }}
+None
+{{ end }}
+## Gaps
+{{ if .Gaps }}
+{{ len .Gaps }} active assets are missing the most recent quote.
+
+{{ range .Gaps }}* {{ .Ticker }} ({{ .CompositeFigi }}) last quoted {{ date .LastDate }}
+{{ end }}{{ else }}
+None
+{{ end }}
+## Biggest Movers
+{{ if .Movers }}
+| Ticker | Previous Close | Close | Return |
+|--------|---------------:|------:|-------:|
+{{ range .Movers }}| {{ .Ticker }} | {{ money .PrevClose }} | {{ money .Close }} | {{ percent .Return }} |
+{{ end }}{{ else }}
+None
+{{ end }}{{ if .Unresolved }}
+## Unresolved Assets
+
+{{ len .Unresolved }} assets could not be matched to a composite FIGI; see ` + "`pvdata unresolved`" + `.
+
+{{ range .Unresolved }}* {{ .Ticker }} ({{ .PrimaryExchange }}) {{ .Attempts }} attempts since {{ date .FirstSeen }}
+{{ end }}{{ end }}`))
+
+// ObservationKeys returns the data types with observations in sorted order
+func (report *Report) ObservationKeys() []string {
+	keys := make([]string, 0, len(report.Observations))
+	for key := range report.Observations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Markdown renders the report as a markdown document
+func (report *Report) Markdown() string {
+	var buf strings.Builder
+	if err := markdownTemplate.Execute(&buf, report); err != nil {
+		return fmt.Sprintf("could not render report: %s", err)
+	}
+	return buf.String()
+}
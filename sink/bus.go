@@ -0,0 +1,52 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package sink
+
+import (
+	"context"
+
+	"github.com/penny-vault/pvdata/bus"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+)
+
+// Bus publishes observations to a message bus
+type Bus struct {
+	publisher bus.Publisher
+}
+
+// NewBus creates a sink that publishes to the configured message bus
+func NewBus(config bus.Config) (*Bus, error) {
+	publisher, err := bus.New(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bus{
+		publisher: publisher,
+	}, nil
+}
+
+func (sink *Bus) Name() string {
+	return BusKey
+}
+
+func (sink *Bus) Write(ctx context.Context, subscription *library.Subscription, obs *data.Observation) error {
+	return sink.publisher.Publish(ctx, obs)
+}
+
+func (sink *Bus) Close() error {
+	return sink.publisher.Close()
+}
@@ -0,0 +1,200 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/jackc/pgx/v5"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+)
+
+const ClickHouseKey = "clickhouse"
+
+// clickHouseBatchSize is the number of rows buffered before an insert;
+// ClickHouse performs best with large, infrequent inserts
+const clickHouseBatchSize = 10000
+
+// clickHouseEodSchema uses a ReplacingMergeTree so re-importing a quote replaces
+// the previous version when parts are merged
+const clickHouseEodSchema = `CREATE TABLE IF NOT EXISTS %[1]s (
+	ticker            LowCardinality(String),
+	composite_figi    LowCardinality(String),
+	event_date        Date,
+	open              Float64,
+	high              Float64,
+	low               Float64,
+	close             Float64,
+	volume            Float64,
+	dividend          Float64,
+	split_factor      Float64,
+	currency          LowCardinality(String),
+	pre_market_open   Nullable(Float64),
+	after_hours_close Nullable(Float64),
+	updated           DateTime DEFAULT now()
+) ENGINE = ReplacingMergeTree(updated)
+PARTITION BY toYYYYMM(event_date)
+ORDER BY (composite_figi, event_date)%[2]s`
+
+// ClickHouse copies EOD quotes to ClickHouse for analytical queries; other
+// data types are ignored and nothing in pvdata reads from it. Tables are named after the subscription's postgres
+// table and created when first written to. Quotes are buffered and inserted in
+// batches; the router holds journal acknowledgements until they are inserted.
+type ClickHouse struct {
+	conn driver.Conn
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	tables  map[string]bool
+	pending map[string][]*data.Eod
+}
+
+// NewClickHouse connects to the ClickHouse server at dsn. If ttl is greater
+// than zero rows older than ttl are removed by ClickHouse.
+func NewClickHouse(dsn string, ttl time.Duration) (*ClickHouse, error) {
+	options, err := clickhouse.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := clickhouse.Open(options)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Ping(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return &ClickHouse{
+		conn:    conn,
+		ttl:     ttl,
+		tables:  make(map[string]bool),
+		pending: make(map[string][]*data.Eod),
+	}, nil
+}
+
+func (sink *ClickHouse) Name() string {
+	return ClickHouseKey
+}
+
+func (sink *ClickHouse) Write(ctx context.Context, subscription *library.Subscription, obs *data.Observation) error {
+	if obs.EodQuote == nil {
+		return nil
+	}
+
+	tbl := subscription.DataTablesMap[data.EODKey]
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	if !sink.tables[tbl] {
+		if err := sink.createTable(ctx, tbl); err != nil {
+			return err
+		}
+		sink.tables[tbl] = true
+	}
+
+	sink.pending[tbl] = append(sink.pending[tbl], obs.EodQuote)
+	if len(sink.pending[tbl]) >= clickHouseBatchSize {
+		return sink.flush(ctx, tbl)
+	}
+
+	return nil
+}
+
+// Buffered returns the number of quotes waiting to be inserted
+func (sink *ClickHouse) Buffered() int {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	count := 0
+	for _, quotes := range sink.pending {
+		count += len(quotes)
+	}
+	return count
+}
+
+func (sink *ClickHouse) createTable(ctx context.Context, tbl string) error {
+	ttl := ""
+	if days := int(sink.ttl.Hours() / 24); days > 0 {
+		ttl = fmt.Sprintf("\nTTL event_date + INTERVAL %d DAY", days)
+	}
+
+	return sink.conn.Exec(ctx, fmt.Sprintf(clickHouseEodSchema, pgx.Identifier{tbl}.Sanitize(), ttl))
+}
+
+func (sink *ClickHouse) flush(ctx context.Context, tbl string) error {
+	quotes := sink.pending[tbl]
+	if len(quotes) == 0 {
+		return nil
+	}
+
+	// rows stay buffered until the insert succeeds and are retried by the next
+	// flush; the table's ReplacingMergeTree removes any duplicates
+	batch, err := sink.conn.PrepareBatch(ctx, fmt.Sprintf(`INSERT INTO %s (ticker, composite_figi, event_date, open, high,
+low, close, volume, dividend, split_factor, currency, pre_market_open, after_hours_close)`, pgx.Identifier{tbl}.Sanitize()))
+	if err != nil {
+		return err
+	}
+
+	for _, quote := range quotes {
+		currency := quote.Currency
+		if currency == "" {
+			currency = data.USD
+		}
+
+		if err := batch.Append(quote.Ticker, quote.CompositeFigi, quote.Date, quote.Open, quote.High, quote.Low,
+			quote.Close, quote.Volume, quote.Dividend, quote.Split, currency, nullableFloat(quote.PreMarketOpen),
+			nullableFloat(quote.AfterHoursClose)); err != nil {
+			return err
+		}
+	}
+
+	if err := batch.Send(); err != nil {
+		return err
+	}
+
+	delete(sink.pending, tbl)
+	return nil
+}
+
+// Close inserts any buffered rows and closes the connection
+func (sink *ClickHouse) Close() error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	ctx := context.Background()
+	var err error
+	for tbl := range sink.pending {
+		err = errors.Join(err, sink.flush(ctx, tbl))
+	}
+
+	return errors.Join(err, sink.conn.Close())
+}
+
+func nullableFloat(val float64) *float64 {
+	if val == 0 {
+		return nil
+	}
+	return &val
+}
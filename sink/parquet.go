@@ -0,0 +1,147 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gosimple/slug"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/source"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// parquetRecord is the schema of files written by the parquet sink. The
+// observation itself is stored as JSON so every data type shares one schema.
+type parquetRecord struct {
+	SubscriptionID  string `parquet:"name=subscription_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	DataType        string `parquet:"name=data_type, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	ObservationDate int64  `parquet:"name=observation_date, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Payload         string `parquet:"name=payload, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+type parquetFile struct {
+	file   source.ParquetFile
+	writer *writer.ParquetWriter
+}
+
+// Parquet writes observations to parquet files laid out as
+// <dir>/<data type>/<subscription>-<timestamp>.parquet; a new set of files
+// is created each time the sink is opened
+type Parquet struct {
+	dir     string
+	created time.Time
+
+	mu    sync.Mutex
+	files map[string]*parquetFile
+}
+
+// NewParquet creates a sink that writes files under dir
+func NewParquet(dir string) (*Parquet, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &Parquet{
+		dir:     dir,
+		created: time.Now(),
+		files:   make(map[string]*parquetFile),
+	}, nil
+}
+
+func (sink *Parquet) Name() string {
+	return ParquetKey
+}
+
+func (sink *Parquet) Write(ctx context.Context, subscription *library.Subscription, obs *data.Observation) error {
+	payload, err := json.Marshal(obs)
+	if err != nil {
+		return err
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	pf, err := sink.file(subscription.ID, subscription.Name, obs.DataType())
+	if err != nil {
+		return err
+	}
+
+	return pf.writer.Write(&parquetRecord{
+		SubscriptionID:  obs.SubscriptionID.String(),
+		DataType:        obs.DataType(),
+		ObservationDate: obs.ObservationDate.UnixMilli(),
+		Payload:         string(payload),
+	})
+}
+
+// file returns the open file for the subscription and data type, creating it if needed
+func (sink *Parquet) file(subscriptionID uuid.UUID, subscriptionName, dataType string) (*parquetFile, error) {
+	key := fmt.Sprintf("%s/%s", subscriptionID, dataType)
+	if pf, ok := sink.files[key]; ok {
+		return pf, nil
+	}
+
+	dir := filepath.Join(sink.dir, dataType)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	fn := filepath.Join(dir, fmt.Sprintf("%s-%s.parquet", slug.Make(subscriptionName), sink.created.Format("20060102T150405")))
+	fh, err := local.NewLocalFileWriter(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	pw, err := writer.NewParquetWriter(fh, new(parquetRecord), 4)
+	if err != nil {
+		fh.Close()
+		return nil, err
+	}
+
+	pw.CompressionType = parquet.CompressionCodec_ZSTD
+
+	pf := &parquetFile{
+		file:   fh,
+		writer: pw,
+	}
+	sink.files[key] = pf
+
+	return pf, nil
+}
+
+func (sink *Parquet) Close() error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	var err error
+	for key, pf := range sink.files {
+		err = errors.Join(err, pf.writer.WriteStop(), pf.file.Close())
+		delete(sink.files, key)
+	}
+
+	return err
+}
@@ -0,0 +1,81 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package sink
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+)
+
+// Postgres saves observations to the library's database tables
+type Postgres struct {
+	library *library.Library
+
+	mu   sync.Mutex
+	conn *pgxpool.Conn
+}
+
+// NewPostgres creates a sink that writes to the library database
+func NewPostgres(myLibrary *library.Library) *Postgres {
+	return &Postgres{
+		library: myLibrary,
+	}
+}
+
+func (sink *Postgres) Name() string {
+	return PostgresKey
+}
+
+func (sink *Postgres) Write(ctx context.Context, subscription *library.Subscription, obs *data.Observation) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	return sink.library.Retry(ctx, func(ctx context.Context) error {
+		// hold a single connection for the lifetime of the sink
+		if sink.conn == nil {
+			conn, err := sink.library.Pool.Acquire(ctx)
+			if err != nil {
+				return err
+			}
+			sink.conn = conn
+		}
+
+		err := sink.library.SaveObservation(ctx, sink.conn, subscription, obs)
+
+		// the connection may be broken; acquire a new one for the retry
+		if library.IsTransient(err) {
+			sink.conn.Release()
+			sink.conn = nil
+		}
+
+		return err
+	})
+}
+
+func (sink *Postgres) Close() error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	if sink.conn != nil {
+		sink.conn.Release()
+		sink.conn = nil
+	}
+
+	return nil
+}
@@ -0,0 +1,409 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package sink
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/hook"
+	"github.com/penny-vault/pvdata/library"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	PostgresKey = "postgres"
+	ParquetKey  = "parquet"
+	BusKey      = "bus"
+	StdoutKey   = "stdout"
+
+	// hooksKey counts observations that failed a hook in Failures
+	hooksKey = "hooks"
+)
+
+var (
+	ErrRouterClosed = errors.New("router stopped before the observations were handled")
+)
+
+// Sink is a destination observations are written to
+type Sink interface {
+	// Name returns the key subscriptions use to select the sink
+	Name() string
+
+	// Write saves a single observation produced by subscription
+	Write(ctx context.Context, subscription *library.Subscription, obs *data.Observation) error
+
+	// Close flushes any buffered observations
+	Close() error
+}
+
+// Buffered is implemented by sinks that hold written observations in memory
+// before saving them. Observations are not acknowledged in the journal while
+// any sink has buffered observations.
+type Buffered interface {
+	// Buffered returns the number of observations not yet saved
+	Buffered() int
+}
+
+// Screener flags observations that should be held for review instead of saved
+type Screener interface {
+	Screen(ctx context.Context, subscription *library.Subscription, obs *data.Observation) []string
+}
+
+// Router writes each observation to the sinks listed in its subscription's
+// `sinks` config value (a comma separated list of sink names; defaults to
+// postgres) after running it through the subscription's hooks. A failing sink
+// does not prevent observations from reaching the others. If a Screener is set,
+// observations it flags are quarantined in the library instead of being
+// written.
+type Router struct {
+	Library  *library.Library
+	Sinks    map[string]Sink
+	Screener Screener
+
+	mu          sync.Mutex
+	failures    map[string]int
+	counts      map[string]int
+	quarantined map[string]int
+
+	// handled counts the observations of each run that have been written,
+	// quarantined, dropped, or failed; changed is closed whenever it is updated
+	handled map[runKey]int
+	changed chan struct{}
+	closed  bool
+
+	// unacked is the highest journal sequence that was handled but not yet
+	// acknowledged because a sink was still buffering
+	unacked uint64
+}
+
+// runKey identifies the observations a single run of a subscription produced
+type runKey struct {
+	subscriptionID uuid.UUID
+	runID          uuid.UUID
+}
+
+// NewRouter creates a router that dispatches to the given sinks
+func NewRouter(myLibrary *library.Library, sinks ...Sink) *Router {
+	router := &Router{
+		Library:  myLibrary,
+		Sinks:    make(map[string]Sink, len(sinks)),
+		failures: make(map[string]int),
+		counts:   make(map[string]int),
+
+		quarantined: make(map[string]int),
+		handled:     make(map[runKey]int),
+		changed:     make(chan struct{}),
+	}
+
+	for _, sink := range sinks {
+		router.Sinks[sink.Name()] = sink
+	}
+
+	return router
+}
+
+// Failures returns the number of observations each sink failed to write
+func (router *Router) Failures() map[string]int {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+
+	failures := make(map[string]int, len(router.failures))
+	for name, count := range router.failures {
+		failures[name] = count
+	}
+	return failures
+}
+
+// Counts returns the number of observations routed for each data type
+func (router *Router) Counts() map[string]int {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+
+	counts := make(map[string]int, len(router.counts))
+	for dataType, count := range router.counts {
+		counts[dataType] = count
+	}
+	return counts
+}
+
+// Quarantined returns the number of observations quarantined for each data type
+func (router *Router) Quarantined() map[string]int {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+
+	quarantined := make(map[string]int, len(router.quarantined))
+	for dataType, count := range router.quarantined {
+		quarantined[dataType] = count
+	}
+	return quarantined
+}
+
+// Run continuously reads from the input queue until it is closed. If the library
+// has a journal, observations are acknowledged once every sink has written them.
+func (router *Router) Run(queue <-chan *data.Observation, wg *sync.WaitGroup) {
+	ctx := context.Background()
+	defer wg.Done()
+
+	subscriptionList, err := router.Library.Subscriptions(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("could not get list of subscriptions")
+	}
+
+	subscriptions := make(map[uuid.UUID]*Subscription, len(subscriptionList))
+	for _, sub := range subscriptionList {
+		hooks, err := hook.ForSubscription(sub)
+		if err != nil {
+			log.Error().Err(err).Str("SubscriptionID", sub.ID.String()).Msg("invalid hooks; observations of the subscription will not be written")
+		}
+
+		subscriptions[sub.ID] = &Subscription{
+			Subscription: sub,
+			sinks:        router.subscriptionSinks(sub),
+			hooks:        hooks,
+			hooksErr:     err,
+		}
+	}
+
+	defer router.stop()
+
+	for elem := range queue {
+		key := runKey{subscriptionID: elem.SubscriptionID, runID: elem.RunID}
+		router.route(ctx, subscriptions, elem)
+		router.done(key)
+	}
+}
+
+// route writes elem to its subscription's sinks and updates the journal
+func (router *Router) route(ctx context.Context, subscriptions map[uuid.UUID]*Subscription, elem *data.Observation) {
+	subscription, ok := subscriptions[elem.SubscriptionID]
+	if !ok {
+		log.Error().Str("SubscriptionID", elem.SubscriptionID.String()).Str("SubscriptionName", elem.SubscriptionName).Msg("subscription not found")
+		router.acknowledge(elem, false)
+		return
+	}
+
+	router.mu.Lock()
+	router.counts[elem.DataType()]++
+	router.mu.Unlock()
+
+	obs, err := router.transform(ctx, subscription, elem)
+	if err != nil {
+		router.mu.Lock()
+		router.failures[hooksKey]++
+		router.mu.Unlock()
+		router.acknowledge(elem, false)
+		return
+	}
+
+	if obs == nil {
+		router.acknowledge(elem, true)
+		return
+	}
+
+	elem = obs
+
+	saved := true
+	if reasons := router.screen(ctx, subscription.Subscription, elem); len(reasons) > 0 {
+		if err := router.Library.Quarantine(ctx, elem, reasons); err != nil {
+			log.Error().Err(err).Str("SubscriptionID", elem.SubscriptionID.String()).Msg("could not quarantine observation")
+			saved = false
+		} else {
+			router.mu.Lock()
+			router.quarantined[elem.DataType()]++
+			router.mu.Unlock()
+		}
+
+		router.acknowledge(elem, saved)
+		return
+	}
+
+	for _, sink := range subscription.sinks {
+		if err := sink.Write(ctx, subscription.Subscription, elem); err != nil {
+			log.Error().Err(err).Str("Sink", sink.Name()).Str("SubscriptionID", elem.SubscriptionID.String()).
+				Str("DataType", elem.DataType()).Msg("sink could not write observation")
+			router.mu.Lock()
+			router.failures[sink.Name()]++
+			router.mu.Unlock()
+			saved = false
+		}
+	}
+
+	router.acknowledge(elem, saved)
+}
+
+// Wait blocks until count observations produced by the run of the subscription
+// have been handled. Observations are handled once every sink returned, they
+// were quarantined, or a hook dropped them.
+func (router *Router) Wait(ctx context.Context, subscriptionID, runID uuid.UUID, count int) error {
+	key := runKey{subscriptionID: subscriptionID, runID: runID}
+
+	for {
+		router.mu.Lock()
+		handled := router.handled[key]
+		changed := router.changed
+		closed := router.closed
+		if handled >= count {
+			delete(router.handled, key)
+		}
+		router.mu.Unlock()
+
+		if handled >= count {
+			return nil
+		}
+
+		if closed {
+			return ErrRouterClosed
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// done records that an observation of the run has been handled and wakes any
+// waiters
+func (router *Router) done(key runKey) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+
+	router.handled[key]++
+	close(router.changed)
+	router.changed = make(chan struct{})
+}
+
+// stop wakes any waiters once the queue is closed
+func (router *Router) stop() {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+
+	router.closed = true
+	close(router.changed)
+	router.changed = make(chan struct{})
+}
+
+// transform runs obs through the subscription's hooks; it returns nil if a
+// hook dropped the observation
+func (router *Router) transform(ctx context.Context, subscription *Subscription, obs *data.Observation) (*data.Observation, error) {
+	if subscription.hooksErr != nil {
+		return nil, subscription.hooksErr
+	}
+
+	transformed, err := hook.Apply(ctx, subscription.hooks, subscription.Subscription, obs)
+	if err != nil {
+		log.Error().Err(err).Str("SubscriptionID", obs.SubscriptionID.String()).Str("DataType", obs.DataType()).
+			Msg("hook could not transform observation")
+	}
+
+	return transformed, err
+}
+
+func (router *Router) screen(ctx context.Context, subscription *library.Subscription, obs *data.Observation) []string {
+	if router.Screener == nil {
+		return nil
+	}
+
+	reasons := router.Screener.Screen(ctx, subscription, obs)
+	if len(reasons) > 0 {
+		log.Warn().Strs("Reasons", reasons).Str("SubscriptionID", obs.SubscriptionID.String()).Str("DataType", obs.DataType()).
+			Msg("quarantining suspicious observation")
+	}
+
+	return reasons
+}
+
+// acknowledge updates the library's journal, if any, once obs has been handled.
+// Acknowledgements are cumulative so they are held while a sink is buffering
+// observations and released once every buffer has been saved.
+func (router *Router) acknowledge(obs *data.Observation, saved bool) {
+	journal := router.Library.Journal
+	if journal == nil || obs.JournalSeq == 0 {
+		return
+	}
+
+	if !saved {
+		journal.Nack(obs.JournalSeq)
+		return
+	}
+
+	router.unacked = max(router.unacked, obs.JournalSeq)
+	router.release()
+}
+
+// release acknowledges held journal entries once no sink is buffering
+func (router *Router) release() {
+	journal := router.Library.Journal
+	if journal == nil || router.unacked == 0 {
+		return
+	}
+
+	for _, sink := range router.Sinks {
+		if buffered, ok := sink.(Buffered); ok && buffered.Buffered() > 0 {
+			return
+		}
+	}
+
+	if err := journal.Ack(router.unacked); err != nil {
+		log.Error().Err(err).Msg("could not acknowledge journal entry")
+	}
+	router.unacked = 0
+}
+
+// Close closes every sink and acknowledges the observations they saved
+func (router *Router) Close() {
+	for name, sink := range router.Sinks {
+		if err := sink.Close(); err != nil {
+			log.Error().Err(err).Str("Sink", name).Msg("could not close sink")
+		}
+	}
+
+	router.release()
+}
+
+// Subscription pairs a subscription with the sinks it writes to and the hooks
+// its observations are transformed by
+type Subscription struct {
+	*library.Subscription
+	sinks    []Sink
+	hooks    []hook.Hook
+	hooksErr error
+}
+
+func (router *Router) subscriptionSinks(subscription *library.Subscription) []Sink {
+	names := []string{PostgresKey}
+	if configured, ok := subscription.Config["sinks"]; ok && strings.TrimSpace(configured) != "" {
+		names = strings.Split(configured, ",")
+	}
+
+	sinks := make([]Sink, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		sink, ok := router.Sinks[name]
+		if !ok {
+			log.Warn().Str("Sink", name).Str("SubscriptionID", subscription.ID.String()).Msg("subscription refers to a sink that is not configured; ignoring")
+			continue
+		}
+		sinks = append(sinks, sink)
+	}
+
+	return sinks
+}
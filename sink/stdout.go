@@ -0,0 +1,52 @@
+// Copyright 2024
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/penny-vault/pvdata/data"
+	"github.com/penny-vault/pvdata/library"
+)
+
+// Stdout writes each observation as a line of JSON
+type Stdout struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewStdout creates a sink that writes to standard output
+func NewStdout() *Stdout {
+	return &Stdout{
+		encoder: json.NewEncoder(os.Stdout),
+	}
+}
+
+func (sink *Stdout) Name() string {
+	return StdoutKey
+}
+
+func (sink *Stdout) Write(ctx context.Context, subscription *library.Subscription, obs *data.Observation) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	return sink.encoder.Encode(obs)
+}
+
+func (sink *Stdout) Close() error {
+	return nil
+}